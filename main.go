@@ -3,18 +3,31 @@ package main
 import (
 	"context"
 	"errors"
+	"net"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/recover"
+	"google.golang.org/grpc"
 
+	"match-engine/src/accounts"
 	"match-engine/src/engine"
+	"match-engine/src/executions"
+	"match-engine/src/fix"
 	"match-engine/src/handlers"
+	"match-engine/src/journal"
 	"match-engine/src/logger"
+	"match-engine/src/marketdata"
+	"match-engine/src/ouch"
+	"match-engine/src/refdata"
 	"match-engine/src/routes"
+	"match-engine/src/schedule"
+	"match-engine/src/snapshot"
 )
 
 func main() {
@@ -23,8 +36,251 @@ func main() {
 
 	log.Info().Msg("Initializing Order Matching Engine")
 
-	matcher := engine.NewMatcher()
+	retention := engine.DefaultRetentionPolicy
+	if envCount := os.Getenv("ORDER_RETENTION_COUNT"); envCount != "" {
+		if parsed, err := strconv.Atoi(envCount); err == nil && parsed >= 0 {
+			retention.MaxOrders = parsed
+		}
+	}
+	if envAge := os.Getenv("ORDER_RETENTION_AGE"); envAge != "" {
+		if parsed, err := time.ParseDuration(envAge); err == nil && parsed >= 0 {
+			retention.MaxAge = parsed
+		}
+	}
+
+	matcherOptions := []engine.MatcherOption{engine.WithRetentionPolicy(retention)}
+
+	if envHistory := os.Getenv("TRADE_HISTORY_LIMIT"); envHistory != "" {
+		if parsed, err := strconv.Atoi(envHistory); err == nil && parsed >= 0 {
+			matcherOptions = append(matcherOptions, engine.WithTradeHistoryLimit(parsed))
+		}
+	}
+
+	switch generator := os.Getenv("ID_GENERATOR"); generator {
+	case "", "uuid":
+	case "sequence":
+		// edge case: seeded from the clock so order IDs keep increasing across restarts
+		matcherOptions = append(matcherOptions, engine.WithIDGenerator(engine.NewSequenceGenerator(uint64(time.Now().UnixNano()))))
+	default:
+		log.Fatal().Str("id_generator", generator).Msg("ID_GENERATOR must be uuid or sequence")
+	}
+
+	instrumentsFile := "data/instruments.json"
+	if envFile := os.Getenv("INSTRUMENTS_FILE"); envFile != "" {
+		instrumentsFile = envFile
+	}
+	instrumentStore, err := refdata.NewFileStore(instrumentsFile)
+	if err != nil {
+		log.Fatal().Err(err).Str("file", instrumentsFile).Msg("Failed to open instrument store")
+	}
+	instruments, err := instrumentStore.Load()
+	if err != nil {
+		log.Fatal().Err(err).Str("file", instrumentsFile).Msg("Failed to load instruments")
+	}
+	instrumentRegistry := engine.NewInstrumentRegistry(instrumentStore)
+	if err := instrumentRegistry.Restore(instruments); err != nil {
+		log.Fatal().Err(err).Str("file", instrumentsFile).Msg("Failed to load instruments")
+	}
+	// edge case: with no instruments every order is rejected until an admin creates one
+	if len(instruments) == 0 {
+		log.Warn().
+			Str("file", instrumentsFile).
+			Msg("No instruments listed, create them through /admin/v1/instruments")
+	} else {
+		log.Info().
+			Int("instruments", len(instruments)).
+			Str("file", instrumentsFile).
+			Msg("Instruments loaded")
+	}
+	matcherOptions = append(matcherOptions, engine.WithInstruments(instrumentRegistry))
+
+	// SELF_TRADE_PREVENTION=desk-7=CANCEL_OLDEST,desk-9=CANCEL_BOTH
+	if envSTP := os.Getenv("SELF_TRADE_PREVENTION"); envSTP != "" {
+		accountModes := make(map[string]engine.SelfTradePrevention)
+		for _, entry := range strings.Split(envSTP, ",") {
+			account, mode, found := strings.Cut(strings.TrimSpace(entry), "=")
+			stp := engine.SelfTradePrevention(mode)
+			if !found || account == "" || stp == "" || !stp.IsValid() {
+				log.Fatal().Str("entry", entry).Msg("SELF_TRADE_PREVENTION entries must be account=MODE")
+			}
+			accountModes[account] = stp
+		}
+		matcherOptions = append(matcherOptions, engine.WithAccountSelfTradePrevention(accountModes))
+	}
+
+	// ACCOUNT_TOKENS=desk-7=secret,desk-9=secret
+	// edge case: without tokens no account can be authenticated, so the execution report
+	// stream is off, OUCH cannot be enabled and REST and gRPC orders cannot name an account
+	var accountTokens *accounts.Tokens
+	if envTokens := os.Getenv("ACCOUNT_TOKENS"); envTokens != "" {
+		parsed, err := accounts.ParseTokens(envTokens)
+		if err != nil {
+			log.Fatal().Err(err).Msg("ACCOUNT_TOKENS entries must be account=token")
+		}
+		accountTokens = parsed
+	}
+
+	// edge case: JOURNAL_DISABLED runs in memory only, orders are lost on restart
+	var commandJournal *journal.Journal
+	if os.Getenv("JOURNAL_DISABLED") != "1" {
+		journalOptions := journal.DefaultOptions("data/journal")
+		if envDir := os.Getenv("JOURNAL_DIR"); envDir != "" {
+			journalOptions.Dir = envDir
+		}
+		if envFsync := os.Getenv("JOURNAL_FSYNC"); envFsync != "" {
+			switch policy := journal.FsyncPolicy(envFsync); policy {
+			case journal.FsyncAlways, journal.FsyncInterval, journal.FsyncNever:
+				journalOptions.Fsync = policy
+			default:
+				log.Fatal().Str("fsync", envFsync).Msg("JOURNAL_FSYNC must be always, interval or never")
+			}
+		}
+		if envInterval := os.Getenv("JOURNAL_FSYNC_INTERVAL"); envInterval != "" {
+			if parsed, err := time.ParseDuration(envInterval); err == nil && parsed > 0 {
+				journalOptions.FsyncInterval = parsed
+			}
+		}
+		if envSize := os.Getenv("JOURNAL_SEGMENT_SIZE"); envSize != "" {
+			if parsed, err := strconv.ParseInt(envSize, 10, 64); err == nil && parsed > 0 {
+				journalOptions.SegmentSize = parsed
+			}
+		}
+
+		var err error
+		commandJournal, err = journal.Open(journalOptions)
+		if err != nil {
+			log.Fatal().Err(err).Str("dir", journalOptions.Dir).Msg("Failed to open journal")
+		}
+		if commandJournal.TruncatedBytes > 0 {
+			log.Warn().
+				Int64("bytes", commandJournal.TruncatedBytes).
+				Msg("Truncated torn record at the end of the journal")
+		}
+		matcherOptions = append(matcherOptions, engine.WithCommandLog(commandJournal))
+	}
+
+	marketDataBuffer := marketdata.DefaultBufferSize
+	if envBuffer := os.Getenv("MARKETDATA_BUFFER_SIZE"); envBuffer != "" {
+		if parsed, err := strconv.Atoi(envBuffer); err == nil && parsed > 0 {
+			marketDataBuffer = parsed
+		}
+	}
+	marketDataHub := marketdata.NewHub(marketDataBuffer)
+	matcherOptions = append(matcherOptions, engine.WithBookListener(marketDataHub))
+
+	executionBuffer := executions.DefaultBufferSize
+	if envBuffer := os.Getenv("EXECUTIONS_BUFFER_SIZE"); envBuffer != "" {
+		if parsed, err := strconv.Atoi(envBuffer); err == nil && parsed > 0 {
+			executionBuffer = parsed
+		}
+	}
+	executionHub := executions.NewHub(executionBuffer)
+	matcherOptions = append(matcherOptions, engine.WithExecutionListener(executionHub))
+
+	matcher := engine.NewMatcher(matcherOptions...)
+
+	var snapshotWriter *snapshot.Writer
+	if commandJournal != nil {
+		snapshotDir := "data/snapshots"
+		if envDir := os.Getenv("SNAPSHOT_DIR"); envDir != "" {
+			snapshotDir = envDir
+		}
+		snapshotStore, err := snapshot.NewStore(snapshotDir)
+		if err != nil {
+			log.Fatal().Err(err).Str("dir", snapshotDir).Msg("Failed to open snapshot store")
+		}
+
+		start := time.Now()
+		restored, replayed, err := snapshot.Recover(matcher, commandJournal, snapshotStore)
+		if err != nil {
+			log.Fatal().Err(err).Int("commands", replayed).Msg("Failed to recover order books")
+		}
+		recovered := log.Info().
+			Int("commands", replayed).
+			Dur("elapsed", time.Since(start))
+		if restored != nil {
+			recovered = recovered.
+				Int("books", len(restored.Books)).
+				Uint64("journal_segment", restored.JournalSegment)
+		}
+		recovered.Msg("Order books recovered from snapshot and journal")
+
+		snapshotInterval := 5 * time.Minute
+		if envInterval := os.Getenv("SNAPSHOT_INTERVAL"); envInterval != "" {
+			if parsed, err := time.ParseDuration(envInterval); err == nil && parsed >= 0 {
+				snapshotInterval = parsed
+			}
+		}
+		snapshotWriter = snapshot.NewWriter(matcher, commandJournal, snapshotStore)
+		// edge case: SNAPSHOT_INTERVAL=0 disables periodic snapshots, one is still taken on shutdown
+		if snapshotInterval > 0 {
+			snapshotWriter.Start(snapshotInterval)
+		}
+	}
+
+	// the scheduler starts after recovery, so the first tick sees the recovered sessions
+	sessionInterval := schedule.DefaultInterval
+	if envInterval := os.Getenv("SESSION_CHECK_INTERVAL"); envInterval != "" {
+		if parsed, err := time.ParseDuration(envInterval); err == nil && parsed >= 0 {
+			sessionInterval = parsed
+		}
+	}
+	sessionScheduler := schedule.NewScheduler(matcher)
+	// edge case: SESSION_CHECK_INTERVAL=0 only changes a symbol's session when an order arrives
+	if sessionInterval > 0 {
+		sessionScheduler.Start(sessionInterval)
+	}
+
+	// edge case: FIX order entry is off unless FIX_PORT is set
+	var fixAcceptor *fix.Acceptor
+	if envPort := os.Getenv("FIX_PORT"); envPort != "" {
+		fixConfig := fix.Config{CompID: "MATCH", StoreDir: "data/fix"}
+		if envCompID := os.Getenv("FIX_COMP_ID"); envCompID != "" {
+			fixConfig.CompID = envCompID
+		}
+		if envCounterparties := os.Getenv("FIX_COUNTERPARTIES"); envCounterparties != "" {
+			fixConfig.Counterparties = strings.Split(envCounterparties, ",")
+		}
+		if envDir := os.Getenv("FIX_STORE_DIR"); envDir != "" {
+			fixConfig.StoreDir = envDir
+		}
+
+		fixAcceptor = fix.NewAcceptor(fixConfig, matcher, executionHub)
+		if err := fixAcceptor.Listen(":" + envPort); err != nil {
+			log.Fatal().Err(err).Str("port", envPort).Msg("FIX acceptor failed to start")
+		}
+		log.Info().
+			Str("port", envPort).
+			Str("comp_id", fixConfig.CompID).
+			Msg("FIX acceptor started")
+	}
+
+	// edge case: OUCH order entry is off unless OUCH_PORT is set
+	var ouchServer *ouch.Server
+	if envPort := os.Getenv("OUCH_PORT"); envPort != "" {
+		if !accountTokens.Enabled() {
+			log.Fatal().Str("port", envPort).Msg("OUCH_PORT needs ACCOUNT_TOKENS to authenticate logins")
+		}
+		ouchServer = ouch.NewServer(matcher, executionHub, accountTokens)
+		if err := ouchServer.Listen(":" + envPort); err != nil {
+			log.Fatal().Err(err).Str("port", envPort).Msg("OUCH server failed to start")
+		}
+		log.Info().
+			Str("port", envPort).
+			Msg("OUCH server started")
+	}
+
 	orderHandler := handlers.NewOrderHandler(matcher)
+	orderHandler.Tokens = accountTokens
+	marketDataHandler := handlers.NewMarketDataHandler(matcher, marketDataHub)
+	executionHandler := handlers.NewExecutionHandler(executionHub, accountTokens)
+	instrumentHandler := handlers.NewInstrumentHandler(matcher)
+	symbolHandler := handlers.NewSymbolHandler(matcher)
+	if envDuration := os.Getenv("REOPENING_AUCTION_DURATION"); envDuration != "" {
+		if parsed, err := time.ParseDuration(envDuration); err == nil && parsed >= 0 {
+			symbolHandler.ReopeningAuction = parsed
+		}
+	}
 
 	app := fiber.New(fiber.Config{
 		ErrorHandler: func(c *fiber.Ctx, err error) error {
@@ -48,6 +304,31 @@ func main() {
 
 	app.Use(recover.New())
 	routes.SetupRoutes(app, orderHandler)
+	routes.SetupStreamRoutes(app, marketDataHandler, executionHandler)
+	routes.SetupInstrumentRoutes(app, instrumentHandler)
+	routes.SetupSymbolRoutes(app, symbolHandler)
+
+	// edge case: the gRPC API is off unless GRPC_PORT is set
+	var grpcServer *grpc.Server
+	var grpcHandler *handlers.GRPCHandler
+	if envPort := os.Getenv("GRPC_PORT"); envPort != "" {
+		listener, err := net.Listen("tcp", ":"+envPort)
+		if err != nil {
+			log.Fatal().Err(err).Str("port", envPort).Msg("gRPC server failed to start")
+		}
+		grpcServer = grpc.NewServer()
+		grpcHandler = handlers.NewGRPCHandler(orderHandler, marketDataHub)
+		routes.SetupGRPCServices(grpcServer, grpcHandler)
+
+		go func() {
+			if err := grpcServer.Serve(listener); err != nil {
+				log.Error().Err(err).Msg("gRPC server stopped")
+			}
+		}()
+		log.Info().
+			Str("port", envPort).
+			Msg("gRPC server started")
+	}
 
 	port := ":8080"
 	if envPort := os.Getenv("PORT"); envPort != "" {
@@ -81,11 +362,26 @@ func main() {
 		log.Info().
 			Strs("endpoints", []string{
 				"POST   /api/v1/orders",
+				"PATCH  /api/v1/orders/:id",
 				"DELETE /api/v1/orders/:id",
 				"GET    /api/v1/orders/:id",
+				"GET    /api/v1/orders/:id/trades",
+				"GET    /api/v1/orders/client/:client_order_id",
+				"DELETE /api/v1/orders/client/:client_order_id",
 				"GET    /api/v1/orderbook/:symbol",
+				"GET    /api/v1/trades/:symbol",
+				"GET    /api/v1/instruments",
+				"GET    /api/v1/instruments/:symbol",
+				"POST   /admin/v1/instruments",
+				"PATCH  /admin/v1/instruments/:symbol",
+				"POST   /admin/v1/symbols/:symbol/auction",
+				"POST   /admin/v1/symbols/:symbol/uncross",
+				"POST   /admin/v1/symbols/:symbol/halt",
+				"DELETE /admin/v1/symbols/:symbol/halt",
 				"GET    /health",
 				"GET    /metrics",
+				"WS     /ws/v1/marketdata",
+				"WS     /ws/v1/executions?account=",
 			}).
 			Msg("API endpoints registered")
 	}
@@ -120,5 +416,52 @@ func main() {
 		log.Info().Msg("Shutdown complete")
 	}
 
+	if grpcServer != nil {
+		// streams never end on their own, so they are ended before waiting on the calls
+		grpcHandler.Shutdown()
+		grpcStopped := make(chan struct{})
+		go func() {
+			grpcServer.GracefulStop()
+			close(grpcStopped)
+		}()
+		select {
+		case <-grpcStopped:
+		case <-ctx.Done():
+			grpcServer.Stop()
+		}
+	}
+
+	sessionScheduler.Stop()
+
+	// sessions log out before the engine stops, their sequence numbers are already stored
+	if fixAcceptor != nil {
+		if err := fixAcceptor.Close(); err != nil {
+			log.Error().Err(err).Msg("Error closing FIX acceptor")
+		}
+	}
+
+	if ouchServer != nil {
+		if err := ouchServer.Close(); err != nil {
+			log.Error().Err(err).Msg("Error closing OUCH server")
+		}
+	}
+
+	// a final snapshot keeps the next startup's journal replay short
+	if snapshotWriter != nil {
+		snapshotWriter.Stop()
+		if _, err := snapshotWriter.Take(); err != nil {
+			log.Error().Err(err).Msg("Error writing shutdown snapshot")
+		}
+	}
+
+	// stop the per-symbol sequencers once no handler can submit to them
+	matcher.Close()
+
+	if commandJournal != nil {
+		if err := commandJournal.Close(); err != nil {
+			log.Error().Err(err).Msg("Error closing journal")
+		}
+	}
+
 	logger.CloseLogger()
 }
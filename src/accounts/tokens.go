@@ -0,0 +1,62 @@
+// Package accounts checks that a client may act for the account it names
+package accounts
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"strings"
+)
+
+// MaxTokenLength is the longest token, so one fits in the OUCH Login
+const MaxTokenLength = 32
+
+// Tokens holds the secret token of each account. A client proves it acts for an account
+// by presenting the account's token. Accounts without a token cannot be authenticated.
+type Tokens struct {
+	tokens map[string][]byte
+}
+
+func NewTokens(tokens map[string]string) *Tokens {
+	t := &Tokens{tokens: make(map[string][]byte, len(tokens))}
+	for account, token := range tokens {
+		t.tokens[account] = []byte(token)
+	}
+	return t
+}
+
+// ParseTokens reads "account=token,account=token", the format of ACCOUNT_TOKENS
+func ParseTokens(s string) (*Tokens, error) {
+	tokens := make(map[string]string)
+	for _, entry := range strings.Split(s, ",") {
+		account, token, found := strings.Cut(strings.TrimSpace(entry), "=")
+		if !found || account == "" || token == "" {
+			return nil, fmt.Errorf("invalid entry %q: entries must be account=token", entry)
+		}
+		if len(token) > MaxTokenLength || strings.ContainsAny(token, " \t") {
+			return nil, fmt.Errorf("invalid token of %s: tokens must be at most %d characters without spaces", account, MaxTokenLength)
+		}
+		if _, exists := tokens[account]; exists {
+			return nil, fmt.Errorf("account %s is listed twice", account)
+		}
+		tokens[account] = token
+	}
+	return NewTokens(tokens), nil
+}
+
+// Enabled reports whether any account can be authenticated. A nil Tokens has none.
+func (t *Tokens) Enabled() bool {
+	return t != nil && len(t.tokens) > 0
+}
+
+// Authenticate reports whether token is the account's. The comparison takes the same time
+// wherever the tokens differ, so it does not leak how much of a guess was right.
+func (t *Tokens) Authenticate(account, token string) bool {
+	if t == nil {
+		return false
+	}
+	expected, exists := t.tokens[account]
+	if !exists || token == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(token), expected) == 1
+}
@@ -0,0 +1,147 @@
+package engine
+
+import "math/bits"
+
+// MatchingAlgorithmName names how an instrument shares an incoming order among the
+// resting orders of a price level
+type MatchingAlgorithmName string
+
+const (
+	AlgorithmFIFO         MatchingAlgorithmName = "FIFO"           // price-time priority
+	AlgorithmProRata      MatchingAlgorithmName = "PRO_RATA"       // in proportion to resting size
+	AlgorithmFIFOTopOrder MatchingAlgorithmName = "FIFO_TOP_ORDER" // the order that set the price first, then FIFO
+)
+
+// Allocation is the quantity one resting order trades against an incoming order
+type Allocation struct {
+	Order    *Order
+	Quantity int64
+}
+
+// MatchingAlgorithm decides which resting orders of the best price level an incoming
+// order trades against. Allocate appends to dst the fills for up to quantity against
+// level, in the order they execute, and must allocate something while both are left.
+// It never gives an order more than its visible quantity, except as documented by the
+// algorithm. The matcher calls it again after the fills while quantity and orders
+// remain, so a replenished iceberg slice is allocated in a later round.
+type MatchingAlgorithm interface {
+	Allocate(dst []Allocation, level *PriceLevel, quantity int64) []Allocation
+}
+
+// FIFO fills the level's orders in time priority
+type FIFO struct{}
+
+func (FIFO) Allocate(dst []Allocation, level *PriceLevel, quantity int64) []Allocation {
+	return allocateFIFO(dst, level.Orders, quantity, nil)
+}
+
+// ProRata shares the quantity among the level's orders in proportion to their visible
+// quantity. Shares are rounded down to whole lots and shares below MinAllocation are
+// dropped. What rounding leaves over goes to the orders in time priority.
+type ProRata struct {
+	MinAllocation int64 // smallest share an order gets from the proportional pass
+	LotSize       int64 // shares are rounded down to a multiple of it, 0 or 1 rounds to units
+}
+
+func (p ProRata) Allocate(dst []Allocation, level *PriceLevel, quantity int64) []Allocation {
+	var total int64
+	for _, order := range level.Orders {
+		total += order.VisibleQuantity()
+	}
+	// edge case: enough for everyone, nothing to share
+	if quantity >= total {
+		return allocateFIFO(dst, level.Orders, quantity, nil)
+	}
+
+	start := len(dst)
+	left := quantity
+	for _, order := range level.Orders {
+		visible := order.VisibleQuantity()
+		// quantity < total, so quantity*visible/total always fits
+		hi, lo := bits.Mul64(uint64(quantity), uint64(visible))
+		share, _ := bits.Div64(hi, lo, uint64(total))
+		allocated := int64(share)
+		if p.LotSize > 1 {
+			allocated -= allocated % p.LotSize
+		}
+		if allocated < p.MinAllocation {
+			allocated = 0
+		}
+		dst = append(dst, Allocation{Order: order, Quantity: allocated})
+		left -= allocated
+	}
+
+	// edge case: the rounded down remainder goes out in time priority
+	for i := start; i < len(dst) && left > 0; i++ {
+		extra := dst[i].Order.VisibleQuantity() - dst[i].Quantity
+		if extra > left {
+			extra = left
+		}
+		dst[i].Quantity += extra
+		left -= extra
+	}
+
+	// drop the orders that got nothing, keeping time priority
+	kept := start
+	for _, allocation := range dst[start:] {
+		if allocation.Quantity > 0 {
+			dst[kept] = allocation
+			kept++
+		}
+	}
+	return dst[:kept]
+}
+
+// FIFOTopOrder fills the level's top order first, then the others in time priority. The
+// top order is the one that set a new best price when it arrived. It keeps that status
+// until it leaves the book or is requeued by an amend, and is filled up to its visible
+// quantity like any other order.
+type FIFOTopOrder struct{}
+
+func (FIFOTopOrder) Allocate(dst []Allocation, level *PriceLevel, quantity int64) []Allocation {
+	var top *Order
+	for _, order := range level.Orders {
+		if order.topOrder {
+			top = order
+			break
+		}
+	}
+	if top != nil {
+		allocated := top.VisibleQuantity()
+		if allocated > quantity {
+			allocated = quantity
+		}
+		dst = append(dst, Allocation{Order: top, Quantity: allocated})
+		quantity -= allocated
+	}
+	return allocateFIFO(dst, level.Orders, quantity, top)
+}
+
+// allocateFIFO fills orders in time priority up to their visible quantity, skipping
+// one that was already allocated
+func allocateFIFO(dst []Allocation, orders []*Order, quantity int64, skip *Order) []Allocation {
+	for _, order := range orders {
+		if quantity <= 0 {
+			break
+		}
+		if order == skip {
+			continue
+		}
+		allocated := order.VisibleQuantity()
+		if allocated > quantity {
+			allocated = quantity
+		}
+		dst = append(dst, Allocation{Order: order, Quantity: allocated})
+		quantity -= allocated
+	}
+	return dst
+}
+
+// matchingAlgorithm is the algorithm of the book's instrument, FIFO when the matcher
+// trades without a registry. Must be called with ob.mu held.
+func (ob *OrderBook) matchingAlgorithm() MatchingAlgorithm {
+	if ob.instrument == nil {
+		return FIFO{}
+	}
+	return ob.instrument.algorithm()
+}
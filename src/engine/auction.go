@@ -0,0 +1,276 @@
+package engine
+
+import (
+	"sort"
+
+	"github.com/google/btree"
+)
+
+// IndicativeUncross is what an uncross would do if the auction ended now
+type IndicativeUncross struct {
+	Price     int64 // equilibrium price, 0 while the book does not cross
+	Volume    int64 // quantity that would trade at Price
+	Imbalance int64 // buy minus sell quantity willing to trade at Price, positive for surplus demand
+}
+
+// UncrossResult is the outcome of ending a symbol's auction
+type UncrossResult struct {
+	Symbol          string
+	Price           int64 // 0 when the book did not cross and nothing traded
+	Volume          int64
+	Trades          []*Trade
+	TriggeredOrders []*TriggeredOrder // stop orders released by the uncross trades
+	Sequence        uint64            // per-symbol command sequence number
+}
+
+// StartAuction stops continuous matching on a symbol. Orders accumulate on the book,
+// crossing or not, until Uncross executes them at one price. It runs as a command on
+// the symbol's sequencer and returns its sequence number.
+func (m *Matcher) StartAuction(symbol string) (uint64, error) {
+	if err := m.checkListed(symbol); err != nil {
+		return 0, err
+	}
+	reply := m.getOrCreateSequencer(symbol).submit(&command{
+		Command: Command{Kind: CommandAuction},
+	})
+	return reply.seq, reply.err
+}
+
+// Uncross ends a symbol's auction: every order that crosses at the equilibrium price
+// trades at that price, what is left stays on the book and continuous matching resumes
+func (m *Matcher) Uncross(symbol string) (*UncrossResult, error) {
+	if err := m.checkListed(symbol); err != nil {
+		return nil, err
+	}
+	reply := m.getOrCreateSequencer(symbol).submit(&command{
+		Command: Command{Kind: CommandUncross},
+	})
+	return reply.uncross, reply.err
+}
+
+// checkListed refuses symbols the registry does not list, any symbol without a registry
+func (m *Matcher) checkListed(symbol string) error {
+	if m.instruments == nil {
+		return nil
+	}
+	if _, listed := m.instruments.Get(symbol); !listed {
+		return &UnknownInstrumentError{Symbol: symbol}
+	}
+	return nil
+}
+
+// InAuction reports whether the book is accumulating orders for an uncross
+func (ob *OrderBook) InAuction() bool {
+	ob.mu.RLock()
+	defer ob.mu.RUnlock()
+
+	return ob.auction
+}
+
+// IndicativeUncross returns the price, volume and imbalance the auction would uncross
+// at now, false when the book is not in an auction
+func (ob *OrderBook) IndicativeUncross() (IndicativeUncross, bool) {
+	ob.mu.RLock()
+	defer ob.mu.RUnlock()
+
+	if !ob.auction {
+		return IndicativeUncross{}, false
+	}
+	return ob.indicativeUncross(), true
+}
+
+// must be called with ob.mu held
+func (ob *OrderBook) startAuction() error {
+	if ob.auction {
+		return &AuctionStateError{Symbol: ob.Symbol, Auction: true}
+	}
+	ob.auction = true
+	return nil
+}
+
+// auctionDepth is a side's total quantity per price, hidden iceberg quantity included,
+// best price first
+type auctionDepth struct {
+	prices     []int64
+	cumulative []int64 // quantity at this price or better
+}
+
+// must be called with ob.mu held
+func (ob *OrderBook) auctionDepth(side OrderSide) auctionDepth {
+	tree := ob.Bids
+	if side == SideSell {
+		tree = ob.Asks
+	}
+
+	var depth auctionDepth
+	var total int64
+	tree.Ascend(func(item btree.Item) bool {
+		priceLevel := priceLevelOf(item)
+		for _, order := range priceLevel.Orders {
+			total += order.RemainingQuantity()
+		}
+		depth.prices = append(depth.prices, priceLevel.Price)
+		depth.cumulative = append(depth.cumulative, total)
+		return true
+	})
+	return depth
+}
+
+// demand is the buy quantity willing to trade at price, bids best (highest) first
+func (d auctionDepth) demand(price int64) int64 {
+	n := sort.Search(len(d.prices), func(i int) bool { return d.prices[i] < price })
+	if n == 0 {
+		return 0
+	}
+	return d.cumulative[n-1]
+}
+
+// supply is the sell quantity willing to trade at price, asks best (lowest) first
+func (d auctionDepth) supply(price int64) int64 {
+	n := sort.Search(len(d.prices), func(i int) bool { return d.prices[i] > price })
+	if n == 0 {
+		return 0
+	}
+	return d.cumulative[n-1]
+}
+
+// indicativeUncross finds the price that trades the most. Ties go to the smallest
+// imbalance, then to the price closest to the reference price (the last trade), then to
+// the lower price. Must be called with ob.mu held.
+func (ob *OrderBook) indicativeUncross() IndicativeUncross {
+	bids := ob.auctionDepth(SideBuy)
+	asks := ob.auctionDepth(SideSell)
+	// edge case: nothing crosses, there is no equilibrium price
+	if len(bids.prices) == 0 || len(asks.prices) == 0 || bids.prices[0] < asks.prices[0] {
+		return IndicativeUncross{}
+	}
+	low, high := asks.prices[0], bids.prices[0]
+
+	// the volume only changes at a level's price, so the best price is one of them. The
+	// reference price is a candidate too, in case it lies between two tied levels.
+	reference := ob.GetLastTradePrice()
+	candidates := make([]int64, 0, len(bids.prices)+len(asks.prices)+1)
+	for _, prices := range [][]int64{bids.prices, asks.prices} {
+		for _, price := range prices {
+			if price >= low && price <= high {
+				candidates = append(candidates, price)
+			}
+		}
+	}
+	if reference >= low && reference <= high {
+		candidates = append(candidates, reference)
+	}
+
+	var best IndicativeUncross
+	for _, price := range candidates {
+		demand, supply := bids.demand(price), asks.supply(price)
+		candidate := IndicativeUncross{Price: price, Volume: min(demand, supply), Imbalance: demand - supply}
+		if best.Price == 0 || betterUncross(candidate, best, reference) {
+			best = candidate
+		}
+	}
+	return best
+}
+
+func betterUncross(candidate, best IndicativeUncross, reference int64) bool {
+	if candidate.Volume != best.Volume {
+		return candidate.Volume > best.Volume
+	}
+	if imbalance, bestImbalance := abs(candidate.Imbalance), abs(best.Imbalance); imbalance != bestImbalance {
+		return imbalance < bestImbalance
+	}
+	if reference > 0 {
+		if distance, bestDistance := abs(candidate.Price-reference), abs(best.Price-reference); distance != bestDistance {
+			return distance < bestDistance
+		}
+	}
+	return candidate.Price < best.Price
+}
+
+func abs(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// uncross executes the auction at its equilibrium price in price-time priority and
+// returns the book to continuous matching. Must be called with orderBook.mu held.
+func (m *Matcher) uncross(orderBook *OrderBook) (*UncrossResult, error) {
+	if !orderBook.auction {
+		return nil, &AuctionStateError{Symbol: orderBook.Symbol}
+	}
+	indicative := orderBook.indicativeUncross()
+	orderBook.auction = false
+	orderBook.reopenAt = 0
+
+	result := &UncrossResult{
+		Symbol: orderBook.Symbol,
+		Price:  indicative.Price,
+		Volume: indicative.Volume,
+		Trades: make([]*Trade, 0),
+	}
+
+	for left := indicative.Volume; left > 0; {
+		bidLevel, askLevel := orderBook.bestLevel(SideBuy), orderBook.bestLevel(SideSell)
+		bid, ask := bidLevel.Orders[0], askLevel.Orders[0]
+		quantity := min(bid.RemainingQuantity(), ask.RemainingQuantity(), left)
+
+		trade := m.executeTrade(orderBook, bid, ask, indicative.Price, quantity, "")
+		result.Trades = append(result.Trades, trade)
+		left -= quantity
+
+		orderBook.touchLevel(SideBuy, bidLevel.Price)
+		orderBook.touchLevel(SideSell, askLevel.Price)
+		orderBook.settleFill(bidLevel, bid, quantity)
+		orderBook.settleFill(askLevel, ask, quantity)
+	}
+
+	if len(result.Trades) > 0 {
+		result.TriggeredOrders = m.releaseTriggeredStops(orderBook, result.Trades)
+	}
+	return result, nil
+}
+
+// auctionOrder rests an order without matching it. Only GTC limit orders can wait for
+// the uncross, anything else is rejected. Must be called with orderBook.mu held.
+func (m *Matcher) auctionOrder(order *Order, orderBook *OrderBook) (*MatchResult, error) {
+	if order.Type != TypeLimit || order.TimeInForce != TIFGTC {
+		order.SetStatus(StatusRejected)
+		orderBook.retireOrder(order)
+		return nil, &AuctionOrderError{Symbol: orderBook.Symbol}
+	}
+
+	orderBook.reportAccepted(order)
+	orderBook.addOrder(order)
+	return &MatchResult{
+		Order:             order,
+		Status:            StatusAccepted,
+		RemainingQuantity: order.RemainingQuantity(),
+		Trades:            make([]*Trade, 0),
+	}, nil
+}
+
+// AuctionStateError is returned when starting an auction that is already running, or
+// uncrossing a symbol that is not in one
+type AuctionStateError struct {
+	Symbol  string
+	Auction bool // whether the symbol was in an auction
+}
+
+func (e *AuctionStateError) Error() string {
+	if e.Auction {
+		return e.Symbol + " is already in an auction"
+	}
+	return e.Symbol + " is not in an auction"
+}
+
+// AuctionOrderError is returned for an order an auction cannot hold: market, IOC and FOK
+// orders and triggered stops need continuous matching
+type AuctionOrderError struct {
+	Symbol string
+}
+
+func (e *AuctionOrderError) Error() string {
+	return e.Symbol + " is in an auction: only GTC limit orders are accepted"
+}
@@ -0,0 +1,88 @@
+package engine
+
+type CommandKind string
+
+const (
+	CommandSubmit CommandKind = "SUBMIT"
+	CommandCancel CommandKind = "CANCEL"
+	CommandAmend  CommandKind = "AMEND"
+
+	CommandAuction CommandKind = "AUCTION" // start accumulating orders for an uncross
+	CommandUncross CommandKind = "UNCROSS" // end the auction
+	CommandSession CommandKind = "SESSION" // move to the next trading session
+	CommandHalt    CommandKind = "HALT"    // stop trading the symbol
+	CommandResume  CommandKind = "RESUME"  // lift the halt through a reopening auction
+	CommandReopen  CommandKind = "REOPEN"  // end the reopening auction once its time is up
+
+	CommandInstrument CommandKind = "INSTRUMENT" // apply the instrument's changed rules
+)
+
+// Command is the durable form of one sequenced command. Replaying a symbol's commands
+// in sequence order through a fresh Matcher rebuilds its order book.
+type Command struct {
+	Kind         CommandKind    `json:"kind"`
+	Symbol       string         `json:"symbol"`
+	Sequence     uint64         `json:"seq"`
+	Timestamp    int64          `json:"ts"`                      // unix nanoseconds, read from the engine clock when sequenced
+	Order        *OrderRecord   `json:"order,omitempty"`         // submit
+	OrderID      string         `json:"order_id,omitempty"`      // cancel, amend
+	Price        int64          `json:"price,omitempty"`         // amend, 0 keeps the current price
+	Quantity     int64          `json:"quantity,omitempty"`      // amend, 0 keeps the current quantity
+	Session      TradingSession `json:"session,omitempty"`       // session
+	Reason       string         `json:"reason,omitempty"`        // halt
+	AllowCancels bool           `json:"allow_cancels,omitempty"` // halt
+	Duration     int64          `json:"duration,omitempty"`      // resume, reopening auction length in nanoseconds, 0 waits for an uncross
+	Instrument   *Instrument    `json:"instrument,omitempty"`    // any kind, the rules from this command on when they changed
+}
+
+// OrderRecord holds the fields of an order as it was submitted
+type OrderRecord struct {
+	ID                  string              `json:"id"`
+	ClientOrderID       string              `json:"client_order_id,omitempty"`
+	Account             string              `json:"account,omitempty"`
+	SelfTradePrevention SelfTradePrevention `json:"self_trade_prevention,omitempty"`
+	Side                OrderSide           `json:"side"`
+	Type                OrderType           `json:"type"`
+	TimeInForce         TimeInForce         `json:"time_in_force"`
+	Price               int64               `json:"price,omitempty"`
+	StopPrice           int64               `json:"stop_price,omitempty"`
+	Quantity            int64               `json:"quantity"`
+	DisplayQuantity     int64               `json:"display_quantity,omitempty"`
+	Timestamp           int64               `json:"timestamp"`
+}
+
+// CommandLog receives every command before the sequencer applies it. If Append fails
+// the command is not applied and the caller gets the error instead of an acknowledgement.
+type CommandLog interface {
+	Append(cmd *Command) error
+}
+
+func newOrderRecord(order *Order) *OrderRecord {
+	return &OrderRecord{
+		ID:                  order.ID,
+		ClientOrderID:       order.ClientOrderID,
+		Account:             order.Account,
+		SelfTradePrevention: order.SelfTradePrevention,
+		Side:                order.Side,
+		Type:                order.Type,
+		TimeInForce:         order.TimeInForce,
+		Price:               order.Price,
+		StopPrice:           order.StopPrice,
+		Quantity:            order.Quantity,
+		DisplayQuantity:     order.DisplayQuantity,
+		Timestamp:           order.Timestamp,
+	}
+}
+
+// NewOrder rebuilds a fresh, unfilled order from its submitted fields
+func (r *OrderRecord) NewOrder(symbol string) *Order {
+	order := NewOrder(r.ID, symbol, r.Side, r.Type, r.Price, r.Quantity)
+	order.ClientOrderID = r.ClientOrderID
+	order.Account = r.Account
+	order.SelfTradePrevention = r.SelfTradePrevention
+	order.TimeInForce = r.TimeInForce
+	order.StopPrice = r.StopPrice
+	order.DisplayQuantity = r.DisplayQuantity
+	order.Timestamp = r.Timestamp
+	return order
+}
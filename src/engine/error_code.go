@@ -0,0 +1,28 @@
+package engine
+
+// Machine-readable codes of the errors a client can act on. Unlike the messages they
+// never change, and every gateway reports the same one.
+const (
+	CodeSymbolHalted         = "SYMBOL_HALTED"          // the symbol is halted
+	CodeSymbolNotHalted      = "SYMBOL_NOT_HALTED"      // resuming a symbol that is not halted
+	CodeSessionRejected      = "SESSION_REJECTED"       // the trading session does not accept the command
+	CodeInstrumentNotTrading = "INSTRUMENT_NOT_TRADING" // the instrument's status is not TRADING
+)
+
+// ErrorCode is the machine-readable code of an engine error, "" for errors without one
+func ErrorCode(err error) string {
+	switch err := err.(type) {
+	case *HaltedError:
+		return CodeSymbolHalted
+	case *HaltStateError:
+		if err.Halted {
+			return CodeSymbolHalted
+		}
+		return CodeSymbolNotHalted
+	case *SessionError:
+		return CodeSessionRejected
+	case *InstrumentNotTradingError:
+		return CodeInstrumentNotTrading
+	}
+	return ""
+}
@@ -0,0 +1,114 @@
+package engine
+
+type ExecType string
+
+const (
+	ExecNew         ExecType = "NEW"
+	ExecPartialFill ExecType = "PARTIAL_FILL"
+	ExecFilled      ExecType = "FILLED"
+	ExecCancelled   ExecType = "CANCELLED"
+	ExecRejected    ExecType = "REJECTED"
+	ExecExpired     ExecType = "EXPIRED"
+)
+
+// ExecutionReport tells an order's owner what just happened to it. Fill reports carry
+// the trade, one for each side of every trade.
+type ExecutionReport struct {
+	ExecType           ExecType    `json:"exec_type"`
+	OrderID            string      `json:"order_id"`
+	ClientOrderID      string      `json:"client_order_id,omitempty"`
+	Account            string      `json:"account,omitempty"`
+	Symbol             string      `json:"symbol"`
+	Side               OrderSide   `json:"side"`
+	Status             OrderStatus `json:"status"`
+	Price              int64       `json:"price,omitempty"` // order limit price
+	Quantity           int64       `json:"quantity"`        // order quantity
+	TradeID            string      `json:"trade_id,omitempty"`
+	FillPrice          int64       `json:"fill_price,omitempty"`
+	FillQuantity       int64       `json:"fill_quantity,omitempty"`
+	CumulativeQuantity int64       `json:"cum_quantity"`
+	LeavesQuantity     int64       `json:"leaves_quantity"`      // 0 once the order is done
+	SelfTrade          bool        `json:"self_trade,omitempty"` // cancelled by self-trade prevention
+	CommandSequence    uint64      `json:"command_seq"`
+	Timestamp          int64       `json:"timestamp"` // unix nanoseconds
+}
+
+// ExecutionListener receives the execution reports of every order, in the order they
+// happen within each symbol. It is called with the book locked, so it must not block or
+// call into the matcher.
+type ExecutionListener interface {
+	OnExecutionReport(report *ExecutionReport)
+}
+
+// WithExecutionListener sends the execution reports of every book to listener
+func WithExecutionListener(listener ExecutionListener) MatcherOption {
+	return func(m *Matcher) {
+		m.executionListener = listener
+	}
+}
+
+// reportAccepted sends NEW the first time an order passes the engine's checks, whether
+// it trades, rests or waits for its stop. Must be called with ob.mu held.
+func (ob *OrderBook) reportAccepted(order *Order) {
+	if order.accepted {
+		return
+	}
+	order.accepted = true
+	ob.report(order, ExecNew, nil)
+}
+
+// reportFill sends PARTIAL_FILL or FILLED for one side of a trade. Must be called with
+// ob.mu held.
+func (ob *OrderBook) reportFill(order *Order, trade *Trade) {
+	execType := ExecPartialFill
+	if order.IsFilled() {
+		execType = ExecFilled
+	}
+	ob.report(order, execType, trade)
+}
+
+// reportDone sends the report of an order that left the book without filling
+// completely. Must be called with ob.mu held.
+func (ob *OrderBook) reportDone(order *Order) {
+	switch order.GetStatus() {
+	case StatusCancelled:
+		ob.report(order, ExecCancelled, nil)
+	case StatusRejected:
+		ob.report(order, ExecRejected, nil)
+	case StatusExpired:
+		ob.report(order, ExecExpired, nil)
+	}
+}
+
+// must be called with ob.mu held
+func (ob *OrderBook) report(order *Order, execType ExecType, trade *Trade) {
+	if ob.executions == nil {
+		return
+	}
+
+	status := order.GetStatus()
+	report := &ExecutionReport{
+		ExecType:           execType,
+		OrderID:            order.ID,
+		ClientOrderID:      order.ClientOrderID,
+		Account:            order.Account,
+		Symbol:             ob.Symbol,
+		Side:               order.Side,
+		Status:             status,
+		Price:              order.Price,
+		Quantity:           order.Quantity,
+		CumulativeQuantity: order.GetFilledQuantity(),
+		SelfTrade:          execType == ExecCancelled && order.selfTrade,
+		CommandSequence:    ob.LastSequence(),
+		Timestamp:          ob.now().UnixNano(),
+	}
+	if !status.IsTerminal() {
+		report.LeavesQuantity = order.RemainingQuantity()
+	}
+	if trade != nil {
+		report.TradeID = trade.TradeID
+		report.FillPrice = trade.Price
+		report.FillQuantity = trade.Quantity
+	}
+	ob.executions.OnExecutionReport(report)
+}
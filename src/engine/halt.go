@@ -0,0 +1,175 @@
+package engine
+
+import (
+	"time"
+)
+
+// TradingHalt is an admin stop of trading on one symbol. The book keeps its orders
+// while halted and nothing trades.
+type TradingHalt struct {
+	Reason       string `json:"reason,omitempty"`
+	AllowCancels bool   `json:"allow_cancels,omitempty"` // resting orders can still be cancelled
+	Since        int64  `json:"since"`                   // unix nanoseconds
+}
+
+// Halt stops trading on a symbol until Resume. New orders, amends, auctions and uncrosses
+// are refused with a HaltedError, and so are cancels unless allowCancels is set. It runs
+// as a command on the symbol's sequencer and returns its sequence number.
+func (m *Matcher) Halt(symbol, reason string, allowCancels bool) (uint64, error) {
+	if err := m.checkListed(symbol); err != nil {
+		return 0, err
+	}
+	reply := m.getOrCreateSequencer(symbol).submit(&command{
+		Command: Command{Kind: CommandHalt, Reason: reason, AllowCancels: allowCancels},
+	})
+	return reply.seq, reply.err
+}
+
+// Resume lifts a symbol's halt. In continuous trading the book reopens through an
+// auction that uncrosses once auction has passed, or on a manual Uncross when auction is
+// 0. In any other session the session decides when the book trades again.
+func (m *Matcher) Resume(symbol string, auction time.Duration) (uint64, error) {
+	if err := m.checkListed(symbol); err != nil {
+		return 0, err
+	}
+	reply := m.getOrCreateSequencer(symbol).submit(&command{
+		Command: Command{Kind: CommandResume, Duration: int64(auction)},
+	})
+	return reply.seq, reply.err
+}
+
+// EndReopeningAuctions uncrosses every reopening auction whose time is up on the engine
+// clock, and returns the uncrosses it ran
+func (m *Matcher) EndReopeningAuctions() ([]*UncrossResult, error) {
+	var results []*UncrossResult
+	for symbol := range m.GetOrderBooksSnapshot() {
+		result, err := m.syncReopening(symbol)
+		if err != nil {
+			return results, err
+		}
+		if result != nil {
+			results = append(results, result)
+		}
+	}
+	return results, nil
+}
+
+// syncReopening ends the symbol's reopening auction if its time is up, so an order
+// arriving after the reopening never waits in the auction. Returns nil when there was
+// none to end.
+func (m *Matcher) syncReopening(symbol string) (*UncrossResult, error) {
+	orderBook, exists := m.GetOrderBook(symbol)
+	if !exists || !orderBook.reopeningDue(m.clock.Now()) {
+		return nil, nil
+	}
+	reply := m.getOrCreateSequencer(symbol).submit(&command{
+		Command: Command{Kind: CommandReopen},
+	})
+	return reply.uncross, reply.err
+}
+
+// Halted returns the symbol's halt, false while it trades
+func (ob *OrderBook) Halted() (TradingHalt, bool) {
+	ob.mu.RLock()
+	defer ob.mu.RUnlock()
+
+	if ob.halt == nil {
+		return TradingHalt{}, false
+	}
+	return *ob.halt, true
+}
+
+// ReopensAt is when the reopening auction uncrosses, zero when none is timed
+func (ob *OrderBook) ReopensAt() time.Time {
+	ob.mu.RLock()
+	defer ob.mu.RUnlock()
+
+	if ob.reopenAt == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, ob.reopenAt)
+}
+
+func (ob *OrderBook) reopeningDue(now time.Time) bool {
+	ob.mu.RLock()
+	defer ob.mu.RUnlock()
+
+	return ob.reopenAt != 0 && now.UnixNano() >= ob.reopenAt
+}
+
+// checkHalt refuses a command the book's halt does not allow. Must be called with
+// ob.mu held.
+func (ob *OrderBook) checkHalt(kind CommandKind) error {
+	if ob.halt == nil || (kind == CommandCancel && ob.halt.AllowCancels) {
+		return nil
+	}
+	return &HaltedError{Symbol: ob.Symbol, Reason: ob.halt.Reason, Kind: kind}
+}
+
+// must be called with ob.mu held
+func (ob *OrderBook) haltTrading(cmd *Command) error {
+	if ob.halt != nil {
+		return &HaltStateError{Symbol: ob.Symbol, Halted: true}
+	}
+	ob.halt = &TradingHalt{Reason: cmd.Reason, AllowCancels: cmd.AllowCancels, Since: cmd.Timestamp}
+	// edge case: a halt stops the reopening clock, the next resume starts it again
+	ob.reopenAt = 0
+	return nil
+}
+
+// must be called with ob.mu held
+func (ob *OrderBook) resumeTrading(cmd *Command) error {
+	if ob.halt == nil {
+		return &HaltStateError{Symbol: ob.Symbol}
+	}
+	ob.halt = nil
+
+	// edge case: outside continuous trading the schedule opens the book, not the resume
+	if ob.tradingSession() != SessionContinuous {
+		return nil
+	}
+	ob.auction = true
+	if cmd.Duration > 0 {
+		ob.reopenAt = cmd.Timestamp + cmd.Duration
+	}
+	return nil
+}
+
+// reopen uncrosses the reopening auction if it is due at the command's time, and does
+// nothing if an admin uncross or another halt got there first. Must be called with
+// orderBook.mu held.
+func (m *Matcher) reopen(orderBook *OrderBook, cmd *Command) *UncrossResult {
+	if orderBook.reopenAt == 0 || cmd.Timestamp < orderBook.reopenAt {
+		return nil
+	}
+	result, _ := m.uncross(orderBook)
+	return result
+}
+
+// HaltedError is returned for a command a halted symbol does not accept
+type HaltedError struct {
+	Symbol string
+	Reason string
+	Kind   CommandKind
+}
+
+func (e *HaltedError) Error() string {
+	if e.Reason == "" {
+		return e.Symbol + " is halted: " + notAccepted(e.Kind)
+	}
+	return e.Symbol + " is halted (" + e.Reason + "): " + notAccepted(e.Kind)
+}
+
+// HaltStateError is returned when halting a symbol that is already halted, or resuming
+// one that is not
+type HaltStateError struct {
+	Symbol string
+	Halted bool // whether the symbol was halted
+}
+
+func (e *HaltStateError) Error() string {
+	if e.Halted {
+		return e.Symbol + " is already halted"
+	}
+	return e.Symbol + " is not halted"
+}
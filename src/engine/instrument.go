@@ -0,0 +1,445 @@
+package engine
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// TradingStatus says whether an instrument takes new orders. A listed instrument stops
+// trading for a while with Matcher.Halt, which has no status of its own.
+type TradingStatus string
+
+const (
+	TradingStatusTrading TradingStatus = "TRADING"
+	TradingStatusClosed  TradingStatus = "CLOSED"
+)
+
+// DefaultPricePrecision is the precision of symbols without an instrument: prices in cents
+const DefaultPricePrecision = 2
+
+const maxPricePrecision = 8
+
+var symbolPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,16}$`)
+
+// Instrument is the reference data of a tradable symbol. Prices are integers in the
+// instrument's smallest unit, PricePrecision says how many of their digits are decimals
+// (2 for cents).
+type Instrument struct {
+	Symbol         string        `json:"symbol"`
+	TickSize       int64         `json:"tick_size"`    // prices must be a multiple of it
+	LotSize        int64         `json:"lot_size"`     // quantities must be a multiple of it
+	MinQuantity    int64         `json:"min_quantity"` // defaults to LotSize
+	MaxQuantity    int64         `json:"max_quantity"` // 0 is no limit
+	PricePrecision int           `json:"price_precision"`
+	Status         TradingStatus `json:"status"`
+
+	MatchingAlgorithm MatchingAlgorithmName `json:"matching_algorithm"`       // defaults to FIFO
+	MinAllocation     int64                 `json:"min_allocation,omitempty"` // smallest pro-rata share, PRO_RATA only
+
+	Schedule *TradingSchedule `json:"schedule,omitempty"` // nil trades continuously around the clock
+}
+
+// Validate fills in defaults and checks that the instrument's rules are consistent
+func (i *Instrument) Validate() error {
+	if i.MinQuantity == 0 {
+		i.MinQuantity = i.LotSize
+	}
+	if i.Status == "" {
+		i.Status = TradingStatusTrading
+	}
+	if i.MatchingAlgorithm == "" {
+		i.MatchingAlgorithm = AlgorithmFIFO
+	}
+
+	if !symbolPattern.MatchString(i.Symbol) {
+		return &InvalidInstrumentError{Message: "symbol must be 1 to 16 letters, digits, '.', '_' or '-'"}
+	}
+	if i.TickSize <= 0 {
+		return &InvalidInstrumentError{Message: "tick_size must be positive"}
+	}
+	if i.LotSize <= 0 {
+		return &InvalidInstrumentError{Message: "lot_size must be positive"}
+	}
+	if i.MinQuantity < 0 || i.MinQuantity%i.LotSize != 0 {
+		return &InvalidInstrumentError{Message: "min_quantity must be a multiple of lot_size"}
+	}
+	if i.MaxQuantity < 0 || (i.MaxQuantity > 0 && (i.MaxQuantity < i.MinQuantity || i.MaxQuantity%i.LotSize != 0)) {
+		return &InvalidInstrumentError{Message: "max_quantity must be 0 or a multiple of lot_size of at least min_quantity"}
+	}
+	if i.PricePrecision < 0 || i.PricePrecision > maxPricePrecision {
+		return &InvalidInstrumentError{Message: "price_precision must be between 0 and " + strconv.Itoa(maxPricePrecision)}
+	}
+	switch i.Status {
+	case TradingStatusTrading, TradingStatusClosed:
+	default:
+		return &InvalidInstrumentError{Message: "status must be TRADING or CLOSED, halts go through /admin/v1/symbols/{symbol}/halt"}
+	}
+	switch i.MatchingAlgorithm {
+	case AlgorithmFIFO, AlgorithmProRata, AlgorithmFIFOTopOrder:
+	default:
+		return &InvalidInstrumentError{Message: "matching_algorithm must be FIFO, PRO_RATA or FIFO_TOP_ORDER"}
+	}
+	if i.MinAllocation < 0 || i.MinAllocation%i.LotSize != 0 {
+		return &InvalidInstrumentError{Message: "min_allocation must be a multiple of lot_size"}
+	}
+	if i.MinAllocation > 0 && i.MatchingAlgorithm != AlgorithmProRata {
+		return &InvalidInstrumentError{Message: "min_allocation is only allowed with PRO_RATA"}
+	}
+	if i.Schedule != nil {
+		// edge case: copies of the instrument share the schedule, parse into a new one
+		schedule := *i.Schedule
+		if err := schedule.validate(); err != nil {
+			return err
+		}
+		i.Schedule = &schedule
+	}
+	return nil
+}
+
+// algorithm is the matching algorithm the instrument's rules describe
+func (i *Instrument) algorithm() MatchingAlgorithm {
+	switch i.MatchingAlgorithm {
+	case AlgorithmProRata:
+		return ProRata{MinAllocation: i.MinAllocation, LotSize: i.LotSize}
+	case AlgorithmFIFOTopOrder:
+		return FIFOTopOrder{}
+	}
+	return FIFO{}
+}
+
+// checkOrder checks a new order against the instrument's rules
+func (i *Instrument) checkOrder(order *Order) error {
+	if err := i.checkPrice("price", order.Price); err != nil {
+		return err
+	}
+	if err := i.checkPrice("stop_price", order.StopPrice); err != nil {
+		return err
+	}
+	if err := i.checkQuantity(order.Quantity); err != nil {
+		return err
+	}
+	// edge case: an iceberg shows whole lots
+	if order.DisplayQuantity%i.LotSize != 0 {
+		return &InvalidOrderError{Symbol: i.Symbol, Field: "display_quantity", Message: "display_quantity must be a multiple of lot size " + strconv.FormatInt(i.LotSize, 10)}
+	}
+	return nil
+}
+
+// checkAmend checks the new price and quantity of an amend, 0 keeps the current value
+func (i *Instrument) checkAmend(price, quantity int64) error {
+	if err := i.checkPrice("price", price); err != nil {
+		return err
+	}
+	if quantity == 0 {
+		return nil
+	}
+	return i.checkQuantity(quantity)
+}
+
+// checkPrice checks that a price is on the tick, 0 (no price) always is
+func (i *Instrument) checkPrice(field string, price int64) error {
+	if price%i.TickSize != 0 {
+		return &InvalidOrderError{Symbol: i.Symbol, Field: field, Message: field + " must be a multiple of tick size " + strconv.FormatInt(i.TickSize, 10)}
+	}
+	return nil
+}
+
+func (i *Instrument) checkQuantity(quantity int64) error {
+	if quantity%i.LotSize != 0 {
+		return &InvalidOrderError{Symbol: i.Symbol, Field: "quantity", Message: "quantity must be a multiple of lot size " + strconv.FormatInt(i.LotSize, 10)}
+	}
+	if quantity < i.MinQuantity {
+		return &InvalidOrderError{Symbol: i.Symbol, Field: "quantity", Message: "quantity must be at least " + strconv.FormatInt(i.MinQuantity, 10)}
+	}
+	if i.MaxQuantity > 0 && quantity > i.MaxQuantity {
+		return &InvalidOrderError{Symbol: i.Symbol, Field: "quantity", Message: "quantity must be at most " + strconv.FormatInt(i.MaxQuantity, 10)}
+	}
+	return nil
+}
+
+// InstrumentStore keeps the instrument definitions across restarts
+type InstrumentStore interface {
+	Save(instruments []Instrument) error
+}
+
+// InstrumentRegistry holds the instruments a matcher trades. It hands out copies, so a
+// change only takes effect through Create or Update.
+type InstrumentRegistry struct {
+	mu          sync.RWMutex
+	instruments map[string]Instrument
+	store       InstrumentStore // nil keeps the definitions in memory only
+}
+
+func NewInstrumentRegistry(store InstrumentStore) *InstrumentRegistry {
+	return &InstrumentRegistry{
+		instruments: make(map[string]Instrument),
+		store:       store,
+	}
+}
+
+// Restore loads instruments read back from the store, without saving them again
+func (r *InstrumentRegistry) Restore(instruments []Instrument) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, instrument := range instruments {
+		if err := instrument.Validate(); err != nil {
+			return err
+		}
+		r.instruments[instrument.Symbol] = instrument
+	}
+	return nil
+}
+
+// Get returns a symbol's instrument
+func (r *InstrumentRegistry) Get(symbol string) (Instrument, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	instrument, exists := r.instruments[symbol]
+	return instrument, exists
+}
+
+// List returns every instrument, sorted by symbol
+func (r *InstrumentRegistry) List() []Instrument {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.list()
+}
+
+// must be called with r.mu held
+func (r *InstrumentRegistry) list() []Instrument {
+	instruments := make([]Instrument, 0, len(r.instruments))
+	for _, instrument := range r.instruments {
+		instruments = append(instruments, instrument)
+	}
+	sort.Slice(instruments, func(a, b int) bool {
+		return instruments[a].Symbol < instruments[b].Symbol
+	})
+	return instruments
+}
+
+// Create adds a new instrument and returns it with its defaults filled in
+func (r *InstrumentRegistry) Create(instrument Instrument) (Instrument, error) {
+	if err := instrument.Validate(); err != nil {
+		return Instrument{}, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.instruments[instrument.Symbol]; exists {
+		return Instrument{}, &InstrumentExistsError{Symbol: instrument.Symbol}
+	}
+	r.instruments[instrument.Symbol] = instrument
+	if err := r.save(); err != nil {
+		delete(r.instruments, instrument.Symbol)
+		return Instrument{}, err
+	}
+	return instrument, nil
+}
+
+// Update applies change to a copy of a symbol's instrument and stores the result if it
+// is valid. The symbol itself cannot change.
+func (r *InstrumentRegistry) Update(symbol string, change func(*Instrument)) (Instrument, error) {
+	return r.update(symbol, change, nil)
+}
+
+// update is Update with a check of the changed instrument against the previous one,
+// nil for none. Nothing is stored if the check fails.
+func (r *InstrumentRegistry) update(symbol string, change func(*Instrument), check func(previous, instrument *Instrument) error) (Instrument, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	previous, exists := r.instruments[symbol]
+	if !exists {
+		return Instrument{}, &UnknownInstrumentError{Symbol: symbol}
+	}
+
+	instrument := previous
+	change(&instrument)
+	instrument.Symbol = previous.Symbol
+	if err := instrument.Validate(); err != nil {
+		return Instrument{}, err
+	}
+	if check != nil {
+		if err := check(&previous, &instrument); err != nil {
+			return Instrument{}, err
+		}
+	}
+
+	r.instruments[previous.Symbol] = instrument
+	if err := r.save(); err != nil {
+		r.instruments[previous.Symbol] = previous
+		return Instrument{}, err
+	}
+	return instrument, nil
+}
+
+// UpdateInstrument changes a listed instrument like InstrumentRegistry.Update. When the
+// symbol has a book the change is made by a command on its sequencer, so every order sees
+// either the old rules or the new ones. The rules are journaled with the command, and a
+// replay switches to them at the same point in the book's sequence.
+func (m *Matcher) UpdateInstrument(symbol string, change func(*Instrument)) (Instrument, error) {
+	if m.instruments == nil {
+		return Instrument{}, &UnknownInstrumentError{Symbol: symbol}
+	}
+	// edge case: a book that does not exist yet takes the rules with its first command
+	if _, exists := m.GetOrderBook(symbol); !exists {
+		return m.instruments.Update(symbol, change)
+	}
+
+	var instrument Instrument
+	reply := m.getOrCreateSequencer(symbol).submit(&command{
+		Command: Command{Kind: CommandInstrument},
+		update: func(orderBook *OrderBook, journal func(*Instrument) error) (err error) {
+			instrument, err = m.instruments.update(symbol, change, func(previous, instrument *Instrument) error {
+				// edge case: resting prices were entered at the old precision
+				if instrument.PricePrecision != previous.PricePrecision && orderBook.hasOrders() {
+					return &PricePrecisionError{Symbol: symbol}
+				}
+				// write-ahead: the new rules are journaled before the registry stores them
+				rules := *instrument
+				return journal(&rules)
+			})
+			return err
+		},
+	})
+	if reply.err != nil {
+		return Instrument{}, reply.err
+	}
+	return instrument, nil
+}
+
+// PricePrecision is how many digits of the symbol's integer prices are decimals,
+// DefaultPricePrecision for a symbol without an instrument
+func (m *Matcher) PricePrecision(symbol string) int {
+	if instrument := m.listedInstrument(symbol); instrument != nil {
+		return instrument.PricePrecision
+	}
+	return DefaultPricePrecision
+}
+
+// listedInstrument is a copy of the symbol's instrument, nil when it is not listed or
+// the matcher trades without a registry
+func (m *Matcher) listedInstrument(symbol string) *Instrument {
+	if m.instruments == nil {
+		return nil
+	}
+	instrument, listed := m.instruments.Get(symbol)
+	if !listed {
+		return nil
+	}
+	return &instrument
+}
+
+// changedInstrument is the symbol's instrument when the book does not run under its
+// rules yet, nil when it does. Must be called with orderBook.mu held.
+func (m *Matcher) changedInstrument(orderBook *OrderBook) *Instrument {
+	instrument := m.listedInstrument(orderBook.Symbol)
+	if instrument == nil || (orderBook.instrument != nil && sameRules(*orderBook.instrument, *instrument)) {
+		return nil
+	}
+	return instrument
+}
+
+// sameRules reports whether two definitions of an instrument have the same rules.
+// Schedules are compared by their times, not their parsed form.
+func sameRules(a, b Instrument) bool {
+	aSchedule, bSchedule := a.Schedule, b.Schedule
+	a.Schedule, b.Schedule = nil, nil
+	if a != b {
+		return false
+	}
+	return sameSchedule(aSchedule, bSchedule)
+}
+
+// must be called with r.mu held
+func (r *InstrumentRegistry) save() error {
+	if r.store == nil {
+		return nil
+	}
+	if err := r.store.Save(r.list()); err != nil {
+		return &InstrumentStoreError{Err: err}
+	}
+	return nil
+}
+
+type InvalidInstrumentError struct {
+	Message string
+}
+
+func (e *InvalidInstrumentError) Error() string {
+	return "Invalid instrument: " + e.Message
+}
+
+type InstrumentExistsError struct {
+	Symbol string
+}
+
+func (e *InstrumentExistsError) Error() string {
+	return "Instrument " + e.Symbol + " already exists"
+}
+
+type UnknownInstrumentError struct {
+	Symbol string
+}
+
+func (e *UnknownInstrumentError) Error() string {
+	return "Unknown symbol " + e.Symbol
+}
+
+// InvalidOrderError is returned for an order or amend that breaks its instrument's tick,
+// lot or quantity rules
+type InvalidOrderError struct {
+	Symbol  string
+	Field   string // price, stop_price, quantity or display_quantity
+	Message string
+}
+
+func (e *InvalidOrderError) Error() string {
+	return e.Message
+}
+
+// checkStatus refuses new orders and amends while the book's instrument is not trading.
+// Must be called with ob.mu held.
+func (ob *OrderBook) checkStatus() error {
+	if ob.instrument == nil || ob.instrument.Status == TradingStatusTrading {
+		return nil
+	}
+	return &InstrumentNotTradingError{Symbol: ob.Symbol, Status: ob.instrument.Status}
+}
+
+type InstrumentNotTradingError struct {
+	Symbol string
+	Status TradingStatus
+}
+
+func (e *InstrumentNotTradingError) Error() string {
+	return e.Symbol + " is not trading: " + string(e.Status)
+}
+
+// PricePrecisionError is returned for a change of price_precision while the symbol has
+// orders, whose prices would change value
+type PricePrecisionError struct {
+	Symbol string
+}
+
+func (e *PricePrecisionError) Error() string {
+	return "price_precision of " + e.Symbol + " cannot change while it has orders"
+}
+
+type InstrumentStoreError struct {
+	Err error
+}
+
+func (e *InstrumentStoreError) Error() string {
+	return "instrument store save failed: " + e.Err.Error()
+}
+
+func (e *InstrumentStoreError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,180 @@
+package engine
+
+import (
+	"sync/atomic"
+
+	"github.com/google/btree"
+)
+
+// LevelChange is the new aggregate visible quantity at one price level, 0 once the
+// level has left the book
+type LevelChange struct {
+	Side     OrderSide `json:"side"`
+	Price    int64     `json:"price"`
+	Quantity int64     `json:"quantity"`
+}
+
+// BookUpdate is every price level one change to the book touched, and the trades it
+// executed. Updates of a symbol are numbered consecutively, so a subscriber that sees
+// Sequence jump has missed one.
+type BookUpdate struct {
+	Symbol          string        `json:"symbol"`
+	Sequence        uint64        `json:"seq"`         // feed sequence number, per symbol
+	CommandSequence uint64        `json:"command_seq"` // command that changed the book
+	Timestamp       int64         `json:"timestamp"`   // unix nanoseconds
+	Changes         []LevelChange `json:"changes"`
+	Trades          []*Trade      `json:"trades,omitempty"` // in execution order
+	Status          *SymbolStatus `json:"status,omitempty"` // set when the command changed what the symbol accepts
+}
+
+// SymbolStatus is what a symbol is accepting: its session, whether it is collecting
+// orders for an auction and whether it is halted
+type SymbolStatus struct {
+	Session        TradingSession `json:"session"`
+	Auction        bool           `json:"auction"`
+	Halted         bool           `json:"halted"`
+	HaltReason     string         `json:"halt_reason,omitempty"`
+	CancelsAllowed bool           `json:"cancels_allowed,omitempty"` // during the halt
+	ReopensAt      int64          `json:"reopens_at,omitempty"`      // unix nanoseconds the reopening auction uncrosses at
+}
+
+// BookListener is told about every change to a book's visible depth. It is called with
+// the book locked, in feed sequence order, so it must not block or call into the matcher.
+type BookListener interface {
+	OnBookUpdate(update *BookUpdate)
+}
+
+// WithBookListener publishes price level changes of every book to listener
+func WithBookListener(listener BookListener) MatcherOption {
+	return func(m *Matcher) {
+		m.bookListener = listener
+	}
+}
+
+// touchLevel records that a price level may have changed, for the next published update.
+// Must be called with ob.mu held.
+func (ob *OrderBook) touchLevel(side OrderSide, price int64) {
+	if ob.listener == nil {
+		return
+	}
+	// edge case: a sweep fills many orders at one level, record it once
+	if n := len(ob.touched); n > 0 && ob.touched[n-1].Side == side && ob.touched[n-1].Price == price {
+		return
+	}
+	ob.touched = append(ob.touched, LevelChange{Side: side, Price: price})
+}
+
+// tradeExecuted records a trade for the next published update. Must be called with
+// ob.mu held.
+func (ob *OrderBook) tradeExecuted(trade *Trade) {
+	if ob.listener == nil {
+		return
+	}
+	ob.traded = append(ob.traded, trade)
+}
+
+// Status is what the symbol is accepting now
+func (ob *OrderBook) Status() SymbolStatus {
+	ob.mu.RLock()
+	defer ob.mu.RUnlock()
+
+	return ob.status()
+}
+
+// must be called with ob.mu held
+func (ob *OrderBook) status() SymbolStatus {
+	status := SymbolStatus{
+		Session:   ob.tradingSession(),
+		Auction:   ob.auction,
+		ReopensAt: ob.reopenAt,
+	}
+	if ob.halt != nil {
+		status.Halted = true
+		status.HaltReason = ob.halt.Reason
+		status.CancelsAllowed = ob.halt.AllowCancels
+	}
+	return status
+}
+
+// publishLevels sends the current quantity of every touched level to the listener as
+// one update, with the symbol's status if it is no longer previous. Must be called with
+// ob.mu held.
+func (ob *OrderBook) publishLevels(previous SymbolStatus) {
+	if ob.listener == nil {
+		return
+	}
+	var status *SymbolStatus
+	if current := ob.status(); current != previous {
+		status = &current
+	}
+	if len(ob.touched) == 0 && status == nil {
+		return
+	}
+
+	changes := make([]LevelChange, 0, len(ob.touched))
+	seen := make(map[LevelChange]bool, len(ob.touched))
+	for _, level := range ob.touched {
+		if seen[level] {
+			continue
+		}
+		seen[level] = true
+		level.Quantity = ob.levelQuantity(level.Side, level.Price)
+		changes = append(changes, level)
+	}
+	ob.touched = ob.touched[:0]
+
+	// edge case: the update keeps the trades, so the next command needs a new slice
+	trades := ob.traded
+	ob.traded = nil
+
+	ob.listener.OnBookUpdate(&BookUpdate{
+		Symbol:          ob.Symbol,
+		Sequence:        atomic.AddUint64(&ob.FeedSequence, 1),
+		CommandSequence: ob.LastSequence(),
+		Timestamp:       ob.now().UnixNano(),
+		Changes:         changes,
+		Trades:          trades,
+		Status:          status,
+	})
+}
+
+// levelQuantity sums the visible quantity resting at one price, 0 if there is no level.
+// Must be called with ob.mu held.
+func (ob *OrderBook) levelQuantity(side OrderSide, price int64) int64 {
+	var item btree.Item
+	if side == SideBuy {
+		item = ob.Bids.Get(&PriceLevelItem{PriceLevel: &PriceLevel{Price: price}})
+	} else {
+		item = ob.Asks.Get(&PriceLevelItemAscending{PriceLevel: &PriceLevel{Price: price}})
+	}
+	if item == nil {
+		return 0
+	}
+
+	var totalQuantity int64
+	for _, order := range priceLevelOf(item).Orders {
+		totalQuantity += order.VisibleQuantity()
+	}
+	return totalQuantity
+}
+
+// GetSequencedOrderBookSnapshot is the full depth of the book together with the feed
+// sequence number it reflects. Subscribers apply only the updates numbered after it.
+func (ob *OrderBook) GetSequencedOrderBookSnapshot() (bids []OrderBookSnapshot, asks []OrderBookSnapshot, seq uint64) {
+	bids, asks, _, seq = ob.GetSequencedOrderBookState()
+	return bids, asks, seq
+}
+
+// GetSequencedOrderBookState is GetSequencedOrderBookSnapshot with the symbol's status
+// as of the same feed sequence number
+func (ob *OrderBook) GetSequencedOrderBookState() (bids []OrderBookSnapshot, asks []OrderBookSnapshot, status SymbolStatus, seq uint64) {
+	ob.mu.RLock()
+	defer ob.mu.RUnlock()
+
+	depth := ob.Bids.Len()
+	if ob.Asks.Len() > depth {
+		depth = ob.Asks.Len()
+	}
+	bids, asks = ob.getOrderBookSnapshot(depth)
+	return bids, asks, ob.status(), atomic.LoadUint64(&ob.FeedSequence)
+}
@@ -1,24 +1,116 @@
 package engine
 
 import (
+	"strconv"
+	"strings"
 	"sync"
 	"time"
-
-	"github.com/google/btree"
-	"github.com/google/uuid"
 )
 
 type Matcher struct {
 	OrderBooks map[string]*OrderBook
+	sequencers map[string]*sequencer
 	mu         sync.RWMutex
+
+	// order ID → symbol for every order a book still knows about (resting, pending
+	// stop or terminal within retention), so lookups don't scan every book
+	orderSymbols map[string]string
+	clientOrders map[string]string // client order ID → order ID
+	indexMu      sync.RWMutex
+
+	retention    RetentionPolicy
+	tradeHistory int
+	commandLog   CommandLog // nil keeps the engine in memory only
+	clock        Clock
+	ids          IDGenerator
+
+	// nil trades any symbol, otherwise only listed instruments within their rules
+	instruments *InstrumentRegistry
+	accountSTP  map[string]SelfTradePrevention // self-trade prevention default per account
+
+	// told about book and order changes as each command applies them, nil when unused
+	bookListener      BookListener
+	executionListener ExecutionListener
+}
+
+type MatcherOption func(*Matcher)
+
+// WithRetentionPolicy sets how many terminal orders each book keeps, and for how long
+func WithRetentionPolicy(policy RetentionPolicy) MatcherOption {
+	return func(m *Matcher) {
+		m.retention = policy
+	}
+}
+
+// WithTradeHistoryLimit sets how many recent trades each book keeps for history queries,
+// 0 keeps them all
+func WithTradeHistoryLimit(limit int) MatcherOption {
+	return func(m *Matcher) {
+		m.tradeHistory = limit
+	}
 }
 
-func NewMatcher() *Matcher {
-	return &Matcher{
-		OrderBooks: make(map[string]*OrderBook),
+// WithCommandLog writes every sequenced command to log before it is applied
+func WithCommandLog(log CommandLog) MatcherOption {
+	return func(m *Matcher) {
+		m.commandLog = log
 	}
 }
 
+// WithClock sets the clock commands are timestamped with
+func WithClock(clock Clock) MatcherOption {
+	return func(m *Matcher) {
+		m.clock = clock
+	}
+}
+
+// WithIDGenerator sets how trades are named
+func WithIDGenerator(ids IDGenerator) MatcherOption {
+	return func(m *Matcher) {
+		m.ids = ids
+	}
+}
+
+// WithInstruments only accepts orders for the registry's instruments, checked against
+// their tick size, lot size and trading status
+func WithInstruments(registry *InstrumentRegistry) MatcherOption {
+	return func(m *Matcher) {
+		m.instruments = registry
+	}
+}
+
+func NewMatcher(opts ...MatcherOption) *Matcher {
+	m := &Matcher{
+		OrderBooks:   make(map[string]*OrderBook),
+		sequencers:   make(map[string]*sequencer),
+		orderSymbols: make(map[string]string),
+		clientOrders: make(map[string]string),
+		retention:    DefaultRetentionPolicy,
+		tradeHistory: DefaultTradeHistoryLimit,
+		clock:        SystemClock,
+		ids:          UUIDGenerator{},
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// NewOrderID names a new order with the matcher's ID generator
+func (m *Matcher) NewOrderID() string {
+	return m.ids.OrderID()
+}
+
+// Now reads the matcher's clock
+func (m *Matcher) Now() time.Time {
+	return m.clock.Now()
+}
+
+// Instruments is the matcher's instrument registry, nil when any symbol trades
+func (m *Matcher) Instruments() *InstrumentRegistry {
+	return m.instruments
+}
+
 func (m *Matcher) GetOrderBooksSnapshot() map[string]*OrderBook {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
@@ -31,10 +123,27 @@ func (m *Matcher) GetOrderBooksSnapshot() map[string]*OrderBook {
 }
 
 func (m *Matcher) GetOrCreateOrderBook(symbol string) *OrderBook {
+	return m.getOrCreateSequencer(symbol).orderBook
+}
+
+// ListedOrderBook returns the book of a listed instrument, creating it on first use.
+// Unlike GetOrCreateOrderBook it never creates a book for an unknown symbol.
+func (m *Matcher) ListedOrderBook(symbol string) (*OrderBook, bool) {
+	if m.instruments != nil {
+		if _, listed := m.instruments.Get(symbol); !listed {
+			return nil, false
+		}
+	}
+	return m.GetOrCreateOrderBook(symbol), true
+}
+
+// getOrCreateSequencer returns the single writer for a symbol, creating the order book
+// and starting its sequencer goroutine on first use
+func (m *Matcher) getOrCreateSequencer(symbol string) *sequencer {
 	m.mu.RLock()
-	if ob, exists := m.OrderBooks[symbol]; exists {
+	if s, exists := m.sequencers[symbol]; exists {
 		m.mu.RUnlock()
-		return ob
+		return s
 	}
 	m.mu.RUnlock()
 
@@ -42,306 +151,623 @@ func (m *Matcher) GetOrCreateOrderBook(symbol string) *OrderBook {
 	defer m.mu.Unlock()
 
 	// edge case: double-check after acquiring write lock
-	if ob, exists := m.OrderBooks[symbol]; exists {
-		return ob
+	if s, exists := m.sequencers[symbol]; exists {
+		return s
 	}
 
-	ob := NewOrderBook(symbol)
+	ob := newOrderBook(symbol, m.retention, m.tradeHistory, m.clock)
+	ob.listener = m.bookListener
+	ob.executions = m.executionListener
+	s := newSequencer(m, ob)
 	m.OrderBooks[symbol] = ob
-	return ob
+	m.sequencers[symbol] = s
+	return s
+}
+
+func (m *Matcher) indexOrder(orderID, symbol string) {
+	m.indexMu.Lock()
+	m.orderSymbols[orderID] = symbol
+	m.indexMu.Unlock()
+}
+
+func (m *Matcher) unindexOrders(orders []*Order) {
+	m.indexMu.Lock()
+	for _, order := range orders {
+		delete(m.orderSymbols, order.ID)
+		if order.ClientOrderID != "" && m.clientOrders[order.ClientOrderID] == order.ID {
+			delete(m.clientOrders, order.ClientOrderID)
+		}
+	}
+	m.indexMu.Unlock()
+}
+
+// claimClientOrderID reserves a client order ID for an incoming order, failing if it
+// already belongs to an order the engine still knows about
+func (m *Matcher) claimClientOrderID(order *Order) bool {
+	m.indexMu.Lock()
+	defer m.indexMu.Unlock()
+
+	if _, exists := m.clientOrders[order.ClientOrderID]; exists {
+		return false
+	}
+	m.clientOrders[order.ClientOrderID] = order.ID
+	return true
+}
+
+// lookupSymbol returns the book an order ID belongs to, without creating one
+func (m *Matcher) lookupSymbol(orderID string) (*sequencer, bool) {
+	m.indexMu.RLock()
+	symbol, exists := m.orderSymbols[orderID]
+	m.indexMu.RUnlock()
+	if !exists {
+		return nil, false
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	s, exists := m.sequencers[symbol]
+	return s, exists
+}
+
+// GetOrder finds an order by ID in whichever book holds it, including terminal
+// orders still within the retention policy
+func (m *Matcher) GetOrder(orderID string) (*Order, bool) {
+	s, exists := m.lookupSymbol(orderID)
+	if !exists {
+		return nil, false
+	}
+
+	if order, exists := s.orderBook.GetOrder(orderID); exists {
+		return order, true
+	}
+	return s.orderBook.GetTerminalOrder(orderID)
+}
+
+// GetOrderBook returns a symbol's book without creating one
+func (m *Matcher) GetOrderBook(symbol string) (*OrderBook, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	orderBook, exists := m.OrderBooks[symbol]
+	return orderBook, exists
+}
+
+// GetOrderByClientID finds an order by the client order ID it was submitted with
+func (m *Matcher) GetOrderByClientID(clientOrderID string) (*Order, bool) {
+	m.indexMu.RLock()
+	orderID, exists := m.clientOrders[clientOrderID]
+	m.indexMu.RUnlock()
+	if !exists {
+		return nil, false
+	}
+
+	return m.GetOrder(orderID)
+}
+
+// Close stops every symbol's sequencer after it drains its queue.
+// No commands may be submitted once Close has been called.
+func (m *Matcher) Close() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for symbol, s := range m.sequencers {
+		s.stop()
+		delete(m.sequencers, symbol)
+	}
 }
 
 type MatchResult struct {
-	Status          OrderStatus
-	FilledQuantity  int64
+	Order             *Order // the order the command applied to
+	Status            OrderStatus
+	FilledQuantity    int64
 	RemainingQuantity int64
-	Trades          []*Trade
+	Trades            []*Trade
+	StopPending       bool              // stop order parked in the trigger book
+	TriggeredOrders   []*TriggeredOrder // stop orders released by this order's trades
+	PreventedMatches  []*PreventedMatch // trades self-trade prevention stopped
+	Sequence          uint64            // per-symbol sequence number of the command
+}
+
+type CancelResult struct {
+	Order    *Order
+	Sequence uint64
+}
+
+type TriggeredOrder struct {
+	Order  *Order
+	Result *MatchResult // nil when Err is set
+	Err    error
 }
 
+// MatchOrder queues an incoming order on its symbol's sequencer and waits for the result.
+// The whole match-and-rest sequence, including any stop orders it triggers, runs as one
+// sequenced command so orders on one symbol are processed strictly one at a time.
 func (m *Matcher) MatchOrder(order *Order) (*MatchResult, error) {
-	orderBook := m.GetOrCreateOrderBook(order.Symbol)
+	// edge case: orders breaking their instrument's rules are refused before they are
+	// sequenced, so they never reach the journal
+	if m.instruments != nil {
+		instrument, listed := m.instruments.Get(order.Symbol)
+		if !listed {
+			return nil, &UnknownInstrumentError{Symbol: order.Symbol}
+		}
+		if err := instrument.checkOrder(order); err != nil {
+			return nil, err
+		}
+		// edge case: a session boundary passed since the scheduler last ran
+		if instrument.Schedule != nil {
+			if _, err := m.syncSession(&instrument); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// edge case: a reopening auction ran out since the scheduler last ran
+	if _, err := m.syncReopening(order.Symbol); err != nil {
+		return nil, err
+	}
+
+	// edge case: the account default is fixed on the order before it is journaled, so a
+	// replay matches it the same way
+	order.SelfTradePrevention = m.selfTradeMode(order)
 
-	if order.Type == TypeMarket {
-		return m.matchMarketOrder(order, orderBook)
+	// edge case: a client order ID can only be reused once its order has been evicted
+	if order.ClientOrderID != "" && !m.claimClientOrderID(order) {
+		return nil, &DuplicateClientOrderIDError{ClientOrderID: order.ClientOrderID}
 	}
 
-	return m.matchLimitOrder(order, orderBook)
+	reply := m.getOrCreateSequencer(order.Symbol).submit(&command{
+		Command: Command{Kind: CommandSubmit},
+		order:   order,
+	})
+
+	// edge case: an order that was never recorded gives its client order ID back
+	if _, notLogged := reply.err.(*CommandLogError); notLogged && order.ClientOrderID != "" {
+		m.unindexOrders([]*Order{order})
+	}
+	return reply.result, reply.err
 }
 
-func (m *Matcher) matchLimitOrder(order *Order, orderBook *OrderBook) (*MatchResult, error) {
-	result := &MatchResult{
-		Status:          StatusAccepted,
-		FilledQuantity: 0,
-		RemainingQuantity: order.Quantity,
-		Trades:          make([]*Trade, 0),
+// must be called with orderBook.mu held
+func (m *Matcher) matchOrder(order *Order, orderBook *OrderBook) (*MatchResult, error) {
+	if err := orderBook.checkHalt(CommandSubmit); err != nil {
+		order.SetStatus(StatusRejected)
+		orderBook.retireOrder(order)
+		return nil, err
+	}
+	if err := orderBook.checkStatus(); err != nil {
+		order.SetStatus(StatusRejected)
+		orderBook.retireOrder(order)
+		return nil, err
+	}
+	if err := orderBook.checkSession(CommandSubmit); err != nil {
+		order.SetStatus(StatusRejected)
+		orderBook.retireOrder(order)
+		return nil, err
 	}
 
-	remainingQty := order.Quantity
+	// edge case: stop orders rest in the trigger book unless the last trade already crossed them
+	if order.IsStop() && !order.IsTriggeredBy(orderBook.GetLastTradePrice()) {
+		orderBook.reportAccepted(order)
+		orderBook.addStopOrder(order)
+		return &MatchResult{
+			Order:             order,
+			Status:            StatusAccepted,
+			RemainingQuantity: order.Quantity,
+			Trades:            make([]*Trade, 0),
+			StopPending:       true,
+		}, nil
+	}
 
-	for remainingQty > 0 {
-		var bestPrice int64
-		var bestPriceLevel *PriceLevel
-		var canMatch bool
+	// edge case: an auction only collects orders, the uncross matches them
+	if orderBook.auction {
+		return m.auctionOrder(order, orderBook)
+	}
 
-		if order.Side == SideBuy {
-			askPrice, _, hasAsk := orderBook.GetBestAsk()
-			if !hasAsk {
-				break
-			}
-			if order.Price < askPrice {
-				break
-			}
-			bestPrice = askPrice
-			bestPriceLevel = orderBook.GetPriceLevelForAsk(askPrice)
-			canMatch = true
-		} else {
-			bidPrice, _, hasBid := orderBook.GetBestBid()
-			if !hasBid {
-				break
-			}
-			if order.Price > bidPrice {
-				break
+	result, err := m.executeOrder(order, orderBook)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(result.Trades) > 0 {
+		result.TriggeredOrders = m.releaseTriggeredStops(orderBook, result.Trades)
+	}
+
+	return result, nil
+}
+
+// AmendOrder changes a resting order's price and/or quantity (0 keeps the current value).
+// If the amend lost the order its priority and the new price crosses the book, the order
+// is matched again as an aggressor and any remainder rests at the back of its level.
+// The amend and any resulting match run as one command on the symbol's sequencer.
+func (m *Matcher) AmendOrder(orderID string, newPrice, newQuantity int64) (*MatchResult, error) {
+	s, exists := m.lookupSymbol(orderID)
+	if !exists {
+		return nil, &OrderNotFoundError{OrderID: orderID}
+	}
+	if m.instruments != nil {
+		if instrument, listed := m.instruments.Get(s.orderBook.Symbol); listed {
+			if err := instrument.checkAmend(newPrice, newQuantity); err != nil {
+				return nil, err
 			}
-			bestPrice = bidPrice
-			bestPriceLevel = orderBook.GetPriceLevelForBid(bidPrice)
-			canMatch = true
 		}
+	}
+	if err := m.syncSymbolSession(s.orderBook.Symbol); err != nil {
+		return nil, err
+	}
+	if _, err := m.syncReopening(s.orderBook.Symbol); err != nil {
+		return nil, err
+	}
 
-		if !canMatch || bestPriceLevel == nil {
-			break
-		}
+	reply := s.submit(&command{
+		Command: Command{
+			Kind:     CommandAmend,
+			OrderID:  orderID,
+			Price:    newPrice,
+			Quantity: newQuantity,
+		},
+	})
+	return reply.result, reply.err
+}
 
-		for remainingQty > 0 && !order.IsFilled() {
-			// edge case: re-check length to prevent race condition
-			if len(bestPriceLevel.Orders) == 0 {
-				break
-			}
-			restingOrder := bestPriceLevel.Orders[0]
-			restingRemaining := restingOrder.RemainingQuantity()
-
-			if restingRemaining <= 0 {
-				// edge case: order already filled, remove it
-				if len(bestPriceLevel.Orders) > 1 {
-					bestPriceLevel.Orders = bestPriceLevel.Orders[1:]
-				} else {
-					bestPriceLevel.Orders = bestPriceLevel.Orders[:0]
-				}
-				continue
-			}
+// must be called with orderBook.mu held
+func (m *Matcher) amendOrder(orderBook *OrderBook, orderID string, newPrice, newQuantity int64) (*MatchResult, error) {
+	if err := orderBook.checkHalt(CommandAmend); err != nil {
+		return nil, err
+	}
+	if err := orderBook.checkStatus(); err != nil {
+		return nil, err
+	}
+	if err := orderBook.checkSession(CommandAmend); err != nil {
+		return nil, err
+	}
+	order, requeued, err := orderBook.amendOrder(orderID, newPrice, newQuantity)
+	if err != nil {
+		return nil, err
+	}
 
-			executionPrice := bestPrice
-			executionQty := remainingQty
-			if executionQty > restingRemaining {
-				executionQty = restingRemaining
-			}
-			
-			if executionQty <= 0 {
-				break
-			}
+	// edge case: an amended order waits for the uncross like any other during an auction
+	if !requeued || orderBook.auction || !crossesBook(order, orderBook) {
+		return &MatchResult{
+			Order:             order,
+			Status:            order.GetStatus(),
+			RemainingQuantity: order.RemainingQuantity(),
+			Trades:            make([]*Trade, 0),
+		}, nil
+	}
 
-			trade := &Trade{
-				TradeID:     uuid.New().String(),
-				Price:       executionPrice,
-				Quantity:    executionQty,
-				Timestamp:   time.Now().UnixMilli(),
-				BuyOrderID:  "",
-				SellOrderID: "",
-			}
+	orderBook.removeOrder(order.ID)
 
-			if order.Side == SideBuy {
-				trade.BuyOrderID = order.ID
-				trade.SellOrderID = restingOrder.ID
-			} else {
-				trade.BuyOrderID = restingOrder.ID
-				trade.SellOrderID = order.ID
-			}
+	result, err := m.matchLimitOrder(order, orderBook)
+	if !orderBook.isResting(order.ID) {
+		orderBook.retireOrder(order)
+	}
+	if err != nil {
+		return nil, err
+	}
+	result.Order = order
 
-			result.Trades = append(result.Trades, trade)
+	if len(result.Trades) > 0 {
+		result.TriggeredOrders = m.releaseTriggeredStops(orderBook, result.Trades)
+	}
 
-			order.Fill(executionQty)
-			restingOrder.Fill(executionQty)
+	return result, nil
+}
 
-			result.FilledQuantity += executionQty
-			remainingQty = order.Quantity - order.FilledQuantity
+// CancelOrder removes a resting or pending stop order from its book and marks it
+// CANCELLED. The check and the removal run as one command on the symbol's sequencer,
+// so an order cannot be cancelled halfway through a match that is filling it.
+func (m *Matcher) CancelOrder(orderID string) (*CancelResult, error) {
+	s, exists := m.lookupSymbol(orderID)
+	if !exists {
+		return nil, &OrderNotFoundError{OrderID: orderID}
+	}
+	if err := m.syncSymbolSession(s.orderBook.Symbol); err != nil {
+		return nil, err
+	}
+	if _, err := m.syncReopening(s.orderBook.Symbol); err != nil {
+		return nil, err
+	}
 
-			if remainingQty <= 0 || order.IsFilled() {
-				break
-			}
+	reply := s.submit(&command{
+		Command: Command{Kind: CommandCancel, OrderID: orderID},
+	})
+	if reply.err != nil {
+		return nil, reply.err
+	}
+	return &CancelResult{Order: reply.order, Sequence: reply.seq}, nil
+}
 
-			if restingOrder.IsFilled() {
-				orderBook.RemoveOrder(restingOrder.ID)
-			}
+// Replay applies a command recovered from the command log. Commands must be replayed in
+// sequence order per symbol; ones the book has already applied are skipped, and a gap
+// in the sequence is reported as a SequenceGapError. Replayed commands are not logged again.
+func (m *Matcher) Replay(cmd *Command) error {
+	replayed := &command{Command: *cmd, replay: true}
 
-			// edge case: remove empty price level
-			if len(bestPriceLevel.Orders) == 0 {
-				if order.Side == SideBuy {
-					orderBook.Asks.Delete(&PriceLevelItemAscending{PriceLevel: &PriceLevel{Price: bestPrice}})
-				} else {
-					orderBook.Bids.Delete(&PriceLevelItem{PriceLevel: &PriceLevel{Price: bestPrice}})
-				}
-				break
-			}
+	if cmd.Kind == CommandSubmit {
+		if cmd.Order == nil {
+			return &InvalidCommandError{Sequence: cmd.Sequence, Message: "submit without order"}
 		}
-		
-		if remainingQty <= 0 {
-			break
+		replayed.order = cmd.Order.NewOrder(cmd.Symbol)
+		if replayed.order.ClientOrderID != "" {
+			m.claimClientOrderID(replayed.order)
 		}
 	}
 
-	result.RemainingQuantity = remainingQty
+	reply := m.getOrCreateSequencer(cmd.Symbol).submit(replayed)
+
+	// edge case: business rejections (insufficient liquidity, unknown order) replay
+	// exactly as they happened live, only log and sequencing errors stop recovery
+	switch reply.err.(type) {
+	case *SequenceGapError, *CommandLogError, *InvalidCommandError:
+		return reply.err
+	}
+	return nil
+}
 
-	if result.FilledQuantity == 0 {
-		result.Status = StatusAccepted
-		orderBook.AddOrder(order)
-	} else if remainingQty > 0 {
-		result.Status = StatusPartialFill
-		orderBook.AddOrder(order)
+// must be called with orderBook.mu held
+func crossesBook(order *Order, orderBook *OrderBook) bool {
+	if order.Side == SideBuy {
+		bestAsk := orderBook.bestLevel(SideSell)
+		return bestAsk != nil && order.Price >= bestAsk.Price
+	}
+	bestBid := orderBook.bestLevel(SideBuy)
+	return bestBid != nil && order.Price <= bestBid.Price
+}
+
+// executeOrder matches an order that is not resting yet. Orders that do not end up
+// resting are done (filled, cancelled or rejected) and move to the terminal store.
+// Must be called with orderBook.mu held.
+func (m *Matcher) executeOrder(order *Order, orderBook *OrderBook) (*MatchResult, error) {
+	var result *MatchResult
+	var err error
+	if order.Type == TypeMarket || order.Type == TypeStop {
+		result, err = m.matchMarketOrder(order, orderBook)
 	} else {
-		result.Status = StatusFilled
+		result, err = m.matchLimitOrder(order, orderBook)
+	}
+
+	if !orderBook.isResting(order.ID) {
+		orderBook.retireOrder(order)
+	}
+	if err != nil {
+		return nil, err
 	}
 
+	result.Order = order
 	return result, nil
 }
 
-// edge case: market orders must execute completely or be rejected
-func (m *Matcher) matchMarketOrder(order *Order, orderBook *OrderBook) (*MatchResult, error) {
-	result := &MatchResult{
-		Status:          StatusFilled,
-		FilledQuantity: 0,
-		RemainingQuantity: 0,
-		Trades:          make([]*Trade, 0),
+// releaseTriggeredStops executes stop orders crossed by any of the given trades,
+// repeating with the trades of the released orders so that cascades resolve in one pass.
+// Must be called with orderBook.mu held.
+func (m *Matcher) releaseTriggeredStops(orderBook *OrderBook, trades []*Trade) []*TriggeredOrder {
+	var released []*TriggeredOrder
+
+	for len(trades) > 0 {
+		low, high := tradePriceRange(trades)
+		triggered := orderBook.takeTriggeredStops(low, high)
+		trades = nil
+
+		for _, order := range triggered {
+			result, err := m.executeOrder(order, orderBook)
+			released = append(released, &TriggeredOrder{
+				Order:  order,
+				Result: result,
+				Err:    err,
+			})
+			if err == nil {
+				trades = append(trades, result.Trades...)
+			}
+		}
 	}
 
-	remainingQty := order.Quantity
-	totalAvailable := int64(0)
+	return released
+}
 
-	if order.Side == SideBuy {
-		orderBook.Asks.Ascend(func(item btree.Item) bool {
-			priceLevel := item.(*PriceLevelItemAscending).PriceLevel
-			for _, o := range priceLevel.Orders {
-				totalAvailable += o.RemainingQuantity()
+func tradePriceRange(trades []*Trade) (low, high int64) {
+	low, high = trades[0].Price, trades[0].Price
+	for _, trade := range trades[1:] {
+		if trade.Price < low {
+			low = trade.Price
+		}
+		if trade.Price > high {
+			high = trade.Price
+		}
+	}
+	return low, high
+}
+
+// must be called with orderBook.mu held
+func (m *Matcher) matchLimitOrder(order *Order, orderBook *OrderBook) (*MatchResult, error) {
+	// edge case: amended orders re-enter matching with part of their quantity already filled
+	remainingQty := order.RemainingQuantity()
+
+	// edge case: fill-or-kill is rejected up front if the book cannot fill it completely,
+	// not counting the account's own orders that self-trade prevention keeps it from
+	if order.TimeInForce == TIFFOK {
+		available := orderBook.fillableQuantity(order)
+		if available < remainingQty {
+			order.SetStatus(StatusRejected)
+			return nil, &InsufficientLiquidityError{
+				Requested: remainingQty,
+				Available: available,
 			}
-			return true
-		})
+		}
+	}
+
+	orderBook.reportAccepted(order)
+	result := m.matchAgainstBook(order, orderBook, order.Price)
+	remainingQty = result.RemainingQuantity
+
+	// edge case: self-trade prevention cancelled the rest of the order, it never rests
+	if order.GetStatus() == StatusCancelled {
+		result.Status = StatusCancelled
+		return result, nil
+	}
+
+	// edge case: immediate-or-cancel and fill-or-kill never rest, the unfilled remainder is
+	// cancelled
+	if remainingQty > 0 && (order.TimeInForce == TIFIOC || order.TimeInForce == TIFFOK) {
+		order.SetStatus(StatusCancelled)
+		result.Status = StatusCancelled
+		return result, nil
+	}
+
+	if remainingQty > 0 {
+		// ACCEPTED, or PARTIAL_FILL if this or an earlier match filled part of it
+		result.Status = order.GetStatus()
+		orderBook.addOrder(order)
 	} else {
-		orderBook.Bids.Ascend(func(item btree.Item) bool {
-			priceLevel := item.(*PriceLevelItem).PriceLevel
-			for _, o := range priceLevel.Orders {
-				totalAvailable += o.RemainingQuantity()
-			}
-			return true
-		})
+		result.Status = StatusFilled
 	}
 
+	return result, nil
+}
+
+// edge case: market orders must execute completely or be rejected
+// must be called with orderBook.mu held
+func (m *Matcher) matchMarketOrder(order *Order, orderBook *OrderBook) (*MatchResult, error) {
+	remainingQty := order.Quantity
+	totalAvailable := orderBook.availableQuantity(order.Side, 0)
+
 	// edge case: reject if insufficient liquidity
 	if totalAvailable < remainingQty {
+		order.SetStatus(StatusRejected)
 		return nil, &InsufficientLiquidityError{
 			Requested: remainingQty,
 			Available: totalAvailable,
 		}
 	}
 
-	for remainingQty > 0 {
-		var bestPrice int64
-		var bestPriceLevel *PriceLevel
+	orderBook.reportAccepted(order)
+	result := m.matchAgainstBook(order, orderBook, 0)
+	result.Status = StatusFilled
+
+	// edge case: liquidity of the order's own account may have been cancelled instead of
+	// traded, the unfilled remainder is cancelled
+	if result.RemainingQuantity > 0 {
+		order.selfTrade = true
+		order.SetStatus(StatusCancelled)
+		result.Status = StatusCancelled
+	}
 
-		if order.Side == SideBuy {
-			askPrice, _, hasAsk := orderBook.GetBestAsk()
-			if !hasAsk {
-				break
-			}
-			bestPrice = askPrice
-			bestPriceLevel = orderBook.GetPriceLevelForAsk(askPrice)
-		} else {
-			bidPrice, _, hasBid := orderBook.GetBestBid()
-			if !hasBid {
-				break
-			}
-			bestPrice = bidPrice
-			bestPriceLevel = orderBook.GetPriceLevelForBid(bidPrice)
-		}
+	return result, nil
+}
 
+// matchAgainstBook walks the opposite side of the book in price priority, filling the
+// order until it is done or the next level is beyond limitPrice (0 means no limit). The
+// symbol's matching algorithm shares each level among its resting orders.
+// Filled resting orders and emptied price levels are removed as they are consumed.
+// Must be called with orderBook.mu held.
+func (m *Matcher) matchAgainstBook(order *Order, orderBook *OrderBook, limitPrice int64) *MatchResult {
+	result := &MatchResult{
+		Status:            StatusAccepted,
+		FilledQuantity:    0,
+		RemainingQuantity: order.RemainingQuantity(),
+		Trades:            make([]*Trade, 0),
+	}
+
+	oppositeSide := SideSell
+	if order.Side == SideSell {
+		oppositeSide = SideBuy
+	}
+
+	remainingQty := result.RemainingQuantity
+	algorithm := orderBook.matchingAlgorithm()
+	preventSelfTrade := order.Account != "" && order.SelfTradePrevention != "" && order.SelfTradePrevention != STPNone
+
+	for remainingQty > 0 && order.GetStatus() != StatusCancelled {
+		bestPriceLevel := orderBook.bestLevel(oppositeSide)
 		if bestPriceLevel == nil {
 			break
 		}
 
-		for remainingQty > 0 {
-			// edge case: re-check length to prevent race condition
-			if len(bestPriceLevel.Orders) == 0 {
+		if limitPrice > 0 {
+			if order.Side == SideBuy && limitPrice < bestPriceLevel.Price {
 				break
 			}
-			restingOrder := bestPriceLevel.Orders[0]
-			restingRemaining := restingOrder.RemainingQuantity()
-
-			if restingRemaining <= 0 {
-				if len(bestPriceLevel.Orders) > 1 {
-					bestPriceLevel.Orders = bestPriceLevel.Orders[1:]
-				} else {
-					bestPriceLevel.Orders = bestPriceLevel.Orders[:0]
-				}
-				continue
-			}
-
-			executionPrice := bestPrice
-			executionQty := remainingQty
-			if executionQty > restingRemaining {
-				executionQty = restingRemaining
-			}
-
-			trade := &Trade{
-				TradeID:     uuid.New().String(),
-				Price:       executionPrice,
-				Quantity:    executionQty,
-				Timestamp:   time.Now().UnixMilli(),
-				BuyOrderID:  "",
-				SellOrderID: "",
+			if order.Side == SideSell && limitPrice > bestPriceLevel.Price {
+				break
 			}
+		}
 
-			if order.Side == SideBuy {
-				trade.BuyOrderID = order.ID
-				trade.SellOrderID = restingOrder.ID
-			} else {
-				trade.BuyOrderID = restingOrder.ID
-				trade.SellOrderID = order.ID
+		for remainingQty > 0 && len(bestPriceLevel.Orders) > 0 && order.GetStatus() != StatusCancelled {
+			orderBook.allocations = algorithm.Allocate(orderBook.allocations[:0], bestPriceLevel, remainingQty)
+			// edge case: an algorithm that allocates nothing would never leave the level
+			if len(orderBook.allocations) == 0 {
+				break
 			}
 
-			result.Trades = append(result.Trades, trade)
-
-			order.Fill(executionQty)
-			restingOrder.Fill(executionQty)
-
-			result.FilledQuantity += executionQty
-			remainingQty -= executionQty
+			for _, allocation := range orderBook.allocations {
+				restingOrder := allocation.Order
 
-			if restingOrder.IsFilled() {
-				orderBook.RemoveOrder(restingOrder.ID)
-				if len(bestPriceLevel.Orders) > 1 {
-					bestPriceLevel.Orders = bestPriceLevel.Orders[1:]
-				} else {
-					bestPriceLevel.Orders = bestPriceLevel.Orders[:0]
+				if preventSelfTrade && restingOrder.Account == order.Account {
+					prevented := m.preventSelfTrade(order, restingOrder, orderBook)
+					result.PreventedMatches = append(result.PreventedMatches, prevented)
+					remainingQty = order.RemainingQuantity()
+					// edge case: the rest of the round was allocated for the quantity before
+					break
 				}
-			}
 
-			if remainingQty <= 0 {
-				break
-			}
+				executionQty := allocation.Quantity
 
-			// edge case: remove empty price level
-			if len(bestPriceLevel.Orders) == 0 {
-				if order.Side == SideBuy {
-					orderBook.Asks.Delete(&PriceLevelItemAscending{PriceLevel: &PriceLevel{Price: bestPrice}})
-				} else {
-					orderBook.Bids.Delete(&PriceLevelItem{PriceLevel: &PriceLevel{Price: bestPrice}})
-				}
-				break
+				trade := m.executeTrade(orderBook, order, restingOrder, bestPriceLevel.Price, executionQty, order.Side)
+				result.Trades = append(result.Trades, trade)
+				result.FilledQuantity += executionQty
+				remainingQty -= executionQty
+				orderBook.touchLevel(restingOrder.Side, bestPriceLevel.Price)
+
+				orderBook.settleFill(bestPriceLevel, restingOrder, executionQty)
 			}
+			clear(orderBook.allocations)
 		}
 	}
 
 	result.RemainingQuantity = remainingQty
-	result.Status = StatusFilled
+	return result
+}
 
-	return result, nil
+// executeTrade trades quantity between two orders at price and sends their fill reports,
+// order's first. aggressor is the side that took liquidity, "" for an auction uncross.
+// Must be called with orderBook.mu held.
+func (m *Matcher) executeTrade(orderBook *OrderBook, order, resting *Order, price, quantity int64, aggressor OrderSide) *Trade {
+	now := orderBook.now()
+	orderBook.tradeCount++
+	trade := &Trade{
+		TradeID: m.ids.TradeID(TradeKey{
+			Symbol:    orderBook.Symbol,
+			Sequence:  orderBook.LastSequence(),
+			Timestamp: now.UnixNano(),
+			Match:     orderBook.tradeCount,
+		}),
+		Symbol:        orderBook.Symbol,
+		Price:         price,
+		Quantity:      quantity,
+		Timestamp:     now.UnixNano(),
+		AggressorSide: aggressor,
+		Sequence:      orderBook.LastSequence(),
+	}
+
+	if order.Side == SideBuy {
+		trade.BuyOrderID = order.ID
+		trade.SellOrderID = resting.ID
+	} else {
+		trade.BuyOrderID = resting.ID
+		trade.SellOrderID = order.ID
+	}
+
+	orderBook.trades.add(trade)
+
+	order.Fill(quantity)
+	resting.Fill(quantity)
+	order.addFill(trade)
+	resting.addFill(trade)
+	orderBook.reportFill(order, trade)
+	orderBook.reportFill(resting, trade)
+	orderBook.SetLastTradePrice(trade.Price)
+	orderBook.tradeExecuted(trade)
+	return trade
 }
 
 type InsufficientLiquidityError struct {
@@ -353,3 +779,75 @@ func (e *InsufficientLiquidityError) Error() string {
 	return "Insufficient liquidity"
 }
 
+type OrderNotFoundError struct {
+	OrderID string
+}
+
+func (e *OrderNotFoundError) Error() string {
+	return "Order not found"
+}
+
+type DuplicateClientOrderIDError struct {
+	ClientOrderID string
+}
+
+func (e *DuplicateClientOrderIDError) Error() string {
+	return "Duplicate client_order_id"
+}
+
+type OrderNotCancellableError struct {
+	OrderID string
+	Status  OrderStatus
+}
+
+func (e *OrderNotCancellableError) Error() string {
+	return "Cannot cancel: order already " + strings.ToLower(string(e.Status))
+}
+
+type SequenceGapError struct {
+	Symbol   string
+	Expected uint64
+	Got      uint64
+}
+
+func (e *SequenceGapError) Error() string {
+	return "sequence gap for " + e.Symbol + ": expected " + strconv.FormatUint(e.Expected, 10) + ", got " + strconv.FormatUint(e.Got, 10)
+}
+
+// BookNotEmptyError is returned when restoring a snapshot over a book that already has orders or commands
+type BookNotEmptyError struct {
+	Symbol string
+}
+
+func (e *BookNotEmptyError) Error() string {
+	return "cannot restore " + e.Symbol + ": order book is not empty"
+}
+
+type CommandLogError struct {
+	Err error
+}
+
+func (e *CommandLogError) Error() string {
+	return "command log append failed: " + e.Err.Error()
+}
+
+func (e *CommandLogError) Unwrap() error {
+	return e.Err
+}
+
+type InvalidCommandError struct {
+	Sequence uint64
+	Message  string
+}
+
+func (e *InvalidCommandError) Error() string {
+	return "invalid command at sequence " + strconv.FormatUint(e.Sequence, 10) + ": " + e.Message
+}
+
+type InvalidAmendError struct {
+	Message string
+}
+
+func (e *InvalidAmendError) Error() string {
+	return e.Message
+}
@@ -3,7 +3,6 @@ package engine
 import (
 	"sync"
 	"sync/atomic"
-	"time"
 )
 
 type OrderSide string
@@ -16,8 +15,20 @@ const (
 type OrderType string
 
 const (
-	TypeLimit  OrderType = "LIMIT"
-	TypeMarket OrderType = "MARKET"
+	TypeLimit     OrderType = "LIMIT"
+	TypeMarket    OrderType = "MARKET"
+	TypeStop      OrderType = "STOP"       // becomes a MARKET order once the stop price trades
+	TypeStopLimit OrderType = "STOP_LIMIT" // becomes a LIMIT order once the stop price trades
+)
+
+// edge case: time in force only affects the unfilled remainder of LIMIT orders,
+// MARKET orders are always all-or-nothing
+type TimeInForce string
+
+const (
+	TIFGTC TimeInForce = "GTC" // good till cancelled, remainder rests on the book
+	TIFIOC TimeInForce = "IOC" // immediate or cancel, remainder is cancelled
+	TIFFOK TimeInForce = "FOK" // fill or kill, rejected unless it fills completely
 )
 
 type OrderStatus string
@@ -25,31 +36,52 @@ type OrderStatus string
 const (
 	StatusAccepted    OrderStatus = "ACCEPTED"
 	StatusPartialFill OrderStatus = "PARTIAL_FILL"
-	StatusFilled     OrderStatus = "FILLED"
+	StatusFilled      OrderStatus = "FILLED"
 	StatusCancelled   OrderStatus = "CANCELLED"
+	StatusRejected    OrderStatus = "REJECTED"
+	StatusExpired     OrderStatus = "EXPIRED"
 )
 
+// IsTerminal reports whether an order in this status can no longer trade
+func (s OrderStatus) IsTerminal() bool {
+	return s == StatusFilled || s == StatusCancelled || s == StatusRejected || s == StatusExpired
+}
+
 // edge case: price stored as int64 in cents to avoid floating-point precision errors
 type Order struct {
-	ID            string
-	Symbol        string
-	Side          OrderSide
-	Type          OrderType
-	Price         int64 // price in cents, required for LIMIT, 0 for MARKET
-	Quantity      int64
-	FilledQuantity int64 // atomic for thread-safety
-	Status        OrderStatus
-	Timestamp     int64
-	statusMu      sync.Mutex
+	ID                  string
+	ClientOrderID       string              // optional, unique among the orders the engine still knows about
+	Account             string              // optional owner, execution reports are routed by it
+	SelfTradePrevention SelfTradePrevention // what happens when it meets the account's own orders, "" for the account default
+	Symbol              string
+	Side                OrderSide
+	Type                OrderType
+	TimeInForce         TimeInForce
+	Price               int64 // price in cents, required for LIMIT, 0 for MARKET
+	StopPrice           int64 // trigger price in cents, required for STOP and STOP_LIMIT
+	Quantity            int64
+	DisplayQuantity     int64 // iceberg slice size, 0 displays the full quantity
+	FilledQuantity      int64 // atomic for thread-safety
+	displayRemaining    int64 // atomic, quantity left in the current iceberg slice
+	Status              OrderStatus
+	Timestamp           int64    // unix nanoseconds, set by the Matcher when the order is sequenced
+	fills               []*Trade // trades on either side of this order, guarded by statusMu
+	accepted            bool     // NEW has been reported, guarded by the book's mutex
+	selfTrade           bool     // cancelled by self-trade prevention, guarded by the book's mutex
+	topOrder            bool     // opened a new best price level, guarded by the book's mutex
+	statusMu            sync.Mutex
 }
 
 type Trade struct {
-	TradeID     string
-	Price       int64
-	Quantity    int64
-	Timestamp   int64
-	BuyOrderID  string
-	SellOrderID string
+	TradeID       string    `json:"trade_id"`
+	Symbol        string    `json:"symbol"`
+	Price         int64     `json:"price"`
+	Quantity      int64     `json:"quantity"`
+	Timestamp     int64     `json:"timestamp"` // unix nanoseconds
+	BuyOrderID    string    `json:"buy_order_id"`
+	SellOrderID   string    `json:"sell_order_id"`
+	AggressorSide OrderSide `json:"aggressor_side"` // side of the incoming order that took liquidity
+	Sequence      uint64    `json:"seq"`            // sequence number of the command that produced the trade
 }
 
 type PriceLevel struct {
@@ -59,18 +91,77 @@ type PriceLevel struct {
 
 func NewOrder(id, symbol string, side OrderSide, orderType OrderType, price, quantity int64) *Order {
 	return &Order{
-		ID:            id,
-		Symbol:        symbol,
-		Side:          side,
-		Type:          orderType,
-		Price:         price,
-		Quantity:      quantity,
+		ID:             id,
+		Symbol:         symbol,
+		Side:           side,
+		Type:           orderType,
+		TimeInForce:    TIFGTC,
+		Price:          price,
+		Quantity:       quantity,
 		FilledQuantity: 0,
-		Status:        StatusAccepted,
-		Timestamp:     time.Now().UnixMilli(),
+		Status:         StatusAccepted,
 	}
 }
 
+func (o *Order) IsStop() bool {
+	return o.Type == TypeStop || o.Type == TypeStopLimit
+}
+
+// edge case: buy stops trigger at or above the stop price, sell stops at or below
+func (o *Order) IsTriggeredBy(lastTradePrice int64) bool {
+	if lastTradePrice <= 0 {
+		return false
+	}
+	if o.Side == SideBuy {
+		return lastTradePrice >= o.StopPrice
+	}
+	return lastTradePrice <= o.StopPrice
+}
+
+func (o *Order) IsIceberg() bool {
+	return o.DisplayQuantity > 0 && o.DisplayQuantity < o.Quantity
+}
+
+// VisibleQuantity is the quantity shown on the book and available to a single fill,
+// for icebergs this is what is left of the current slice
+func (o *Order) VisibleQuantity() int64 {
+	remaining := o.RemainingQuantity()
+	if !o.IsIceberg() {
+		return remaining
+	}
+	visible := atomic.LoadInt64(&o.displayRemaining)
+	if visible > remaining {
+		return remaining
+	}
+	return visible
+}
+
+// refreshDisplay starts a new iceberg slice from the hidden remainder
+func (o *Order) refreshDisplay() {
+	if !o.IsIceberg() {
+		return
+	}
+	slice := o.DisplayQuantity
+	if remaining := o.RemainingQuantity(); remaining < slice {
+		slice = remaining
+	}
+	atomic.StoreInt64(&o.displayRemaining, slice)
+}
+
+// consumeDisplay takes a passive fill out of the current iceberg slice and reports
+// whether the slice was used up with hidden quantity left to replenish
+func (o *Order) consumeDisplay(quantity int64) bool {
+	if !o.IsIceberg() {
+		return false
+	}
+	left := atomic.AddInt64(&o.displayRemaining, -quantity)
+	if left > 0 || o.IsFilled() {
+		return false
+	}
+	o.refreshDisplay()
+	return true
+}
+
 func (o *Order) GetFilledQuantity() int64 {
 	return atomic.LoadInt64(&o.FilledQuantity)
 }
@@ -87,7 +178,7 @@ func (o *Order) IsFilled() bool {
 
 func (o *Order) Fill(quantity int64) {
 	newFilled := atomic.AddInt64(&o.FilledQuantity, quantity)
-	
+
 	o.statusMu.Lock()
 	if newFilled >= o.Quantity {
 		o.Status = StatusFilled
@@ -97,6 +188,22 @@ func (o *Order) Fill(quantity int64) {
 	o.statusMu.Unlock()
 }
 
+func (o *Order) addFill(trade *Trade) {
+	o.statusMu.Lock()
+	o.fills = append(o.fills, trade)
+	o.statusMu.Unlock()
+}
+
+// Fills returns the trades this order has taken part in, oldest first
+func (o *Order) Fills() []*Trade {
+	o.statusMu.Lock()
+	defer o.statusMu.Unlock()
+
+	fills := make([]*Trade, len(o.fills))
+	copy(fills, o.fills)
+	return fills
+}
+
 func (o *Order) GetStatus() OrderStatus {
 	o.statusMu.Lock()
 	defer o.statusMu.Unlock()
@@ -108,4 +215,3 @@ func (o *Order) SetStatus(status OrderStatus) {
 	defer o.statusMu.Unlock()
 	o.Status = status
 }
-
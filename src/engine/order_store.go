@@ -0,0 +1,67 @@
+package engine
+
+import "time"
+
+// RetentionPolicy bounds how many terminal orders each book keeps and for how long.
+// A zero value on either field means no limit on that dimension.
+type RetentionPolicy struct {
+	MaxOrders int
+	MaxAge    time.Duration
+}
+
+var DefaultRetentionPolicy = RetentionPolicy{MaxOrders: 10000}
+
+type retiredOrder struct {
+	order     *Order
+	retiredAt time.Time
+}
+
+// orderStore keeps FILLED, CANCELLED, REJECTED and EXPIRED orders with their final
+// state and fills after they leave the book, so clients can still reconcile them.
+// Oldest orders are evicted first. Guarded by the owning book's mutex.
+type orderStore struct {
+	policy RetentionPolicy
+	orders map[string]*retiredOrder
+	queue  []*retiredOrder // oldest first
+}
+
+func newOrderStore(policy RetentionPolicy) *orderStore {
+	return &orderStore{
+		policy: policy,
+		orders: make(map[string]*retiredOrder),
+	}
+}
+
+// add retains an order and returns the orders evicted to stay within the policy
+func (s *orderStore) add(order *Order, now time.Time) []*Order {
+	retired := &retiredOrder{order: order, retiredAt: now}
+	s.orders[order.ID] = retired
+	s.queue = append(s.queue, retired)
+
+	var evicted []*Order
+	for len(s.queue) > 0 {
+		oldest := s.queue[0]
+		overCount := s.policy.MaxOrders > 0 && len(s.queue) > s.policy.MaxOrders
+		if !overCount && !s.expired(oldest, now) {
+			break
+		}
+		delete(s.orders, oldest.order.ID)
+		s.queue[0] = nil
+		s.queue = s.queue[1:]
+		evicted = append(evicted, oldest.order)
+	}
+	return evicted
+}
+
+func (s *orderStore) get(orderID string, now time.Time) (*Order, bool) {
+	retired, exists := s.orders[orderID]
+	// edge case: books that go quiet only evict on the next add, so age is checked here too
+	if !exists || s.expired(retired, now) {
+		return nil, false
+	}
+	return retired.order, true
+}
+
+func (s *orderStore) expired(retired *retiredOrder, now time.Time) bool {
+	return s.policy.MaxAge > 0 && now.Sub(retired.retiredAt) > s.policy.MaxAge
+}
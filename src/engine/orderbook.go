@@ -1,7 +1,10 @@
 package engine
 
 import (
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/google/btree"
 )
@@ -29,15 +32,69 @@ type OrderBook struct {
 	Bids   *btree.BTree // sorted descending (highest first)
 	Asks   *btree.BTree // sorted ascending (lowest first)
 	Orders map[string]*Order
-	mu     sync.RWMutex
+
+	// pending stop orders, keyed by stop price and hidden from the book
+	BuyStops       *btree.BTree // sorted ascending (lowest stop triggers first)
+	SellStops      *btree.BTree // sorted descending (highest stop triggers first)
+	StopOrders     map[string]*Order
+	LastTradePrice int64 // atomic, 0 until the first trade
+
+	Sequence uint64 // atomic, last command sequence number applied by the sequencer
+
+	FeedSequence uint64 // atomic, last market data update published for the book
+
+	// orders that reached a terminal status, kept for lookups after they leave the book
+	terminal *orderStore
+
+	// recent trades in execution order, for history queries
+	trades *tradeStore
+
+	// orders evicted from the terminal store, drained by the sequencer to keep the
+	// matcher's order ID index in step with the book
+	forgotten []*Order
+
+	clock       Clock
+	commandTime time.Time    // time of the command being applied, zero between commands
+	tradeCount  int          // trades produced by the command being applied
+	allocations []Allocation // reused by matchAgainstBook for each round of fills
+
+	// price levels changed and trades executed since the last market data update, nil
+	// listener disables tracking
+	listener BookListener
+	touched  []LevelChange
+	traded   []*Trade
+
+	executions ExecutionListener // nil when nobody listens for execution reports
+
+	auction  bool           // orders accumulate without matching until the uncross
+	session  TradingSession // "" until the first session change, trades continuously
+	halt     *TradingHalt   // nil while the symbol trades, never modified once set
+	reopenAt int64          // unix nanoseconds the reopening auction uncrosses at, 0 for none
+
+	// rules the book's commands run under, set by the commands that journal them. nil
+	// when the matcher trades without a registry.
+	instrument *Instrument
+
+	mu sync.RWMutex
 }
 
 func NewOrderBook(symbol string) *OrderBook {
+	return newOrderBook(symbol, DefaultRetentionPolicy, DefaultTradeHistoryLimit, SystemClock)
+}
+
+func newOrderBook(symbol string, retention RetentionPolicy, tradeHistory int, clock Clock) *OrderBook {
 	return &OrderBook{
-		Symbol: symbol,
-		Bids:   btree.New(32),
-		Asks:   btree.New(32),
-		Orders: make(map[string]*Order),
+		Symbol:     symbol,
+		Bids:       btree.New(32),
+		Asks:       btree.New(32),
+		Orders:     make(map[string]*Order),
+		BuyStops:   btree.New(32),
+		SellStops:  btree.New(32),
+		StopOrders: make(map[string]*Order),
+
+		terminal: newOrderStore(retention),
+		trades:   newTradeStore(tradeHistory),
+		clock:    clock,
 	}
 }
 
@@ -45,7 +102,14 @@ func (ob *OrderBook) AddOrder(order *Order) {
 	ob.mu.Lock()
 	defer ob.mu.Unlock()
 
+	ob.addOrder(order)
+	ob.publishLevels(ob.status())
+}
+
+// must be called with ob.mu held
+func (ob *OrderBook) addOrder(order *Order) {
 	ob.Orders[order.ID] = order
+	order.refreshDisplay()
 
 	var tree *btree.BTree
 	var priceLevel *PriceLevel
@@ -61,12 +125,17 @@ func (ob *OrderBook) AddOrder(order *Order) {
 
 	existing := tree.Get(item)
 	if existing != nil {
+		order.topOrder = false
 		if order.Side == SideBuy {
 			priceLevel = existing.(*PriceLevelItem).PriceLevel
 		} else {
 			priceLevel = existing.(*PriceLevelItemAscending).PriceLevel
 		}
 	} else {
+		// edge case: the order that opens a new best price is the level's top order
+		best := ob.bestLevel(order.Side)
+		order.topOrder = best == nil || (order.Side == SideBuy && order.Price > best.Price) || (order.Side == SideSell && order.Price < best.Price)
+
 		priceLevel = &PriceLevel{
 			Price:  order.Price,
 			Orders: make([]*Order, 0),
@@ -80,12 +149,24 @@ func (ob *OrderBook) AddOrder(order *Order) {
 	}
 
 	priceLevel.Orders = append(priceLevel.Orders, order)
+	ob.touchLevel(order.Side, order.Price)
 }
 
 func (ob *OrderBook) RemoveOrder(orderID string) bool {
 	ob.mu.Lock()
 	defer ob.mu.Unlock()
 
+	removed := ob.removeOrder(orderID)
+	ob.publishLevels(ob.status())
+	return removed
+}
+
+// must be called with ob.mu held
+func (ob *OrderBook) removeOrder(orderID string) bool {
+	if _, exists := ob.StopOrders[orderID]; exists {
+		return ob.removeStopOrder(orderID)
+	}
+
 	order, exists := ob.Orders[orderID]
 	if !exists {
 		return false
@@ -126,6 +207,7 @@ func (ob *OrderBook) RemoveOrder(orderID string) bool {
 	if len(priceLevel.Orders) == 0 {
 		tree.Delete(item)
 	}
+	ob.touchLevel(order.Side, order.Price)
 
 	delete(ob.Orders, orderID)
 	return true
@@ -151,7 +233,7 @@ func (ob *OrderBook) GetBestBid() (price int64, quantity int64, ok bool) {
 
 	var totalQuantity int64
 	for _, order := range priceLevel.Orders {
-		totalQuantity += order.RemainingQuantity()
+		totalQuantity += order.VisibleQuantity()
 	}
 
 	return priceLevel.Price, totalQuantity, true
@@ -177,20 +259,235 @@ func (ob *OrderBook) GetBestAsk() (price int64, quantity int64, ok bool) {
 
 	var totalQuantity int64
 	for _, order := range priceLevel.Orders {
-		totalQuantity += order.RemainingQuantity()
+		totalQuantity += order.VisibleQuantity()
 	}
 
 	return priceLevel.Price, totalQuantity, true
 }
 
+// AvailableQuantity sums the resting quantity an incoming order on the given side
+// could execute against, stopping at limitPrice (0 means no price limit)
+func (ob *OrderBook) AvailableQuantity(side OrderSide, limitPrice int64) int64 {
+	ob.mu.RLock()
+	defer ob.mu.RUnlock()
+
+	return ob.availableQuantity(side, limitPrice)
+}
+
+// must be called with ob.mu held
+func (ob *OrderBook) availableQuantity(side OrderSide, limitPrice int64) int64 {
+	var totalAvailable int64
+	ob.ascendCrossing(side, limitPrice, func(priceLevel *PriceLevel) bool {
+		for _, o := range priceLevel.Orders {
+			totalAvailable += o.RemainingQuantity()
+		}
+		return true
+	})
+
+	return totalAvailable
+}
+
+// ascendCrossing calls fn with each level an incoming order on the given side could
+// execute against, best price first, until fn returns false or the next level is beyond
+// limitPrice (0 means no price limit). Must be called with ob.mu held.
+func (ob *OrderBook) ascendCrossing(side OrderSide, limitPrice int64, fn func(*PriceLevel) bool) {
+	levels := ob.Asks
+	if side == SideSell {
+		levels = ob.Bids
+	}
+	levels.Ascend(func(item btree.Item) bool {
+		priceLevel := priceLevelOf(item)
+		if limitPrice > 0 && ((side == SideBuy && priceLevel.Price > limitPrice) || (side == SideSell && priceLevel.Price < limitPrice)) {
+			return false
+		}
+		return fn(priceLevel)
+	})
+}
+
+// AmendOrder changes the price and/or total quantity of a resting order (0 keeps the
+// current value). A pure quantity reduction keeps the order's place in its price level;
+// any price change or quantity increase moves it to the back of the new level, which is
+// reported as requeued so the caller can check whether it now crosses the book.
+func (ob *OrderBook) AmendOrder(orderID string, newPrice, newQuantity int64) (order *Order, requeued bool, err error) {
+	ob.mu.Lock()
+	defer ob.mu.Unlock()
+
+	defer ob.publishLevels(ob.status())
+	return ob.amendOrder(orderID, newPrice, newQuantity)
+}
+
+// must be called with ob.mu held
+func (ob *OrderBook) amendOrder(orderID string, newPrice, newQuantity int64) (order *Order, requeued bool, err error) {
+	order, exists := ob.Orders[orderID]
+	if !exists {
+		if _, isStop := ob.StopOrders[orderID]; isStop {
+			return nil, false, &InvalidAmendError{Message: "Cannot amend: pending stop orders cannot be amended"}
+		}
+		if done, isDone := ob.terminal.get(orderID, ob.now()); isDone {
+			return nil, false, &InvalidAmendError{Message: "Cannot amend: order already " + strings.ToLower(string(done.GetStatus()))}
+		}
+		return nil, false, &OrderNotFoundError{OrderID: orderID}
+	}
+
+	if newPrice == 0 {
+		newPrice = order.Price
+	}
+	if newQuantity == 0 {
+		newQuantity = order.Quantity
+	}
+
+	// edge case: the new total must leave something open
+	if newQuantity <= order.GetFilledQuantity() {
+		return nil, false, &InvalidAmendError{Message: "Cannot amend: quantity must be greater than filled quantity"}
+	}
+
+	if newPrice == order.Price && newQuantity <= order.Quantity {
+		order.Quantity = newQuantity
+		ob.touchLevel(order.Side, order.Price)
+		return order, false, nil
+	}
+
+	ob.removeOrder(orderID)
+	order.Price = newPrice
+	order.Quantity = newQuantity
+	ob.addOrder(order)
+
+	return order, true, nil
+}
+
+// must be called with ob.mu held
+func (ob *OrderBook) cancelOrder(orderID string) (*Order, error) {
+	// edge case: orders that already reached a terminal status cannot be cancelled
+	if done, isDone := ob.terminal.get(orderID, ob.now()); isDone {
+		return nil, &OrderNotCancellableError{OrderID: orderID, Status: done.GetStatus()}
+	}
+
+	order, exists := ob.Orders[orderID]
+	if !exists {
+		order, exists = ob.StopOrders[orderID]
+	}
+	if !exists {
+		return nil, &OrderNotFoundError{OrderID: orderID}
+	}
+
+	order.SetStatus(StatusCancelled)
+	ob.retireOrder(order)
+	return order, nil
+}
+
+// retireOrder takes an order that reached a terminal status off the book, if it was
+// resting, and keeps it in the terminal store until the retention policy evicts it.
+// Must be called with ob.mu held.
+func (ob *OrderBook) retireOrder(order *Order) {
+	ob.removeOrder(order.ID)
+	ob.reportDone(order)
+
+	for _, evicted := range ob.terminal.add(order, ob.now()) {
+		ob.forgetOrder(evicted)
+	}
+}
+
+// now is the time of the command being applied, or the clock between commands.
+// Must be called with ob.mu held.
+func (ob *OrderBook) now() time.Time {
+	if ob.commandTime.IsZero() {
+		return ob.clock.Now()
+	}
+	return ob.commandTime
+}
+
+// must be called with ob.mu held
+func (ob *OrderBook) isResting(orderID string) bool {
+	_, exists := ob.Orders[orderID]
+	return exists
+}
+
+// hasOrders reports whether any order rests or waits for its stop. Must be called with
+// ob.mu held.
+func (ob *OrderBook) hasOrders() bool {
+	return len(ob.Orders) > 0 || len(ob.StopOrders) > 0
+}
+
+// must be called with ob.mu held
+func (ob *OrderBook) forgetOrder(order *Order) {
+	ob.forgotten = append(ob.forgotten, order)
+}
+
+// must be called with ob.mu held
+func (ob *OrderBook) takeForgotten() []*Order {
+	forgotten := ob.forgotten
+	ob.forgotten = nil
+	return forgotten
+}
+
+// bestLevel returns the best price level on one side of the book, or nil if it is empty.
+// Must be called with ob.mu held.
+func (ob *OrderBook) bestLevel(side OrderSide) *PriceLevel {
+	var item btree.Item
+	if side == SideBuy {
+		item = ob.Bids.Min()
+	} else {
+		item = ob.Asks.Min()
+	}
+	if item == nil {
+		return nil
+	}
+	return priceLevelOf(item)
+}
+
+// settleFill retires a resting order that a fill completed, or moves an iceberg whose
+// slice ran out to the back of its level. Must be called with ob.mu held.
+func (ob *OrderBook) settleFill(priceLevel *PriceLevel, order *Order, quantity int64) {
+	if order.IsFilled() {
+		// edge case: removing the last order also removes the empty price level
+		ob.retireOrder(order)
+	} else if order.consumeDisplay(quantity) {
+		requeue(priceLevel, order)
+	}
+}
+
+// requeue moves an order of a price level to the back, used when a replenished iceberg
+// gives up time priority for its new slice. Must be called with ob.mu held.
+func requeue(priceLevel *PriceLevel, order *Order) {
+	for i, o := range priceLevel.Orders {
+		if o == order {
+			copy(priceLevel.Orders[i:], priceLevel.Orders[i+1:])
+			priceLevel.Orders[len(priceLevel.Orders)-1] = order
+			return
+		}
+	}
+}
+
 func (ob *OrderBook) GetOrder(orderID string) (*Order, bool) {
 	ob.mu.RLock()
 	defer ob.mu.RUnlock()
 
 	order, exists := ob.Orders[orderID]
+	if !exists {
+		order, exists = ob.StopOrders[orderID]
+	}
 	return order, exists
 }
 
+// GetTerminalOrder looks up an order that is FILLED, CANCELLED, REJECTED or EXPIRED
+// and still within the book's retention policy
+func (ob *OrderBook) GetTerminalOrder(orderID string) (*Order, bool) {
+	ob.mu.RLock()
+	defer ob.mu.RUnlock()
+
+	return ob.terminal.get(orderID, ob.now())
+}
+
+// Trades returns a page of the book's trade history, oldest first, and the cursor for
+// the next page (0 when there are no more trades). A cursor pointing at evicted trades
+// gets a CursorExpiredError.
+func (ob *OrderBook) Trades(q TradeQuery) ([]*Trade, uint64, error) {
+	ob.mu.RLock()
+	defer ob.mu.RUnlock()
+
+	return ob.trades.query(q)
+}
+
 type OrderBookSnapshot struct {
 	Price    int64
 	Quantity int64
@@ -200,6 +497,11 @@ func (ob *OrderBook) GetOrderBookSnapshot(depth int) (bids []OrderBookSnapshot,
 	ob.mu.RLock()
 	defer ob.mu.RUnlock()
 
+	return ob.getOrderBookSnapshot(depth)
+}
+
+// must be called with ob.mu held
+func (ob *OrderBook) getOrderBookSnapshot(depth int) (bids []OrderBookSnapshot, asks []OrderBookSnapshot) {
 	bids = make([]OrderBookSnapshot, 0, depth)
 	asks = make([]OrderBookSnapshot, 0, depth)
 
@@ -211,7 +513,7 @@ func (ob *OrderBook) GetOrderBookSnapshot(depth int) (bids []OrderBookSnapshot,
 		priceLevel := item.(*PriceLevelItem).PriceLevel
 		var totalQuantity int64
 		for _, order := range priceLevel.Orders {
-			totalQuantity += order.RemainingQuantity()
+			totalQuantity += order.VisibleQuantity()
 		}
 		bids = append(bids, OrderBookSnapshot{
 			Price:    priceLevel.Price,
@@ -229,7 +531,7 @@ func (ob *OrderBook) GetOrderBookSnapshot(depth int) (bids []OrderBookSnapshot,
 		priceLevel := item.(*PriceLevelItemAscending).PriceLevel
 		var totalQuantity int64
 		for _, order := range priceLevel.Orders {
-			totalQuantity += order.RemainingQuantity()
+			totalQuantity += order.VisibleQuantity()
 		}
 		asks = append(asks, OrderBookSnapshot{
 			Price:    priceLevel.Price,
@@ -264,3 +566,149 @@ func (ob *OrderBook) GetPriceLevelForAsk(price int64) *PriceLevel {
 	return item.(*PriceLevelItemAscending).PriceLevel
 }
 
+func (ob *OrderBook) AddStopOrder(order *Order) {
+	ob.mu.Lock()
+	defer ob.mu.Unlock()
+
+	ob.addStopOrder(order)
+}
+
+// must be called with ob.mu held
+func (ob *OrderBook) addStopOrder(order *Order) {
+	ob.StopOrders[order.ID] = order
+
+	var tree *btree.BTree
+	var item btree.Item
+	if order.Side == SideBuy {
+		tree = ob.BuyStops
+		item = &PriceLevelItemAscending{PriceLevel: &PriceLevel{Price: order.StopPrice}}
+	} else {
+		tree = ob.SellStops
+		item = &PriceLevelItem{PriceLevel: &PriceLevel{Price: order.StopPrice}}
+	}
+
+	var priceLevel *PriceLevel
+	if existing := tree.Get(item); existing != nil {
+		priceLevel = priceLevelOf(existing)
+	} else {
+		priceLevel = &PriceLevel{
+			Price:  order.StopPrice,
+			Orders: make([]*Order, 0),
+		}
+		if order.Side == SideBuy {
+			item = &PriceLevelItemAscending{PriceLevel: priceLevel}
+		} else {
+			item = &PriceLevelItem{PriceLevel: priceLevel}
+		}
+		tree.ReplaceOrInsert(item)
+	}
+
+	priceLevel.Orders = append(priceLevel.Orders, order)
+}
+
+// must be called with ob.mu held
+func (ob *OrderBook) removeStopOrder(orderID string) bool {
+	order := ob.StopOrders[orderID]
+
+	var tree *btree.BTree
+	var item btree.Item
+	if order.Side == SideBuy {
+		tree = ob.BuyStops
+		item = &PriceLevelItemAscending{PriceLevel: &PriceLevel{Price: order.StopPrice}}
+	} else {
+		tree = ob.SellStops
+		item = &PriceLevelItem{PriceLevel: &PriceLevel{Price: order.StopPrice}}
+	}
+
+	delete(ob.StopOrders, orderID)
+
+	existing := tree.Get(item)
+	if existing == nil {
+		return false
+	}
+
+	priceLevel := priceLevelOf(existing)
+	for i, o := range priceLevel.Orders {
+		if o.ID == orderID {
+			priceLevel.Orders = append(priceLevel.Orders[:i], priceLevel.Orders[i+1:]...)
+			break
+		}
+	}
+
+	if len(priceLevel.Orders) == 0 {
+		tree.Delete(item)
+	}
+	return true
+}
+
+// TakeTriggeredStops removes and returns the stop orders triggered by trades between
+// low and high, in stop price order and FIFO within a stop price
+func (ob *OrderBook) TakeTriggeredStops(low, high int64) []*Order {
+	ob.mu.Lock()
+	defer ob.mu.Unlock()
+
+	return ob.takeTriggeredStops(low, high)
+}
+
+// must be called with ob.mu held
+func (ob *OrderBook) takeTriggeredStops(low, high int64) []*Order {
+	if len(ob.StopOrders) == 0 {
+		return nil
+	}
+
+	var triggered []*Order
+	var emptied []btree.Item
+
+	ob.BuyStops.Ascend(func(item btree.Item) bool {
+		priceLevel := item.(*PriceLevelItemAscending).PriceLevel
+		if priceLevel.Price > high {
+			return false
+		}
+		triggered = append(triggered, priceLevel.Orders...)
+		emptied = append(emptied, item)
+		return true
+	})
+	for _, item := range emptied {
+		ob.BuyStops.Delete(item)
+	}
+
+	emptied = emptied[:0]
+	ob.SellStops.Ascend(func(item btree.Item) bool {
+		priceLevel := item.(*PriceLevelItem).PriceLevel
+		if priceLevel.Price < low {
+			return false
+		}
+		triggered = append(triggered, priceLevel.Orders...)
+		emptied = append(emptied, item)
+		return true
+	})
+	for _, item := range emptied {
+		ob.SellStops.Delete(item)
+	}
+
+	for _, order := range triggered {
+		delete(ob.StopOrders, order.ID)
+	}
+
+	return triggered
+}
+
+// LastSequence returns the sequence number of the last command applied to the book
+func (ob *OrderBook) LastSequence() uint64 {
+	return atomic.LoadUint64(&ob.Sequence)
+}
+
+func (ob *OrderBook) GetLastTradePrice() int64 {
+	return atomic.LoadInt64(&ob.LastTradePrice)
+}
+
+func (ob *OrderBook) SetLastTradePrice(price int64) {
+	atomic.StoreInt64(&ob.LastTradePrice, price)
+}
+
+func priceLevelOf(item btree.Item) *PriceLevel {
+	if ascending, ok := item.(*PriceLevelItemAscending); ok {
+		return ascending.PriceLevel
+	}
+	return item.(*PriceLevelItem).PriceLevel
+}
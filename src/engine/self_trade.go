@@ -0,0 +1,131 @@
+package engine
+
+// SelfTradePrevention says what happens when an incoming order would trade against a
+// resting order of the same account. The incoming order's mode decides.
+type SelfTradePrevention string
+
+const (
+	STPNone               SelfTradePrevention = "NONE"                 // trade anyway, overrides an account default
+	STPCancelNewest       SelfTradePrevention = "CANCEL_NEWEST"        // cancel the rest of the incoming order
+	STPCancelOldest       SelfTradePrevention = "CANCEL_OLDEST"        // cancel the resting order and keep matching
+	STPCancelBoth         SelfTradePrevention = "CANCEL_BOTH"          // cancel both orders
+	STPDecrementAndCancel SelfTradePrevention = "DECREMENT_AND_CANCEL" // take the smaller quantity off both, cancelling the order it uses up
+)
+
+// IsValid reports whether mode is one of the modes above, or "" for the account default
+func (mode SelfTradePrevention) IsValid() bool {
+	switch mode {
+	case "", STPNone, STPCancelNewest, STPCancelOldest, STPCancelBoth, STPDecrementAndCancel:
+		return true
+	}
+	return false
+}
+
+// PreventedMatch is a trade self-trade prevention stopped
+type PreventedMatch struct {
+	Mode              SelfTradePrevention
+	IncomingOrderID   string
+	RestingOrderID    string
+	Account           string
+	Price             int64 // price of the resting order
+	Quantity          int64 // smaller of the two remaining quantities
+	IncomingCancelled bool
+	RestingCancelled  bool
+}
+
+// WithAccountSelfTradePrevention sets the mode of each account's orders that do not
+// choose one themselves
+func WithAccountSelfTradePrevention(modes map[string]SelfTradePrevention) MatcherOption {
+	return func(m *Matcher) {
+		m.accountSTP = modes
+	}
+}
+
+// selfTradeMode is the mode an order is matched with once its account default applies
+func (m *Matcher) selfTradeMode(order *Order) SelfTradePrevention {
+	if order.SelfTradePrevention != "" || order.Account == "" {
+		return order.SelfTradePrevention
+	}
+	return m.accountSTP[order.Account]
+}
+
+// preventSelfTrade applies the incoming order's mode to a match against one of its
+// account's resting orders. Must be called with orderBook.mu held.
+func (m *Matcher) preventSelfTrade(order, resting *Order, orderBook *OrderBook) *PreventedMatch {
+	quantity := order.RemainingQuantity()
+	if restingQuantity := resting.RemainingQuantity(); restingQuantity < quantity {
+		quantity = restingQuantity
+	}
+
+	prevented := &PreventedMatch{
+		Mode:            order.SelfTradePrevention,
+		IncomingOrderID: order.ID,
+		RestingOrderID:  resting.ID,
+		Account:         order.Account,
+		Price:           resting.Price,
+		Quantity:        quantity,
+	}
+
+	switch order.SelfTradePrevention {
+	case STPCancelNewest:
+		prevented.IncomingCancelled = true
+	case STPCancelOldest:
+		prevented.RestingCancelled = true
+	case STPCancelBoth:
+		prevented.IncomingCancelled = true
+		prevented.RestingCancelled = true
+	case STPDecrementAndCancel:
+		// edge case: equal quantities cancel both orders
+		prevented.IncomingCancelled = order.RemainingQuantity() == quantity
+		prevented.RestingCancelled = resting.RemainingQuantity() == quantity
+		if !prevented.IncomingCancelled {
+			order.Quantity -= quantity
+		}
+		if !prevented.RestingCancelled {
+			resting.Quantity -= quantity
+			orderBook.touchLevel(resting.Side, resting.Price)
+		}
+	}
+
+	if prevented.RestingCancelled {
+		resting.selfTrade = true
+		resting.SetStatus(StatusCancelled)
+		orderBook.retireOrder(resting)
+	}
+	if prevented.IncomingCancelled {
+		order.selfTrade = true
+		order.SetStatus(StatusCancelled)
+	}
+	return prevented
+}
+
+// fillableQuantity is how much of a fill-or-kill order the book fills within its limit
+// after self-trade prevention: the account's own orders never trade with it. CANCEL_OLDEST
+// cancels them and matching goes on, every other mode cancels or shrinks the order at the
+// first level holding one, so only the levels before it count. Must be called with
+// orderBook.mu held.
+func (ob *OrderBook) fillableQuantity(order *Order) int64 {
+	if order.Account == "" || order.SelfTradePrevention == "" || order.SelfTradePrevention == STPNone {
+		return ob.availableQuantity(order.Side, order.Price)
+	}
+
+	var fillable int64
+	ob.ascendCrossing(order.Side, order.Price, func(priceLevel *PriceLevel) bool {
+		var levelQuantity int64
+		for _, o := range priceLevel.Orders {
+			if o.Account != order.Account {
+				levelQuantity += o.RemainingQuantity()
+			} else if order.SelfTradePrevention != STPCancelOldest {
+				return false
+			}
+		}
+		fillable += levelQuantity
+		return true
+	})
+	return fillable
+}
+
+// SelfTradeCancelled reports whether self-trade prevention cancelled the order
+func (o *Order) SelfTradeCancelled() bool {
+	return o.selfTrade
+}
@@ -0,0 +1,295 @@
+package engine
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// size of each symbol's inbound command queue; submitters block once it is full
+const commandQueueSize = 4096
+
+// replies reuses the reply channels of finished commands, so a submit does not allocate one
+var replies = sync.Pool{
+	New: func() any { return make(chan commandReply, 1) },
+}
+
+// command is one request queued for a symbol's sequencer. The caller blocks on reply,
+// which is buffered so the sequencer never waits for a slow reader.
+type command struct {
+	Command                  // durable part, written to the command log
+	order   *Order           // submit
+	update  instrumentUpdate // instrument change, made under the book's lock
+	replay  bool             // recovered from the command log, already durable and sequenced
+	reply   chan commandReply
+}
+
+// instrumentUpdate changes a book's instrument. It calls journal with the new rules before
+// it stores them, and stores nothing if journal fails.
+type instrumentUpdate func(orderBook *OrderBook, journal func(*Instrument) error) error
+
+type commandReply struct {
+	result  *MatchResult
+	uncross *UncrossResult
+	session *SessionChange
+	order   *Order
+	seq     uint64
+	err     error
+}
+
+// sequencer is the single writer for one symbol's order book. Commands are processed
+// strictly in arrival order and each one is stamped with the book's next sequence number.
+// Commands are only taken off the queue by the holder of the book's write lock, which is
+// the sequencer goroutine or a caller that found the lock free, so whoever applies them
+// applies them in the order they were queued.
+type sequencer struct {
+	matcher   *Matcher
+	orderBook *OrderBook
+	commands  chan *command
+	wake      chan struct{} // queued commands are waiting for the sequencer goroutine
+	done      chan struct{}
+}
+
+func newSequencer(matcher *Matcher, orderBook *OrderBook) *sequencer {
+	s := &sequencer{
+		matcher:   matcher,
+		orderBook: orderBook,
+		commands:  make(chan *command, commandQueueSize),
+		wake:      make(chan struct{}, 1),
+		done:      make(chan struct{}),
+	}
+	go s.run()
+	return s
+}
+
+func (s *sequencer) run() {
+	defer close(s.done)
+
+	for range s.wake {
+		s.orderBook.mu.Lock()
+		s.process(nil)
+		s.orderBook.mu.Unlock()
+	}
+}
+
+// submit enqueues a command and waits for its reply. A caller that finds the book's write
+// lock free applies the queued commands itself up to its own, which saves two goroutine
+// switches. The sequencer goroutine applies the rest.
+func (s *sequencer) submit(cmd *command) commandReply {
+	cmd.Symbol = s.orderBook.Symbol
+	cmd.reply = replies.Get().(chan commandReply)
+	s.commands <- cmd
+	if s.orderBook.mu.TryLock() {
+		s.process(cmd)
+		s.orderBook.mu.Unlock()
+	}
+	// edge case: the commands queued behind this one, or this one when another writer or a
+	// reader held the lock, are left to the sequencer goroutine
+	if len(s.commands) > 0 {
+		select {
+		case s.wake <- struct{}{}:
+		default:
+		}
+	}
+	reply := <-cmd.reply
+	replies.Put(cmd.reply)
+	cmd.reply = nil
+	return reply
+}
+
+func (s *sequencer) stop() {
+	close(s.wake)
+	<-s.done
+}
+
+// process applies queued commands in arrival order until the queue is empty or last has
+// been applied. The write lock is held so that the queue has one reader and readers
+// (snapshots, status lookups) never observe a half-applied command. Must be called with
+// orderBook.mu held.
+func (s *sequencer) process(last *command) {
+	for {
+		select {
+		case cmd := <-s.commands:
+			cmd.reply <- s.apply(cmd)
+			if cmd == last {
+				return
+			}
+		default:
+			return
+		}
+	}
+}
+
+// apply runs one command. Must be called with orderBook.mu held.
+func (s *sequencer) apply(cmd *command) commandReply {
+	orderBook := s.orderBook
+	seq := orderBook.LastSequence() + 1
+	var updateErr error
+	if cmd.replay {
+		// edge case: commands already covered by the book's state are skipped
+		if cmd.Sequence < seq {
+			return commandReply{seq: cmd.Sequence}
+		}
+		if cmd.Sequence > seq {
+			return commandReply{err: &SequenceGapError{Symbol: orderBook.Symbol, Expected: seq, Got: cmd.Sequence}}
+		}
+		// edge case: journals written before commands were timestamped fall back to the clock
+		if cmd.Timestamp == 0 {
+			cmd.Timestamp = s.matcher.clock.Now().UnixNano()
+		}
+		if cmd.Instrument != nil {
+			if err := cmd.Instrument.Validate(); err != nil {
+				return commandReply{err: &InvalidCommandError{Sequence: cmd.Sequence, Message: err.Error()}}
+			}
+		} else if orderBook.instrument == nil {
+			// edge case: journals written before the rules were journaled take the registry's
+			cmd.Instrument = s.matcher.listedInstrument(orderBook.Symbol)
+		}
+	} else {
+		cmd.Sequence = seq
+		cmd.Timestamp = s.matcher.clock.Now().UnixNano()
+		if cmd.Kind == CommandSubmit {
+			cmd.order.Timestamp = cmd.Timestamp
+		}
+		// rules that changed since the book's last command are journaled with this one, so
+		// a replay runs every command under the rules the live book used
+		cmd.Instrument = s.matcher.changedInstrument(orderBook)
+
+		if cmd.update != nil {
+			journaled := false
+			updateErr = cmd.update(orderBook, func(instrument *Instrument) error {
+				cmd.Instrument = instrument
+				if err := s.journal(cmd); err != nil {
+					return err
+				}
+				journaled = true
+				return nil
+			})
+			// edge case: rules that were journaled but could not be stored still take
+			// effect, so the book matches its journal. The next command journals the
+			// registry's rules again.
+			if updateErr != nil && !journaled {
+				return commandReply{err: updateErr}
+			}
+		} else if err := s.journal(cmd); err != nil {
+			return commandReply{err: err}
+		}
+	}
+	atomic.StoreUint64(&orderBook.Sequence, seq)
+	previous := orderBook.instrument
+	if cmd.Instrument != nil {
+		orderBook.instrument = cmd.Instrument
+	}
+
+	// trades and retirements inside the command use its journaled time, so a replay
+	// produces the same trade IDs, timestamps and retention decisions
+	orderBook.commandTime = time.Unix(0, cmd.Timestamp)
+	orderBook.tradeCount = 0
+
+	status := orderBook.status()
+	rescheduled := s.matcher.reschedule(orderBook, previous, cmd.Timestamp)
+	defer func() {
+		orderBook.publishLevels(status)
+		orderBook.commandTime = time.Time{}
+		if forgotten := orderBook.takeForgotten(); len(forgotten) > 0 {
+			s.matcher.unindexOrders(forgotten)
+		}
+	}()
+
+	switch cmd.Kind {
+	case CommandSubmit:
+		s.matcher.indexOrder(cmd.order.ID, orderBook.Symbol)
+		result, err := s.matcher.matchOrder(cmd.order, orderBook)
+		if result != nil {
+			result.Sequence = seq
+		}
+		return commandReply{result: result, order: cmd.order, seq: seq, err: err}
+
+	case CommandAmend:
+		result, err := s.matcher.amendOrder(orderBook, cmd.OrderID, cmd.Price, cmd.Quantity)
+		if result != nil {
+			result.Sequence = seq
+		}
+		return commandReply{result: result, seq: seq, err: err}
+
+	case CommandCancel:
+		if err := orderBook.checkHalt(CommandCancel); err != nil {
+			return commandReply{seq: seq, err: err}
+		}
+		if err := orderBook.checkSession(CommandCancel); err != nil {
+			return commandReply{seq: seq, err: err}
+		}
+		order, err := orderBook.cancelOrder(cmd.OrderID)
+		return commandReply{order: order, seq: seq, err: err}
+
+	case CommandAuction:
+		if err := orderBook.checkHalt(CommandAuction); err != nil {
+			return commandReply{seq: seq, err: err}
+		}
+		if err := orderBook.checkSession(CommandAuction); err != nil {
+			return commandReply{seq: seq, err: err}
+		}
+		return commandReply{seq: seq, err: orderBook.startAuction()}
+
+	case CommandUncross:
+		if err := orderBook.checkHalt(CommandUncross); err != nil {
+			return commandReply{seq: seq, err: err}
+		}
+		if err := orderBook.checkSession(CommandUncross); err != nil {
+			return commandReply{seq: seq, err: err}
+		}
+		result, err := s.matcher.uncross(orderBook)
+		if result != nil {
+			result.Sequence = seq
+		}
+		return commandReply{uncross: result, seq: seq, err: err}
+
+	case CommandSession:
+		change := s.matcher.changeSession(orderBook, cmd.Session)
+		change.Sequence = seq
+		if change.Uncross != nil {
+			change.Uncross.Sequence = seq
+		}
+		return commandReply{session: change, seq: seq}
+
+	case CommandInstrument:
+		if rescheduled != nil {
+			rescheduled.Sequence = seq
+			if rescheduled.Uncross != nil {
+				rescheduled.Uncross.Sequence = seq
+			}
+		}
+		return commandReply{session: rescheduled, seq: seq, err: updateErr}
+
+	case CommandHalt:
+		return commandReply{seq: seq, err: orderBook.haltTrading(&cmd.Command)}
+
+	case CommandResume:
+		return commandReply{seq: seq, err: orderBook.resumeTrading(&cmd.Command)}
+
+	case CommandReopen:
+		result := s.matcher.reopen(orderBook, &cmd.Command)
+		if result != nil {
+			result.Sequence = seq
+		}
+		return commandReply{uncross: result, seq: seq}
+	}
+
+	return commandReply{seq: seq}
+}
+
+// journal writes a live command to the command log, if there is one. Write-ahead: the
+// command is durable before it changes the book or is acknowledged.
+func (s *sequencer) journal(cmd *command) error {
+	commandLog := s.matcher.commandLog
+	if commandLog == nil {
+		return nil
+	}
+	if cmd.Kind == CommandSubmit {
+		cmd.Order = newOrderRecord(cmd.order)
+	}
+	if err := commandLog.Append(&cmd.Command); err != nil {
+		return &CommandLogError{Err: err}
+	}
+	return nil
+}
@@ -0,0 +1,303 @@
+package engine
+
+import (
+	"time"
+)
+
+// TradingSession is the phase of its trading day a symbol is in. It decides which
+// commands the symbol's book accepts.
+type TradingSession string
+
+const (
+	SessionPreOpen        TradingSession = "PRE_OPEN"        // cancels only
+	SessionOpeningAuction TradingSession = "OPENING_AUCTION" // orders collect for the opening uncross
+	SessionContinuous     TradingSession = "CONTINUOUS"      // everything, the default without a schedule
+	SessionClosingAuction TradingSession = "CLOSING_AUCTION" // orders collect for the closing uncross
+	SessionClosed         TradingSession = "CLOSED"          // nothing
+)
+
+// sessions in the order a trading day goes through them
+var sessions = [...]TradingSession{SessionPreOpen, SessionOpeningAuction, SessionContinuous, SessionClosingAuction, SessionClosed}
+
+func (s TradingSession) IsValid() bool {
+	for _, session := range sessions {
+		if s == session {
+			return true
+		}
+	}
+	return false
+}
+
+func (s TradingSession) isAuction() bool {
+	return s == SessionOpeningAuction || s == SessionClosingAuction
+}
+
+// TradingSchedule is an instrument's trading day. Each time is when its session starts,
+// "HH:MM" on the clock of TimeZone, and an empty one skips the session. The day is CLOSED
+// from Close until the first session of the next day. Every day is a trading day.
+type TradingSchedule struct {
+	TimeZone       string `json:"time_zone,omitempty"` // IANA name, defaults to UTC
+	PreOpen        string `json:"pre_open,omitempty"`
+	OpeningAuction string `json:"opening_auction,omitempty"`
+	Continuous     string `json:"continuous"`
+	ClosingAuction string `json:"closing_auction,omitempty"`
+	Close          string `json:"close"`
+
+	location *time.Location
+	starts   [len(sessions)]time.Duration // since midnight, -1 for a skipped session
+}
+
+// times are the schedule's settings as given
+func (s *TradingSchedule) times() [6]string {
+	return [...]string{s.TimeZone, s.PreOpen, s.OpeningAuction, s.Continuous, s.ClosingAuction, s.Close}
+}
+
+// validate checks the schedule and parses its times
+func (s *TradingSchedule) validate() error {
+	location, err := time.LoadLocation(s.TimeZone)
+	if err != nil {
+		return &InvalidInstrumentError{Message: "schedule time_zone " + s.TimeZone + " is unknown"}
+	}
+	if s.Continuous == "" || s.Close == "" {
+		return &InvalidInstrumentError{Message: "schedule needs continuous and close times"}
+	}
+
+	var starts [len(sessions)]time.Duration
+	last := time.Duration(-1)
+	for i, start := range [...]string{s.PreOpen, s.OpeningAuction, s.Continuous, s.ClosingAuction, s.Close} {
+		if start == "" {
+			starts[i] = -1
+			continue
+		}
+		t, err := time.Parse("15:04", start)
+		if err != nil {
+			return &InvalidInstrumentError{Message: "schedule times must be HH:MM"}
+		}
+		starts[i] = time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+		if starts[i] <= last {
+			return &InvalidInstrumentError{Message: "schedule times must follow each other: pre_open, opening_auction, continuous, closing_auction, close"}
+		}
+		last = starts[i]
+	}
+
+	s.location = location
+	s.starts = starts
+	return nil
+}
+
+// sessionAt is the session the schedule is in at now, CONTINUOUS without a schedule
+func (s *TradingSchedule) sessionAt(now time.Time) TradingSession {
+	if s == nil {
+		return SessionContinuous
+	}
+	// edge case: read off the local clock, so a daylight saving change moves the
+	// sessions with it
+	local := now.In(s.location)
+	timeOfDay := time.Duration(local.Hour())*time.Hour + time.Duration(local.Minute())*time.Minute +
+		time.Duration(local.Second())*time.Second
+
+	session := SessionClosed
+	for i, start := range s.starts {
+		if start >= 0 && timeOfDay >= start {
+			session = sessions[i]
+		}
+	}
+	return session
+}
+
+// SessionChange is a symbol moving from one session to the next
+type SessionChange struct {
+	Symbol   string
+	From     TradingSession
+	To       TradingSession
+	Uncross  *UncrossResult // the auction the change ended, nil if it ended none
+	Sequence uint64         // per-symbol command sequence number
+}
+
+// SetSession moves a symbol to a session. Entering an auction session starts an
+// auction, and entering CONTINUOUS, PRE_OPEN or CLOSED ends a running one with an
+// uncross. It runs as a command on the symbol's sequencer.
+func (m *Matcher) SetSession(symbol string, session TradingSession) (*SessionChange, error) {
+	if !session.IsValid() {
+		return nil, &InvalidSessionError{Session: session}
+	}
+	if err := m.checkListed(symbol); err != nil {
+		return nil, err
+	}
+	reply := m.getOrCreateSequencer(symbol).submit(&command{
+		Command: Command{Kind: CommandSession, Session: session},
+	})
+	return reply.session, reply.err
+}
+
+// UpdateSessions moves every listed symbol to the session its schedule says it is in on
+// the engine clock, and returns the changes it made. Symbols without a schedule trade
+// continuously.
+func (m *Matcher) UpdateSessions() ([]*SessionChange, error) {
+	if m.instruments == nil {
+		return nil, nil
+	}
+
+	var changes []*SessionChange
+	for _, instrument := range m.instruments.List() {
+		// edge case: no book to keep closed before the first order, which syncs it
+		if _, exists := m.GetOrderBook(instrument.Symbol); !exists {
+			continue
+		}
+		change, err := m.syncSession(&instrument)
+		if err != nil {
+			return changes, err
+		}
+		if change != nil {
+			changes = append(changes, change)
+		}
+	}
+	return changes, nil
+}
+
+// syncSession moves the instrument's book to the session of its schedule if it is not
+// there yet, so a command arriving after a session boundary never sees the previous
+// session. Returns nil when the book was already there.
+func (m *Matcher) syncSession(instrument *Instrument) (*SessionChange, error) {
+	session := instrument.Schedule.sessionAt(m.clock.Now())
+	if m.getOrCreateSequencer(instrument.Symbol).orderBook.Session() == session {
+		return nil, nil
+	}
+	return m.SetSession(instrument.Symbol, session)
+}
+
+// syncSymbolSession is syncSession for a symbol's instrument, if it has a schedule
+func (m *Matcher) syncSymbolSession(symbol string) error {
+	if m.instruments == nil {
+		return nil
+	}
+	instrument, listed := m.instruments.Get(symbol)
+	if !listed || instrument.Schedule == nil {
+		return nil
+	}
+	_, err := m.syncSession(&instrument)
+	return err
+}
+
+// reschedule moves the book to the session its new schedule is in at the command's time,
+// when the command changed the schedule, instead of waiting for the next boundary. Without
+// a schedule the book trades continuously. Returns nil when the session stays. Must be
+// called with orderBook.mu held.
+func (m *Matcher) reschedule(orderBook *OrderBook, previous *Instrument, at int64) *SessionChange {
+	// edge case: a book's first rules are not a change, its sessions are already journaled
+	if previous == nil || orderBook.instrument == previous || sameSchedule(previous.Schedule, orderBook.instrument.Schedule) {
+		return nil
+	}
+	session := SessionContinuous
+	if schedule := orderBook.instrument.Schedule; schedule != nil {
+		session = schedule.sessionAt(time.Unix(0, at))
+	}
+	if session == orderBook.tradingSession() {
+		return nil
+	}
+	return m.changeSession(orderBook, session)
+}
+
+// sameSchedule reports whether two schedules have the same sessions, nil for none
+func sameSchedule(a, b *TradingSchedule) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.times() == b.times()
+}
+
+// Session is the trading session the book is in
+func (ob *OrderBook) Session() TradingSession {
+	ob.mu.RLock()
+	defer ob.mu.RUnlock()
+
+	return ob.tradingSession()
+}
+
+// must be called with ob.mu held
+func (ob *OrderBook) tradingSession() TradingSession {
+	if ob.session == "" {
+		return SessionContinuous
+	}
+	return ob.session
+}
+
+// checkSession refuses a command the book's session does not allow. Must be called
+// with ob.mu held.
+func (ob *OrderBook) checkSession(kind CommandKind) error {
+	session := ob.tradingSession()
+	switch session {
+	case SessionPreOpen:
+		if kind == CommandCancel {
+			return nil
+		}
+	case SessionClosed:
+		// edge case: not even a cancel, the book is frozen until the next session
+	case SessionOpeningAuction, SessionClosingAuction:
+		// edge case: the session's end uncrosses its auction
+		if kind != CommandUncross {
+			return nil
+		}
+	default:
+		return nil
+	}
+	return &SessionError{Symbol: ob.Symbol, Session: session, Kind: kind}
+}
+
+// changeSession moves the book to session. Must be called with orderBook.mu held.
+func (m *Matcher) changeSession(orderBook *OrderBook, session TradingSession) *SessionChange {
+	change := &SessionChange{
+		Symbol: orderBook.Symbol,
+		From:   orderBook.tradingSession(),
+		To:     session,
+	}
+	// edge case: an auction ends with its session, whether the schedule or an admin
+	// started it. A halted book keeps it for the resume to reopen through.
+	if orderBook.auction && !session.isAuction() && orderBook.halt == nil {
+		change.Uncross, _ = m.uncross(orderBook)
+	}
+	// edge case: the session's own auction or uncross takes over from a reopening auction
+	orderBook.reopenAt = 0
+	if session.isAuction() {
+		orderBook.auction = true
+	}
+	orderBook.session = session
+	return change
+}
+
+// SessionError is returned for a command the symbol's trading session does not accept
+type SessionError struct {
+	Symbol  string
+	Session TradingSession
+	Kind    CommandKind
+}
+
+func (e *SessionError) Error() string {
+	return e.Symbol + " is in session " + string(e.Session) + ": " + notAccepted(e.Kind)
+}
+
+// notAccepted says which commands a session or halt refuses
+func notAccepted(kind CommandKind) string {
+	var what string
+	switch kind {
+	case CommandSubmit:
+		what = "new orders are"
+	case CommandAmend:
+		what = "amends are"
+	case CommandCancel:
+		what = "cancels are"
+	case CommandAuction:
+		what = "auctions are"
+	case CommandUncross:
+		what = "manual uncrosses are"
+	}
+	return what + " not accepted"
+}
+
+type InvalidSessionError struct {
+	Session TradingSession
+}
+
+func (e *InvalidSessionError) Error() string {
+	return "Invalid trading session " + string(e.Session)
+}
@@ -0,0 +1,226 @@
+package engine
+
+import (
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/btree"
+)
+
+// BookState is the full state of one order book after the command at Sequence:
+// resting orders in price and FIFO order, pending stops in trigger order, and the
+// terminal orders still within retention
+type BookState struct {
+	Symbol         string          `json:"symbol"`
+	Sequence       uint64          `json:"seq"`
+	LastTradePrice int64           `json:"last_trade_price"`
+	Bids           []*LevelState   `json:"bids"`                  // best price first
+	Asks           []*LevelState   `json:"asks"`                  // best price first
+	BuyStops       []*LevelState   `json:"buy_stops"`             // first to trigger first, keyed by stop price
+	SellStops      []*LevelState   `json:"sell_stops"`            // first to trigger first, keyed by stop price
+	Terminal       []*RetiredState `json:"terminal"`              // oldest first
+	Trades         []*Trade        `json:"trades,omitempty"`      // trade history, oldest first
+	FirstTrade     uint64          `json:"first_trade,omitempty"` // trade stream position of Trades[0]
+	Auction        bool            `json:"auction,omitempty"`     // accumulating orders for an uncross
+	Session        TradingSession  `json:"session,omitempty"`     // "" before the first session change
+	Halt           *TradingHalt    `json:"halt,omitempty"`        // nil while the symbol trades
+	ReopenAt       int64           `json:"reopen_at,omitempty"`   // unix nanoseconds the reopening auction ends, 0 for none
+	Instrument     *Instrument     `json:"instrument,omitempty"`  // rules the book runs under, nil without a registry
+}
+
+type LevelState struct {
+	Price  int64         `json:"price"`
+	Orders []*OrderState `json:"orders"` // time priority order
+}
+
+// OrderState is an order as submitted plus everything that changed since
+type OrderState struct {
+	OrderRecord
+	Status           OrderStatus `json:"status"`
+	FilledQuantity   int64       `json:"filled_quantity"`
+	DisplayRemaining int64       `json:"display_remaining,omitempty"` // left in the current iceberg slice
+	TopOrder         bool        `json:"top_order,omitempty"`         // opened a new best price level
+	Fills            []*Trade    `json:"fills,omitempty"`
+}
+
+type RetiredState struct {
+	Order     *OrderState `json:"order"`
+	RetiredAt int64       `json:"retired_at"` // unix nanoseconds
+}
+
+func newOrderState(order *Order) *OrderState {
+	order.statusMu.Lock()
+	defer order.statusMu.Unlock()
+
+	fills := make([]*Trade, len(order.fills))
+	copy(fills, order.fills)
+
+	return &OrderState{
+		OrderRecord:      *newOrderRecord(order),
+		Status:           order.Status,
+		FilledQuantity:   atomic.LoadInt64(&order.FilledQuantity),
+		DisplayRemaining: atomic.LoadInt64(&order.displayRemaining),
+		TopOrder:         order.topOrder,
+		Fills:            fills,
+	}
+}
+
+func (s *OrderState) restore(symbol string) *Order {
+	order := s.OrderRecord.NewOrder(symbol)
+	order.Status = s.Status
+	order.FilledQuantity = s.FilledQuantity
+	order.displayRemaining = s.DisplayRemaining
+	order.fills = s.Fills
+	// edge case: NEW went out before the snapshot was taken
+	order.accepted = true
+	return order
+}
+
+func captureLevels(tree *btree.BTree) []*LevelState {
+	levels := make([]*LevelState, 0, tree.Len())
+	tree.Ascend(func(item btree.Item) bool {
+		priceLevel := priceLevelOf(item)
+		level := &LevelState{
+			Price:  priceLevel.Price,
+			Orders: make([]*OrderState, 0, len(priceLevel.Orders)),
+		}
+		for _, order := range priceLevel.Orders {
+			level.Orders = append(level.Orders, newOrderState(order))
+		}
+		levels = append(levels, level)
+		return true
+	})
+	return levels
+}
+
+// captureState copies the book between two commands; the sequencer holds the write
+// lock while it applies one
+func (ob *OrderBook) captureState() *BookState {
+	ob.mu.RLock()
+	defer ob.mu.RUnlock()
+
+	state := &BookState{
+		Symbol:         ob.Symbol,
+		Sequence:       ob.LastSequence(),
+		LastTradePrice: ob.GetLastTradePrice(),
+		Bids:           captureLevels(ob.Bids),
+		Asks:           captureLevels(ob.Asks),
+		BuyStops:       captureLevels(ob.BuyStops),
+		SellStops:      captureLevels(ob.SellStops),
+		Terminal:       make([]*RetiredState, 0, len(ob.terminal.queue)),
+		Auction:        ob.auction,
+		Session:        ob.session,
+		Halt:           ob.halt,
+		ReopenAt:       ob.reopenAt,
+		Instrument:     ob.instrument,
+	}
+	state.Trades = append([]*Trade(nil), ob.trades.trades...)
+	state.FirstTrade = ob.trades.first
+	for _, retired := range ob.terminal.queue {
+		state.Terminal = append(state.Terminal, &RetiredState{
+			Order:     newOrderState(retired.order),
+			RetiredAt: retired.retiredAt.UnixNano(),
+		})
+	}
+	return state
+}
+
+// Snapshot captures every order book, sorted by symbol. Books are captured one at a
+// time, each at the sequence number recorded in its state.
+func (m *Matcher) Snapshot() []*BookState {
+	books := m.GetOrderBooksSnapshot()
+
+	symbols := make([]string, 0, len(books))
+	for symbol := range books {
+		symbols = append(symbols, symbol)
+	}
+	sort.Strings(symbols)
+
+	states := make([]*BookState, 0, len(symbols))
+	for _, symbol := range symbols {
+		states = append(states, books[symbol].captureState())
+	}
+	return states
+}
+
+// Restore rebuilds a book from a captured state. It must be called before any command
+// reaches the symbol; replaying commands up to state.Sequence afterwards is a no-op.
+func (m *Matcher) Restore(state *BookState) error {
+	orderBook := m.getOrCreateSequencer(state.Symbol).orderBook
+
+	orderBook.mu.Lock()
+	defer orderBook.mu.Unlock()
+
+	if orderBook.LastSequence() != 0 || len(orderBook.Orders) > 0 || len(orderBook.StopOrders) > 0 {
+		return &BookNotEmptyError{Symbol: state.Symbol}
+	}
+	// edge case: the captured rules need their schedule parsed again
+	if state.Instrument != nil {
+		if err := state.Instrument.Validate(); err != nil {
+			return err
+		}
+	}
+
+	var restored []*Order
+	for _, levels := range [][]*LevelState{state.Bids, state.Asks} {
+		for _, level := range levels {
+			for _, orderState := range level.Orders {
+				order := orderState.restore(state.Symbol)
+				orderBook.addOrder(order)
+				// edge case: addOrder starts a fresh iceberg slice and picks its own top
+				// order, keep the captured ones
+				order.displayRemaining = orderState.DisplayRemaining
+				order.topOrder = orderState.TopOrder
+				restored = append(restored, order)
+			}
+		}
+	}
+	for _, levels := range [][]*LevelState{state.BuyStops, state.SellStops} {
+		for _, level := range levels {
+			for _, orderState := range level.Orders {
+				order := orderState.restore(state.Symbol)
+				orderBook.addStopOrder(order)
+				restored = append(restored, order)
+			}
+		}
+	}
+	for _, retired := range state.Terminal {
+		order := retired.Order.restore(state.Symbol)
+		for _, evicted := range orderBook.terminal.add(order, time.Unix(0, retired.RetiredAt)) {
+			orderBook.forgetOrder(evicted)
+		}
+		restored = append(restored, order)
+	}
+
+	for _, order := range restored {
+		m.indexOrder(order.ID, state.Symbol)
+		if order.ClientOrderID != "" {
+			m.claimClientOrderID(order)
+		}
+	}
+	// edge case: a smaller retention policy than the one the snapshot was taken with
+	if forgotten := orderBook.takeForgotten(); len(forgotten) > 0 {
+		m.unindexOrders(forgotten)
+	}
+
+	for _, trade := range state.Trades {
+		orderBook.trades.add(trade)
+	}
+	if state.FirstTrade > 0 {
+		// edge case: a smaller history limit than the snapshot was taken with drops the oldest
+		orderBook.trades.first = state.FirstTrade + uint64(len(state.Trades)-len(orderBook.trades.trades))
+	}
+
+	// edge case: restoring rebuilds the book the feed starts from, it is not an update
+	orderBook.touched = orderBook.touched[:0]
+
+	orderBook.auction = state.Auction
+	orderBook.session = state.Session
+	orderBook.halt = state.Halt
+	orderBook.reopenAt = state.ReopenAt
+	orderBook.instrument = state.Instrument
+	atomic.StoreInt64(&orderBook.LastTradePrice, state.LastTradePrice)
+	atomic.StoreUint64(&orderBook.Sequence, state.Sequence)
+	return nil
+}
@@ -0,0 +1,94 @@
+package engine
+
+import (
+	"sort"
+	"strconv"
+)
+
+// DefaultTradeHistoryLimit is how many trades each book keeps for history queries
+const DefaultTradeHistoryLimit = 100000
+
+// TradeQuery selects a page of a symbol's trade history, oldest first
+type TradeQuery struct {
+	Since  int64  // unix nanoseconds, 0 for no lower bound
+	Until  int64  // unix nanoseconds inclusive, 0 for no upper bound
+	Cursor uint64 // position returned by the previous page, 0 to start at Since
+	Limit  int
+}
+
+// tradeStore keeps the most recent trades of one book in execution order. Each trade
+// has a position in the symbol's trade stream that cursors refer to, so pages stay
+// stable while older trades are evicted. Guarded by the owning book's mutex.
+type tradeStore struct {
+	limit  int
+	first  uint64 // position of trades[0], positions start at 1
+	trades []*Trade
+}
+
+func newTradeStore(limit int) *tradeStore {
+	return &tradeStore{limit: limit, first: 1}
+}
+
+func (s *tradeStore) add(trade *Trade) {
+	s.trades = append(s.trades, trade)
+	if s.limit > 0 && len(s.trades) > s.limit {
+		s.trades[0] = nil
+		s.trades = s.trades[1:]
+		s.first++
+	}
+}
+
+// query returns up to q.Limit trades and the cursor for the next page, which is 0 once
+// there is nothing more to read
+func (s *tradeStore) query(q TradeQuery) ([]*Trade, uint64, error) {
+	// edge case: the trades between the previous page and the oldest kept one are gone,
+	// resuming would skip them without the client knowing
+	if q.Cursor > 0 && q.Cursor < s.first {
+		return nil, 0, &CursorExpiredError{Cursor: q.Cursor, Oldest: s.first}
+	}
+	start := 0
+	if q.Cursor > s.first {
+		start = int(q.Cursor - s.first)
+	}
+	// edge case: trades are stored in execution order, which is time order unless the clock stepped back
+	if q.Since > 0 {
+		fromSince := sort.Search(len(s.trades), func(i int) bool {
+			return s.trades[i].Timestamp >= q.Since
+		})
+		if fromSince > start {
+			start = fromSince
+		}
+	}
+	if start >= len(s.trades) {
+		return []*Trade{}, 0, nil
+	}
+
+	end := len(s.trades)
+	if q.Limit > 0 && start+q.Limit < end {
+		end = start + q.Limit
+	}
+
+	page := make([]*Trade, 0, end-start)
+	for _, trade := range s.trades[start:end] {
+		if q.Until > 0 && trade.Timestamp > q.Until {
+			return page, 0, nil
+		}
+		page = append(page, trade)
+	}
+
+	if end == len(s.trades) {
+		return page, 0, nil
+	}
+	return page, s.first + uint64(end), nil
+}
+
+// CursorExpiredError is returned for a cursor that points at trades the history has
+// already evicted
+type CursorExpiredError struct {
+	Cursor uint64
+	Oldest uint64 // position of the oldest trade still kept
+}
+
+func (e *CursorExpiredError) Error() string {
+	return "cursor " + strconv.FormatUint(e.Cursor, 10) + " expired: the oldest trade kept is at " + strconv.FormatUint(e.Oldest, 10)
+}
@@ -0,0 +1,120 @@
+// Package executions routes the engine's execution reports to the sessions of each account
+package executions
+
+import (
+	"sync"
+
+	"match-engine/src/engine"
+)
+
+// DefaultBufferSize is how many reports a session may fall behind before it is dropped
+const DefaultBufferSize = 1024
+
+// Hub is an engine.ExecutionListener that forwards each report to every session of the
+// order's account. Publishing never blocks the sequencer: a session whose buffer is full
+// is closed, and its owner has to reconcile through the order status API.
+type Hub struct {
+	mu         sync.RWMutex
+	sessions   map[string]map[*Session]struct{}
+	bufferSize int
+}
+
+// Session receives the execution reports of one account. Reports is closed when the
+// session falls behind or unsubscribes.
+type Session struct {
+	Account string
+	Reports <-chan *engine.ExecutionReport
+
+	// sequencers of different symbols publish to the same session concurrently
+	mu      sync.Mutex
+	reports chan *engine.ExecutionReport
+	closed  bool
+	lagged  bool
+}
+
+func NewHub(bufferSize int) *Hub {
+	if bufferSize <= 0 {
+		bufferSize = DefaultBufferSize
+	}
+	return &Hub{
+		sessions:   make(map[string]map[*Session]struct{}),
+		bufferSize: bufferSize,
+	}
+}
+
+// Subscribe opens a session for an account's reports from now on
+func (h *Hub) Subscribe(account string) *Session {
+	reports := make(chan *engine.ExecutionReport, h.bufferSize)
+	session := &Session{Account: account, Reports: reports, reports: reports}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.sessions[account] == nil {
+		h.sessions[account] = make(map[*Session]struct{})
+	}
+	h.sessions[account][session] = struct{}{}
+	return session
+}
+
+// Unsubscribe ends a session and closes its channel
+func (h *Hub) Unsubscribe(session *Session) {
+	h.mu.Lock()
+	if sessions := h.sessions[session.Account]; sessions != nil {
+		delete(sessions, session)
+		if len(sessions) == 0 {
+			delete(h.sessions, session.Account)
+		}
+	}
+	h.mu.Unlock()
+
+	session.mu.Lock()
+	session.close()
+	session.mu.Unlock()
+}
+
+// Lagged reports whether the session was closed because its buffer filled up
+func (s *Session) Lagged() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lagged
+}
+
+// must be called with s.mu held
+func (s *Session) close() {
+	if !s.closed {
+		s.closed = true
+		close(s.reports)
+	}
+}
+
+func (s *Session) send(report *engine.ExecutionReport) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return
+	}
+	select {
+	case s.reports <- report:
+	default:
+		// edge case: reports cannot be replayed, the owner learns of the gap from the close
+		s.lagged = true
+		s.close()
+	}
+}
+
+// OnExecutionReport implements engine.ExecutionListener. Orders without an account
+// have nobody to report to.
+func (h *Hub) OnExecutionReport(report *engine.ExecutionReport) {
+	if report.Account == "" {
+		return
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for session := range h.sessions[report.Account] {
+		session.send(report)
+	}
+}
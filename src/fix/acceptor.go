@@ -0,0 +1,227 @@
+package fix
+
+import (
+	"bufio"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"match-engine/src/engine"
+	"match-engine/src/executions"
+)
+
+// how long a new connection has to send its Logon
+const logonTimeout = 10 * time.Second
+
+// CompIDs name session directories, so they are kept to safe characters
+var compIDPattern = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,64}$`)
+
+type Config struct {
+	CompID         string   // our CompID, the TargetCompID counterparties log on to
+	Counterparties []string // SenderCompIDs allowed to log on, empty allows any
+	StoreDir       string   // one directory per session under it
+}
+
+// Acceptor listens for FIX initiators. Each counterparty CompID has one Session for the
+// acceptor's lifetime, restored from its store when the acceptor starts.
+type Acceptor struct {
+	config  Config
+	matcher *engine.Matcher
+	hub     *executions.Hub
+
+	listener net.Listener
+	conns    sync.WaitGroup
+
+	mu       sync.Mutex
+	sessions map[string]*Session
+	pending  map[net.Conn]struct{} // connections that have not logged on yet
+	closed   bool
+}
+
+// NewAcceptor serves orders to matcher. Execution reports of resting orders reach their
+// session through hub, which must be the matcher's execution listener.
+func NewAcceptor(config Config, matcher *engine.Matcher, hub *executions.Hub) *Acceptor {
+	return &Acceptor{
+		config:   config,
+		matcher:  matcher,
+		hub:      hub,
+		sessions: make(map[string]*Session),
+		pending:  make(map[net.Conn]struct{}),
+	}
+}
+
+// Listen restores the sessions found in the store directory and starts accepting
+// connections on addr
+func (a *Acceptor) Listen(addr string) error {
+	entries, err := os.ReadDir(a.config.StoreDir)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() && a.allowed(entry.Name()) {
+			if _, err := a.session(entry.Name()); err != nil {
+				a.Close()
+				return err
+			}
+		}
+	}
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		a.Close()
+		return err
+	}
+	a.listener = listener
+
+	go a.accept()
+	return nil
+}
+
+// Addr is the address the acceptor listens on
+func (a *Acceptor) Addr() net.Addr {
+	return a.listener.Addr()
+}
+
+// Close stops accepting connections and logs every session out
+func (a *Acceptor) Close() error {
+	a.mu.Lock()
+	if a.closed {
+		a.mu.Unlock()
+		return nil
+	}
+	a.closed = true
+	for conn := range a.pending {
+		conn.Close()
+	}
+	a.mu.Unlock()
+
+	var err error
+	if a.listener != nil {
+		err = a.listener.Close()
+	}
+	// no new sessions can be created once closed is set
+	for _, session := range a.sessions {
+		session.stop()
+	}
+	a.conns.Wait()
+	return err
+}
+
+func (a *Acceptor) accept() {
+	for {
+		conn, err := a.listener.Accept()
+		if err != nil {
+			return
+		}
+		a.mu.Lock()
+		if a.closed {
+			a.mu.Unlock()
+			conn.Close()
+			return
+		}
+		a.pending[conn] = struct{}{}
+		a.conns.Add(1)
+		a.mu.Unlock()
+		go a.serve(conn)
+	}
+}
+
+func (a *Acceptor) allowed(compID string) bool {
+	if !compIDPattern.MatchString(compID) {
+		return false
+	}
+	if len(a.config.Counterparties) == 0 {
+		return true
+	}
+	for _, counterparty := range a.config.Counterparties {
+		if counterparty == compID {
+			return true
+		}
+	}
+	return false
+}
+
+// session returns the counterparty's session, opening its store the first time
+func (a *Acceptor) session(compID string) (*Session, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.closed {
+		return nil, net.ErrClosed
+	}
+	if session, exists := a.sessions[compID]; exists {
+		return session, nil
+	}
+	store, err := OpenFileStore(filepath.Join(a.config.StoreDir, compID))
+	if err != nil {
+		return nil, err
+	}
+	session := newSession(compID, a.config.CompID, store, a.matcher, a.hub)
+	a.sessions[compID] = session
+	return session, nil
+}
+
+// serve waits for the connection's Logon, then hands every message it reads to the
+// session the Logon names
+func (a *Acceptor) serve(conn net.Conn) {
+	defer a.conns.Done()
+
+	remote := conn.RemoteAddr().String()
+	reader := bufio.NewReader(conn)
+
+	conn.SetReadDeadline(time.Now().Add(logonTimeout))
+	raw, err := ReadMessage(reader)
+	a.mu.Lock()
+	delete(a.pending, conn)
+	a.mu.Unlock()
+	if err != nil {
+		log.Warn().Err(err).Str("remote", remote).Msg("FIX connection closed before logon")
+		conn.Close()
+		return
+	}
+	conn.SetReadDeadline(time.Time{})
+
+	// edge case: anything but a valid Logon for us from a known counterparty is dropped
+	// without an answer
+	logon, err := Parse(raw)
+	if err != nil || logon.Type() != MsgLogon || logon.Get(TagTargetCompID) != a.config.CompID || !a.allowed(logon.Get(TagSenderCompID)) {
+		log.Warn().
+			Str("remote", remote).
+			Str("sender_comp_id", logonField(logon, TagSenderCompID)).
+			Str("target_comp_id", logonField(logon, TagTargetCompID)).
+			Msg("Invalid FIX logon, closing connection")
+		conn.Close()
+		return
+	}
+
+	session, err := a.session(logon.Get(TagSenderCompID))
+	if err != nil {
+		log.Error().Err(err).Str("remote", remote).Msg("Failed to open FIX session")
+		conn.Close()
+		return
+	}
+
+	if !session.deliver(event{conn: conn, raw: raw, logon: true}) {
+		conn.Close()
+		return
+	}
+	for {
+		raw, err := ReadMessage(reader)
+		if !session.deliver(event{conn: conn, raw: raw, err: err}) || err != nil {
+			conn.Close()
+			return
+		}
+	}
+}
+
+func logonField(logon *Message, tag int) string {
+	if logon == nil {
+		return ""
+	}
+	return logon.Get(tag)
+}
@@ -0,0 +1,690 @@
+package fix
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"match-engine/src/engine"
+)
+
+// ExecType and OrdStatus values
+const (
+	execNew      = "0"
+	execCanceled = "4"
+	execReplaced = "5"
+	execRejected = "8"
+	execExpired  = "C"
+	execTrade    = "F"
+
+	statusNew             = "0"
+	statusPartiallyFilled = "1"
+	statusFilled          = "2"
+)
+
+// OrdRejReason and CxlRejReason values
+const (
+	ordRejUnknownSymbol     = 1
+	ordRejExchangeClosed    = 2
+	ordRejExceedsLimit      = 3
+	ordRejDuplicateOrder    = 6
+	ordRejUnsupported       = 11
+	ordRejIncorrectQuantity = 13
+	ordRejOther             = 99
+
+	cxlRejTooLate          = 0
+	cxlRejUnknownOrder     = 1
+	cxlRejDuplicateClOrdID = 6
+	cxlRejOther            = 99
+)
+
+// report is what one ExecutionReport says about an order
+type report struct {
+	orderID     string
+	clOrdID     string
+	origClOrdID string
+	symbol      string
+	side        engine.OrderSide
+	price       int64
+	quantity    int64
+	execID      string
+	execType    string
+	ordStatus   string
+	cum         int64
+	leaves      int64
+	notional    int64 // price × quantity over every fill so far, for AvgPx
+	precision   int   // decimals of the symbol's prices
+	lastPx      int64
+	lastQty     int64
+	rejReason   int
+	text        string
+	timestamp   int64 // unix nanoseconds
+}
+
+func (r *report) message() *Message {
+	msg := NewMessage(MsgExecutionReport).
+		Set(TagOrderID, r.orderID).
+		Set(TagClOrdID, r.clOrdID).
+		Set(TagExecID, r.execID).
+		Set(TagExecType, r.execType).
+		Set(TagOrdStatus, r.ordStatus).
+		Set(TagSymbol, r.symbol).
+		Set(TagSide, formatSide(r.side)).
+		SetInt(TagOrderQty, r.quantity).
+		SetInt(TagCumQty, r.cum).
+		SetInt(TagLeavesQty, r.leaves).
+		Set(TagAvgPx, formatAvgPx(r.notional, r.cum, r.precision)).
+		Set(TagTransactTime, time.Unix(0, r.timestamp).UTC().Format(sendingTimeFormat))
+	if r.origClOrdID != "" {
+		msg.Set(TagOrigClOrdID, r.origClOrdID)
+	}
+	if r.price > 0 {
+		msg.Set(TagPrice, formatPrice(r.price, r.precision))
+	}
+	if r.execType == execTrade {
+		msg.Set(TagLastPx, formatPrice(r.lastPx, r.precision)).SetInt(TagLastQty, r.lastQty)
+	}
+	if r.execType == execRejected {
+		msg.SetInt(TagOrdRejReason, int64(r.rejReason))
+	}
+	if r.text != "" {
+		msg.Set(TagText, r.text)
+	}
+	return msg
+}
+
+func (s *Session) onNewOrderSingle(msg *Message) {
+	clOrdID, ok := msg.Lookup(TagClOrdID)
+	if !ok || clOrdID == "" {
+		s.reject(msg, &FieldError{Tag: TagClOrdID, Reason: RejectRequiredTagMissing})
+		return
+	}
+	order, err := s.newOrder(msg)
+	if err != nil {
+		if fieldErr, ok := err.(*FieldError); ok {
+			s.reject(msg, fieldErr)
+			return
+		}
+		s.rejectOrder(msg, ordRejOther, err.Error())
+		return
+	}
+
+	// edge case: a ClOrdID names one order for the life of the session, replaced ones too
+	if _, exists := s.store.OrderID(clOrdID); exists {
+		s.rejectOrder(msg, ordRejDuplicateOrder, "Duplicate ClOrdID")
+		return
+	}
+	if err := s.store.SaveOrder(clOrdID, order.ID); err != nil {
+		log.Error().Err(err).Str("session", s.ID).Str("cl_ord_id", clOrdID).Msg("Failed to store FIX order")
+		s.rejectOrder(msg, ordRejOther, "Internal error")
+		return
+	}
+
+	log.Info().
+		Str("session", s.ID).
+		Str("order_id", order.ID).
+		Str("cl_ord_id", clOrdID).
+		Str("symbol", order.Symbol).
+		Str("side", string(order.Side)).
+		Str("type", string(order.Type)).
+		Str("time_in_force", string(order.TimeInForce)).
+		Int64("price", order.Price).
+		Int64("quantity", order.Quantity).
+		Msg("FIX order submitted")
+
+	s.drainReports()
+	result, err := s.matcher.MatchOrder(order)
+	if err != nil {
+		s.handled[order.ID] = 0
+		text := "Internal error"
+		reason := ordRejOther
+		switch err := err.(type) {
+		case *engine.InsufficientLiquidityError:
+			text = "Insufficient liquidity"
+			reason = ordRejExceedsLimit
+		case *engine.UnknownInstrumentError:
+			text = err.Error()
+			reason = ordRejUnknownSymbol
+		case *engine.InstrumentNotTradingError, *engine.SessionError, *engine.HaltedError:
+			text = rejectText(err)
+			reason = ordRejExchangeClosed
+		case *engine.AuctionOrderError:
+			text = err.Error()
+			reason = ordRejUnsupported
+		case *engine.InvalidOrderError:
+			text = err.Error()
+			if err.Field == "quantity" || err.Field == "display_quantity" {
+				reason = ordRejIncorrectQuantity
+			}
+		default:
+			log.Error().Err(err).Str("session", s.ID).Str("order_id", order.ID).Msg("Error matching FIX order")
+		}
+		r := s.orderReport(order, clOrdID)
+		r.execID = order.ID + "-" + execRejected
+		r.execType, r.ordStatus = execRejected, execRejected
+		r.rejReason, r.text = reason, text
+		r.timestamp = s.matcher.Now().UnixNano()
+		s.send(r.message())
+		return
+	}
+	s.handled[order.ID] = result.Sequence
+
+	r := s.orderReport(order, clOrdID)
+	r.execID = order.ID + "-" + execNew + "-" + strconv.FormatUint(result.Sequence, 10)
+	r.execType, r.ordStatus = execNew, statusNew
+	r.leaves = order.Quantity
+	r.timestamp = order.Timestamp
+	s.send(r.message())
+
+	s.sendResult(r, result)
+}
+
+// newOrder builds the engine order a NewOrderSingle asks for. Missing or malformed fields
+// are a FieldError, fields the engine does not support a plain error.
+func (s *Session) newOrder(msg *Message) (*engine.Order, error) {
+	symbol := msg.Get(TagSymbol)
+	if symbol == "" {
+		return nil, &FieldError{Tag: TagSymbol, Reason: RejectRequiredTagMissing}
+	}
+	side, err := parseSide(msg)
+	if err != nil {
+		return nil, err
+	}
+	quantity, err := parseQty(msg, TagOrderQty)
+	if err != nil {
+		return nil, err
+	}
+
+	var orderType engine.OrderType
+	switch ordType, _ := msg.Lookup(TagOrdType); ordType {
+	case "1":
+		orderType = engine.TypeMarket
+	case "2":
+		orderType = engine.TypeLimit
+	case "3":
+		orderType = engine.TypeStop
+	case "4":
+		orderType = engine.TypeStopLimit
+	case "":
+		return nil, &FieldError{Tag: TagOrdType, Reason: RejectRequiredTagMissing}
+	default:
+		return nil, &UnsupportedError{Message: "Unsupported OrdType " + ordType}
+	}
+
+	var price, stopPrice int64
+	precision := s.matcher.PricePrecision(symbol)
+	if orderType == engine.TypeLimit || orderType == engine.TypeStopLimit {
+		if price, err = parsePrice(msg, TagPrice, precision); err != nil {
+			return nil, err
+		}
+	}
+	if orderType == engine.TypeStop || orderType == engine.TypeStopLimit {
+		if stopPrice, err = parsePrice(msg, TagStopPx, precision); err != nil {
+			return nil, err
+		}
+	}
+
+	timeInForce := engine.TIFGTC
+	switch tif, _ := msg.Lookup(TagTimeInForce); tif {
+	case "", "1":
+	case "3":
+		timeInForce = engine.TIFIOC
+	case "4":
+		timeInForce = engine.TIFFOK
+	default:
+		return nil, &UnsupportedError{Message: "Unsupported TimeInForce " + tif}
+	}
+
+	var displayQuantity int64
+	if _, ok := msg.Lookup(TagMaxFloor); ok {
+		if displayQuantity, err = parseQty(msg, TagMaxFloor); err != nil {
+			return nil, err
+		}
+		// edge case: only resting limit orders can hide quantity
+		if orderType != engine.TypeLimit || displayQuantity > quantity {
+			return nil, &UnsupportedError{Message: "MaxFloor is only allowed on limit orders, up to OrderQty"}
+		}
+	}
+
+	selfTradePrevention := engine.SelfTradePrevention(msg.Get(TagSelfTradePrevention))
+	if !selfTradePrevention.IsValid() {
+		return nil, &UnsupportedError{Message: "Unsupported SelfTradePrevention " + string(selfTradePrevention)}
+	}
+
+	order := engine.NewOrder(s.matcher.NewOrderID(), symbol, side, orderType, price, quantity)
+	order.Account = s.ID
+	order.StopPrice = stopPrice
+	order.TimeInForce = timeInForce
+	order.DisplayQuantity = displayQuantity
+	order.SelfTradePrevention = selfTradePrevention
+	return order, nil
+}
+
+// sendResult sends a fill for each of a command's trades, and the cancel of an IOC
+// remainder or of an order self-trade prevention stopped. r describes the order before the trades.
+func (s *Session) sendResult(r *report, result *engine.MatchResult) {
+	for _, trade := range result.Trades {
+		r.cum += trade.Quantity
+		r.notional += trade.Price * trade.Quantity
+		r.leaves = r.quantity - r.cum
+		r.execID = fillExecID(trade, r.side)
+		r.execType, r.ordStatus = execTrade, statusPartiallyFilled
+		if r.leaves == 0 {
+			r.ordStatus = statusFilled
+		}
+		r.lastPx, r.lastQty = trade.Price, trade.Quantity
+		r.timestamp = trade.Timestamp
+		s.send(r.message())
+	}
+
+	if result.Status == engine.StatusCancelled {
+		r.execID = r.orderID + "-" + execCanceled + "-" + strconv.FormatUint(result.Sequence, 10)
+		r.execType, r.ordStatus = execCanceled, execCanceled
+		r.leaves = 0
+		r.timestamp = s.matcher.Now().UnixNano()
+		if result.Order != nil && result.Order.SelfTradeCancelled() {
+			r.text = "Self-trade prevention"
+		}
+		s.send(r.message())
+	}
+}
+
+func (s *Session) onOrderCancelRequest(msg *Message) {
+	clOrdID, origClOrdID, orderID, ok := s.resolveCancel(msg, "1")
+	if !ok {
+		return
+	}
+
+	s.drainReports()
+	result, err := s.matcher.CancelOrder(orderID)
+	if err != nil {
+		s.cancelReject(msg, "1", orderID, cancelRejectReason(err), rejectText(err))
+		return
+	}
+	s.handled[orderID] = result.Sequence
+	if err := s.store.SaveOrder(clOrdID, orderID); err != nil {
+		log.Error().Err(err).Str("session", s.ID).Str("cl_ord_id", clOrdID).Msg("Failed to store FIX order")
+	}
+
+	log.Info().
+		Str("session", s.ID).
+		Str("order_id", orderID).
+		Str("cl_ord_id", clOrdID).
+		Uint64("sequence", result.Sequence).
+		Msg("FIX order cancelled")
+
+	order := result.Order
+	r := s.orderReport(order, clOrdID)
+	r.origClOrdID = origClOrdID
+	r.execID = orderID + "-" + execCanceled + "-" + strconv.FormatUint(result.Sequence, 10)
+	r.execType, r.ordStatus = execCanceled, execCanceled
+	r.cum, r.notional = fillsBefore(order, result.Sequence+1)
+	r.timestamp = s.matcher.Now().UnixNano()
+	s.send(r.message())
+}
+
+func (s *Session) onOrderCancelReplaceRequest(msg *Message) {
+	clOrdID, origClOrdID, orderID, ok := s.resolveCancel(msg, "2")
+	if !ok {
+		return
+	}
+	quantity, err := parseQty(msg, TagOrderQty)
+	if err != nil {
+		s.reject(msg, err.(*FieldError))
+		return
+	}
+	var price int64
+	if _, ok := msg.Lookup(TagPrice); ok {
+		if price, err = parsePrice(msg, TagPrice, s.matcher.PricePrecision(msg.Get(TagSymbol))); err != nil {
+			s.reject(msg, err.(*FieldError))
+			return
+		}
+	}
+
+	// edge case: only the price and quantity of an order can change
+	if order, exists := s.matcher.GetOrder(orderID); exists && (order.Symbol != msg.Get(TagSymbol) || formatSide(order.Side) != msg.Get(TagSide)) {
+		s.cancelReject(msg, "2", orderID, cxlRejOther, "Symbol and Side cannot be replaced")
+		return
+	}
+
+	s.drainReports()
+	result, err := s.matcher.AmendOrder(orderID, price, quantity)
+	if err != nil {
+		s.cancelReject(msg, "2", orderID, cancelRejectReason(err), rejectText(err))
+		return
+	}
+	s.handled[orderID] = result.Sequence
+	if err := s.store.SaveOrder(clOrdID, orderID); err != nil {
+		log.Error().Err(err).Str("session", s.ID).Str("cl_ord_id", clOrdID).Msg("Failed to store FIX order")
+	}
+
+	log.Info().
+		Str("session", s.ID).
+		Str("order_id", orderID).
+		Str("cl_ord_id", clOrdID).
+		Int64("price", result.Order.Price).
+		Int64("quantity", result.Order.Quantity).
+		Int("trades_count", len(result.Trades)).
+		Msg("FIX order replaced")
+
+	r := s.orderReport(result.Order, clOrdID)
+	r.origClOrdID = origClOrdID
+	r.execID = orderID + "-" + execReplaced + "-" + strconv.FormatUint(result.Sequence, 10)
+	r.execType = execReplaced
+	r.cum, r.notional = fillsBefore(result.Order, result.Sequence)
+	r.leaves = r.quantity - r.cum
+	r.ordStatus = statusNew
+	if r.cum > 0 {
+		r.ordStatus = statusPartiallyFilled
+	}
+	r.timestamp = s.matcher.Now().UnixNano()
+	s.send(r.message())
+
+	r.origClOrdID = ""
+	s.sendResult(r, result)
+}
+
+// resolveCancel finds the order a cancel or replace refers to, by OrderID or else by
+// OrigClOrdID. Requests it cannot resolve are answered and reported as not ok.
+func (s *Session) resolveCancel(msg *Message, responseTo string) (clOrdID, origClOrdID, orderID string, ok bool) {
+	for _, tag := range []int{TagClOrdID, TagOrigClOrdID} {
+		if msg.Get(tag) == "" {
+			s.reject(msg, &FieldError{Tag: tag, Reason: RejectRequiredTagMissing})
+			return "", "", "", false
+		}
+	}
+	clOrdID, origClOrdID = msg.Get(TagClOrdID), msg.Get(TagOrigClOrdID)
+
+	orderID = msg.Get(TagOrderID)
+	if orderID == "" || orderID == "NONE" {
+		orderID, _ = s.store.OrderID(origClOrdID)
+	}
+	// edge case: a session can only touch the orders it entered
+	if _, mine := s.store.ClOrdID(orderID); !mine {
+		s.cancelReject(msg, responseTo, "NONE", cxlRejUnknownOrder, "Unknown order")
+		return "", "", "", false
+	}
+	if _, exists := s.store.OrderID(clOrdID); exists {
+		s.cancelReject(msg, responseTo, orderID, cxlRejDuplicateClOrdID, "Duplicate ClOrdID")
+		return "", "", "", false
+	}
+	return clOrdID, origClOrdID, orderID, true
+}
+
+func cancelRejectReason(err error) int {
+	switch err.(type) {
+	case *engine.OrderNotFoundError:
+		return cxlRejUnknownOrder
+	case *engine.OrderNotCancellableError:
+		return cxlRejTooLate
+	}
+	return cxlRejOther
+}
+
+// rejectText is the Text of a reject, led by the error's machine-readable code if it has
+// one: "SYMBOL_HALTED: AAPL is halted: new orders are not accepted"
+func rejectText(err error) string {
+	if code := engine.ErrorCode(err); code != "" {
+		return code + ": " + err.Error()
+	}
+	return err.Error()
+}
+
+func (s *Session) cancelReject(msg *Message, responseTo, orderID string, reason int, text string) {
+	ordStatus := execRejected
+	if order, exists := s.matcher.GetOrder(orderID); exists {
+		ordStatus = formatOrdStatus(order.GetStatus())
+	}
+	s.send(NewMessage(MsgOrderCancelReject).
+		Set(TagOrderID, orderID).
+		Set(TagClOrdID, msg.Get(TagClOrdID)).
+		Set(TagOrigClOrdID, msg.Get(TagOrigClOrdID)).
+		Set(TagOrdStatus, ordStatus).
+		Set(TagCxlRejResponseTo, responseTo).
+		SetInt(TagCxlRejReason, int64(reason)).
+		Set(TagText, text))
+}
+
+// rejectOrder answers a NewOrderSingle that never reached the engine
+func (s *Session) rejectOrder(msg *Message, reason int, text string) {
+	r := &report{
+		orderID:   "NONE",
+		clOrdID:   msg.Get(TagClOrdID),
+		symbol:    msg.Get(TagSymbol),
+		execID:    "REJ-" + s.ID + "-" + msg.Get(TagMsgSeqNum),
+		execType:  execRejected,
+		ordStatus: execRejected,
+		rejReason: reason,
+		text:      text,
+		timestamp: s.matcher.Now().UnixNano(),
+	}
+	r.side, _ = parseSide(msg)
+	r.quantity, _ = strconv.ParseInt(msg.Get(TagOrderQty), 10, 64)
+	s.send(r.message())
+}
+
+func (s *Session) orderReport(order *engine.Order, clOrdID string) *report {
+	return &report{
+		orderID:   order.ID,
+		clOrdID:   clOrdID,
+		symbol:    order.Symbol,
+		side:      order.Side,
+		price:     order.Price,
+		quantity:  order.Quantity,
+		precision: s.matcher.PricePrecision(order.Symbol),
+	}
+}
+
+// onExecutionReport sends an engine report about one of the session's orders that no
+// MatchResult covered: fills of resting orders, triggered stops, expiries
+func (s *Session) onExecutionReport(er *engine.ExecutionReport) {
+	clOrdID, mine := s.store.ClOrdID(er.OrderID)
+	if !mine {
+		return
+	}
+	if seq, handled := s.handled[er.OrderID]; handled {
+		switch {
+		case seq == 0 || seq == er.CommandSequence:
+			if er.Status.IsTerminal() {
+				delete(s.handled, er.OrderID)
+			}
+			return
+		case er.CommandSequence > seq:
+			// reports come in sequence order, the handled command is behind us
+			delete(s.handled, er.OrderID)
+		}
+	}
+
+	r := &report{
+		orderID:   er.OrderID,
+		clOrdID:   clOrdID,
+		symbol:    er.Symbol,
+		side:      er.Side,
+		price:     er.Price,
+		quantity:  er.Quantity,
+		execID:    er.OrderID + "-" + strconv.FormatUint(er.CommandSequence, 10),
+		ordStatus: formatOrdStatus(er.Status),
+		cum:       er.CumulativeQuantity,
+		leaves:    er.LeavesQuantity,
+		precision: s.matcher.PricePrecision(er.Symbol),
+		timestamp: er.Timestamp,
+	}
+	if order, exists := s.matcher.GetOrder(er.OrderID); exists {
+		r.notional = fillsUpTo(order, er.CumulativeQuantity)
+	}
+
+	switch er.ExecType {
+	case engine.ExecNew:
+		r.execType = execNew
+	case engine.ExecPartialFill, engine.ExecFilled:
+		r.execType = execTrade
+		r.execID = fillExecID(&engine.Trade{TradeID: er.TradeID}, er.Side)
+		r.lastPx, r.lastQty = er.FillPrice, er.FillQuantity
+	case engine.ExecCancelled:
+		r.execType = execCanceled
+		if er.SelfTrade {
+			r.text = "Self-trade prevention"
+		}
+	case engine.ExecRejected:
+		r.execType = execRejected
+		r.rejReason = ordRejExceedsLimit
+		r.text = "Insufficient liquidity"
+	case engine.ExecExpired:
+		r.execType = execExpired
+	default:
+		return
+	}
+	if r.execType != execTrade {
+		r.execID += "-" + r.execType
+	}
+	s.send(r.message())
+}
+
+// fillExecID is unique per side of a trade
+func fillExecID(trade *engine.Trade, side engine.OrderSide) string {
+	if side == engine.SideBuy {
+		return trade.TradeID + "-B"
+	}
+	return trade.TradeID + "-S"
+}
+
+// fillsBefore sums an order's fills from commands before seq
+func fillsBefore(order *engine.Order, seq uint64) (quantity, notional int64) {
+	for _, fill := range order.Fills() {
+		if fill.Sequence < seq {
+			quantity += fill.Quantity
+			notional += fill.Price * fill.Quantity
+		}
+	}
+	return quantity, notional
+}
+
+// fillsUpTo is the notional of an order's first fills, up to cum in quantity
+func fillsUpTo(order *engine.Order, cum int64) (notional int64) {
+	for _, fill := range order.Fills() {
+		if cum <= 0 {
+			break
+		}
+		quantity := fill.Quantity
+		if quantity > cum {
+			quantity = cum
+		}
+		notional += fill.Price * quantity
+		cum -= quantity
+	}
+	return notional
+}
+
+func parseSide(msg *Message) (engine.OrderSide, error) {
+	switch side, _ := msg.Lookup(TagSide); side {
+	case "1":
+		return engine.SideBuy, nil
+	case "2":
+		return engine.SideSell, nil
+	case "":
+		return "", &FieldError{Tag: TagSide, Reason: RejectRequiredTagMissing}
+	default:
+		return "", &UnsupportedError{Message: "Unsupported Side " + side}
+	}
+}
+
+func formatSide(side engine.OrderSide) string {
+	if side == engine.SideBuy {
+		return "1"
+	}
+	return "2"
+}
+
+func formatOrdStatus(status engine.OrderStatus) string {
+	switch status {
+	case engine.StatusPartialFill:
+		return statusPartiallyFilled
+	case engine.StatusFilled:
+		return statusFilled
+	case engine.StatusCancelled:
+		return execCanceled
+	case engine.StatusRejected:
+		return execRejected
+	case engine.StatusExpired:
+		return execExpired
+	}
+	return statusNew
+}
+
+// parseQty reads a positive whole quantity, "100" or "100.00"
+func parseQty(msg *Message, tag int) (int64, error) {
+	value, ok := msg.Lookup(tag)
+	if !ok {
+		return 0, &FieldError{Tag: tag, Reason: RejectRequiredTagMissing}
+	}
+	whole, fraction, _ := strings.Cut(value, ".")
+	quantity, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil || strings.Trim(fraction, "0") != "" || quantity <= 0 {
+		return 0, &FieldError{Tag: tag, Reason: RejectIncorrectDataFormat}
+	}
+	return quantity, nil
+}
+
+// parsePrice reads a positive decimal price into an integer with precision decimals, the
+// symbol's smallest unit. Prices finer than that are rejected rather than rounded.
+func parsePrice(msg *Message, tag int, precision int) (int64, error) {
+	value, ok := msg.Lookup(tag)
+	if !ok {
+		return 0, &FieldError{Tag: tag, Reason: RejectRequiredTagMissing}
+	}
+	whole, fraction, _ := strings.Cut(value, ".")
+	fraction = strings.TrimRight(fraction, "0")
+	units, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil || len(fraction) > precision || units < 0 {
+		return 0, &FieldError{Tag: tag, Reason: RejectIncorrectDataFormat}
+	}
+	decimals := int64(0)
+	if fraction != "" {
+		if decimals, err = strconv.ParseInt(fraction+strings.Repeat("0", precision-len(fraction)), 10, 64); err != nil {
+			return 0, &FieldError{Tag: tag, Reason: RejectIncorrectDataFormat}
+		}
+	}
+	price := units*priceScale(precision) + decimals
+	if price <= 0 {
+		return 0, &FieldError{Tag: tag, Reason: RejectIncorrectDataFormat}
+	}
+	return price, nil
+}
+
+func formatPrice(price int64, precision int) string {
+	if precision == 0 {
+		return strconv.FormatInt(price, 10)
+	}
+	scale := priceScale(precision)
+	// edge case: the leading 1 of scale keeps the zeros that pad the decimals
+	return strconv.FormatInt(price/scale, 10) + "." + strconv.FormatInt(scale+price%scale, 10)[1:]
+}
+
+// formatAvgPx shows two decimals more than the symbol's prices
+func formatAvgPx(notional, cum int64, precision int) string {
+	if cum == 0 {
+		return "0"
+	}
+	return strconv.FormatFloat(float64(notional)/float64(cum)/float64(priceScale(precision)), 'f', precision+2, 64)
+}
+
+// priceScale is the number of a symbol's smallest price units in one
+func priceScale(precision int) int64 {
+	scale := int64(1)
+	for range precision {
+		scale *= 10
+	}
+	return scale
+}
+
+// UnsupportedError is a well formed order the engine cannot take, answered with a
+// rejecting ExecutionReport
+type UnsupportedError struct {
+	Message string
+}
+
+func (e *UnsupportedError) Error() string {
+	return e.Message
+}
@@ -0,0 +1,332 @@
+// Package fix is a FIX 4.4 order entry acceptor in front of engine.Matcher. It speaks the
+// session layer (logon, heartbeats, sequence numbers, resend and sequence reset) and maps
+// NewOrderSingle, OrderCancelRequest and OrderCancelReplaceRequest onto the matcher.
+package fix
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+const (
+	BeginString = "FIX.4.4"
+	soh         = '\x01'
+
+	// largest body accepted, anything bigger means the stream is out of step
+	maxBodyLength = 64 * 1024
+)
+
+// message types
+const (
+	MsgHeartbeat                 = "0"
+	MsgTestRequest               = "1"
+	MsgResendRequest             = "2"
+	MsgReject                    = "3"
+	MsgSequenceReset             = "4"
+	MsgLogout                    = "5"
+	MsgExecutionReport           = "8"
+	MsgOrderCancelReject         = "9"
+	MsgLogon                     = "A"
+	MsgNewOrderSingle            = "D"
+	MsgOrderCancelRequest        = "F"
+	MsgOrderCancelReplaceRequest = "G"
+)
+
+// tags
+const (
+	TagAccount             = 1
+	TagAvgPx               = 6
+	TagBeginSeqNo          = 7
+	TagBeginString         = 8
+	TagBodyLength          = 9
+	TagCheckSum            = 10
+	TagClOrdID             = 11
+	TagCumQty              = 14
+	TagEndSeqNo            = 16
+	TagExecID              = 17
+	TagLastPx              = 31
+	TagLastQty             = 32
+	TagMsgSeqNum           = 34
+	TagMsgType             = 35
+	TagNewSeqNo            = 36
+	TagOrderID             = 37
+	TagOrderQty            = 38
+	TagOrdStatus           = 39
+	TagOrdType             = 40
+	TagOrigClOrdID         = 41
+	TagPossDupFlag         = 43
+	TagPrice               = 44
+	TagRefSeqNum           = 45
+	TagSenderCompID        = 49
+	TagSendingTime         = 52
+	TagSide                = 54
+	TagSymbol              = 55
+	TagTargetCompID        = 56
+	TagText                = 58
+	TagTimeInForce         = 59
+	TagTransactTime        = 60
+	TagEncryptMethod       = 98
+	TagStopPx              = 99
+	TagCxlRejReason        = 102
+	TagOrdRejReason        = 103
+	TagHeartBtInt          = 108
+	TagMaxFloor            = 111
+	TagTestReqID           = 112
+	TagOrigSendingTime     = 122
+	TagGapFillFlag         = 123
+	TagResetSeqNumFlag     = 141
+	TagExecType            = 150
+	TagLeavesQty           = 151
+	TagRefTagID            = 371
+	TagRefMsgType          = 372
+	TagSessionRejectReason = 373
+	TagCxlRejResponseTo    = 434
+
+	// user-defined: the engine's self-trade prevention mode, e.g. CANCEL_OLDEST
+	TagSelfTradePrevention = 5000
+)
+
+// headerTags are written right after MsgType, in this order
+var headerTags = []int{TagSenderCompID, TagTargetCompID, TagMsgSeqNum, TagPossDupFlag, TagSendingTime, TagOrigSendingTime}
+
+type field struct {
+	tag   int
+	value string
+}
+
+// Message is a FIX message as an ordered list of fields. BeginString, BodyLength and
+// CheckSum are computed when it is encoded.
+type Message struct {
+	fields []field
+}
+
+func NewMessage(msgType string) *Message {
+	m := &Message{}
+	m.Set(TagMsgType, msgType)
+	return m
+}
+
+func (m *Message) Type() string {
+	return m.Get(TagMsgType)
+}
+
+// Get returns a field's value, "" if the message does not have it
+func (m *Message) Get(tag int) string {
+	value, _ := m.Lookup(tag)
+	return value
+}
+
+func (m *Message) Lookup(tag int) (string, bool) {
+	for _, f := range m.fields {
+		if f.tag == tag {
+			return f.value, true
+		}
+	}
+	return "", false
+}
+
+// Int parses an integer field
+func (m *Message) Int(tag int) (int64, error) {
+	value, ok := m.Lookup(tag)
+	if !ok {
+		return 0, &FieldError{Tag: tag, Reason: RejectRequiredTagMissing}
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, &FieldError{Tag: tag, Reason: RejectIncorrectDataFormat}
+	}
+	return n, nil
+}
+
+func (m *Message) SeqNum() uint64 {
+	n, _ := strconv.ParseUint(m.Get(TagMsgSeqNum), 10, 64)
+	return n
+}
+
+// Set replaces a field's value or appends the field
+func (m *Message) Set(tag int, value string) *Message {
+	for i := range m.fields {
+		if m.fields[i].tag == tag {
+			m.fields[i].value = value
+			return m
+		}
+	}
+	m.fields = append(m.fields, field{tag: tag, value: value})
+	return m
+}
+
+func (m *Message) SetInt(tag int, value int64) *Message {
+	return m.Set(tag, strconv.FormatInt(value, 10))
+}
+
+// Bytes encodes the message with its BeginString, BodyLength and CheckSum
+func (m *Message) Bytes() []byte {
+	var body bytes.Buffer
+	writeField(&body, TagMsgType, m.Type())
+	for _, tag := range headerTags {
+		if value, ok := m.Lookup(tag); ok {
+			writeField(&body, tag, value)
+		}
+	}
+	for _, f := range m.fields {
+		if !isHeaderTag(f.tag) {
+			writeField(&body, f.tag, f.value)
+		}
+	}
+
+	var out bytes.Buffer
+	writeField(&out, TagBeginString, BeginString)
+	writeField(&out, TagBodyLength, strconv.Itoa(body.Len()))
+	out.Write(body.Bytes())
+	writeField(&out, TagCheckSum, fmt.Sprintf("%03d", checksum(out.Bytes())))
+	return out.Bytes()
+}
+
+// String shows the message with | for SOH, for logs
+func (m *Message) String() string {
+	return string(bytes.ReplaceAll(m.Bytes(), []byte{soh}, []byte{'|'}))
+}
+
+func isHeaderTag(tag int) bool {
+	switch tag {
+	case TagBeginString, TagBodyLength, TagMsgType, TagCheckSum:
+		return true
+	}
+	for _, header := range headerTags {
+		if tag == header {
+			return true
+		}
+	}
+	return false
+}
+
+func writeField(buf *bytes.Buffer, tag int, value string) {
+	buf.WriteString(strconv.Itoa(tag))
+	buf.WriteByte('=')
+	buf.WriteString(value)
+	buf.WriteByte(soh)
+}
+
+func checksum(data []byte) int {
+	var sum int
+	for _, b := range data {
+		sum += int(b)
+	}
+	return sum % 256
+}
+
+// ReadMessage reads one framed message. An error means the stream cannot be trusted any
+// more; a message that is framed correctly but garbled is reported by Parse instead.
+func ReadMessage(r *bufio.Reader) ([]byte, error) {
+	begin, err := r.ReadBytes(soh)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(begin, []byte("8="+BeginString+"\x01")) {
+		return nil, &ParseError{Reason: "expected BeginString " + BeginString}
+	}
+
+	lengthField, err := r.ReadBytes(soh)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.HasPrefix(lengthField, []byte("9=")) {
+		return nil, &ParseError{Reason: "expected BodyLength"}
+	}
+	length, err := strconv.Atoi(string(lengthField[2 : len(lengthField)-1]))
+	if err != nil || length <= 0 || length > maxBodyLength {
+		return nil, &ParseError{Reason: "invalid BodyLength"}
+	}
+
+	// body plus the 7 byte trailer 10=NNN<SOH>
+	raw := make([]byte, len(begin)+len(lengthField)+length+7)
+	n := copy(raw, begin)
+	n += copy(raw[n:], lengthField)
+	if _, err := io.ReadFull(r, raw[n:]); err != nil {
+		return nil, err
+	}
+	return raw, nil
+}
+
+// Parse decodes a framed message and checks its BodyLength and CheckSum
+func Parse(raw []byte) (*Message, error) {
+	if len(raw) < len("10=000\x01")+1 {
+		return nil, &ParseError{Reason: "message too short"}
+	}
+	trailer := bytes.LastIndex(raw[:len(raw)-1], []byte{soh})
+	if trailer < 0 || !bytes.HasPrefix(raw[trailer+1:], []byte("10=")) || raw[len(raw)-1] != soh {
+		return nil, &ParseError{Reason: "missing CheckSum"}
+	}
+	sum, err := strconv.Atoi(string(raw[trailer+4 : len(raw)-1]))
+	if err != nil || sum != checksum(raw[:trailer+1]) {
+		return nil, &ParseError{Reason: "invalid CheckSum"}
+	}
+
+	m := &Message{}
+	bodyStart, bodyLength := -1, 0
+	for pos, i := 0, 0; pos <= trailer; i++ {
+		end := pos + bytes.IndexByte(raw[pos:], soh)
+		token := raw[pos:end]
+		eq := bytes.IndexByte(token, '=')
+		if eq <= 0 {
+			return nil, &ParseError{Reason: "malformed field"}
+		}
+		tag, err := strconv.Atoi(string(token[:eq]))
+		if err != nil {
+			return nil, &ParseError{Reason: "malformed tag"}
+		}
+		pos = end + 1
+
+		switch {
+		case i == 0 && tag != TagBeginString, i == 1 && tag != TagBodyLength, i == 2 && tag != TagMsgType:
+			return nil, &ParseError{Reason: "header out of order"}
+		case i == 1:
+			bodyLength, _ = strconv.Atoi(string(token[eq+1:]))
+			bodyStart = pos
+		case i > 1:
+			m.fields = append(m.fields, field{tag: tag, value: string(token[eq+1:])})
+		}
+	}
+	if bodyStart < 0 || trailer+1-bodyStart != bodyLength || len(m.fields) == 0 {
+		return nil, &ParseError{Reason: "invalid BodyLength"}
+	}
+	return m, nil
+}
+
+type ParseError struct {
+	Reason string
+}
+
+func (e *ParseError) Error() string {
+	return "fix: " + e.Reason
+}
+
+// SessionRejectReason values
+const (
+	RejectRequiredTagMissing  = 1
+	RejectValueIncorrect      = 5
+	RejectIncorrectDataFormat = 6
+	RejectCompIDProblem       = 9
+	RejectSendingTimeAccuracy = 10
+	RejectInvalidMsgType      = 11
+)
+
+// FieldError is a field of an inbound message that is missing or cannot be used, answered
+// with a session level Reject
+type FieldError struct {
+	Tag    int
+	Reason int // SessionRejectReason
+}
+
+func (e *FieldError) Error() string {
+	switch e.Reason {
+	case RejectRequiredTagMissing:
+		return "Required tag missing: " + strconv.Itoa(e.Tag)
+	case RejectIncorrectDataFormat:
+		return "Incorrect data format for tag " + strconv.Itoa(e.Tag)
+	}
+	return "Value is incorrect for tag " + strconv.Itoa(e.Tag)
+}
@@ -0,0 +1,513 @@
+package fix
+
+import (
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"match-engine/src/engine"
+	"match-engine/src/executions"
+)
+
+const (
+	sendingTimeFormat = "20060102-15:04:05.000"
+
+	// how often the session checks its heartbeat timers
+	timerInterval = 100 * time.Millisecond
+	writeTimeout  = 5 * time.Second
+)
+
+// Session is the acceptor's side of one counterparty. It outlives connections: orders,
+// sequence numbers and the messages sent are kept in its store, and execution reports
+// that arrive while the counterparty is away are sent and stored so it can ask for them
+// again after logging back on. A single goroutine runs the session, so nothing in it
+// needs a lock.
+type Session struct {
+	ID string // the counterparty's CompID, which is also the account of its orders
+
+	compID     string
+	matcher    *engine.Matcher
+	hub        *executions.Hub
+	executions *executions.Session
+	store      *FileStore
+
+	events chan event
+	quit   chan struct{}
+	done   chan struct{}
+
+	conn            net.Conn
+	heartBtInt      time.Duration
+	lastSent        time.Time
+	lastReceived    time.Time
+	testRequestSent bool
+	resendUpTo      uint64 // highest sequence number asked for again, resends below it are in flight
+
+	// handled holds, per order, the command whose reports were already sent from its
+	// MatchResult, so the same reports coming from the hub are skipped. 0 skips every
+	// report, for orders rejected without a sequence number coming back.
+	handled map[string]uint64
+}
+
+// event is a message read from a connection, or the connection failing
+type event struct {
+	conn  net.Conn
+	raw   []byte
+	err   error
+	logon bool
+}
+
+func newSession(id, compID string, store *FileStore, matcher *engine.Matcher, hub *executions.Hub) *Session {
+	s := &Session{
+		ID:         id,
+		compID:     compID,
+		matcher:    matcher,
+		hub:        hub,
+		executions: hub.Subscribe(id),
+		store:      store,
+		events:     make(chan event),
+		quit:       make(chan struct{}),
+		done:       make(chan struct{}),
+		handled:    make(map[string]uint64),
+	}
+	go s.run()
+	return s
+}
+
+func (s *Session) run() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(timerInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case report, ok := <-s.executions.Reports:
+			if !ok {
+				s.resubscribe()
+				continue
+			}
+			s.onExecutionReport(report)
+
+		case ev := <-s.events:
+			s.onEvent(ev)
+
+		case now := <-ticker.C:
+			s.onTimer(now)
+
+		case <-s.quit:
+			// edge case: reports already published are stored so they can be resent later
+			s.drainReports()
+			if s.conn != nil {
+				s.logout("Acceptor shutting down")
+			}
+			s.hub.Unsubscribe(s.executions)
+			if err := s.store.Close(); err != nil {
+				log.Error().Err(err).Str("session", s.ID).Msg("Error closing FIX session store")
+			}
+			return
+		}
+	}
+}
+
+// resubscribe replaces a hub session that fell behind. The reports it dropped are lost,
+// the counterparty has to check its orders' status.
+func (s *Session) resubscribe() {
+	log.Warn().
+		Str("session", s.ID).
+		Msg("FIX session fell behind the execution reports, some were dropped")
+	s.executions = s.hub.Subscribe(s.ID)
+}
+
+// drainReports sends the reports already waiting, so that the ones a synchronous command
+// produces are the only ones left for its orders
+func (s *Session) drainReports() {
+	for {
+		select {
+		case report, ok := <-s.executions.Reports:
+			if !ok {
+				s.resubscribe()
+				continue
+			}
+			s.onExecutionReport(report)
+		default:
+			return
+		}
+	}
+}
+
+// stop asks the session to log out and waits until it has
+func (s *Session) stop() {
+	close(s.quit)
+	<-s.done
+}
+
+// deliver hands an event to the session, false if the session has stopped
+func (s *Session) deliver(ev event) bool {
+	select {
+	case s.events <- ev:
+		return true
+	case <-s.done:
+		return false
+	}
+}
+
+func (s *Session) onEvent(ev event) {
+	if ev.logon {
+		if s.conn != nil {
+			// edge case: a second connection for a logged on session is turned away, the
+			// first one keeps the session
+			log.Warn().
+				Str("session", s.ID).
+				Str("remote", ev.conn.RemoteAddr().String()).
+				Msg("FIX session already logged on, closing new connection")
+			ev.conn.Close()
+			return
+		}
+		s.conn = ev.conn
+		s.lastReceived = time.Now()
+		s.testRequestSent = false
+		s.resendUpTo = 0
+	}
+
+	// edge case: events from a connection the session already dropped
+	if ev.conn != s.conn {
+		return
+	}
+	if ev.err != nil {
+		log.Info().
+			Err(ev.err).
+			Str("session", s.ID).
+			Msg("FIX connection closed")
+		s.disconnect()
+		return
+	}
+
+	s.lastReceived = time.Now()
+	s.testRequestSent = false
+
+	msg, err := Parse(ev.raw)
+	if err != nil {
+		// edge case: garbled messages are ignored, the sequence gap they leave is resent
+		log.Warn().
+			Err(err).
+			Str("session", s.ID).
+			Msg("Ignoring garbled FIX message")
+		return
+	}
+
+	if ev.logon {
+		s.onLogon(msg)
+		return
+	}
+	s.onMessage(msg)
+}
+
+func (s *Session) onLogon(msg *Message) {
+	heartBtInt, err := msg.Int(TagHeartBtInt)
+	if err != nil || heartBtInt <= 0 {
+		s.logout("Logon requires a positive HeartBtInt")
+		return
+	}
+	if msg.Get(TagEncryptMethod) != "0" {
+		s.logout("Logon requires EncryptMethod 0")
+		return
+	}
+
+	reset := msg.Get(TagResetSeqNumFlag) == "Y"
+	if reset {
+		if err := s.store.Reset(); err != nil {
+			log.Error().Err(err).Str("session", s.ID).Msg("Failed to reset FIX session store")
+		}
+	}
+
+	seq, expected := msg.SeqNum(), s.store.NextTargetSeq()
+	if seq < expected {
+		s.logout("MsgSeqNum too low, expecting " + strconv.FormatUint(expected, 10) + " but received " + strconv.FormatUint(seq, 10))
+		return
+	}
+
+	s.heartBtInt = time.Duration(heartBtInt) * time.Second
+	reply := NewMessage(MsgLogon).
+		Set(TagEncryptMethod, "0").
+		SetInt(TagHeartBtInt, heartBtInt)
+	if reset {
+		reply.Set(TagResetSeqNumFlag, "Y")
+	}
+	s.send(reply)
+
+	log.Info().
+		Str("session", s.ID).
+		Str("remote", s.conn.RemoteAddr().String()).
+		Uint64("target_seq", seq).
+		Uint64("sender_seq", s.store.NextSenderSeq()).
+		Msg("FIX session logged on")
+
+	// edge case: the counterparty sent messages we never got, the Logon itself is filled
+	// in by the resend
+	if seq > expected {
+		s.requestResend(expected, seq)
+		return
+	}
+	s.advanceTarget(seq + 1)
+}
+
+func (s *Session) onMessage(msg *Message) {
+	if msg.Get(TagSenderCompID) != s.ID || msg.Get(TagTargetCompID) != s.compID {
+		s.reject(msg, &FieldError{Tag: TagSenderCompID, Reason: RejectCompIDProblem})
+		s.logout("CompID problem")
+		return
+	}
+
+	// edge case: SequenceReset-Reset moves the sequence number whatever MsgSeqNum says
+	if msg.Type() == MsgSequenceReset && msg.Get(TagGapFillFlag) != "Y" {
+		s.onSequenceReset(msg)
+		return
+	}
+
+	seq, expected := msg.SeqNum(), s.store.NextTargetSeq()
+	switch {
+	case seq == 0:
+		s.logout("MsgSeqNum missing")
+		return
+	case seq > expected:
+		// edge case: resend requests are answered straight away, the counterparty may be
+		// waiting for them before it fills our gap
+		if msg.Type() == MsgResendRequest {
+			s.onResendRequest(msg)
+		}
+		s.requestResend(expected, seq)
+		return
+	case seq < expected:
+		if msg.Get(TagPossDupFlag) == "Y" {
+			return
+		}
+		s.logout("MsgSeqNum too low, expecting " + strconv.FormatUint(expected, 10) + " but received " + strconv.FormatUint(seq, 10))
+		return
+	}
+
+	s.advanceTarget(seq + 1)
+
+	switch msg.Type() {
+	case MsgHeartbeat, MsgLogon:
+	case MsgTestRequest:
+		testReqID, ok := msg.Lookup(TagTestReqID)
+		if !ok {
+			s.reject(msg, &FieldError{Tag: TagTestReqID, Reason: RejectRequiredTagMissing})
+			return
+		}
+		s.send(NewMessage(MsgHeartbeat).Set(TagTestReqID, testReqID))
+	case MsgResendRequest:
+		s.onResendRequest(msg)
+	case MsgSequenceReset:
+		s.onSequenceReset(msg)
+	case MsgReject:
+		log.Warn().
+			Str("session", s.ID).
+			Str("ref_seq", msg.Get(TagRefSeqNum)).
+			Str("text", msg.Get(TagText)).
+			Msg("FIX counterparty rejected a message")
+	case MsgLogout:
+		log.Info().
+			Str("session", s.ID).
+			Str("text", msg.Get(TagText)).
+			Msg("FIX counterparty logged out")
+		s.logout("")
+	case MsgNewOrderSingle:
+		s.onNewOrderSingle(msg)
+	case MsgOrderCancelRequest:
+		s.onOrderCancelRequest(msg)
+	case MsgOrderCancelReplaceRequest:
+		s.onOrderCancelReplaceRequest(msg)
+	default:
+		s.reject(msg, &FieldError{Tag: TagMsgType, Reason: RejectInvalidMsgType})
+	}
+}
+
+func (s *Session) advanceTarget(next uint64) {
+	if err := s.store.SetNextTargetSeq(next); err != nil {
+		log.Error().Err(err).Str("session", s.ID).Msg("Failed to save FIX sequence number")
+	}
+}
+
+// requestResend asks for the messages from begin on, unless an earlier request already
+// covers them
+func (s *Session) requestResend(begin, seen uint64) {
+	if begin <= s.resendUpTo {
+		if seen > s.resendUpTo {
+			s.resendUpTo = seen
+		}
+		return
+	}
+	s.resendUpTo = seen
+	s.send(NewMessage(MsgResendRequest).
+		Set(TagBeginSeqNo, strconv.FormatUint(begin, 10)).
+		Set(TagEndSeqNo, "0"))
+}
+
+func (s *Session) onSequenceReset(msg *Message) {
+	newSeqNo, err := msg.Int(TagNewSeqNo)
+	if err != nil {
+		s.reject(msg, err.(*FieldError))
+		return
+	}
+	// edge case: sequence numbers never go backwards
+	if uint64(newSeqNo) < s.store.NextTargetSeq() {
+		s.reject(msg, &FieldError{Tag: TagNewSeqNo, Reason: RejectValueIncorrect})
+		return
+	}
+	s.advanceTarget(uint64(newSeqNo))
+}
+
+// onResendRequest sends the requested application messages again with PossDupFlag set,
+// and a SequenceReset-GapFill over each run of session messages, which are never resent
+func (s *Session) onResendRequest(msg *Message) {
+	begin, err := msg.Int(TagBeginSeqNo)
+	if err != nil {
+		s.reject(msg, err.(*FieldError))
+		return
+	}
+	end, err := msg.Int(TagEndSeqNo)
+	if err != nil {
+		s.reject(msg, err.(*FieldError))
+		return
+	}
+
+	next := s.store.NextSenderSeq()
+	last := next - 1
+	if end != 0 && uint64(end) < last {
+		last = uint64(end)
+	}
+	if begin <= 0 || uint64(begin) > last {
+		return
+	}
+
+	seqs, sent := s.store.Messages(uint64(begin), last)
+	gapFrom := uint64(begin)
+	for _, seq := range seqs {
+		original, err := Parse(sent[seq])
+		if err != nil || isAdmin(original.Type()) {
+			continue
+		}
+		if seq > gapFrom {
+			s.gapFill(gapFrom, seq)
+		}
+		original.Set(TagPossDupFlag, "Y").
+			Set(TagOrigSendingTime, original.Get(TagSendingTime)).
+			Set(TagSendingTime, time.Now().UTC().Format(sendingTimeFormat))
+		s.write(original.Bytes())
+		gapFrom = seq + 1
+	}
+	if gapFrom <= last {
+		s.gapFill(gapFrom, last+1)
+	}
+}
+
+// gapFill tells the counterparty to skip from seq to newSeqNo. It reuses seq, so it is
+// not stored.
+func (s *Session) gapFill(seq, newSeqNo uint64) {
+	msg := NewMessage(MsgSequenceReset).
+		Set(TagSenderCompID, s.compID).
+		Set(TagTargetCompID, s.ID).
+		Set(TagMsgSeqNum, strconv.FormatUint(seq, 10)).
+		Set(TagPossDupFlag, "Y").
+		Set(TagSendingTime, time.Now().UTC().Format(sendingTimeFormat)).
+		Set(TagGapFillFlag, "Y").
+		Set(TagNewSeqNo, strconv.FormatUint(newSeqNo, 10))
+	s.write(msg.Bytes())
+}
+
+func isAdmin(msgType string) bool {
+	switch msgType {
+	case MsgHeartbeat, MsgTestRequest, MsgResendRequest, MsgReject, MsgSequenceReset, MsgLogout, MsgLogon:
+		return true
+	}
+	return false
+}
+
+// reject sends a session level Reject for a message that cannot be processed
+func (s *Session) reject(msg *Message, err *FieldError) {
+	reply := NewMessage(MsgReject).
+		Set(TagRefSeqNum, msg.Get(TagMsgSeqNum)).
+		SetInt(TagRefTagID, int64(err.Tag)).
+		Set(TagRefMsgType, msg.Type()).
+		SetInt(TagSessionRejectReason, int64(err.Reason)).
+		Set(TagText, err.Error())
+	s.send(reply)
+}
+
+func (s *Session) onTimer(now time.Time) {
+	if s.conn == nil || s.heartBtInt == 0 {
+		return
+	}
+
+	// edge case: a fifth of the interval allows for transmission delay
+	grace := s.heartBtInt / 5
+	silence := now.Sub(s.lastReceived)
+	switch {
+	case silence >= 2*s.heartBtInt+grace:
+		log.Warn().
+			Str("session", s.ID).
+			Dur("silence", silence).
+			Msg("FIX counterparty stopped responding, disconnecting")
+		s.disconnect()
+		return
+	case silence >= s.heartBtInt+grace && !s.testRequestSent:
+		s.testRequestSent = true
+		s.send(NewMessage(MsgTestRequest).Set(TagTestReqID, "TEST-"+strconv.FormatInt(now.UnixNano(), 10)))
+	}
+
+	if now.Sub(s.lastSent) >= s.heartBtInt {
+		s.send(NewMessage(MsgHeartbeat))
+	}
+}
+
+// send numbers, stores and writes a message. Messages sent while the counterparty is
+// away are only stored, it gets them by asking for a resend when it logs back on.
+func (s *Session) send(msg *Message) {
+	seq := s.store.NextSenderSeq()
+	msg.Set(TagSenderCompID, s.compID).
+		Set(TagTargetCompID, s.ID).
+		Set(TagMsgSeqNum, strconv.FormatUint(seq, 10)).
+		Set(TagSendingTime, time.Now().UTC().Format(sendingTimeFormat))
+
+	raw := msg.Bytes()
+	if err := s.store.SaveMessage(seq, raw); err != nil {
+		log.Error().Err(err).Str("session", s.ID).Uint64("seq", seq).Msg("Failed to store FIX message")
+	}
+	s.write(raw)
+}
+
+func (s *Session) write(raw []byte) {
+	if s.conn == nil {
+		return
+	}
+	s.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	if _, err := s.conn.Write(raw); err != nil {
+		log.Warn().Err(err).Str("session", s.ID).Msg("FIX write failed, disconnecting")
+		s.disconnect()
+		return
+	}
+	s.lastSent = time.Now()
+}
+
+// logout sends a Logout, with the reason if there is one, and drops the connection
+func (s *Session) logout(text string) {
+	msg := NewMessage(MsgLogout)
+	if text != "" {
+		msg.Set(TagText, text)
+		log.Warn().Str("session", s.ID).Str("reason", text).Msg("Logging out FIX session")
+	}
+	s.send(msg)
+	s.disconnect()
+}
+
+func (s *Session) disconnect() {
+	if s.conn == nil {
+		return
+	}
+	s.conn.Close()
+	s.conn = nil
+	s.heartBtInt = 0
+}
@@ -0,0 +1,221 @@
+package fix
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// FileStore keeps one session's state in a directory, so that a restarted acceptor carries
+// on where it stopped: the next sequence number in each direction, every message sent
+// (to answer resend requests) and which engine order each ClOrdID refers to. Writes are
+// not fsynced, the state survives a process restart but not a power loss. Only the
+// session's own goroutine uses it.
+type FileStore struct {
+	seqnums  *os.File
+	messages *os.File
+	orders   *os.File
+
+	nextSender uint64
+	nextTarget uint64
+	sent       map[uint64][]byte
+	orderIDs   map[string]string // ClOrdID → engine order ID, for every ClOrdID an order had
+	clOrdIDs   map[string]string // engine order ID → its latest ClOrdID
+}
+
+// seqnums holds "<next sender> <next target>\n", rewritten in place
+const seqnumsFormat = "%020d %020d\n"
+
+func OpenFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	s := &FileStore{
+		nextSender: 1,
+		nextTarget: 1,
+		sent:       make(map[uint64][]byte),
+		orderIDs:   make(map[string]string),
+		clOrdIDs:   make(map[string]string),
+	}
+
+	var err error
+	if s.seqnums, err = os.OpenFile(filepath.Join(dir, "seqnums"), os.O_RDWR|os.O_CREATE, 0o644); err != nil {
+		return nil, err
+	}
+	if s.messages, err = os.OpenFile(filepath.Join(dir, "messages"), os.O_RDWR|os.O_CREATE, 0o644); err != nil {
+		s.Close()
+		return nil, err
+	}
+	if s.orders, err = os.OpenFile(filepath.Join(dir, "orders"), os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o644); err != nil {
+		s.Close()
+		return nil, err
+	}
+
+	if err := s.load(); err != nil {
+		s.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *FileStore) load() error {
+	data, err := io.ReadAll(s.seqnums)
+	if err != nil {
+		return err
+	}
+	if len(data) > 0 {
+		if _, err := fmt.Sscanf(string(data), seqnumsFormat, &s.nextSender, &s.nextTarget); err != nil {
+			return &StoreError{File: s.seqnums.Name(), Err: err}
+		}
+	}
+
+	reader := bufio.NewReader(s.messages)
+	var offset int64
+	for {
+		raw, err := ReadMessage(reader)
+		if err != nil {
+			// edge case: a message torn by a crash mid-append was never sent, drop it
+			if err := s.messages.Truncate(offset); err != nil {
+				return err
+			}
+			break
+		}
+		msg, err := Parse(raw)
+		if err != nil {
+			return &StoreError{File: s.messages.Name(), Err: err}
+		}
+		s.sent[msg.SeqNum()] = raw
+		offset += int64(len(raw))
+	}
+	if _, err := s.messages.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+
+	data, err = io.ReadAll(s.orders)
+	if err != nil {
+		return err
+	}
+	// records are "<ClOrdID><SOH><order ID><SOH>", SOH cannot appear in a FIX value
+	fields := bytes.Split(data, []byte{soh})
+	for i := 0; i+1 < len(fields); i += 2 {
+		s.mapOrder(string(fields[i]), string(fields[i+1]))
+	}
+	return nil
+}
+
+func (s *FileStore) NextSenderSeq() uint64 {
+	return s.nextSender
+}
+
+func (s *FileStore) NextTargetSeq() uint64 {
+	return s.nextTarget
+}
+
+func (s *FileStore) SetNextTargetSeq(seq uint64) error {
+	s.nextTarget = seq
+	return s.writeSeqnums()
+}
+
+func (s *FileStore) SetNextSenderSeq(seq uint64) error {
+	s.nextSender = seq
+	return s.writeSeqnums()
+}
+
+func (s *FileStore) writeSeqnums() error {
+	_, err := s.seqnums.WriteAt([]byte(fmt.Sprintf(seqnumsFormat, s.nextSender, s.nextTarget)), 0)
+	return err
+}
+
+// SaveMessage records a message about to be sent with sequence number seq
+func (s *FileStore) SaveMessage(seq uint64, raw []byte) error {
+	if _, err := s.messages.Write(raw); err != nil {
+		return err
+	}
+	s.sent[seq] = raw
+	return s.SetNextSenderSeq(seq + 1)
+}
+
+// Messages returns the messages sent with sequence numbers from begin to end inclusive,
+// in order, keyed by sequence number
+func (s *FileStore) Messages(begin, end uint64) ([]uint64, map[uint64][]byte) {
+	var seqs []uint64
+	for seq := range s.sent {
+		if seq >= begin && seq <= end {
+			seqs = append(seqs, seq)
+		}
+	}
+	sort.Slice(seqs, func(i, j int) bool { return seqs[i] < seqs[j] })
+	return seqs, s.sent
+}
+
+// Reset starts both directions over at sequence number 1 and forgets the messages sent.
+// Orders keep their ClOrdIDs.
+func (s *FileStore) Reset() error {
+	if err := s.messages.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := s.messages.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	s.sent = make(map[uint64][]byte)
+	s.nextSender = 1
+	s.nextTarget = 1
+	return s.writeSeqnums()
+}
+
+// SaveOrder records that clOrdID now refers to an engine order
+func (s *FileStore) SaveOrder(clOrdID, orderID string) error {
+	if _, err := s.orders.Write([]byte(clOrdID + string(soh) + orderID + string(soh))); err != nil {
+		return err
+	}
+	s.mapOrder(clOrdID, orderID)
+	return nil
+}
+
+func (s *FileStore) mapOrder(clOrdID, orderID string) {
+	s.orderIDs[clOrdID] = orderID
+	s.clOrdIDs[orderID] = clOrdID
+}
+
+// OrderID looks up the engine order a ClOrdID, current or replaced, refers to
+func (s *FileStore) OrderID(clOrdID string) (string, bool) {
+	orderID, ok := s.orderIDs[clOrdID]
+	return orderID, ok
+}
+
+// ClOrdID is the latest ClOrdID of an engine order, false if this session did not enter it
+func (s *FileStore) ClOrdID(orderID string) (string, bool) {
+	clOrdID, ok := s.clOrdIDs[orderID]
+	return clOrdID, ok
+}
+
+func (s *FileStore) Close() error {
+	var firstErr error
+	for _, file := range []*os.File{s.seqnums, s.messages, s.orders} {
+		if file == nil {
+			continue
+		}
+		if err := file.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+type StoreError struct {
+	File string
+	Err  error
+}
+
+func (e *StoreError) Error() string {
+	return "fix: corrupt session store " + e.File + ": " + e.Err.Error()
+}
+
+func (e *StoreError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/contrib/websocket"
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog/log"
+
+	"match-engine/src/accounts"
+	"match-engine/src/engine"
+	"match-engine/src/executions"
+	"match-engine/src/models"
+)
+
+type ExecutionHandler struct {
+	Hub    *executions.Hub
+	Tokens *accounts.Tokens
+}
+
+// NewExecutionHandler streams the reports of hub. A session needs its account's token
+// from tokens, and with no tokens the stream is disabled.
+func NewExecutionHandler(hub *executions.Hub, tokens *accounts.Tokens) *ExecutionHandler {
+	return &ExecutionHandler{Hub: hub, Tokens: tokens}
+}
+
+// RequireAccount turns away plain HTTP requests, connections that do not say whose
+// reports they want and connections without "Authorization: Bearer <token>" for that
+// account
+func (h *ExecutionHandler) RequireAccount(c *fiber.Ctx) error {
+	if !websocket.IsWebSocketUpgrade(c) {
+		return c.Status(fiber.StatusUpgradeRequired).JSON(models.ErrorResponse{
+			Error: "Upgrade required: connect with a WebSocket client",
+		})
+	}
+
+	account := c.Query("account")
+	if account == "" || len(account) > maxAccountLength {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error: "Invalid account: account is required and must be at most " + strconv.Itoa(maxAccountLength) + " characters",
+		})
+	}
+
+	if !h.Tokens.Enabled() {
+		return c.Status(fiber.StatusForbidden).JSON(models.ErrorResponse{
+			Error: "Execution reports disabled: ACCOUNT_TOKENS is not set",
+		})
+	}
+	token, found := strings.CutPrefix(c.Get(fiber.HeaderAuthorization), "Bearer ")
+	if !found || token == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(models.ErrorResponse{
+			Error: "Unauthorized: the account's token is required",
+		})
+	}
+	if !h.Tokens.Authenticate(account, token) {
+		log.Warn().
+			Str("account", account).
+			Str("ip", c.IP()).
+			Msg("Execution report session rejected: token is not the account's")
+		return c.Status(fiber.StatusForbidden).JSON(models.ErrorResponse{
+			Error: "Forbidden: the token is not the account's",
+		})
+	}
+	return c.Next()
+}
+
+// Stream serves /ws/v1/executions?account=...: every execution report of the account's
+// orders from the moment the session opens, numbered per session
+func (h *ExecutionHandler) Stream() fiber.Handler {
+	return websocket.New(h.serve)
+}
+
+func (h *ExecutionHandler) serve(conn *websocket.Conn) {
+	account := conn.Query("account")
+	session := h.Hub.Subscribe(account)
+	defer h.Hub.Unsubscribe(session)
+
+	// the session is one way, reading only notices the client going away
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	if err := conn.WriteJSON(models.ExecutionStreamStatus{Type: "subscribed", Account: account}); err != nil {
+		return
+	}
+
+	var seq uint64
+	for {
+		select {
+		case report, ok := <-session.Reports:
+			if !ok {
+				if session.Lagged() {
+					log.Warn().
+						Str("account", account).
+						Str("ip", conn.IP()).
+						Msg("Execution report session fell behind, closing it")
+					conn.WriteJSON(models.ExecutionStreamStatus{
+						Type:    "error",
+						Account: account,
+						Error:   "Session fell behind and reports were dropped: reconnect and check order status",
+					})
+				}
+				return
+			}
+			seq++
+			if err := conn.WriteJSON(newExecutionReportMessage(seq, report)); err != nil {
+				return
+			}
+
+		case <-closed:
+			return
+		}
+	}
+}
+
+func newExecutionReportMessage(seq uint64, report *engine.ExecutionReport) models.ExecutionReportMessage {
+	return models.ExecutionReportMessage{
+		Type:               "execution_report",
+		Sequence:           seq,
+		ExecType:           string(report.ExecType),
+		OrderID:            report.OrderID,
+		ClientOrderID:      report.ClientOrderID,
+		Account:            report.Account,
+		Symbol:             report.Symbol,
+		Side:               string(report.Side),
+		Status:             string(report.Status),
+		Price:              report.Price,
+		Quantity:           report.Quantity,
+		TradeID:            report.TradeID,
+		FillPrice:          report.FillPrice,
+		FillQuantity:       report.FillQuantity,
+		CumulativeQuantity: report.CumulativeQuantity,
+		LeavesQuantity:     report.LeavesQuantity,
+		SelfTrade:          report.SelfTrade,
+		CommandSequence:    report.CommandSequence,
+		Timestamp:          report.Timestamp / int64(time.Millisecond),
+		TimestampNs:        report.Timestamp,
+	}
+}
@@ -0,0 +1,399 @@
+package handlers
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"match-engine/src/engine"
+	"match-engine/src/marketdata"
+	"match-engine/src/models"
+	"match-engine/src/pb"
+)
+
+// GRPCHandler serves the gRPC OrderService. Orders go through the OrderHandler, so they
+// are validated like REST orders and counted in the same metrics.
+type GRPCHandler struct {
+	pb.UnimplementedOrderServiceServer
+
+	Orders *OrderHandler
+	Hub    *marketdata.Hub
+
+	shutdown     chan struct{}
+	shutdownOnce sync.Once
+}
+
+func NewGRPCHandler(orders *OrderHandler, hub *marketdata.Hub) *GRPCHandler {
+	return &GRPCHandler{
+		Orders:   orders,
+		Hub:      hub,
+		shutdown: make(chan struct{}),
+	}
+}
+
+// Shutdown ends every open stream, which grpc.Server.GracefulStop would otherwise wait on
+func (h *GRPCHandler) Shutdown() {
+	h.shutdownOnce.Do(func() {
+		close(h.shutdown)
+	})
+}
+
+func (h *GRPCHandler) SubmitOrder(ctx context.Context, req *pb.SubmitOrderRequest) (*pb.SubmitOrderResponse, error) {
+	submitReq := models.SubmitOrderRequest{
+		Symbol:              req.Symbol,
+		Side:                req.Side,
+		Type:                req.Type,
+		Price:               req.Price,
+		StopPrice:           req.StopPrice,
+		Quantity:            req.Quantity,
+		DisplayQuantity:     req.DisplayQuantity,
+		TimeInForce:         req.TimeInForce,
+		ClientOrderID:       req.ClientOrderId,
+		Account:             req.Account,
+		SelfTradePrevention: req.SelfTradePrevention,
+	}
+	ip := peerIP(ctx)
+
+	if err := validateSubmitOrderRequest(&submitReq); err != nil {
+		log.Warn().
+			Err(err).
+			Str("symbol", req.Symbol).
+			Str("side", req.Side).
+			Str("type", req.Type).
+			Str("ip", ip).
+			Msg("Invalid order request")
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	if err := h.Orders.authenticateAccount(req.Account, bearerToken(ctx)); err != nil {
+		log.Warn().
+			Str("account", req.Account).
+			Str("ip", ip).
+			Msg("Order rejected: " + err.Error())
+		if err.Status == http.StatusUnauthorized {
+			return nil, status.Error(codes.Unauthenticated, err.Error())
+		}
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	}
+
+	order, result, err := h.Orders.placeOrder(&submitReq, ip)
+	if err != nil {
+		if _, ok := err.(*engine.DuplicateClientOrderIDError); ok {
+			return nil, status.Error(codes.AlreadyExists, "Duplicate client_order_id: already used by another order")
+		}
+		if liquidityErr, ok := err.(*engine.InsufficientLiquidityError); ok {
+			return nil, status.Error(codes.FailedPrecondition, insufficientLiquidityMessage(liquidityErr, req.Quantity))
+		}
+		switch err.(type) {
+		case *engine.InstrumentNotTradingError, *engine.AuctionOrderError, *engine.SessionError, *engine.HaltedError:
+			return nil, codedError(codes.FailedPrecondition, err, instrumentRejectMessage(err))
+		}
+		if message := instrumentRejectMessage(err); message != "" {
+			return nil, status.Error(codes.InvalidArgument, message)
+		}
+		return nil, status.Error(codes.Internal, "Internal server error")
+	}
+
+	return &pb.SubmitOrderResponse{
+		OrderId:           order.ID,
+		ClientOrderId:     req.ClientOrderId,
+		Status:            string(result.Status),
+		FilledQuantity:    result.FilledQuantity,
+		RemainingQuantity: result.RemainingQuantity,
+		Trades:            newPBFills(result.Trades),
+		Seq:               result.Sequence,
+		Message:           submitResultMessage(result),
+	}, nil
+}
+
+func (h *GRPCHandler) CancelOrder(ctx context.Context, req *pb.CancelOrderRequest) (*pb.CancelOrderResponse, error) {
+	order, err := h.findOrder(req.OrderId, req.ClientOrderId)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := h.Orders.cancel(order.ID, peerIP(ctx))
+	if err != nil {
+		switch err.(type) {
+		case *engine.OrderNotFoundError:
+			return nil, status.Error(codes.NotFound, "Order not found")
+		case *engine.OrderNotCancellableError, *engine.SessionError, *engine.HaltedError:
+			return nil, codedError(codes.FailedPrecondition, err, err.Error())
+		}
+		return nil, status.Error(codes.Internal, "Internal server error")
+	}
+
+	return &pb.CancelOrderResponse{
+		OrderId:       order.ID,
+		ClientOrderId: result.Order.ClientOrderID,
+		Status:        string(engine.StatusCancelled),
+		Seq:           result.Sequence,
+	}, nil
+}
+
+func (h *GRPCHandler) GetOrder(ctx context.Context, req *pb.GetOrderRequest) (*pb.Order, error) {
+	order, err := h.findOrder(req.OrderId, req.ClientOrderId)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.Order{
+		OrderId:         order.ID,
+		ClientOrderId:   order.ClientOrderID,
+		Account:         order.Account,
+		Symbol:          order.Symbol,
+		Side:            string(order.Side),
+		Type:            string(order.Type),
+		Price:           order.Price,
+		StopPrice:       order.StopPrice,
+		Quantity:        order.Quantity,
+		DisplayQuantity: order.DisplayQuantity,
+		FilledQuantity:  order.GetFilledQuantity(),
+		Status:          string(order.GetStatus()),
+		TimestampNs:     order.Timestamp,
+		Fills:           newPBFills(order.Fills()),
+	}, nil
+}
+
+// findOrder looks an order up by ID, or by client_order_id when no ID is given
+func (h *GRPCHandler) findOrder(orderID, clientOrderID string) (*engine.Order, error) {
+	if orderID == "" && clientOrderID == "" {
+		return nil, status.Error(codes.InvalidArgument, "Invalid request: order_id or client_order_id is required")
+	}
+
+	var order *engine.Order
+	var exists bool
+	if orderID != "" {
+		order, exists = h.Orders.Matcher.GetOrder(orderID)
+	} else {
+		order, exists = h.Orders.Matcher.GetOrderByClientID(clientOrderID)
+	}
+	if !exists {
+		return nil, status.Error(codes.NotFound, "Order not found")
+	}
+	return order, nil
+}
+
+func (h *GRPCHandler) GetOrderBook(ctx context.Context, req *pb.GetOrderBookRequest) (*pb.OrderBook, error) {
+	if req.Symbol == "" {
+		return nil, status.Error(codes.InvalidArgument, "Invalid symbol: symbol is required")
+	}
+
+	orderBook, listed := h.Orders.Matcher.ListedOrderBook(req.Symbol)
+	if !listed {
+		return nil, status.Error(codes.NotFound, "Unknown symbol")
+	}
+	bidsLevels, asksLevels := orderBook.GetOrderBookSnapshot(orderBookDepth(int(req.Depth)))
+
+	return &pb.OrderBook{
+		Symbol:      req.Symbol,
+		TimestampNs: h.Orders.Matcher.Now().UnixNano(),
+		Bids:        newPBPriceLevels(bidsLevels),
+		Asks:        newPBPriceLevels(asksLevels),
+	}, nil
+}
+
+// StreamTrades sends the symbol's trades as they execute, starting with the response
+// headers once no trade can be missed. There is no snapshot to resync from, so a
+// subscriber that falls behind gets an error and backfills from /api/v1/trades.
+func (h *GRPCHandler) StreamTrades(req *pb.StreamTradesRequest, stream pb.OrderService_StreamTradesServer) error {
+	if req.Symbol == "" {
+		return status.Error(codes.InvalidArgument, "Invalid symbol: symbol is required")
+	}
+
+	sub := h.Hub.Subscribe(req.Symbol)
+	defer h.Hub.Unsubscribe(sub)
+
+	if err := stream.SendHeader(nil); err != nil {
+		return err
+	}
+
+	for {
+		update, err := h.next(stream.Context(), sub)
+		if err != nil {
+			return err
+		}
+		if update == nil {
+			log.Warn().
+				Str("symbol", req.Symbol).
+				Str("ip", peerIP(stream.Context())).
+				Msg("gRPC trade subscriber fell behind, ending stream")
+			return status.Error(codes.ResourceExhausted, "Trade stream fell behind, trades were missed")
+		}
+		for _, trade := range update.Trades {
+			if err := stream.Send(newPBTrade(trade)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// StreamBook sends a full depth snapshot, then the symbol's level updates. A subscriber
+// that falls behind gets a fresh snapshot, as on the WebSocket feed.
+func (h *GRPCHandler) StreamBook(req *pb.StreamBookRequest, stream pb.OrderService_StreamBookServer) error {
+	if req.Symbol == "" {
+		return status.Error(codes.InvalidArgument, "Invalid symbol: symbol is required")
+	}
+	if _, listed := h.Orders.Matcher.ListedOrderBook(req.Symbol); !listed {
+		return status.Error(codes.NotFound, "Unknown symbol")
+	}
+
+	for {
+		sub := h.Hub.Subscribe(req.Symbol)
+		lagged, err := h.streamBook(stream, sub)
+		h.Hub.Unsubscribe(sub)
+		if err != nil || !lagged {
+			return err
+		}
+		log.Warn().
+			Str("symbol", req.Symbol).
+			Str("ip", peerIP(stream.Context())).
+			Msg("gRPC book subscriber fell behind, resending snapshot")
+	}
+}
+
+// streamBook sends one subscription's snapshot and updates, until it lags or fails
+func (h *GRPCHandler) streamBook(stream pb.OrderService_StreamBookServer, sub *marketdata.Subscription) (bool, error) {
+	orderBook := h.Orders.Matcher.GetOrCreateOrderBook(sub.Symbol)
+	bidsLevels, asksLevels, snapshotSeq := orderBook.GetSequencedOrderBookSnapshot()
+
+	err := stream.Send(&pb.BookUpdate{
+		Symbol:      sub.Symbol,
+		Seq:         snapshotSeq,
+		Snapshot:    true,
+		Bids:        newPBPriceLevels(bidsLevels),
+		Asks:        newPBPriceLevels(asksLevels),
+		TimestampNs: h.Orders.Matcher.Now().UnixNano(),
+	})
+	if err != nil {
+		return false, err
+	}
+
+	for {
+		update, err := h.next(stream.Context(), sub)
+		if err != nil {
+			return false, err
+		}
+		if update == nil {
+			return true, nil
+		}
+		// edge case: updates buffered before the snapshot was taken are already in it
+		if update.Sequence <= snapshotSeq {
+			continue
+		}
+		if err := stream.Send(newPBBookUpdate(update)); err != nil {
+			return false, err
+		}
+	}
+}
+
+// next waits for a subscription's next update. It returns nil once the subscription
+// lagged, and an error when the stream is cancelled or the server shuts down.
+func (h *GRPCHandler) next(ctx context.Context, sub *marketdata.Subscription) (*engine.BookUpdate, error) {
+	select {
+	case update, ok := <-sub.Updates:
+		if !ok {
+			return nil, nil
+		}
+		return update, nil
+	case <-ctx.Done():
+		return nil, status.FromContextError(ctx.Err()).Err()
+	case <-h.shutdown:
+		return nil, status.Error(codes.Unavailable, "Server shutting down")
+	}
+}
+
+// codedError is a status with message, which starts with the engine error's code when it
+// has one, like the Text of a FIX reject
+func codedError(c codes.Code, err error, message string) error {
+	if code := engine.ErrorCode(err); code != "" {
+		message = code + ": " + message
+	}
+	return status.Error(c, message)
+}
+
+func peerIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+	return host
+}
+
+// bearerToken is the token in the call's "authorization: Bearer <token>" metadata
+func bearerToken(ctx context.Context) string {
+	values := metadata.ValueFromIncomingContext(ctx, "authorization")
+	if len(values) == 0 {
+		return ""
+	}
+	token, _ := strings.CutPrefix(values[0], "Bearer ")
+	return token
+}
+
+func newPBFills(trades []*engine.Trade) []*pb.Fill {
+	fills := make([]*pb.Fill, 0, len(trades))
+	for _, trade := range trades {
+		fills = append(fills, &pb.Fill{
+			TradeId:     trade.TradeID,
+			Price:       trade.Price,
+			Quantity:    trade.Quantity,
+			TimestampNs: trade.Timestamp,
+		})
+	}
+	return fills
+}
+
+func newPBPriceLevels(levels []engine.OrderBookSnapshot) []*pb.PriceLevel {
+	infos := make([]*pb.PriceLevel, 0, len(levels))
+	for _, level := range levels {
+		infos = append(infos, &pb.PriceLevel{
+			Price:    level.Price,
+			Quantity: level.Quantity,
+		})
+	}
+	return infos
+}
+
+func newPBTrade(trade *engine.Trade) *pb.Trade {
+	return &pb.Trade{
+		TradeId:       trade.TradeID,
+		Symbol:        trade.Symbol,
+		Price:         trade.Price,
+		Quantity:      trade.Quantity,
+		TimestampNs:   trade.Timestamp,
+		BuyOrderId:    trade.BuyOrderID,
+		SellOrderId:   trade.SellOrderID,
+		AggressorSide: string(trade.AggressorSide),
+		CommandSeq:    trade.Sequence,
+	}
+}
+
+func newPBBookUpdate(update *engine.BookUpdate) *pb.BookUpdate {
+	changes := make([]*pb.LevelChange, 0, len(update.Changes))
+	for _, change := range update.Changes {
+		changes = append(changes, &pb.LevelChange{
+			Side:     string(change.Side),
+			Price:    change.Price,
+			Quantity: change.Quantity,
+		})
+	}
+	return &pb.BookUpdate{
+		Symbol:      update.Symbol,
+		Seq:         update.Sequence,
+		Changes:     changes,
+		CommandSeq:  update.CommandSequence,
+		TimestampNs: update.Timestamp,
+	}
+}
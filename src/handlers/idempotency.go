@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"sync"
+	"time"
+
+	"match-engine/src/models"
+)
+
+// submission is the outcome of one order submission, kept so that a retry with the
+// same key within the dedupe window gets the original response instead of a new order
+type submission struct {
+	request   models.SubmitOrderRequest
+	status    int         // 0 while the original request is still being processed
+	body      interface{} // response body sent for the original request
+	expiresAt time.Time
+	done      chan struct{} // closed once status and body are set
+}
+
+type submissionKey struct {
+	key       string
+	expiresAt time.Time
+}
+
+// idempotencyCache dedupes order submissions by Idempotency-Key header or client_order_id
+type idempotencyCache struct {
+	window      time.Duration
+	submissions map[string]*submission
+	queue       []submissionKey // insertion order, every entry has the same window
+	mu          sync.Mutex
+}
+
+func newIdempotencyCache(window time.Duration) *idempotencyCache {
+	return &idempotencyCache{
+		window:      window,
+		submissions: make(map[string]*submission),
+	}
+}
+
+// claim returns the submission stored under key. owner is true when the caller created
+// it and must finish it with complete or abandon; otherwise the caller should wait on done.
+func (ic *idempotencyCache) claim(key string, req models.SubmitOrderRequest) (entry *submission, owner bool) {
+	ic.mu.Lock()
+	defer ic.mu.Unlock()
+
+	now := time.Now()
+	ic.evictExpired(now)
+
+	if existing, exists := ic.submissions[key]; exists {
+		return existing, false
+	}
+
+	entry = &submission{
+		request:   req,
+		expiresAt: now.Add(ic.window),
+		done:      make(chan struct{}),
+	}
+	ic.submissions[key] = entry
+	ic.queue = append(ic.queue, submissionKey{key: key, expiresAt: entry.expiresAt})
+	return entry, true
+}
+
+func (ic *idempotencyCache) complete(entry *submission, status int, body interface{}) {
+	ic.mu.Lock()
+	entry.status = status
+	entry.body = body
+	ic.mu.Unlock()
+
+	close(entry.done)
+}
+
+// abandon drops a submission that produced no order (e.g. the handler panicked),
+// so waiting retries claim the key again instead of replaying nothing
+func (ic *idempotencyCache) abandon(key string, entry *submission) {
+	ic.mu.Lock()
+	if ic.submissions[key] == entry {
+		delete(ic.submissions, key)
+	}
+	ic.mu.Unlock()
+
+	close(entry.done)
+}
+
+// result waits for the original request to finish and returns its response
+func (ic *idempotencyCache) result(entry *submission) (status int, body interface{}) {
+	<-entry.done
+
+	ic.mu.Lock()
+	defer ic.mu.Unlock()
+	return entry.status, entry.body
+}
+
+// must be called with ic.mu held
+func (ic *idempotencyCache) evictExpired(now time.Time) {
+	for len(ic.queue) > 0 && now.After(ic.queue[0].expiresAt) {
+		oldest := ic.queue[0]
+		// edge case: an abandoned key may have been claimed again with a later expiry
+		if entry, exists := ic.submissions[oldest.key]; exists && !now.Before(entry.expiresAt) {
+			delete(ic.submissions, oldest.key)
+		}
+		ic.queue = ic.queue[1:]
+	}
+}
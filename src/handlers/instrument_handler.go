@@ -0,0 +1,210 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog/log"
+
+	"match-engine/src/engine"
+	"match-engine/src/models"
+)
+
+// InstrumentHandler serves the instrument reference data, and lets admins list new
+// instruments and change existing ones
+type InstrumentHandler struct {
+	Matcher     *engine.Matcher
+	Instruments *engine.InstrumentRegistry
+}
+
+// NewInstrumentHandler serves the instruments of the matcher's registry. Changes reach
+// the matcher's books as commands.
+func NewInstrumentHandler(matcher *engine.Matcher) *InstrumentHandler {
+	return &InstrumentHandler{Matcher: matcher, Instruments: matcher.Instruments()}
+}
+
+func (h *InstrumentHandler) ListInstruments(c *fiber.Ctx) error {
+	instruments := h.Instruments.List()
+
+	response := models.InstrumentListResponse{
+		Instruments: make([]models.InstrumentResponse, 0, len(instruments)),
+	}
+	for _, instrument := range instruments {
+		response.Instruments = append(response.Instruments, newInstrumentResponse(instrument))
+	}
+	return c.Status(fiber.StatusOK).JSON(response)
+}
+
+func (h *InstrumentHandler) GetInstrument(c *fiber.Ctx) error {
+	instrument, exists := h.Instruments.Get(c.Params("symbol"))
+	if !exists {
+		return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
+			Error: "Instrument not found",
+		})
+	}
+	return c.Status(fiber.StatusOK).JSON(newInstrumentResponse(instrument))
+}
+
+func (h *InstrumentHandler) CreateInstrument(c *fiber.Ctx) error {
+	var req models.InstrumentRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error: "Invalid request: malformed JSON",
+		})
+	}
+
+	pricePrecision := engine.DefaultPricePrecision
+	if req.PricePrecision != nil {
+		pricePrecision = *req.PricePrecision
+	}
+
+	instrument, err := h.Instruments.Create(engine.Instrument{
+		Symbol:            req.Symbol,
+		TickSize:          req.TickSize,
+		LotSize:           req.LotSize,
+		MinQuantity:       req.MinQuantity,
+		MaxQuantity:       req.MaxQuantity,
+		PricePrecision:    pricePrecision,
+		Status:            engine.TradingStatus(req.Status),
+		MatchingAlgorithm: engine.MatchingAlgorithmName(req.MatchingAlgorithm),
+		MinAllocation:     req.MinAllocation,
+		Schedule:          newTradingSchedule(req.Schedule),
+	})
+	if err != nil {
+		return instrumentError(c, req.Symbol, err)
+	}
+
+	log.Info().
+		Str("symbol", instrument.Symbol).
+		Int64("tick_size", instrument.TickSize).
+		Int64("lot_size", instrument.LotSize).
+		Str("status", string(instrument.Status)).
+		Str("matching_algorithm", string(instrument.MatchingAlgorithm)).
+		Str("ip", c.IP()).
+		Msg("Instrument created")
+
+	return c.Status(fiber.StatusCreated).JSON(newInstrumentResponse(instrument))
+}
+
+func (h *InstrumentHandler) UpdateInstrument(c *fiber.Ctx) error {
+	symbol := c.Params("symbol")
+
+	var req models.UpdateInstrumentRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error: "Invalid request: malformed JSON",
+		})
+	}
+
+	instrument, err := h.Matcher.UpdateInstrument(symbol, func(instrument *engine.Instrument) {
+		if req.TickSize != nil {
+			instrument.TickSize = *req.TickSize
+		}
+		if req.LotSize != nil {
+			instrument.LotSize = *req.LotSize
+		}
+		if req.MinQuantity != nil {
+			instrument.MinQuantity = *req.MinQuantity
+		}
+		if req.MaxQuantity != nil {
+			instrument.MaxQuantity = *req.MaxQuantity
+		}
+		if req.PricePrecision != nil {
+			instrument.PricePrecision = *req.PricePrecision
+		}
+		if req.Status != nil {
+			instrument.Status = engine.TradingStatus(*req.Status)
+		}
+		if req.MatchingAlgorithm != nil {
+			instrument.MatchingAlgorithm = engine.MatchingAlgorithmName(*req.MatchingAlgorithm)
+		}
+		if req.MinAllocation != nil {
+			instrument.MinAllocation = *req.MinAllocation
+		}
+		if req.Schedule != nil {
+			instrument.Schedule = newTradingSchedule(req.Schedule)
+		}
+	})
+	if err != nil {
+		return instrumentError(c, symbol, err)
+	}
+
+	log.Info().
+		Str("symbol", symbol).
+		Int64("tick_size", instrument.TickSize).
+		Int64("lot_size", instrument.LotSize).
+		Str("status", string(instrument.Status)).
+		Str("matching_algorithm", string(instrument.MatchingAlgorithm)).
+		Str("ip", c.IP()).
+		Msg("Instrument updated")
+
+	return c.Status(fiber.StatusOK).JSON(newInstrumentResponse(instrument))
+}
+
+func instrumentError(c *fiber.Ctx, symbol string, err error) error {
+	switch err.(type) {
+	case *engine.InvalidInstrumentError:
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error: err.Error(),
+		})
+	case *engine.InstrumentExistsError, *engine.PricePrecisionError:
+		return c.Status(fiber.StatusConflict).JSON(models.ErrorResponse{
+			Error: err.Error(),
+		})
+	case *engine.UnknownInstrumentError:
+		return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
+			Error: "Instrument not found",
+		})
+	}
+
+	log.Error().
+		Err(err).
+		Str("symbol", symbol).
+		Msg("Error saving instrument")
+	return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+		Error: "Internal server error",
+	})
+}
+
+func newInstrumentResponse(instrument engine.Instrument) models.InstrumentResponse {
+	return models.InstrumentResponse{
+		Symbol:            instrument.Symbol,
+		TickSize:          instrument.TickSize,
+		LotSize:           instrument.LotSize,
+		MinQuantity:       instrument.MinQuantity,
+		MaxQuantity:       instrument.MaxQuantity,
+		PricePrecision:    instrument.PricePrecision,
+		Status:            string(instrument.Status),
+		MatchingAlgorithm: string(instrument.MatchingAlgorithm),
+		MinAllocation:     instrument.MinAllocation,
+		Schedule:          newScheduleResponse(instrument.Schedule),
+	}
+}
+
+// newTradingSchedule is the engine schedule a request asks for, nil for none or an
+// empty one
+func newTradingSchedule(schedule *models.TradingSchedule) *engine.TradingSchedule {
+	if schedule == nil || *schedule == (models.TradingSchedule{}) {
+		return nil
+	}
+	return &engine.TradingSchedule{
+		TimeZone:       schedule.TimeZone,
+		PreOpen:        schedule.PreOpen,
+		OpeningAuction: schedule.OpeningAuction,
+		Continuous:     schedule.Continuous,
+		ClosingAuction: schedule.ClosingAuction,
+		Close:          schedule.Close,
+	}
+}
+
+func newScheduleResponse(schedule *engine.TradingSchedule) *models.TradingSchedule {
+	if schedule == nil {
+		return nil
+	}
+	return &models.TradingSchedule{
+		TimeZone:       schedule.TimeZone,
+		PreOpen:        schedule.PreOpen,
+		OpeningAuction: schedule.OpeningAuction,
+		Continuous:     schedule.Continuous,
+		ClosingAuction: schedule.ClosingAuction,
+		Close:          schedule.Close,
+	}
+}
@@ -0,0 +1,235 @@
+package handlers
+
+import (
+	"encoding/json"
+
+	"github.com/gofiber/contrib/websocket"
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog/log"
+
+	"match-engine/src/engine"
+	"match-engine/src/marketdata"
+	"match-engine/src/models"
+)
+
+// how many feed messages a connection may have queued before its subscriptions back up
+// into the hub, which then drops them and has them resync
+const marketDataQueueSize = 256
+
+type MarketDataHandler struct {
+	Matcher *engine.Matcher
+	Hub     *marketdata.Hub
+}
+
+func NewMarketDataHandler(matcher *engine.Matcher, hub *marketdata.Hub) *MarketDataHandler {
+	return &MarketDataHandler{
+		Matcher: matcher,
+		Hub:     hub,
+	}
+}
+
+// RequireUpgrade turns away plain HTTP requests to the WebSocket endpoint
+func (h *MarketDataHandler) RequireUpgrade(c *fiber.Ctx) error {
+	if websocket.IsWebSocketUpgrade(c) {
+		return c.Next()
+	}
+	return c.Status(fiber.StatusUpgradeRequired).JSON(models.ErrorResponse{
+		Error: "Upgrade required: connect with a WebSocket client",
+	})
+}
+
+// Stream serves /ws/v1/marketdata. Clients send {"op":"subscribe","symbol":"AAPL"} and
+// get a full depth snapshot followed by the symbol's level updates.
+func (h *MarketDataHandler) Stream() fiber.Handler {
+	return websocket.New(h.serve)
+}
+
+// feedMessage is an update queued for a connection, or with a nil update a request to
+// resubscribe a symbol whose subscription lagged
+type feedMessage struct {
+	sub    *marketdata.Subscription
+	update *engine.BookUpdate
+}
+
+func (h *MarketDataHandler) serve(conn *websocket.Conn) {
+	requests := make(chan models.MarketDataRequest)
+	outbound := make(chan feedMessage, marketDataQueueSize)
+	done := make(chan struct{})
+	subscriptions := make(map[string]*marketdata.Subscription)
+
+	defer func() {
+		close(done)
+		for _, sub := range subscriptions {
+			h.Hub.Unsubscribe(sub)
+		}
+	}()
+
+	go func() {
+		defer close(requests)
+		for {
+			_, message, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			var req models.MarketDataRequest
+			// edge case: a malformed message is answered, it does not end the connection
+			if err := json.Unmarshal(message, &req); err != nil {
+				req = models.MarketDataRequest{Op: "invalid"}
+			}
+			select {
+			case requests <- req:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	// forward relays one subscription's updates, dropping those the snapshot covers
+	forward := func(sub *marketdata.Subscription, snapshotSeq uint64) {
+		for update := range sub.Updates {
+			if update.Sequence <= snapshotSeq {
+				continue
+			}
+			select {
+			case outbound <- feedMessage{sub: sub, update: update}:
+			case <-done:
+				return
+			}
+		}
+		if sub.Lagged() {
+			select {
+			case outbound <- feedMessage{sub: sub}:
+			case <-done:
+			}
+		}
+	}
+
+	subscribe := func(symbol string) error {
+		sub := h.Hub.Subscribe(symbol)
+		subscriptions[symbol] = sub
+
+		bidsLevels, asksLevels, status, seq := h.Matcher.GetOrCreateOrderBook(symbol).GetSequencedOrderBookState()
+		go forward(sub, seq)
+
+		return conn.WriteJSON(models.MarketDataSnapshot{
+			Type:     "snapshot",
+			Symbol:   symbol,
+			Sequence: seq,
+			Bids:     newPriceLevelInfos(bidsLevels),
+			Asks:     newPriceLevelInfos(asksLevels),
+			Status:   newSymbolStatusInfo(status),
+		})
+	}
+
+	for {
+		var err error
+
+		select {
+		case req, ok := <-requests:
+			if !ok {
+				return
+			}
+			err = h.handleRequest(conn, req, subscriptions, subscribe)
+
+		case msg := <-outbound:
+			// edge case: left over from a subscription that was since dropped or replaced
+			if subscriptions[msg.sub.Symbol] != msg.sub {
+				continue
+			}
+			if msg.update != nil {
+				err = conn.WriteJSON(newMarketDataUpdate(msg.update))
+				break
+			}
+			log.Warn().
+				Str("symbol", msg.sub.Symbol).
+				Str("ip", conn.IP()).
+				Msg("Market data subscriber fell behind, resending snapshot")
+			h.Hub.Unsubscribe(msg.sub)
+			err = subscribe(msg.sub.Symbol)
+		}
+
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (h *MarketDataHandler) handleRequest(conn *websocket.Conn, req models.MarketDataRequest, subscriptions map[string]*marketdata.Subscription, subscribe func(string) error) error {
+	switch req.Op {
+	case "subscribe":
+		if req.Symbol == "" {
+			return writeMarketDataError(conn, req.Symbol, "Invalid symbol: symbol is required")
+		}
+		if _, subscribed := subscriptions[req.Symbol]; subscribed {
+			return writeMarketDataError(conn, req.Symbol, "Already subscribed")
+		}
+		if _, listed := h.Matcher.ListedOrderBook(req.Symbol); !listed {
+			return writeMarketDataError(conn, req.Symbol, "Unknown symbol")
+		}
+		return subscribe(req.Symbol)
+
+	case "unsubscribe":
+		sub, subscribed := subscriptions[req.Symbol]
+		if !subscribed {
+			return writeMarketDataError(conn, req.Symbol, "Not subscribed")
+		}
+		delete(subscriptions, req.Symbol)
+		h.Hub.Unsubscribe(sub)
+		return conn.WriteJSON(models.MarketDataStatus{Type: "unsubscribed", Symbol: req.Symbol})
+
+	case "invalid":
+		return writeMarketDataError(conn, "", "Invalid request: malformed JSON")
+	}
+
+	return writeMarketDataError(conn, req.Symbol, "Invalid op: must be subscribe or unsubscribe")
+}
+
+func writeMarketDataError(conn *websocket.Conn, symbol, message string) error {
+	return conn.WriteJSON(models.MarketDataStatus{Type: "error", Symbol: symbol, Error: message})
+}
+
+func newPriceLevelInfos(levels []engine.OrderBookSnapshot) []models.PriceLevelInfo {
+	infos := make([]models.PriceLevelInfo, 0, len(levels))
+	for _, level := range levels {
+		infos = append(infos, models.PriceLevelInfo{
+			Price:    level.Price,
+			Quantity: level.Quantity,
+		})
+	}
+	return infos
+}
+
+func newMarketDataUpdate(update *engine.BookUpdate) models.MarketDataUpdate {
+	changes := make([]models.LevelChangeInfo, 0, len(update.Changes))
+	for _, change := range update.Changes {
+		changes = append(changes, models.LevelChangeInfo{
+			Side:     string(change.Side),
+			Price:    change.Price,
+			Quantity: change.Quantity,
+		})
+	}
+	message := models.MarketDataUpdate{
+		Type:            "update",
+		Symbol:          update.Symbol,
+		Sequence:        update.Sequence,
+		CommandSequence: update.CommandSequence,
+		Timestamp:       update.Timestamp,
+		Changes:         changes,
+	}
+	if update.Status != nil {
+		status := newSymbolStatusInfo(*update.Status)
+		message.Status = &status
+	}
+	return message
+}
+
+func newSymbolStatusInfo(status engine.SymbolStatus) models.SymbolStatusInfo {
+	return models.SymbolStatusInfo{
+		Session:        string(status.Session),
+		Auction:        status.Auction,
+		Halted:         status.Halted,
+		HaltReason:     status.HaltReason,
+		CancelsAllowed: status.CancelsAllowed,
+		ReopensAt:      status.ReopensAt,
+	}
+}
@@ -4,29 +4,44 @@ import (
 	"os"
 	"sort"
 	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/google/uuid"
 	"github.com/rs/zerolog/log"
 
+	"match-engine/src/accounts"
 	"match-engine/src/engine"
 	"match-engine/src/models"
 )
 
+const (
+	maxClientOrderIDLength  = 64
+	maxAccountLength        = 64
+	maxIdempotencyKeyLength = 255
+)
+
 type OrderHandler struct {
-	Matcher          *engine.Matcher
-	StartTime        time.Time
-	OrdersReceived   int64
-	OrdersMatched    int64
-	OrdersCancelled  int64
-	TradesExecuted   int64
-	
-	latencies        []time.Duration
-	latenciesMu      sync.RWMutex
-	maxLatencies     int
+	Matcher              *engine.Matcher
+	StartTime            time.Time
+	OrdersReceived       int64
+	OrdersMatched        int64
+	OrdersCancelled      int64
+	OrdersAmended        int64
+	TradesExecuted       int64
+	DuplicateSubmissions int64
+
+	// Tokens authenticates the account an order names. With no tokens orders cannot
+	// name an account.
+	Tokens *accounts.Tokens
+
+	submissions *idempotencyCache
+
+	latencies    []time.Duration
+	latenciesMu  sync.RWMutex
+	maxLatencies int
 }
 
 func NewOrderHandler(matcher *engine.Matcher) *OrderHandler {
@@ -36,10 +51,20 @@ func NewOrderHandler(matcher *engine.Matcher) *OrderHandler {
 			maxLatencies = parsed
 		}
 	}
-	
+
+	// edge case: retries arriving later than the dedupe window create a new order
+	// unless they carry a client_order_id the engine still knows about
+	dedupeWindow := 5 * time.Minute
+	if envWindow := os.Getenv("IDEMPOTENCY_WINDOW"); envWindow != "" {
+		if parsed, err := time.ParseDuration(envWindow); err == nil && parsed > 0 {
+			dedupeWindow = parsed
+		}
+	}
+
 	return &OrderHandler{
 		Matcher:      matcher,
 		StartTime:    time.Now(),
+		submissions:  newIdempotencyCache(dedupeWindow),
 		latencies:    make([]time.Duration, 0, maxLatencies),
 		maxLatencies: maxLatencies,
 	}
@@ -72,10 +97,191 @@ func (h *OrderHandler) SubmitOrder(c *fiber.Ctx) error {
 		})
 	}
 
-	orderID := uuid.New().String()
+	// edge case: checked before the dedupe window, so a retry cannot be used to read the
+	// response of another account's order
+	token, _ := strings.CutPrefix(c.Get(fiber.HeaderAuthorization), "Bearer ")
+	if err := h.authenticateAccount(req.Account, token); err != nil {
+		log.Warn().
+			Str("account", req.Account).
+			Str("ip", c.IP()).
+			Msg("Order rejected: " + err.Error())
+		return c.Status(err.Status).JSON(models.ErrorResponse{
+			Error: err.Error(),
+		})
+	}
+
+	// edge case: retries with the same Idempotency-Key (or client_order_id) within the
+	// dedupe window get the original response and never create a second order
+	idempotencyKey := c.Get("Idempotency-Key")
+	if len(idempotencyKey) > maxIdempotencyKeyLength {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error: "Invalid request: Idempotency-Key must be at most " + strconv.Itoa(maxIdempotencyKeyLength) + " characters",
+		})
+	}
+
+	dedupeKey := submissionDedupeKey(idempotencyKey, req.ClientOrderID)
+	if dedupeKey == "" {
+		status, response := h.submitOrder(c, &req)
+		return c.Status(status).JSON(response)
+	}
+
+	for {
+		entry, owner := h.submissions.claim(dedupeKey, req)
+		if owner {
+			return h.submitOnce(c, &req, dedupeKey, entry)
+		}
+
+		// edge case: the same key reused for a different order is a client bug, not a retry
+		if entry.request != req {
+			log.Warn().
+				Str("idempotency_key", dedupeKey).
+				Str("ip", c.IP()).
+				Msg("Idempotency key reused with a different request")
+			return c.Status(fiber.StatusConflict).JSON(models.ErrorResponse{
+				Error: "Idempotency key already used for a different order",
+			})
+		}
+
+		status, response := h.submissions.result(entry)
+		if status == 0 {
+			// edge case: the original request was abandoned, try to claim the key again
+			continue
+		}
+
+		atomic.AddInt64(&h.DuplicateSubmissions, 1)
+		log.Info().
+			Str("idempotency_key", dedupeKey).
+			Int("status", status).
+			Str("ip", c.IP()).
+			Msg("Duplicate submission, returning original response")
+		return c.Status(status).JSON(response)
+	}
+}
+
+// submitOnce runs the submission that owns a dedupe key and stores its response for retries
+func (h *OrderHandler) submitOnce(c *fiber.Ctx, req *models.SubmitOrderRequest, dedupeKey string, entry *submission) error {
+	completed := false
+	defer func() {
+		if !completed {
+			h.submissions.abandon(dedupeKey, entry)
+		}
+	}()
+
+	status, response := h.submitOrder(c, req)
+	// edge case: a duplicate client_order_id created no order, so there is nothing to replay
+	if status != fiber.StatusConflict {
+		h.submissions.complete(entry, status, response)
+		completed = true
+	}
+	return c.Status(status).JSON(response)
+}
+
+func submissionDedupeKey(idempotencyKey, clientOrderID string) string {
+	if idempotencyKey != "" {
+		return "key:" + idempotencyKey
+	}
+	if clientOrderID != "" {
+		return "client:" + clientOrderID
+	}
+	return ""
+}
+
+// submitOrder creates the engine order, matches it and builds the response
+func (h *OrderHandler) submitOrder(c *fiber.Ctx, req *models.SubmitOrderRequest) (int, interface{}) {
+	order, result, err := h.placeOrder(req, c.IP())
+
+	// edge case: handle insufficient liquidity for market and fill-or-kill orders
+	if err != nil {
+		if _, ok := err.(*engine.DuplicateClientOrderIDError); ok {
+			return fiber.StatusConflict, models.ErrorResponse{
+				Error: "Duplicate client_order_id: already used by another order",
+			}
+		}
+		if liquidityErr, ok := err.(*engine.InsufficientLiquidityError); ok {
+			return fiber.StatusBadRequest, models.ErrorResponse{
+				Error: insufficientLiquidityMessage(liquidityErr, req.Quantity),
+			}
+		}
+		if message := instrumentRejectMessage(err); message != "" {
+			return fiber.StatusBadRequest, models.ErrorResponse{
+				Error: message,
+				Code:  engine.ErrorCode(err),
+			}
+		}
+		return fiber.StatusInternalServerError, models.ErrorResponse{
+			Error: "Internal server error",
+		}
+	}
+
+	trades := make([]models.TradeInfo, 0, len(result.Trades))
+	for _, trade := range result.Trades {
+		trades = append(trades, newTradeInfo(trade))
+	}
+
+	response := models.SubmitOrderResponse{
+		OrderID:           order.ID,
+		ClientOrderID:     req.ClientOrderID,
+		Status:            string(result.Status),
+		FilledQuantity:    result.FilledQuantity,
+		RemainingQuantity: result.RemainingQuantity,
+		Trades:            trades,
+		Sequence:          result.Sequence,
+		Message:           submitResultMessage(result),
+	}
+	for _, prevented := range result.PreventedMatches {
+		response.PreventedMatches = append(response.PreventedMatches, models.PreventedMatchInfo{
+			RestingOrderID:    prevented.RestingOrderID,
+			Mode:              string(prevented.Mode),
+			Price:             prevented.Price,
+			Quantity:          prevented.Quantity,
+			IncomingCancelled: prevented.IncomingCancelled,
+			RestingCancelled:  prevented.RestingCancelled,
+		})
+	}
+
+	if result.StopPending || result.Status == engine.StatusAccepted {
+		return fiber.StatusCreated, response
+	} else if result.Status == engine.StatusPartialFill {
+		return fiber.StatusAccepted, response
+	} else {
+		return fiber.StatusOK, response
+	}
+}
+
+// AccountError refuses an order that names an account the client has not proven it acts for
+type AccountError struct {
+	Status  int // HTTP status the order is refused with
+	Message string
+}
+
+func (e *AccountError) Error() string {
+	return e.Message
+}
+
+// authenticateAccount checks that token is the token of the account an order names.
+// Orders without an account need no token.
+func (h *OrderHandler) authenticateAccount(account, token string) *AccountError {
+	if account == "" {
+		return nil
+	}
+	if !h.Tokens.Enabled() {
+		return &AccountError{Status: fiber.StatusForbidden, Message: "Orders cannot name an account: ACCOUNT_TOKENS is not set"}
+	}
+	if token == "" {
+		return &AccountError{Status: fiber.StatusUnauthorized, Message: "Unauthorized: the account's token is required"}
+	}
+	if !h.Tokens.Authenticate(account, token) {
+		return &AccountError{Status: fiber.StatusForbidden, Message: "Forbidden: the token is not the account's"}
+	}
+	return nil
+}
+
+// placeOrder creates the engine order for a validated request, matches it and counts it
+// in the metrics. The REST and gRPC APIs both submit through it.
+func (h *OrderHandler) placeOrder(req *models.SubmitOrderRequest, ip string) (*engine.Order, *engine.MatchResult, error) {
+	orderID := h.Matcher.NewOrderID()
 
 	var side engine.OrderSide
-	var orderType engine.OrderType
 
 	if req.Side == "BUY" {
 		side = engine.SideBuy
@@ -83,87 +289,81 @@ func (h *OrderHandler) SubmitOrder(c *fiber.Ctx) error {
 		side = engine.SideSell
 	}
 
-	if req.Type == "LIMIT" {
-		orderType = engine.TypeLimit
-	} else {
-		orderType = engine.TypeMarket
-	}
+	orderType := engine.OrderType(req.Type)
 
 	order := engine.NewOrder(orderID, req.Symbol, side, orderType, req.Price, req.Quantity)
+	order.ClientOrderID = req.ClientOrderID
+	order.Account = req.Account
+	order.StopPrice = req.StopPrice
+	order.DisplayQuantity = req.DisplayQuantity
+	if req.TimeInForce != "" {
+		order.TimeInForce = engine.TimeInForce(req.TimeInForce)
+	}
+	order.SelfTradePrevention = engine.SelfTradePrevention(req.SelfTradePrevention)
 
 	startTime := time.Now()
 
 	log.Info().
 		Str("order_id", orderID).
+		Str("client_order_id", req.ClientOrderID).
 		Str("symbol", req.Symbol).
 		Str("side", req.Side).
 		Str("type", req.Type).
+		Str("time_in_force", string(order.TimeInForce)).
 		Int64("price", req.Price).
+		Int64("stop_price", req.StopPrice).
 		Int64("quantity", req.Quantity).
-		Str("ip", c.IP()).
+		Int64("display_quantity", req.DisplayQuantity).
+		Str("ip", ip).
 		Msg("Order submitted")
 
 	atomic.AddInt64(&h.OrdersReceived, 1)
 
 	result, err := h.Matcher.MatchOrder(order)
-	
+
 	latency := time.Since(startTime)
 	h.recordLatency(latency)
 
-	// edge case: handle insufficient liquidity for market orders
 	if err != nil {
-		if _, ok := err.(*engine.InsufficientLiquidityError); ok {
-			orderBook := h.Matcher.GetOrCreateOrderBook(req.Symbol)
-			var totalAvailable int64
-			if side == engine.SideBuy {
-				_, qty, _ := orderBook.GetBestAsk()
-				totalAvailable = qty
-			} else {
-				_, qty, _ := orderBook.GetBestBid()
-				totalAvailable = qty
-			}
+		if _, ok := err.(*engine.DuplicateClientOrderIDError); ok {
+			log.Warn().
+				Str("client_order_id", req.ClientOrderID).
+				Str("ip", ip).
+				Msg("Duplicate client order ID")
+		} else if liquidityErr, ok := err.(*engine.InsufficientLiquidityError); ok {
 			log.Warn().
 				Str("order_id", orderID).
 				Str("symbol", req.Symbol).
+				Str("type", req.Type).
+				Str("time_in_force", string(order.TimeInForce)).
 				Int64("requested", req.Quantity).
-				Int64("available", totalAvailable).
-				Msg("Insufficient liquidity for market order")
-			return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
-				Error: "Insufficient liquidity: only " + strconv.FormatInt(totalAvailable, 10) + " shares available, requested " + strconv.FormatInt(req.Quantity, 10),
-			})
+				Int64("available", liquidityErr.Available).
+				Msg("Insufficient liquidity for order")
+		} else if instrumentRejectMessage(err) != "" {
+			log.Warn().
+				Err(err).
+				Str("order_id", orderID).
+				Str("symbol", req.Symbol).
+				Str("ip", ip).
+				Msg("Order rejected by instrument rules")
+		} else {
+			log.Error().
+				Err(err).
+				Str("order_id", orderID).
+				Str("symbol", req.Symbol).
+				Msg("Error matching order")
 		}
-		log.Error().
-			Err(err).
-			Str("order_id", orderID).
-			Str("symbol", req.Symbol).
-			Msg("Error matching order")
-		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
-			Error: "Internal server error",
-		})
-	}
-
-	trades := make([]models.TradeInfo, 0, len(result.Trades))
-	for _, trade := range result.Trades {
-		trades = append(trades, models.TradeInfo{
-			TradeID:   trade.TradeID,
-			Price:     trade.Price,
-			Quantity:  trade.Quantity,
-			Timestamp: trade.Timestamp,
-		})
-	}
-
-	response := models.SubmitOrderResponse{
-		OrderID:          orderID,
-		Status:           string(result.Status),
-		FilledQuantity:   result.FilledQuantity,
-		RemainingQuantity: result.RemainingQuantity,
-		Trades:           trades,
+		return order, nil, err
 	}
 
 	if result.Status == engine.StatusPartialFill || result.Status == engine.StatusFilled {
 		atomic.AddInt64(&h.OrdersMatched, 1)
 	}
-	atomic.AddInt64(&h.TradesExecuted, int64(len(trades)))
+	atomic.AddInt64(&h.TradesExecuted, int64(len(result.Trades)))
+
+	for _, triggered := range result.TriggeredOrders {
+		h.recordTriggeredOrder(triggered)
+	}
 
 	log.Info().
 		Str("order_id", orderID).
@@ -173,98 +373,286 @@ func (h *OrderHandler) SubmitOrder(c *fiber.Ctx) error {
 		Int("trades_count", len(result.Trades)).
 		Msg("Order processed")
 
-	if result.Status == engine.StatusAccepted {
-		response.Message = "Order added to book"
-		return c.Status(fiber.StatusCreated).JSON(response)
-	} else if result.Status == engine.StatusPartialFill {
-		return c.Status(fiber.StatusAccepted).JSON(response)
-	} else {
-		return c.Status(fiber.StatusOK).JSON(response)
-	}
+	return order, result, nil
 }
 
-func (h *OrderHandler) CancelOrder(c *fiber.Ctx) error {
-	orderID := c.Params("id")
+func insufficientLiquidityMessage(err *engine.InsufficientLiquidityError, requested int64) string {
+	return "Insufficient liquidity: only " + strconv.FormatInt(err.Available, 10) + " shares available, requested " + strconv.FormatInt(requested, 10)
+}
 
-	var foundOrder *engine.Order
-	var foundOrderBook *engine.OrderBook
+// instrumentRejectMessage is the client message for an order or amend refused by its
+// instrument's reference data, "" for any other error
+func instrumentRejectMessage(err error) string {
+	switch err := err.(type) {
+	case *engine.UnknownInstrumentError:
+		return "Invalid order: unknown symbol " + err.Symbol
+	case *engine.InvalidOrderError:
+		return "Invalid order: " + err.Message
+	case *engine.InstrumentNotTradingError, *engine.AuctionOrderError, *engine.SessionError, *engine.HaltedError:
+		return "Order rejected: " + err.Error()
+	}
+	return ""
+}
 
-	orderBooks := h.Matcher.GetOrderBooksSnapshot()
-	for _, orderBook := range orderBooks {
-		if order, exists := orderBook.GetOrder(orderID); exists {
-			foundOrder = order
-			foundOrderBook = orderBook
-			break
-		}
+func submitResultMessage(result *engine.MatchResult) string {
+	if result.StopPending {
+		return "Stop order waiting for trigger"
+	} else if result.Status == engine.StatusAccepted {
+		return "Order added to book"
+	} else if result.Status == engine.StatusCancelled && result.Order.SelfTradeCancelled() {
+		return "Unfilled quantity cancelled (self-trade prevention)"
+	} else if result.Status == engine.StatusCancelled {
+		return "Unfilled quantity cancelled (IOC)"
+	}
+	return ""
+}
+
+// edge case: stop orders released by another order's trades are counted here,
+// since nobody is waiting on their response
+func (h *OrderHandler) recordTriggeredOrder(triggered *engine.TriggeredOrder) {
+	if triggered.Err != nil {
+		log.Warn().
+			Err(triggered.Err).
+			Str("order_id", triggered.Order.ID).
+			Str("symbol", triggered.Order.Symbol).
+			Int64("stop_price", triggered.Order.StopPrice).
+			Msg("Triggered stop order rejected")
+		return
 	}
 
-	if foundOrder == nil {
+	if triggered.Result.Status == engine.StatusPartialFill || triggered.Result.Status == engine.StatusFilled {
+		atomic.AddInt64(&h.OrdersMatched, 1)
+	}
+	atomic.AddInt64(&h.TradesExecuted, int64(len(triggered.Result.Trades)))
+
+	log.Info().
+		Str("order_id", triggered.Order.ID).
+		Str("symbol", triggered.Order.Symbol).
+		Int64("stop_price", triggered.Order.StopPrice).
+		Str("status", string(triggered.Result.Status)).
+		Int64("filled_quantity", triggered.Result.FilledQuantity).
+		Int("trades_count", len(triggered.Result.Trades)).
+		Msg("Stop order triggered")
+}
+
+func (h *OrderHandler) CancelOrder(c *fiber.Ctx) error {
+	return h.cancelOrder(c, c.Params("id"))
+}
+
+// CancelOrderByClientID cancels the order submitted with the given client_order_id
+func (h *OrderHandler) CancelOrderByClientID(c *fiber.Ctx) error {
+	clientOrderID := c.Params("client_order_id")
+
+	order, exists := h.Matcher.GetOrderByClientID(clientOrderID)
+	if !exists {
 		log.Warn().
-			Str("order_id", orderID).
+			Str("client_order_id", clientOrderID).
 			Str("ip", c.IP()).
-			Msg("Cancel order: order not found")
+			Msg("Cancel order: client order ID not found")
 		return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
 			Error: "Order not found",
 		})
 	}
 
-	// edge case: cannot cancel already filled orders
-	if foundOrder.GetStatus() == engine.StatusFilled {
-		log.Warn().
-			Str("order_id", orderID).
-			Str("status", string(foundOrder.GetStatus())).
-			Msg("Cancel order: order already filled")
-		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
-			Error: "Cannot cancel: order already filled",
+	return h.cancelOrder(c, order.ID)
+}
+
+func (h *OrderHandler) cancelOrder(c *fiber.Ctx, orderID string) error {
+	cancelResult, err := h.cancel(orderID, c.IP())
+	if err != nil {
+		switch err.(type) {
+		case *engine.OrderNotFoundError:
+			return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
+				Error: "Order not found",
+			})
+		case *engine.OrderNotCancellableError, *engine.SessionError, *engine.HaltedError:
+			return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+				Error: err.Error(),
+				Code:  engine.ErrorCode(err),
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error: "Internal server error",
 		})
 	}
 
-	foundOrderBook.RemoveOrder(orderID)
-	foundOrder.SetStatus(engine.StatusCancelled)
+	return c.Status(fiber.StatusOK).JSON(models.CancelOrderResponse{
+		OrderID:       orderID,
+		ClientOrderID: cancelResult.Order.ClientOrderID,
+		Status:        "CANCELLED",
+		Sequence:      cancelResult.Sequence,
+	})
+}
+
+// cancel cancels an order and counts it in the metrics. The REST and gRPC APIs both
+// cancel through it.
+func (h *OrderHandler) cancel(orderID, ip string) (*engine.CancelResult, error) {
+	cancelResult, err := h.Matcher.CancelOrder(orderID)
+	if err != nil {
+		switch err.(type) {
+		case *engine.OrderNotFoundError:
+			log.Warn().
+				Str("order_id", orderID).
+				Str("ip", ip).
+				Msg("Cancel order: order not found")
+		case *engine.OrderNotCancellableError:
+			// edge case: cannot cancel orders that are already filled, cancelled, rejected or expired
+			log.Warn().
+				Str("order_id", orderID).
+				Str("status", string(err.(*engine.OrderNotCancellableError).Status)).
+				Msg("Cancel order: order already done")
+		case *engine.SessionError:
+			log.Warn().
+				Err(err).
+				Str("order_id", orderID).
+				Str("ip", ip).
+				Msg("Cancel order: not accepted in this trading session")
+		case *engine.HaltedError:
+			log.Warn().
+				Err(err).
+				Str("order_id", orderID).
+				Str("ip", ip).
+				Msg("Cancel order: symbol halted")
+		default:
+			log.Error().
+				Err(err).
+				Str("order_id", orderID).
+				Msg("Error cancelling order")
+		}
+		return nil, err
+	}
 
 	atomic.AddInt64(&h.OrdersCancelled, 1)
 
 	log.Info().
 		Str("order_id", orderID).
-		Str("symbol", foundOrder.Symbol).
-		Str("ip", c.IP()).
+		Str("symbol", cancelResult.Order.Symbol).
+		Uint64("sequence", cancelResult.Sequence).
+		Str("ip", ip).
 		Msg("Order cancelled")
 
-	return c.Status(fiber.StatusOK).JSON(models.CancelOrderResponse{
-		OrderID: orderID,
-		Status:  "CANCELLED",
-	})
+	return cancelResult, nil
 }
 
-func (h *OrderHandler) GetOrderBook(c *fiber.Ctx) error {
-	symbol := c.Params("symbol")
+func (h *OrderHandler) AmendOrder(c *fiber.Ctx) error {
+	orderID := c.Params("id")
 
-	defaultDepth := 10
-	if envDepth := os.Getenv("ORDERBOOK_DEFAULT_DEPTH"); envDepth != "" {
-		if parsed, err := strconv.Atoi(envDepth); err == nil && parsed > 0 {
-			defaultDepth = parsed
-		}
+	var req models.AmendOrderRequest
+	if err := c.BodyParser(&req); err != nil {
+		log.Warn().
+			Err(err).
+			Str("ip", c.IP()).
+			Str("path", c.Path()).
+			Msg("Invalid request: malformed JSON")
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error: "Invalid request: malformed JSON",
+		})
 	}
-	
-	maxDepth := 1000
-	if envMaxDepth := os.Getenv("ORDERBOOK_MAX_DEPTH"); envMaxDepth != "" {
-		if parsed, err := strconv.Atoi(envMaxDepth); err == nil && parsed > 0 {
-			maxDepth = parsed
+
+	if err := validateAmendOrderRequest(&req); err != nil {
+		log.Warn().
+			Err(err).
+			Str("order_id", orderID).
+			Str("ip", c.IP()).
+			Msg("Invalid amend request")
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error: err.Error(),
+		})
+	}
+
+	startTime := time.Now()
+
+	result, err := h.Matcher.AmendOrder(orderID, req.Price, req.Quantity)
+
+	h.recordLatency(time.Since(startTime))
+
+	if err != nil {
+		switch err.(type) {
+		case *engine.OrderNotFoundError:
+			log.Warn().
+				Str("order_id", orderID).
+				Str("ip", c.IP()).
+				Msg("Amend order: order not found")
+			return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
+				Error: "Order not found",
+			})
+		case *engine.InvalidAmendError:
+			log.Warn().
+				Err(err).
+				Str("order_id", orderID).
+				Msg("Amend order rejected")
+			return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+				Error: err.Error(),
+			})
+		case *engine.InvalidOrderError, *engine.InstrumentNotTradingError, *engine.SessionError, *engine.HaltedError:
+			log.Warn().
+				Err(err).
+				Str("order_id", orderID).
+				Msg("Amend order rejected by instrument rules")
+			return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+				Error: instrumentRejectMessage(err),
+				Code:  engine.ErrorCode(err),
+			})
 		}
+		log.Error().
+			Err(err).
+			Str("order_id", orderID).
+			Msg("Error amending order")
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error: "Internal server error",
+		})
 	}
-	
-	depthStr := c.Query("depth", strconv.Itoa(defaultDepth))
-	depth, err := strconv.Atoi(depthStr)
-	if err != nil || depth <= 0 {
-		depth = defaultDepth
+
+	trades := make([]models.TradeInfo, 0, len(result.Trades))
+	for _, trade := range result.Trades {
+		trades = append(trades, newTradeInfo(trade))
 	}
 
-	// edge case: enforce maximum depth limit
-	if depth > maxDepth {
-		depth = maxDepth
+	atomic.AddInt64(&h.OrdersAmended, 1)
+	atomic.AddInt64(&h.TradesExecuted, int64(len(trades)))
+
+	for _, triggered := range result.TriggeredOrders {
+		h.recordTriggeredOrder(triggered)
 	}
 
-	orderBook := h.Matcher.GetOrCreateOrderBook(symbol)
+	amended := result.Order
+
+	log.Info().
+		Str("order_id", orderID).
+		Str("symbol", amended.Symbol).
+		Int64("price", amended.Price).
+		Int64("quantity", amended.Quantity).
+		Str("status", string(result.Status)).
+		Int("trades_count", len(result.Trades)).
+		Str("ip", c.IP()).
+		Msg("Order amended")
+
+	return c.Status(fiber.StatusOK).JSON(models.AmendOrderResponse{
+		OrderID:           orderID,
+		Status:            string(result.Status),
+		Price:             amended.Price,
+		Quantity:          amended.Quantity,
+		FilledQuantity:    amended.GetFilledQuantity(),
+		RemainingQuantity: result.RemainingQuantity,
+		Trades:            trades,
+		Sequence:          result.Sequence,
+	})
+}
+
+func (h *OrderHandler) GetOrderBook(c *fiber.Ctx) error {
+	symbol := c.Params("symbol")
+
+	// edge case: a missing or malformed depth parses as 0 and gets the default
+	depth, _ := strconv.Atoi(c.Query("depth"))
+	depth = orderBookDepth(depth)
+
+	// edge case: unknown symbols get no book, only listed instruments are tradable
+	orderBook, listed := h.Matcher.ListedOrderBook(symbol)
+	if !listed {
+		return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
+			Error: "Unknown symbol",
+		})
+	}
 
 	bidsLevels, asksLevels := orderBook.GetOrderBookSnapshot(depth)
 
@@ -284,45 +672,136 @@ func (h *OrderHandler) GetOrderBook(c *fiber.Ctx) error {
 		})
 	}
 
-	return c.Status(fiber.StatusOK).JSON(models.OrderBookResponse{
+	response := models.OrderBookResponse{
 		Symbol:    symbol,
-		Timestamp: time.Now().UnixMilli(),
+		Timestamp: h.Matcher.Now().UnixMilli(),
 		Bids:      bids,
 		Asks:      asks,
-	})
+		Session:   string(orderBook.Session()),
+	}
+	if indicative, inAuction := orderBook.IndicativeUncross(); inAuction {
+		response.Auction = newAuctionInfo(indicative)
+		if reopensAt := orderBook.ReopensAt(); !reopensAt.IsZero() {
+			response.Auction.ReopensAt = reopensAt.UnixMilli()
+		}
+	}
+	if halt, halted := orderBook.Halted(); halted {
+		response.Halt = &models.HaltInfo{
+			Reason:       halt.Reason,
+			AllowCancels: halt.AllowCancels,
+			Since:        time.Unix(0, halt.Since).UnixMilli(),
+		}
+	}
+	return c.Status(fiber.StatusOK).JSON(response)
+}
+
+func newAuctionInfo(indicative engine.IndicativeUncross) *models.AuctionInfo {
+	info := &models.AuctionInfo{
+		IndicativePrice: indicative.Price,
+		MatchedVolume:   indicative.Volume,
+		Imbalance:       indicative.Imbalance,
+	}
+	if indicative.Imbalance > 0 {
+		info.ImbalanceSide = string(engine.SideBuy)
+	} else if indicative.Imbalance < 0 {
+		info.Imbalance = -indicative.Imbalance
+		info.ImbalanceSide = string(engine.SideSell)
+	}
+	return info
+}
+
+// orderBookDepth applies the configured default to a missing or invalid depth and caps it
+// at the configured maximum
+func orderBookDepth(depth int) int {
+	defaultDepth := 10
+	if envDepth := os.Getenv("ORDERBOOK_DEFAULT_DEPTH"); envDepth != "" {
+		if parsed, err := strconv.Atoi(envDepth); err == nil && parsed > 0 {
+			defaultDepth = parsed
+		}
+	}
+
+	maxDepth := 1000
+	if envMaxDepth := os.Getenv("ORDERBOOK_MAX_DEPTH"); envMaxDepth != "" {
+		if parsed, err := strconv.Atoi(envMaxDepth); err == nil && parsed > 0 {
+			maxDepth = parsed
+		}
+	}
+
+	if depth <= 0 {
+		depth = defaultDepth
+	}
+
+	// edge case: enforce maximum depth limit
+	if depth > maxDepth {
+		depth = maxDepth
+	}
+	return depth
 }
 
 func (h *OrderHandler) GetOrderStatus(c *fiber.Ctx) error {
 	orderID := c.Params("id")
 
-	var foundOrder *engine.Order
-	orderBooks := h.Matcher.GetOrderBooksSnapshot()
-	for _, orderBook := range orderBooks {
-		if order, exists := orderBook.GetOrder(orderID); exists {
-			foundOrder = order
-			break
-		}
+	foundOrder, exists := h.Matcher.GetOrder(orderID)
+	if !exists {
+		return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
+			Error: "Order not found",
+		})
 	}
 
-	if foundOrder == nil {
+	return h.orderStatus(c, foundOrder)
+}
+
+// GetOrderStatusByClientID looks up an order by the client_order_id it was submitted with
+func (h *OrderHandler) GetOrderStatusByClientID(c *fiber.Ctx) error {
+	clientOrderID := c.Params("client_order_id")
+
+	foundOrder, exists := h.Matcher.GetOrderByClientID(clientOrderID)
+	if !exists {
 		return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
 			Error: "Order not found",
 		})
 	}
 
+	return h.orderStatus(c, foundOrder)
+}
+
+func (h *OrderHandler) orderStatus(c *fiber.Ctx, foundOrder *engine.Order) error {
+
+	fills := foundOrder.Fills()
+	fillInfos := make([]models.TradeInfo, 0, len(fills))
+	for _, trade := range fills {
+		fillInfos = append(fillInfos, newTradeInfo(trade))
+	}
+
 	return c.Status(fiber.StatusOK).JSON(models.OrderStatusResponse{
-		OrderID:        foundOrder.ID,
-		Symbol:         foundOrder.Symbol,
-		Side:           string(foundOrder.Side),
-		Type:           string(foundOrder.Type),
-		Price:          foundOrder.Price,
-		Quantity:       foundOrder.Quantity,
-		FilledQuantity: foundOrder.GetFilledQuantity(),
-		Status:         string(foundOrder.GetStatus()),
-		Timestamp:      foundOrder.Timestamp,
+		OrderID:         foundOrder.ID,
+		ClientOrderID:   foundOrder.ClientOrderID,
+		Account:         foundOrder.Account,
+		Symbol:          foundOrder.Symbol,
+		Side:            string(foundOrder.Side),
+		Type:            string(foundOrder.Type),
+		Price:           foundOrder.Price,
+		StopPrice:       foundOrder.StopPrice,
+		Quantity:        foundOrder.Quantity,
+		DisplayQuantity: foundOrder.DisplayQuantity,
+		FilledQuantity:  foundOrder.GetFilledQuantity(),
+		Status:          string(foundOrder.GetStatus()),
+		Timestamp:       foundOrder.Timestamp / int64(time.Millisecond),
+		TimestampNs:     foundOrder.Timestamp,
+		Fills:           fillInfos,
 	})
 }
 
+func newTradeInfo(trade *engine.Trade) models.TradeInfo {
+	return models.TradeInfo{
+		TradeID:     trade.TradeID,
+		Price:       trade.Price,
+		Quantity:    trade.Quantity,
+		Timestamp:   trade.Timestamp / int64(time.Millisecond),
+		TimestampNs: trade.Timestamp,
+	}
+}
+
 func (h *OrderHandler) HealthCheck(c *fiber.Ctx) error {
 	uptime := time.Since(h.StartTime).Seconds()
 
@@ -333,8 +812,8 @@ func (h *OrderHandler) HealthCheck(c *fiber.Ctx) error {
 	}
 
 	return c.Status(fiber.StatusOK).JSON(models.HealthResponse{
-		Status:         "healthy",
-		UptimeSeconds:  int64(uptime),
+		Status:          "healthy",
+		UptimeSeconds:   int64(uptime),
 		OrdersProcessed: ordersProcessed,
 	})
 }
@@ -350,14 +829,16 @@ func (h *OrderHandler) Metrics(c *fiber.Ctx) error {
 	throughput := h.calculateThroughput()
 
 	return c.Status(fiber.StatusOK).JSON(models.MetricsResponse{
-		OrdersReceived:      atomic.LoadInt64(&h.OrdersReceived),
-		OrdersMatched:       atomic.LoadInt64(&h.OrdersMatched),
-		OrdersCancelled:     atomic.LoadInt64(&h.OrdersCancelled),
-		OrdersInBook:        ordersInBook,
-		TradesExecuted:      atomic.LoadInt64(&h.TradesExecuted),
-		LatencyP50Ms:        p50,
-		LatencyP99Ms:        p99,
-		LatencyP999Ms:       p999,
+		OrdersReceived:         atomic.LoadInt64(&h.OrdersReceived),
+		OrdersMatched:          atomic.LoadInt64(&h.OrdersMatched),
+		OrdersCancelled:        atomic.LoadInt64(&h.OrdersCancelled),
+		OrdersAmended:          atomic.LoadInt64(&h.OrdersAmended),
+		DuplicateSubmissions:   atomic.LoadInt64(&h.DuplicateSubmissions),
+		OrdersInBook:           ordersInBook,
+		TradesExecuted:         atomic.LoadInt64(&h.TradesExecuted),
+		LatencyP50Ms:           p50,
+		LatencyP99Ms:           p99,
+		LatencyP999Ms:          p999,
 		ThroughputOrdersPerSec: throughput,
 	})
 }
@@ -365,7 +846,7 @@ func (h *OrderHandler) Metrics(c *fiber.Ctx) error {
 func (h *OrderHandler) recordLatency(latency time.Duration) {
 	h.latenciesMu.Lock()
 	defer h.latenciesMu.Unlock()
-	
+
 	h.latencies = append(h.latencies, latency)
 
 	// edge case: maintain rolling window by removing oldest measurements
@@ -378,18 +859,18 @@ func (h *OrderHandler) recordLatency(latency time.Duration) {
 func (h *OrderHandler) calculateLatencyPercentiles() (p50, p99, p999 float64) {
 	h.latenciesMu.RLock()
 	defer h.latenciesMu.RUnlock()
-	
+
 	if len(h.latencies) == 0 {
 		return 0, 0, 0
 	}
-	
+
 	latenciesCopy := make([]time.Duration, len(h.latencies))
 	copy(latenciesCopy, h.latencies)
-	
+
 	sort.Slice(latenciesCopy, func(i, j int) bool {
 		return latenciesCopy[i] < latenciesCopy[j]
 	})
-	
+
 	p50Index := int(float64(len(latenciesCopy)) * 0.50)
 	p99Index := int(float64(len(latenciesCopy)) * 0.99)
 	p999Index := int(float64(len(latenciesCopy)) * 0.999)
@@ -404,11 +885,11 @@ func (h *OrderHandler) calculateLatencyPercentiles() (p50, p99, p999 float64) {
 	if p999Index >= len(latenciesCopy) {
 		p999Index = len(latenciesCopy) - 1
 	}
-	
+
 	p50 = float64(latenciesCopy[p50Index].Nanoseconds()) / 1e6
 	p99 = float64(latenciesCopy[p99Index].Nanoseconds()) / 1e6
 	p999 = float64(latenciesCopy[p999Index].Nanoseconds()) / 1e6
-	
+
 	return p50, p99, p999
 }
 
@@ -417,7 +898,7 @@ func (h *OrderHandler) calculateThroughput() float64 {
 	if uptime <= 0 {
 		return 0
 	}
-	
+
 	ordersReceived := atomic.LoadInt64(&h.OrdersReceived)
 	return float64(ordersReceived) / uptime
 }
@@ -431,21 +912,74 @@ func validateSubmitOrderRequest(req *models.SubmitOrderRequest) error {
 		return &ValidationError{Message: "Invalid order: side must be BUY or SELL"}
 	}
 
-	if req.Type != "LIMIT" && req.Type != "MARKET" {
-		return &ValidationError{Message: "Invalid order: type must be LIMIT or MARKET"}
+	if req.Type != "LIMIT" && req.Type != "MARKET" && req.Type != "STOP" && req.Type != "STOP_LIMIT" {
+		return &ValidationError{Message: "Invalid order: type must be LIMIT, MARKET, STOP or STOP_LIMIT"}
+	}
+
+	if req.TimeInForce != "" && req.TimeInForce != "GTC" && req.TimeInForce != "IOC" && req.TimeInForce != "FOK" {
+		return &ValidationError{Message: "Invalid order: time_in_force must be GTC, IOC or FOK"}
 	}
 
 	if req.Quantity <= 0 {
 		return &ValidationError{Message: "Invalid order: quantity must be positive"}
 	}
 
+	// edge case: MARKET orders never rest, and STOP orders become MARKET orders
+	if (req.TimeInForce == "IOC" || req.TimeInForce == "FOK") && (req.Type == "MARKET" || req.Type == "STOP") {
+		return &ValidationError{Message: "Invalid order: time_in_force " + req.TimeInForce + " is not allowed on " + req.Type + " orders"}
+	}
+
 	// edge case: price required for limit orders
-	if req.Type == "LIMIT" {
+	if req.Type == "LIMIT" || req.Type == "STOP_LIMIT" {
 		if req.Price <= 0 {
-			return &ValidationError{Message: "Invalid order: price must be positive for LIMIT orders"}
+			return &ValidationError{Message: "Invalid order: price must be positive for " + req.Type + " orders"}
+		}
+	}
+
+	if req.Type == "STOP" || req.Type == "STOP_LIMIT" {
+		if req.StopPrice <= 0 {
+			return &ValidationError{Message: "Invalid order: stop_price must be positive for " + req.Type + " orders"}
 		}
 	}
 
+	// edge case: only resting limit orders can hide quantity
+	if req.DisplayQuantity != 0 {
+		if req.Type != "LIMIT" {
+			return &ValidationError{Message: "Invalid order: display_quantity is only allowed on LIMIT orders"}
+		}
+		if req.DisplayQuantity < 0 || req.DisplayQuantity > req.Quantity {
+			return &ValidationError{Message: "Invalid order: display_quantity must be between 1 and quantity"}
+		}
+	}
+
+	if len(req.ClientOrderID) > maxClientOrderIDLength {
+		return &ValidationError{Message: "Invalid order: client_order_id must be at most " + strconv.Itoa(maxClientOrderIDLength) + " characters"}
+	}
+
+	if len(req.Account) > maxAccountLength {
+		return &ValidationError{Message: "Invalid order: account must be at most " + strconv.Itoa(maxAccountLength) + " characters"}
+	}
+
+	if !engine.SelfTradePrevention(req.SelfTradePrevention).IsValid() {
+		return &ValidationError{Message: "Invalid order: self_trade_prevention must be NONE, CANCEL_NEWEST, CANCEL_OLDEST, CANCEL_BOTH or DECREMENT_AND_CANCEL"}
+	}
+
+	return nil
+}
+
+func validateAmendOrderRequest(req *models.AmendOrderRequest) error {
+	if req.Price < 0 {
+		return &ValidationError{Message: "Invalid amend: price must be positive"}
+	}
+
+	if req.Quantity < 0 {
+		return &ValidationError{Message: "Invalid amend: quantity must be positive"}
+	}
+
+	if req.Price == 0 && req.Quantity == 0 {
+		return &ValidationError{Message: "Invalid amend: price or quantity is required"}
+	}
+
 	return nil
 }
 
@@ -456,4 +990,3 @@ type ValidationError struct {
 func (e *ValidationError) Error() string {
 	return e.Message
 }
-
@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog/log"
+
+	"match-engine/src/engine"
+	"match-engine/src/models"
+)
+
+// DefaultReopeningAuction is how long a resumed symbol collects orders before it uncrosses
+const DefaultReopeningAuction = 5 * time.Minute
+
+// SymbolHandler lets admins move a symbol between continuous trading and auctions, and
+// halt and resume it
+type SymbolHandler struct {
+	Matcher *engine.Matcher
+
+	// ReopeningAuction is the reopening auction of a resume that does not ask for one,
+	// 0 leaves the uncross to an admin
+	ReopeningAuction time.Duration
+}
+
+func NewSymbolHandler(matcher *engine.Matcher) *SymbolHandler {
+	return &SymbolHandler{Matcher: matcher, ReopeningAuction: DefaultReopeningAuction}
+}
+
+func (h *SymbolHandler) StartAuction(c *fiber.Ctx) error {
+	symbol := c.Params("symbol")
+
+	seq, err := h.Matcher.StartAuction(symbol)
+	if err != nil {
+		return symbolError(c, symbol, err)
+	}
+
+	log.Info().
+		Str("symbol", symbol).
+		Uint64("sequence", seq).
+		Str("ip", c.IP()).
+		Msg("Auction started")
+
+	return c.Status(fiber.StatusOK).JSON(models.AuctionResponse{
+		Symbol:   symbol,
+		Auction:  true,
+		Sequence: seq,
+	})
+}
+
+func (h *SymbolHandler) Uncross(c *fiber.Ctx) error {
+	symbol := c.Params("symbol")
+
+	result, err := h.Matcher.Uncross(symbol)
+	if err != nil {
+		return symbolError(c, symbol, err)
+	}
+
+	log.Info().
+		Str("symbol", symbol).
+		Int64("price", result.Price).
+		Int64("volume", result.Volume).
+		Int("trades_count", len(result.Trades)).
+		Uint64("sequence", result.Sequence).
+		Str("ip", c.IP()).
+		Msg("Auction uncrossed")
+
+	return c.Status(fiber.StatusOK).JSON(models.UncrossResponse{
+		Symbol:   symbol,
+		Price:    result.Price,
+		Volume:   result.Volume,
+		Trades:   newTradeRecords(result.Trades),
+		Sequence: result.Sequence,
+	})
+}
+
+func (h *SymbolHandler) Halt(c *fiber.Ctx) error {
+	symbol := c.Params("symbol")
+
+	var req models.HaltRequest
+	// edge case: the body is optional, a bare POST halts without a reason
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+				Error: "Invalid request: malformed JSON",
+			})
+		}
+	}
+
+	seq, err := h.Matcher.Halt(symbol, req.Reason, req.AllowCancels)
+	if err != nil {
+		return symbolError(c, symbol, err)
+	}
+
+	log.Info().
+		Str("symbol", symbol).
+		Str("reason", req.Reason).
+		Bool("allow_cancels", req.AllowCancels).
+		Uint64("sequence", seq).
+		Str("ip", c.IP()).
+		Msg("Trading halted")
+
+	return c.Status(fiber.StatusOK).JSON(models.HaltResponse{
+		Symbol:       symbol,
+		Halted:       true,
+		Reason:       req.Reason,
+		AllowCancels: req.AllowCancels,
+		Sequence:     seq,
+	})
+}
+
+// Resume lifts a halt. The auction query parameter sets how long the reopening auction
+// runs, such as 30s, and 0 waits for an uncross.
+func (h *SymbolHandler) Resume(c *fiber.Ctx) error {
+	symbol := c.Params("symbol")
+
+	auction := h.ReopeningAuction
+	if query := c.Query("auction"); query != "" {
+		parsed, err := time.ParseDuration(query)
+		if err != nil || parsed < 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+				Error: "Invalid request: auction must be a duration such as 30s",
+			})
+		}
+		auction = parsed
+	}
+
+	seq, err := h.Matcher.Resume(symbol, auction)
+	if err != nil {
+		return symbolError(c, symbol, err)
+	}
+
+	response := models.HaltResponse{
+		Symbol:   symbol,
+		Sequence: seq,
+	}
+	if orderBook, exists := h.Matcher.GetOrderBook(symbol); exists {
+		if reopensAt := orderBook.ReopensAt(); !reopensAt.IsZero() {
+			response.ReopensAt = reopensAt.UnixMilli()
+		}
+	}
+
+	log.Info().
+		Str("symbol", symbol).
+		Dur("auction", auction).
+		Uint64("sequence", seq).
+		Str("ip", c.IP()).
+		Msg("Trading resumed")
+
+	return c.Status(fiber.StatusOK).JSON(response)
+}
+
+func symbolError(c *fiber.Ctx, symbol string, err error) error {
+	switch err.(type) {
+	case *engine.UnknownInstrumentError:
+		return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
+			Error: "Unknown symbol",
+		})
+	case *engine.AuctionStateError, *engine.SessionError, *engine.HaltStateError, *engine.HaltedError:
+		return c.Status(fiber.StatusConflict).JSON(models.ErrorResponse{
+			Error: err.Error(),
+			Code:  engine.ErrorCode(err),
+		})
+	}
+
+	log.Error().
+		Err(err).
+		Str("symbol", symbol).
+		Msg("Error changing trading phase")
+	return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+		Error: "Internal server error",
+	})
+}
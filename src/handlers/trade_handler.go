@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog/log"
+
+	"match-engine/src/engine"
+	"match-engine/src/models"
+)
+
+const (
+	defaultTradesLimit = 100
+	maxTradesLimit     = 1000
+)
+
+// GetTrades lists a symbol's trade history, oldest first, filtered by since/until and
+// paged with limit and the next_cursor of the previous page
+func (h *OrderHandler) GetTrades(c *fiber.Ctx) error {
+	symbol := c.Params("symbol")
+
+	query := engine.TradeQuery{Limit: defaultTradesLimit}
+	var err error
+
+	if query.Since, err = parseTradeTime(c.Query("since")); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error: "Invalid since: use unix milliseconds or RFC 3339",
+		})
+	}
+	if query.Until, err = parseTradeTime(c.Query("until")); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error: "Invalid until: use unix milliseconds or RFC 3339",
+		})
+	}
+
+	if limitStr := c.Query("limit"); limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+				Error: "Invalid limit: must be a positive integer",
+			})
+		}
+		// edge case: enforce maximum page size
+		if limit > maxTradesLimit {
+			limit = maxTradesLimit
+		}
+		query.Limit = limit
+	}
+
+	if cursor := c.Query("cursor"); cursor != "" {
+		query.Cursor, err = strconv.ParseUint(cursor, 10, 64)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+				Error: "Invalid cursor",
+			})
+		}
+	}
+
+	response := models.TradeHistoryResponse{
+		Symbol: symbol,
+		Trades: []models.TradeRecord{},
+	}
+
+	// edge case: unknown symbols have no trades, don't create a book for them
+	orderBook, exists := h.Matcher.GetOrderBook(symbol)
+	if !exists {
+		return c.Status(fiber.StatusOK).JSON(response)
+	}
+
+	trades, next, err := orderBook.Trades(query)
+	if err != nil {
+		return c.Status(fiber.StatusGone).JSON(models.ErrorResponse{
+			Error: "Cursor expired: older trades were evicted, query again by since",
+		})
+	}
+	response.Trades = newTradeRecords(trades)
+	if next > 0 {
+		response.NextCursor = strconv.FormatUint(next, 10)
+	}
+
+	log.Debug().
+		Str("symbol", symbol).
+		Int("trades", len(trades)).
+		Msg("Trade history queried")
+
+	return c.Status(fiber.StatusOK).JSON(response)
+}
+
+// GetOrderTrades lists the trades an order took part in, on either side, for as long
+// as the engine still knows the order
+func (h *OrderHandler) GetOrderTrades(c *fiber.Ctx) error {
+	orderID := c.Params("id")
+
+	order, exists := h.Matcher.GetOrder(orderID)
+	if !exists {
+		return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
+			Error: "Order not found",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(models.OrderTradesResponse{
+		OrderID: orderID,
+		Trades:  newTradeRecords(order.Fills()),
+	})
+}
+
+// parseTradeTime accepts unix milliseconds or an RFC 3339 time and returns unix
+// nanoseconds, 0 when the parameter is absent
+func parseTradeTime(value string) (int64, error) {
+	if value == "" {
+		return 0, nil
+	}
+	if millis, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return millis * int64(time.Millisecond), nil
+	}
+	parsed, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return 0, err
+	}
+	return parsed.UnixNano(), nil
+}
+
+func newTradeRecords(trades []*engine.Trade) []models.TradeRecord {
+	records := make([]models.TradeRecord, 0, len(trades))
+	for _, trade := range trades {
+		records = append(records, models.TradeRecord{
+			TradeID:       trade.TradeID,
+			Symbol:        trade.Symbol,
+			Price:         trade.Price,
+			Quantity:      trade.Quantity,
+			BuyOrderID:    trade.BuyOrderID,
+			SellOrderID:   trade.SellOrderID,
+			AggressorSide: string(trade.AggressorSide),
+			Sequence:      trade.Sequence,
+			Timestamp:     trade.Timestamp / int64(time.Millisecond),
+			TimestampNs:   trade.Timestamp,
+		})
+	}
+	return records
+}
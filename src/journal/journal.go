@@ -0,0 +1,488 @@
+package journal
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"match-engine/src/engine"
+)
+
+// FsyncPolicy controls when appended records are forced to stable storage.
+// Records are always written to the OS before Append returns, so a process crash
+// never loses an acknowledged command; the policy decides what survives a power loss.
+type FsyncPolicy string
+
+const (
+	FsyncAlways   FsyncPolicy = "always"   // fsync before every Append returns
+	FsyncInterval FsyncPolicy = "interval" // fsync in the background every FsyncInterval
+	FsyncNever    FsyncPolicy = "never"    // leave flushing to the OS
+)
+
+const (
+	segmentExtension = ".wal"
+	headerSize       = 8 // uint32 payload length + uint32 CRC-32C of the payload
+	maxRecordSize    = 16 << 20
+)
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+var errTornRecord = errors.New("torn or corrupt record")
+
+type Options struct {
+	Dir           string
+	Fsync         FsyncPolicy
+	FsyncInterval time.Duration
+	SegmentSize   int64 // a new segment is started once the active one reaches this size
+}
+
+func DefaultOptions(dir string) Options {
+	return Options{
+		Dir:           dir,
+		Fsync:         FsyncAlways,
+		FsyncInterval: 10 * time.Millisecond,
+		SegmentSize:   64 << 20,
+	}
+}
+
+// Journal is an append-only, checksummed log of engine commands split into numbered
+// segment files. It implements engine.CommandLog.
+type Journal struct {
+	opts Options
+
+	file         *os.File
+	segmentIndex uint64
+	segmentBytes int64
+	buf          []byte
+	dirty        bool // written but not yet fsynced, FsyncInterval only
+	closed       bool
+	failed       error // an append that could not be undone, every later Append fails with it
+	mu           sync.Mutex
+
+	// bytes dropped from the end of the last segment on Open, left by a crash mid-append
+	TruncatedBytes int64
+
+	stopSync chan struct{}
+	syncDone chan struct{}
+}
+
+// CorruptionError reports a bad record in a segment that is not the last one, which
+// cannot be explained by a crash during an append
+type CorruptionError struct {
+	Segment string
+	Offset  int64
+}
+
+func (e *CorruptionError) Error() string {
+	return "journal segment " + e.Segment + " is corrupt at offset " + strconv.FormatInt(e.Offset, 10)
+}
+
+// FailedError is returned by every Append after one that failed and left the journal in
+// a state later appends cannot safely follow
+type FailedError struct {
+	Err error
+}
+
+func (e *FailedError) Error() string {
+	return "journal failed: " + e.Err.Error()
+}
+
+func (e *FailedError) Unwrap() error {
+	return e.Err
+}
+
+// Open opens (or creates) the journal in opts.Dir. A torn record at the end of the last
+// segment, left by a crash during an append, is truncated away.
+func Open(opts Options) (*Journal, error) {
+	if opts.SegmentSize <= 0 {
+		opts.SegmentSize = DefaultOptions(opts.Dir).SegmentSize
+	}
+	if opts.FsyncInterval <= 0 {
+		opts.FsyncInterval = DefaultOptions(opts.Dir).FsyncInterval
+	}
+
+	if err := os.MkdirAll(opts.Dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	segments, err := listSegments(opts.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	j := &Journal{opts: opts}
+
+	for i, index := range segments {
+		path := j.segmentPath(index)
+		validBytes, scanErr := scanSegment(path, func([]byte) error { return nil })
+		if scanErr == nil {
+			continue
+		}
+		if !errors.Is(scanErr, errTornRecord) {
+			return nil, scanErr
+		}
+		// edge case: only the segment being appended to at crash time may have a torn tail
+		if i != len(segments)-1 {
+			return nil, &CorruptionError{Segment: filepath.Base(path), Offset: validBytes}
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := os.Truncate(path, validBytes); err != nil {
+			return nil, err
+		}
+		j.TruncatedBytes = info.Size() - validBytes
+	}
+
+	if len(segments) == 0 {
+		segments = append(segments, 1)
+	}
+	if err := j.openSegment(segments[len(segments)-1]); err != nil {
+		return nil, err
+	}
+
+	if opts.Fsync == FsyncInterval {
+		j.stopSync = make(chan struct{})
+		j.syncDone = make(chan struct{})
+		go j.syncLoop()
+	}
+
+	return j, nil
+}
+
+// Replay decodes every record in segment order and passes it to apply, stopping at the
+// first error. It must be called before the first Append.
+func (j *Journal) Replay(apply func(cmd *engine.Command) error) (int, error) {
+	return j.ReplayFrom(0, apply)
+}
+
+// ReplayFrom is Replay starting at the given segment, skipping the ones a snapshot covers
+func (j *Journal) ReplayFrom(segment uint64, apply func(cmd *engine.Command) error) (int, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return Scan(j.opts.Dir, segment, apply)
+}
+
+// Scan reads the commands in dir from the given segment on without opening the journal
+// for writing, so it is safe on a journal another process is appending to. A torn record
+// at the end of the last segment ends the scan; anywhere else it is a CorruptionError.
+func Scan(dir string, segment uint64, apply func(cmd *engine.Command) error) (int, error) {
+	segments, err := listSegments(dir)
+	if err != nil {
+		return 0, err
+	}
+
+	records := 0
+	for i, index := range segments {
+		if index < segment {
+			continue
+		}
+		name := segmentName(index)
+		offset, err := scanSegment(filepath.Join(dir, name), func(payload []byte) error {
+			var cmd engine.Command
+			if err := json.Unmarshal(payload, &cmd); err != nil {
+				return fmt.Errorf("journal segment %s: decoding record %d: %w", name, records+1, err)
+			}
+			records++
+			return apply(&cmd)
+		})
+		if errors.Is(err, errTornRecord) {
+			if i == len(segments)-1 {
+				return records, nil
+			}
+			return records, &CorruptionError{Segment: name, Offset: offset}
+		}
+		if err != nil {
+			return records, err
+		}
+	}
+
+	return records, nil
+}
+
+// Append writes one command as a framed, checksummed record. The record has reached the
+// OS when Append returns, and stable storage too under FsyncAlways.
+func (j *Journal) Append(cmd *engine.Command) error {
+	payload, err := json.Marshal(cmd)
+	if err != nil {
+		return err
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.closed {
+		return errors.New("journal is closed")
+	}
+	if j.failed != nil {
+		return j.failed
+	}
+
+	j.buf = j.buf[:0]
+	j.buf = binary.BigEndian.AppendUint32(j.buf, uint32(len(payload)))
+	j.buf = binary.BigEndian.AppendUint32(j.buf, crc32.Checksum(payload, crcTable))
+	j.buf = append(j.buf, payload...)
+
+	if _, err := j.file.Write(j.buf); err != nil {
+		j.undoAppend(err)
+		return err
+	}
+
+	switch j.opts.Fsync {
+	case FsyncAlways:
+		if err := j.file.Sync(); err != nil {
+			j.undoAppend(err)
+			// edge case: a failed fsync may have dropped earlier pages too, nothing
+			// appended after it could be trusted
+			j.failed = &FailedError{Err: err}
+			return err
+		}
+	case FsyncInterval:
+		j.dirty = true
+	}
+	j.segmentBytes += int64(len(j.buf))
+
+	// edge case: the record is already written, so a failed rotation is not this append's
+	// error. The active segment keeps growing and the next append rotates again.
+	if j.segmentBytes >= j.opts.SegmentSize {
+		j.rotate()
+	}
+	return nil
+}
+
+// undoAppend cuts the active segment back to where the failed append started, so the
+// next record does not follow a torn one that recovery would stop at. If the segment
+// cannot be cut the journal fails. Must be called with j.mu held.
+func (j *Journal) undoAppend(err error) {
+	if truncateErr := j.file.Truncate(j.segmentBytes); truncateErr != nil {
+		j.failed = &FailedError{Err: err}
+	}
+}
+
+// Rotate starts a new segment and returns its index. Every command appended before
+// Rotate is in an earlier segment. An empty active segment is kept rather than rotated.
+func (j *Journal) Rotate() (uint64, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.closed {
+		return 0, errors.New("journal is closed")
+	}
+	if j.segmentBytes == 0 {
+		return j.segmentIndex, nil
+	}
+	if err := j.rotate(); err != nil {
+		return 0, err
+	}
+	return j.segmentIndex, nil
+}
+
+// Compact deletes every segment before the given one, once a durable snapshot covers
+// them. The active segment is never deleted.
+func (j *Journal) Compact(before uint64) (int, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	segments, err := listSegments(j.opts.Dir)
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, index := range segments {
+		if index >= before || index >= j.segmentIndex {
+			break
+		}
+		if err := os.Remove(j.segmentPath(index)); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// Sync forces everything appended so far to stable storage
+func (j *Journal) Sync() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.closed {
+		return nil
+	}
+	j.dirty = false
+	return j.file.Sync()
+}
+
+func (j *Journal) Close() error {
+	if j.stopSync != nil {
+		close(j.stopSync)
+		<-j.syncDone
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.closed {
+		return nil
+	}
+	j.closed = true
+
+	if err := j.file.Sync(); err != nil {
+		j.file.Close()
+		return err
+	}
+	return j.file.Close()
+}
+
+func (j *Journal) syncLoop() {
+	defer close(j.syncDone)
+
+	ticker := time.NewTicker(j.opts.FsyncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-j.stopSync:
+			return
+		case <-ticker.C:
+			j.mu.Lock()
+			if j.dirty && !j.closed {
+				// edge case: a failed background sync is retried on the next tick
+				if err := j.file.Sync(); err == nil {
+					j.dirty = false
+				}
+			}
+			j.mu.Unlock()
+		}
+	}
+}
+
+// rotate syncs the active segment and starts the next one. The active segment stays open
+// until the next one is, so a rotation that fails leaves appends going to it. Must be
+// called with j.mu held.
+func (j *Journal) rotate() error {
+	if err := j.file.Sync(); err != nil {
+		// edge case: a failed fsync may have dropped earlier pages too, nothing
+		// appended after it could be trusted
+		j.failed = &FailedError{Err: err}
+		return err
+	}
+	j.dirty = false
+
+	previous := j.file
+	if err := j.openSegment(j.segmentIndex + 1); err != nil {
+		return err
+	}
+	return previous.Close()
+}
+
+func (j *Journal) openSegment(index uint64) error {
+	file, err := os.OpenFile(j.segmentPath(index), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	j.file = file
+	j.segmentIndex = index
+	j.segmentBytes = info.Size()
+	return nil
+}
+
+func (j *Journal) segmentPath(index uint64) string {
+	return filepath.Join(j.opts.Dir, segmentName(index))
+}
+
+func segmentName(index uint64) string {
+	return fmt.Sprintf("%016d%s", index, segmentExtension)
+}
+
+func listSegments(dir string) ([]uint64, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var segments []uint64
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, segmentExtension) {
+			continue
+		}
+		index, err := strconv.ParseUint(strings.TrimSuffix(name, segmentExtension), 10, 64)
+		if err != nil {
+			continue
+		}
+		segments = append(segments, index)
+	}
+
+	sort.Slice(segments, func(a, b int) bool { return segments[a] < segments[b] })
+	return segments, nil
+}
+
+// scanSegment passes each valid record payload to fn and returns the offset just past
+// the last valid record. A short or checksum-failing record ends the scan with errTornRecord.
+func scanSegment(path string, fn func(payload []byte) error) (int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	var offset int64
+	header := make([]byte, headerSize)
+	var payload []byte
+
+	for {
+		if _, err := io.ReadFull(file, header); err != nil {
+			if err == io.EOF {
+				return offset, nil
+			}
+			if err == io.ErrUnexpectedEOF {
+				return offset, errTornRecord
+			}
+			return offset, err
+		}
+
+		length := binary.BigEndian.Uint32(header[0:4])
+		checksum := binary.BigEndian.Uint32(header[4:8])
+		if length == 0 || length > maxRecordSize {
+			return offset, errTornRecord
+		}
+
+		if cap(payload) < int(length) {
+			payload = make([]byte, length)
+		}
+		payload = payload[:length]
+		if _, err := io.ReadFull(file, payload); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				return offset, errTornRecord
+			}
+			return offset, err
+		}
+		if crc32.Checksum(payload, crcTable) != checksum {
+			return offset, errTornRecord
+		}
+
+		if err := fn(payload); err != nil {
+			return offset, err
+		}
+		offset += int64(headerSize) + int64(length)
+	}
+}
@@ -0,0 +1,106 @@
+// Package marketdata fans the engine's price level updates out to subscribers
+package marketdata
+
+import (
+	"sync"
+
+	"match-engine/src/engine"
+)
+
+// DefaultBufferSize is how many updates a subscriber may fall behind before it is dropped
+const DefaultBufferSize = 1024
+
+// Hub is an engine.BookListener that forwards each symbol's updates to its subscribers.
+// Publishing never blocks the sequencer: a subscriber whose buffer is full is closed and
+// has to resubscribe from a fresh snapshot.
+type Hub struct {
+	mu          sync.RWMutex
+	subscribers map[string]map[*Subscription]struct{}
+	bufferSize  int
+}
+
+// Subscription receives the updates of one symbol in feed sequence order. Updates is
+// closed when the subscriber falls behind or unsubscribes.
+type Subscription struct {
+	Symbol  string
+	Updates <-chan *engine.BookUpdate
+
+	updates chan *engine.BookUpdate
+
+	// a subscription is only sent to by its symbol's sequencer under the hub's read
+	// lock, and only unsubscribed under the write lock, so these need no lock of their own
+	closed bool
+	lagged bool
+}
+
+func NewHub(bufferSize int) *Hub {
+	if bufferSize <= 0 {
+		bufferSize = DefaultBufferSize
+	}
+	return &Hub{
+		subscribers: make(map[string]map[*Subscription]struct{}),
+		bufferSize:  bufferSize,
+	}
+}
+
+// Subscribe starts buffering a symbol's updates. Take the book snapshot after
+// subscribing and skip updates it already covers, so nothing falls in between.
+func (h *Hub) Subscribe(symbol string) *Subscription {
+	updates := make(chan *engine.BookUpdate, h.bufferSize)
+	sub := &Subscription{Symbol: symbol, Updates: updates, updates: updates}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.subscribers[symbol] == nil {
+		h.subscribers[symbol] = make(map[*Subscription]struct{})
+	}
+	h.subscribers[symbol][sub] = struct{}{}
+	return sub
+}
+
+// Unsubscribe stops a subscription and closes its channel
+func (h *Hub) Unsubscribe(sub *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if subs := h.subscribers[sub.Symbol]; subs != nil {
+		delete(subs, sub)
+		if len(subs) == 0 {
+			delete(h.subscribers, sub.Symbol)
+		}
+	}
+	sub.close()
+}
+
+// Lagged reports whether the subscription was closed because its buffer filled up.
+// Only meaningful once Updates is closed.
+func (s *Subscription) Lagged() bool {
+	return s.lagged
+}
+
+func (s *Subscription) close() {
+	if !s.closed {
+		s.closed = true
+		close(s.updates)
+	}
+}
+
+// OnBookUpdate implements engine.BookListener
+func (h *Hub) OnBookUpdate(update *engine.BookUpdate) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for sub := range h.subscribers[update.Symbol] {
+		if sub.closed {
+			continue
+		}
+		select {
+		case sub.updates <- update:
+		default:
+			// edge case: the subscriber fell behind, it resyncs from a new snapshot
+			sub.lagged = true
+			sub.close()
+		}
+	}
+}
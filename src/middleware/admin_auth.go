@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"crypto/subtle"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog/log"
+)
+
+// AdminAuth lets through requests carrying "Authorization: Bearer <token>". With an empty
+// token the admin API is disabled and every request is refused.
+func AdminAuth(token string) fiber.Handler {
+	expected := []byte("Bearer " + token)
+
+	return func(c *fiber.Ctx) error {
+		if token == "" {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "Admin API disabled: ADMIN_TOKEN is not set",
+			})
+		}
+
+		if subtle.ConstantTimeCompare([]byte(c.Get(fiber.HeaderAuthorization)), expected) != 1 {
+			log.Warn().
+				Str("path", c.Path()).
+				Str("method", c.Method()).
+				Str("ip", c.IP()).
+				Msg("Admin request rejected: invalid token")
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": "Unauthorized",
+			})
+		}
+		return c.Next()
+	}
+}
@@ -1,43 +1,124 @@
 package models
 
 type SubmitOrderRequest struct {
-	Symbol   string `json:"symbol"`
-	Side     string `json:"side"`
-	Type     string `json:"type"`
-	Price    int64  `json:"price"` // price in cents, required for LIMIT, 0 for MARKET
-	Quantity int64  `json:"quantity"`
+	Symbol              string `json:"symbol"`
+	Side                string `json:"side"`
+	Type                string `json:"type"`
+	Price               int64  `json:"price"`                // price in cents, required for LIMIT and STOP_LIMIT, 0 for MARKET and STOP
+	StopPrice           int64  `json:"stop_price,omitempty"` // trigger price in cents, required for STOP and STOP_LIMIT
+	Quantity            int64  `json:"quantity"`
+	DisplayQuantity     int64  `json:"display_quantity,omitempty"`      // iceberg slice shown on the book, LIMIT only
+	TimeInForce         string `json:"time_in_force,omitempty"`         // GTC (default), IOC or FOK
+	ClientOrderID       string `json:"client_order_id,omitempty"`       // optional, dedupes retried submissions
+	Account             string `json:"account,omitempty"`               // optional owner, receives the order's execution reports
+	SelfTradePrevention string `json:"self_trade_prevention,omitempty"` // NONE, CANCEL_NEWEST, CANCEL_OLDEST, CANCEL_BOTH or DECREMENT_AND_CANCEL, defaults to the account's mode
 }
 
 type SubmitOrderResponse struct {
-	OrderID          string      `json:"order_id"`
-	Status           string      `json:"status"`
-	Message          string      `json:"message,omitempty"`
-	FilledQuantity   int64       `json:"filled_quantity,omitempty"`
-	RemainingQuantity int64      `json:"remaining_quantity,omitempty"`
-	Trades           []TradeInfo `json:"trades,omitempty"`
+	OrderID           string               `json:"order_id"`
+	ClientOrderID     string               `json:"client_order_id,omitempty"`
+	Status            string               `json:"status"`
+	Message           string               `json:"message,omitempty"`
+	FilledQuantity    int64                `json:"filled_quantity,omitempty"`
+	RemainingQuantity int64                `json:"remaining_quantity,omitempty"`
+	Trades            []TradeInfo          `json:"trades,omitempty"`
+	Sequence          uint64               `json:"sequence,omitempty"`          // per-symbol command sequence number
+	PreventedMatches  []PreventedMatchInfo `json:"prevented_matches,omitempty"` // trades with the account's own orders that self-trade prevention stopped
+}
+
+type PreventedMatchInfo struct {
+	RestingOrderID    string `json:"resting_order_id"`
+	Mode              string `json:"mode"`
+	Price             int64  `json:"price"` // price in cents
+	Quantity          int64  `json:"quantity"`
+	IncomingCancelled bool   `json:"incoming_cancelled"`
+	RestingCancelled  bool   `json:"resting_cancelled"`
 }
 
 type TradeInfo struct {
-	TradeID   string `json:"trade_id"`
-	Price     int64  `json:"price"` // price in cents
-	Quantity  int64  `json:"quantity"`
-	Timestamp int64  `json:"timestamp"` // unix timestamp in milliseconds
+	TradeID     string `json:"trade_id"`
+	Price       int64  `json:"price"` // price in cents
+	Quantity    int64  `json:"quantity"`
+	Timestamp   int64  `json:"timestamp"`    // unix timestamp in milliseconds
+	TimestampNs int64  `json:"timestamp_ns"` // unix timestamp in nanoseconds
+}
+
+// TradeRecord is a trade as kept in the trade history, with both sides
+type TradeRecord struct {
+	TradeID       string `json:"trade_id"`
+	Symbol        string `json:"symbol"`
+	Price         int64  `json:"price"` // price in cents
+	Quantity      int64  `json:"quantity"`
+	BuyOrderID    string `json:"buy_order_id"`
+	SellOrderID   string `json:"sell_order_id"`
+	AggressorSide string `json:"aggressor_side"` // side of the incoming order
+	Sequence      uint64 `json:"sequence"`       // per-symbol command sequence number
+	Timestamp     int64  `json:"timestamp"`      // unix timestamp in milliseconds
+	TimestampNs   int64  `json:"timestamp_ns"`   // unix timestamp in nanoseconds
+}
+
+type TradeHistoryResponse struct {
+	Symbol     string        `json:"symbol"`
+	Trades     []TradeRecord `json:"trades"`                // oldest first
+	NextCursor string        `json:"next_cursor,omitempty"` // pass as cursor to get the next page
+}
+
+type OrderTradesResponse struct {
+	OrderID string        `json:"order_id"`
+	Trades  []TradeRecord `json:"trades"` // oldest first
+}
+
+type AmendOrderRequest struct {
+	Price    int64 `json:"price,omitempty"`    // new price in cents, 0 keeps the current price
+	Quantity int64 `json:"quantity,omitempty"` // new total quantity, 0 keeps the current quantity
+}
+
+type AmendOrderResponse struct {
+	OrderID           string      `json:"order_id"`
+	Status            string      `json:"status"`
+	Price             int64       `json:"price"` // price in cents
+	Quantity          int64       `json:"quantity"`
+	FilledQuantity    int64       `json:"filled_quantity"`
+	RemainingQuantity int64       `json:"remaining_quantity"`
+	Trades            []TradeInfo `json:"trades,omitempty"`
+	Sequence          uint64      `json:"sequence"`
 }
 
 type CancelOrderResponse struct {
-	OrderID string `json:"order_id"`
-	Status  string `json:"status"`
+	OrderID       string `json:"order_id"`
+	ClientOrderID string `json:"client_order_id,omitempty"`
+	Status        string `json:"status"`
+	Sequence      uint64 `json:"sequence,omitempty"`
 }
 
 type ErrorResponse struct {
 	Error string `json:"error"`
+	Code  string `json:"code,omitempty"` // machine-readable, e.g. SYMBOL_HALTED, for the errors that have one
 }
 
 type OrderBookResponse struct {
 	Symbol    string           `json:"symbol"`
-	Timestamp int64            `json:"timestamp"` // unix timestamp in milliseconds
-	Bids      []PriceLevelInfo `json:"bids"`      // sorted descending (highest first)
-	Asks      []PriceLevelInfo `json:"asks"`      // sorted ascending (lowest first)
+	Timestamp int64            `json:"timestamp"`         // unix timestamp in milliseconds
+	Bids      []PriceLevelInfo `json:"bids"`              // sorted descending (highest first)
+	Asks      []PriceLevelInfo `json:"asks"`              // sorted ascending (lowest first)
+	Session   string           `json:"session"`           // trading session, CONTINUOUS without a schedule
+	Auction   *AuctionInfo     `json:"auction,omitempty"` // only while the symbol is in an auction
+	Halt      *HaltInfo        `json:"halt,omitempty"`    // only while the symbol is halted
+}
+
+// AuctionInfo is what the auction would uncross at if it ended now
+type AuctionInfo struct {
+	IndicativePrice int64  `json:"indicative_price"` // price in cents, 0 while the book does not cross
+	MatchedVolume   int64  `json:"matched_volume"`
+	Imbalance       int64  `json:"imbalance"`                // quantity left unmatched at the indicative price
+	ImbalanceSide   string `json:"imbalance_side,omitempty"` // BUY or SELL, the side with the surplus
+	ReopensAt       int64  `json:"reopens_at,omitempty"`     // unix timestamp in milliseconds the reopening auction uncrosses at
+}
+
+type HaltInfo struct {
+	Reason       string `json:"reason,omitempty"`
+	AllowCancels bool   `json:"allow_cancels"`
+	Since        int64  `json:"since"` // unix timestamp in milliseconds
 }
 
 type PriceLevelInfo struct {
@@ -45,16 +126,105 @@ type PriceLevelInfo struct {
 	Quantity int64 `json:"quantity"` // aggregated quantity at this price
 }
 
+// MarketDataRequest is a message from a /ws/v1/marketdata client
+type MarketDataRequest struct {
+	Op     string `json:"op"` // subscribe or unsubscribe
+	Symbol string `json:"symbol"`
+}
+
+// MarketDataSnapshot is the full depth of a book as of feed sequence number Sequence,
+// sent on subscribe and again whenever the client has to resync
+type MarketDataSnapshot struct {
+	Type     string           `json:"type"` // snapshot
+	Symbol   string           `json:"symbol"`
+	Sequence uint64           `json:"seq"`
+	Bids     []PriceLevelInfo `json:"bids"` // sorted descending (highest first)
+	Asks     []PriceLevelInfo `json:"asks"` // sorted ascending (lowest first)
+	Status   SymbolStatusInfo `json:"status"`
+}
+
+// MarketDataUpdate carries the new aggregate quantity of every level one command changed.
+// Sequence is always one more than the previous snapshot or update of the symbol.
+type MarketDataUpdate struct {
+	Type            string            `json:"type"` // update
+	Symbol          string            `json:"symbol"`
+	Sequence        uint64            `json:"seq"`
+	CommandSequence uint64            `json:"command_seq"`  // per-symbol command sequence number
+	Timestamp       int64             `json:"timestamp_ns"` // unix timestamp in nanoseconds
+	Changes         []LevelChangeInfo `json:"changes"`
+	Status          *SymbolStatusInfo `json:"status,omitempty"` // only when the command changed it
+}
+
+// SymbolStatusInfo is what a symbol is accepting
+type SymbolStatusInfo struct {
+	Session        string `json:"session"`
+	Auction        bool   `json:"auction"`
+	Halted         bool   `json:"halted"`
+	HaltReason     string `json:"halt_reason,omitempty"`
+	CancelsAllowed bool   `json:"cancels_allowed,omitempty"` // during the halt
+	ReopensAt      int64  `json:"reopens_at_ns,omitempty"`   // unix timestamp in nanoseconds the reopening auction uncrosses at
+}
+
+type LevelChangeInfo struct {
+	Side     string `json:"side"`
+	Price    int64  `json:"price"`    // price in cents
+	Quantity int64  `json:"quantity"` // new aggregated quantity, 0 when the level is gone
+}
+
+// MarketDataStatus acknowledges an unsubscribe or reports a rejected request
+type MarketDataStatus struct {
+	Type   string `json:"type"` // unsubscribed or error
+	Symbol string `json:"symbol,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// ExecutionReportMessage is one report on an account's /ws/v1/executions stream
+type ExecutionReportMessage struct {
+	Type               string `json:"type"`      // execution_report
+	Sequence           uint64 `json:"seq"`       // per session, starts at 1 and has no gaps
+	ExecType           string `json:"exec_type"` // NEW, PARTIAL_FILL, FILLED, CANCELLED, REJECTED or EXPIRED
+	OrderID            string `json:"order_id"`
+	ClientOrderID      string `json:"client_order_id,omitempty"`
+	Account            string `json:"account"`
+	Symbol             string `json:"symbol"`
+	Side               string `json:"side"`
+	Status             string `json:"status"`          // order status after this report
+	Price              int64  `json:"price,omitempty"` // order price in cents
+	Quantity           int64  `json:"quantity"`
+	TradeID            string `json:"trade_id,omitempty"`
+	FillPrice          int64  `json:"fill_price,omitempty"` // price in cents
+	FillQuantity       int64  `json:"fill_quantity,omitempty"`
+	CumulativeQuantity int64  `json:"cum_quantity"`
+	LeavesQuantity     int64  `json:"leaves_quantity"`
+	SelfTrade          bool   `json:"self_trade,omitempty"` // cancelled by self-trade prevention
+	CommandSequence    uint64 `json:"command_seq"`          // per-symbol command sequence number
+	Timestamp          int64  `json:"timestamp"`            // unix timestamp in milliseconds
+	TimestampNs        int64  `json:"timestamp_ns"`         // unix timestamp in nanoseconds
+}
+
+// ExecutionStreamStatus confirms an execution report session or says why it ended
+type ExecutionStreamStatus struct {
+	Type    string `json:"type"` // subscribed or error
+	Account string `json:"account,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
 type OrderStatusResponse struct {
-	OrderID        string `json:"order_id"`
-	Symbol         string `json:"symbol"`
-	Side           string `json:"side"`
-	Type           string `json:"type"`
-	Price          int64  `json:"price"` // price in cents
-	Quantity       int64  `json:"quantity"`
-	FilledQuantity int64  `json:"filled_quantity"`
-	Status         string `json:"status"`
-	Timestamp      int64  `json:"timestamp"` // unix timestamp in milliseconds
+	OrderID         string      `json:"order_id"`
+	ClientOrderID   string      `json:"client_order_id,omitempty"`
+	Account         string      `json:"account,omitempty"`
+	Symbol          string      `json:"symbol"`
+	Side            string      `json:"side"`
+	Type            string      `json:"type"`
+	Price           int64       `json:"price"`                // price in cents
+	StopPrice       int64       `json:"stop_price,omitempty"` // price in cents
+	Quantity        int64       `json:"quantity"`
+	DisplayQuantity int64       `json:"display_quantity,omitempty"`
+	FilledQuantity  int64       `json:"filled_quantity"`
+	Status          string      `json:"status"`
+	Timestamp       int64       `json:"timestamp"`    // unix timestamp in milliseconds
+	TimestampNs     int64       `json:"timestamp_ns"` // unix timestamp in nanoseconds
+	Fills           []TradeInfo `json:"fills,omitempty"`
 }
 
 type HealthResponse struct {
@@ -64,15 +234,100 @@ type HealthResponse struct {
 }
 
 type MetricsResponse struct {
-	OrdersReceived        int64   `json:"orders_received"`
-	OrdersMatched         int64   `json:"orders_matched"`
-	OrdersCancelled       int64   `json:"orders_cancelled"`
-	OrdersInBook          int64   `json:"orders_in_book"`
-	TradesExecuted        int64   `json:"trades_executed"`
-	LatencyP50Ms          float64 `json:"latency_p50_ms"`
-	LatencyP99Ms          float64 `json:"latency_p99_ms"`
-	LatencyP999Ms         float64 `json:"latency_p999_ms"`
+	OrdersReceived         int64   `json:"orders_received"`
+	OrdersMatched          int64   `json:"orders_matched"`
+	OrdersCancelled        int64   `json:"orders_cancelled"`
+	OrdersAmended          int64   `json:"orders_amended"`
+	DuplicateSubmissions   int64   `json:"duplicate_submissions"`
+	OrdersInBook           int64   `json:"orders_in_book"`
+	TradesExecuted         int64   `json:"trades_executed"`
+	LatencyP50Ms           float64 `json:"latency_p50_ms"`
+	LatencyP99Ms           float64 `json:"latency_p99_ms"`
+	LatencyP999Ms          float64 `json:"latency_p999_ms"`
 	ThroughputOrdersPerSec float64 `json:"throughput_orders_per_sec"`
 }
 
+type InstrumentRequest struct {
+	Symbol            string `json:"symbol"`
+	TickSize          int64  `json:"tick_size"`                    // prices must be a multiple of it
+	LotSize           int64  `json:"lot_size"`                     // quantities must be a multiple of it
+	MinQuantity       int64  `json:"min_quantity,omitempty"`       // defaults to lot_size
+	MaxQuantity       int64  `json:"max_quantity,omitempty"`       // 0 is no limit
+	PricePrecision    *int   `json:"price_precision,omitempty"`    // decimal digits of a price, defaults to 2
+	Status            string `json:"status,omitempty"`             // TRADING (default) or CLOSED
+	MatchingAlgorithm string `json:"matching_algorithm,omitempty"` // FIFO (default), PRO_RATA or FIFO_TOP_ORDER
+	MinAllocation     int64  `json:"min_allocation,omitempty"`     // smallest pro-rata share, PRO_RATA only
+
+	Schedule *TradingSchedule `json:"schedule,omitempty"` // trading sessions, none trades continuously
+}
+
+// TradingSchedule holds the "HH:MM" start of each session of the trading day, an empty
+// time skips the session
+type TradingSchedule struct {
+	TimeZone       string `json:"time_zone,omitempty"` // IANA name, defaults to UTC
+	PreOpen        string `json:"pre_open,omitempty"`
+	OpeningAuction string `json:"opening_auction,omitempty"`
+	Continuous     string `json:"continuous"`
+	ClosingAuction string `json:"closing_auction,omitempty"`
+	Close          string `json:"close"`
+}
+
+// UpdateInstrumentRequest changes the fields it sets and keeps the others
+type UpdateInstrumentRequest struct {
+	TickSize          *int64  `json:"tick_size,omitempty"`
+	LotSize           *int64  `json:"lot_size,omitempty"`
+	MinQuantity       *int64  `json:"min_quantity,omitempty"`
+	MaxQuantity       *int64  `json:"max_quantity,omitempty"`
+	PricePrecision    *int    `json:"price_precision,omitempty"`
+	Status            *string `json:"status,omitempty"`
+	MatchingAlgorithm *string `json:"matching_algorithm,omitempty"`
+	MinAllocation     *int64  `json:"min_allocation,omitempty"`
+
+	Schedule *TradingSchedule `json:"schedule,omitempty"` // replaces the schedule, {} removes it
+}
 
+type InstrumentResponse struct {
+	Symbol            string `json:"symbol"`
+	TickSize          int64  `json:"tick_size"`
+	LotSize           int64  `json:"lot_size"`
+	MinQuantity       int64  `json:"min_quantity"`
+	MaxQuantity       int64  `json:"max_quantity"` // 0 is no limit
+	PricePrecision    int    `json:"price_precision"`
+	Status            string `json:"status"`
+	MatchingAlgorithm string `json:"matching_algorithm"`
+	MinAllocation     int64  `json:"min_allocation,omitempty"`
+
+	Schedule *TradingSchedule `json:"schedule,omitempty"`
+}
+
+type AuctionResponse struct {
+	Symbol   string `json:"symbol"`
+	Auction  bool   `json:"auction"`
+	Sequence uint64 `json:"sequence"` // per-symbol command sequence number
+}
+
+type HaltRequest struct {
+	Reason       string `json:"reason"`
+	AllowCancels bool   `json:"allow_cancels"` // let resting orders be cancelled during the halt
+}
+
+type HaltResponse struct {
+	Symbol       string `json:"symbol"`
+	Halted       bool   `json:"halted"`
+	Reason       string `json:"reason,omitempty"`
+	AllowCancels bool   `json:"allow_cancels,omitempty"`
+	ReopensAt    int64  `json:"reopens_at,omitempty"` // unix timestamp in milliseconds the reopening auction uncrosses at
+	Sequence     uint64 `json:"sequence"`             // per-symbol command sequence number
+}
+
+type UncrossResponse struct {
+	Symbol   string        `json:"symbol"`
+	Price    int64         `json:"price"` // uncross price in cents, 0 when nothing crossed
+	Volume   int64         `json:"volume"`
+	Trades   []TradeRecord `json:"trades"`
+	Sequence uint64        `json:"sequence"` // per-symbol command sequence number
+}
+
+type InstrumentListResponse struct {
+	Instruments []InstrumentResponse `json:"instruments"` // sorted by symbol
+}
@@ -0,0 +1,546 @@
+// Package ouch is a compact binary order entry protocol in front of engine.Matcher, in the
+// style of NASDAQ OUCH. Every message has a fixed layout behind a two byte length, so
+// decoding and encoding are plain copies into and out of preallocated buffers.
+package ouch
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"strconv"
+)
+
+// Framing: a big-endian uint16 length, then that many bytes starting with the message
+// type. Integers are big-endian, prices are int64 in the instrument's smallest unit,
+// quantities uint32 and alpha fields are left aligned and padded with spaces.
+const lengthSize = 2
+
+// inbound message types
+const (
+	MsgLogin        = 'L'
+	MsgEnterOrder   = 'O'
+	MsgReplaceOrder = 'U'
+	MsgCancelOrder  = 'X'
+)
+
+// outbound message types
+const (
+	MsgLoginAccepted = 'L'
+	MsgAccepted      = 'A'
+	MsgReplaced      = 'U'
+	MsgExecuted      = 'E'
+	MsgCancelled     = 'C'
+	MsgRejected      = 'J'
+	MsgCancelReject  = 'I'
+)
+
+// message lengths, type byte included
+const (
+	loginLength         = 1 + 16 + 32
+	enterOrderLength    = 1 + 14 + 1 + 1 + 1 + 8 + 8 + 4 + 4 + 1
+	replaceOrderLength  = 1 + 14 + 14 + 8 + 4
+	cancelOrderLength   = 1 + 14
+	loginAcceptedLength = 1 + 16
+	acceptedLength      = 1 + 8 + 14 + 40 + 1 + 8 + 8 + 4
+	replacedLength      = 1 + 8 + 14 + 14 + 8 + 4 + 4
+	executedLength      = 1 + 8 + 14 + 40 + 4 + 8 + 4
+	cancelledLength     = 1 + 8 + 14 + 4 + 1
+	rejectedLength      = 1 + 8 + 14 + 1
+
+	// MaxMessageLength is the longest message in either direction, with its length prefix
+	MaxMessageLength = lengthSize + acceptedLength
+)
+
+// Side, Type, TimeInForce and SelfTradePrevention values
+const (
+	SideBuy  = 'B'
+	SideSell = 'S'
+
+	TypeLimit  = 'L'
+	TypeMarket = 'M'
+
+	TimeInForceGTC = 'G'
+	TimeInForceIOC = 'I'
+	TimeInForceFOK = 'F'
+
+	SelfTradeDefault         = ' ' // the account's mode
+	SelfTradeNone            = 'T' // trade anyway
+	SelfTradeCancelNewest    = 'N'
+	SelfTradeCancelOldest    = 'O'
+	SelfTradeCancelBoth      = 'B'
+	SelfTradeDecrementCancel = 'D'
+)
+
+// Cancelled reasons
+const (
+	CancelUserRequested = 'U'
+	CancelIOC           = 'I'
+	CancelExpired       = 'T'
+	CancelSelfTrade     = 'Q'
+)
+
+// Rejected and CancelReject reasons
+const (
+	RejectDuplicateToken        = 'D'
+	RejectInvalidSymbol         = 'S'
+	RejectInvalidSide           = 'B'
+	RejectInvalidType           = 'Y'
+	RejectInvalidTimeInForce    = 'T'
+	RejectInvalidQuantity       = 'Q'
+	RejectInvalidPrice          = 'P'
+	RejectInsufficientLiquidity = 'N'
+	RejectNotTrading            = 'H'
+	RejectAuction               = 'A'
+	RejectHalted                = 'X'
+	RejectUnknownOrder          = 'U'
+	RejectTooLate               = 'C'
+	RejectInvalidReplace        = 'R'
+	RejectInvalidSelfTrade      = 'M'
+	RejectInternal              = 'E'
+)
+
+// Token is the client's name for an order, unique for the life of a connection
+type Token [14]byte
+
+// Symbol is a space padded instrument symbol
+type Symbol [8]byte
+
+// Account is the space padded account a connection logs in as
+type Account [16]byte
+
+// ID is an engine order or trade ID, space padded
+type ID [40]byte
+
+// Password is the account's space padded token
+type Password [32]byte
+
+func NewToken(s string) Token {
+	var t Token
+	setAlpha(t[:], s)
+	return t
+}
+
+func NewSymbol(s string) Symbol {
+	var sym Symbol
+	setAlpha(sym[:], s)
+	return sym
+}
+
+func NewAccount(s string) Account {
+	var a Account
+	setAlpha(a[:], s)
+	return a
+}
+
+func NewID(s string) ID {
+	var id ID
+	setAlpha(id[:], s)
+	return id
+}
+
+func NewPassword(s string) Password {
+	var p Password
+	setAlpha(p[:], s)
+	return p
+}
+
+func (t Token) String() string {
+	return alpha(t[:])
+}
+
+func (s Symbol) String() string {
+	return alpha(s[:])
+}
+
+func (a Account) String() string {
+	return alpha(a[:])
+}
+
+func (id ID) String() string {
+	return alpha(id[:])
+}
+
+func (p Password) String() string {
+	return alpha(p[:])
+}
+
+// setAlpha copies s left aligned into dst and pads it with spaces. Longer values are cut.
+func setAlpha(dst []byte, s string) {
+	n := copy(dst, s)
+	for i := n; i < len(dst); i++ {
+		dst[i] = ' '
+	}
+}
+
+func alpha(b []byte) string {
+	end := len(b)
+	for end > 0 && (b[end-1] == ' ' || b[end-1] == 0) {
+		end--
+	}
+	return string(b[:end])
+}
+
+// Login names the account a connection's orders belong to, with the account's token as
+// Password. It must be the first message.
+type Login struct {
+	Account  Account
+	Password Password
+}
+
+// EnterOrder submits a new order. Price is 0 for market orders, DisplayQuantity 0 shows
+// the whole order. SelfTradePrevention is a space, or 0, for the account's mode.
+type EnterOrder struct {
+	Token               Token
+	Side                byte
+	Type                byte
+	TimeInForce         byte
+	Symbol              Symbol
+	Price               int64
+	Quantity            uint32
+	DisplayQuantity     uint32
+	SelfTradePrevention byte
+}
+
+// ReplaceOrder changes the price and total quantity of a resting order, which is known
+// by ReplacementToken from then on. A price or quantity of 0 keeps the current one.
+type ReplaceOrder struct {
+	ExistingToken    Token
+	ReplacementToken Token
+	Price            int64
+	Quantity         uint32
+}
+
+// CancelOrder cancels what is left of an order
+type CancelOrder struct {
+	Token Token
+}
+
+type LoginAccepted struct {
+	Account Account
+}
+
+// Accepted is sent once an order passed the engine's checks, before any of its fills
+type Accepted struct {
+	Timestamp uint64 // unix nanoseconds
+	Token     Token
+	OrderID   ID
+	Side      byte
+	Symbol    Symbol
+	Price     int64
+	Quantity  uint32
+}
+
+// Replaced confirms a ReplaceOrder. The order's later messages carry Token.
+type Replaced struct {
+	Timestamp      uint64
+	Token          Token
+	PreviousToken  Token
+	Price          int64
+	Quantity       uint32
+	LeavesQuantity uint32
+}
+
+// Executed is one fill of an order
+type Executed struct {
+	Timestamp      uint64
+	Token          Token
+	TradeID        ID
+	Quantity       uint32
+	Price          int64
+	LeavesQuantity uint32
+}
+
+// Cancelled reports that an order is done without filling completely. Quantity is what
+// was left of it.
+type Cancelled struct {
+	Timestamp uint64
+	Token     Token
+	Quantity  uint32
+	Reason    byte
+}
+
+// Rejected answers an EnterOrder the engine did not take
+type Rejected struct {
+	Timestamp uint64
+	Token     Token
+	Reason    byte
+}
+
+// CancelReject answers a ReplaceOrder or CancelOrder that could not be applied
+type CancelReject struct {
+	Timestamp uint64
+	Token     Token
+	Reason    byte
+}
+
+// ReadMessage reads the next message into buf, which must hold MaxMessageLength bytes,
+// and returns it without its length prefix
+func ReadMessage(r *bufio.Reader, buf []byte) ([]byte, error) {
+	if _, err := io.ReadFull(r, buf[:lengthSize]); err != nil {
+		return nil, err
+	}
+	length := int(binary.BigEndian.Uint16(buf))
+	// edge case: an empty or oversized frame means the stream is out of step
+	if length == 0 || length > len(buf) {
+		return nil, &FrameError{Length: length}
+	}
+	msg := buf[:length]
+	if _, err := io.ReadFull(r, msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// Decoding takes a message as returned by ReadMessage, type byte included
+
+func (m *Login) Decode(msg []byte) error {
+	if err := check(msg, MsgLogin, loginLength); err != nil {
+		return err
+	}
+	copy(m.Account[:], msg[1:17])
+	copy(m.Password[:], msg[17:49])
+	return nil
+}
+
+func (m *EnterOrder) Decode(msg []byte) error {
+	if err := check(msg, MsgEnterOrder, enterOrderLength); err != nil {
+		return err
+	}
+	copy(m.Token[:], msg[1:15])
+	m.Side = msg[15]
+	m.Type = msg[16]
+	m.TimeInForce = msg[17]
+	copy(m.Symbol[:], msg[18:26])
+	m.Price = int64(binary.BigEndian.Uint64(msg[26:34]))
+	m.Quantity = binary.BigEndian.Uint32(msg[34:38])
+	m.DisplayQuantity = binary.BigEndian.Uint32(msg[38:42])
+	m.SelfTradePrevention = msg[42]
+	return nil
+}
+
+func (m *ReplaceOrder) Decode(msg []byte) error {
+	if err := check(msg, MsgReplaceOrder, replaceOrderLength); err != nil {
+		return err
+	}
+	copy(m.ExistingToken[:], msg[1:15])
+	copy(m.ReplacementToken[:], msg[15:29])
+	m.Price = int64(binary.BigEndian.Uint64(msg[29:37]))
+	m.Quantity = binary.BigEndian.Uint32(msg[37:41])
+	return nil
+}
+
+func (m *CancelOrder) Decode(msg []byte) error {
+	if err := check(msg, MsgCancelOrder, cancelOrderLength); err != nil {
+		return err
+	}
+	copy(m.Token[:], msg[1:15])
+	return nil
+}
+
+func (m *LoginAccepted) Decode(msg []byte) error {
+	if err := check(msg, MsgLoginAccepted, loginAcceptedLength); err != nil {
+		return err
+	}
+	copy(m.Account[:], msg[1:17])
+	return nil
+}
+
+func (m *Accepted) Decode(msg []byte) error {
+	if err := check(msg, MsgAccepted, acceptedLength); err != nil {
+		return err
+	}
+	m.Timestamp = binary.BigEndian.Uint64(msg[1:9])
+	copy(m.Token[:], msg[9:23])
+	copy(m.OrderID[:], msg[23:63])
+	m.Side = msg[63]
+	copy(m.Symbol[:], msg[64:72])
+	m.Price = int64(binary.BigEndian.Uint64(msg[72:80]))
+	m.Quantity = binary.BigEndian.Uint32(msg[80:84])
+	return nil
+}
+
+func (m *Replaced) Decode(msg []byte) error {
+	if err := check(msg, MsgReplaced, replacedLength); err != nil {
+		return err
+	}
+	m.Timestamp = binary.BigEndian.Uint64(msg[1:9])
+	copy(m.Token[:], msg[9:23])
+	copy(m.PreviousToken[:], msg[23:37])
+	m.Price = int64(binary.BigEndian.Uint64(msg[37:45]))
+	m.Quantity = binary.BigEndian.Uint32(msg[45:49])
+	m.LeavesQuantity = binary.BigEndian.Uint32(msg[49:53])
+	return nil
+}
+
+func (m *Executed) Decode(msg []byte) error {
+	if err := check(msg, MsgExecuted, executedLength); err != nil {
+		return err
+	}
+	m.Timestamp = binary.BigEndian.Uint64(msg[1:9])
+	copy(m.Token[:], msg[9:23])
+	copy(m.TradeID[:], msg[23:63])
+	m.Quantity = binary.BigEndian.Uint32(msg[63:67])
+	m.Price = int64(binary.BigEndian.Uint64(msg[67:75]))
+	m.LeavesQuantity = binary.BigEndian.Uint32(msg[75:79])
+	return nil
+}
+
+func (m *Cancelled) Decode(msg []byte) error {
+	if err := check(msg, MsgCancelled, cancelledLength); err != nil {
+		return err
+	}
+	m.Timestamp = binary.BigEndian.Uint64(msg[1:9])
+	copy(m.Token[:], msg[9:23])
+	m.Quantity = binary.BigEndian.Uint32(msg[23:27])
+	m.Reason = msg[27]
+	return nil
+}
+
+func (m *Rejected) Decode(msg []byte) error {
+	if err := check(msg, MsgRejected, rejectedLength); err != nil {
+		return err
+	}
+	decodeReject(msg, &m.Timestamp, &m.Token, &m.Reason)
+	return nil
+}
+
+func (m *CancelReject) Decode(msg []byte) error {
+	if err := check(msg, MsgCancelReject, rejectedLength); err != nil {
+		return err
+	}
+	decodeReject(msg, &m.Timestamp, &m.Token, &m.Reason)
+	return nil
+}
+
+func decodeReject(msg []byte, timestamp *uint64, token *Token, reason *byte) {
+	*timestamp = binary.BigEndian.Uint64(msg[1:9])
+	copy(token[:], msg[9:23])
+	*reason = msg[23]
+}
+
+// Encoding appends the framed message to buf, which should have room for
+// MaxMessageLength bytes so that nothing is allocated
+
+func (m *Login) Encode(buf []byte) []byte {
+	buf = frame(buf, loginLength, MsgLogin)
+	buf = append(buf, m.Account[:]...)
+	return append(buf, m.Password[:]...)
+}
+
+func (m *EnterOrder) Encode(buf []byte) []byte {
+	buf = frame(buf, enterOrderLength, MsgEnterOrder)
+	buf = append(buf, m.Token[:]...)
+	buf = append(buf, m.Side, m.Type, m.TimeInForce)
+	buf = append(buf, m.Symbol[:]...)
+	buf = binary.BigEndian.AppendUint64(buf, uint64(m.Price))
+	buf = binary.BigEndian.AppendUint32(buf, m.Quantity)
+	buf = binary.BigEndian.AppendUint32(buf, m.DisplayQuantity)
+	return append(buf, m.SelfTradePrevention)
+}
+
+func (m *ReplaceOrder) Encode(buf []byte) []byte {
+	buf = frame(buf, replaceOrderLength, MsgReplaceOrder)
+	buf = append(buf, m.ExistingToken[:]...)
+	buf = append(buf, m.ReplacementToken[:]...)
+	buf = binary.BigEndian.AppendUint64(buf, uint64(m.Price))
+	return binary.BigEndian.AppendUint32(buf, m.Quantity)
+}
+
+func (m *CancelOrder) Encode(buf []byte) []byte {
+	buf = frame(buf, cancelOrderLength, MsgCancelOrder)
+	return append(buf, m.Token[:]...)
+}
+
+func (m *LoginAccepted) Encode(buf []byte) []byte {
+	buf = frame(buf, loginAcceptedLength, MsgLoginAccepted)
+	return append(buf, m.Account[:]...)
+}
+
+func (m *Accepted) Encode(buf []byte) []byte {
+	buf = frame(buf, acceptedLength, MsgAccepted)
+	buf = binary.BigEndian.AppendUint64(buf, m.Timestamp)
+	buf = append(buf, m.Token[:]...)
+	buf = append(buf, m.OrderID[:]...)
+	buf = append(buf, m.Side)
+	buf = append(buf, m.Symbol[:]...)
+	buf = binary.BigEndian.AppendUint64(buf, uint64(m.Price))
+	return binary.BigEndian.AppendUint32(buf, m.Quantity)
+}
+
+func (m *Replaced) Encode(buf []byte) []byte {
+	buf = frame(buf, replacedLength, MsgReplaced)
+	buf = binary.BigEndian.AppendUint64(buf, m.Timestamp)
+	buf = append(buf, m.Token[:]...)
+	buf = append(buf, m.PreviousToken[:]...)
+	buf = binary.BigEndian.AppendUint64(buf, uint64(m.Price))
+	buf = binary.BigEndian.AppendUint32(buf, m.Quantity)
+	return binary.BigEndian.AppendUint32(buf, m.LeavesQuantity)
+}
+
+func (m *Executed) Encode(buf []byte) []byte {
+	buf = frame(buf, executedLength, MsgExecuted)
+	buf = binary.BigEndian.AppendUint64(buf, m.Timestamp)
+	buf = append(buf, m.Token[:]...)
+	buf = append(buf, m.TradeID[:]...)
+	buf = binary.BigEndian.AppendUint32(buf, m.Quantity)
+	buf = binary.BigEndian.AppendUint64(buf, uint64(m.Price))
+	return binary.BigEndian.AppendUint32(buf, m.LeavesQuantity)
+}
+
+func (m *Cancelled) Encode(buf []byte) []byte {
+	buf = frame(buf, cancelledLength, MsgCancelled)
+	buf = binary.BigEndian.AppendUint64(buf, m.Timestamp)
+	buf = append(buf, m.Token[:]...)
+	buf = binary.BigEndian.AppendUint32(buf, m.Quantity)
+	return append(buf, m.Reason)
+}
+
+func (m *Rejected) Encode(buf []byte) []byte {
+	return encodeReject(buf, MsgRejected, m.Timestamp, &m.Token, m.Reason)
+}
+
+func (m *CancelReject) Encode(buf []byte) []byte {
+	return encodeReject(buf, MsgCancelReject, m.Timestamp, &m.Token, m.Reason)
+}
+
+func encodeReject(buf []byte, msgType byte, timestamp uint64, token *Token, reason byte) []byte {
+	buf = frame(buf, rejectedLength, msgType)
+	buf = binary.BigEndian.AppendUint64(buf, timestamp)
+	buf = append(buf, token[:]...)
+	return append(buf, reason)
+}
+
+func frame(buf []byte, length int, msgType byte) []byte {
+	buf = binary.BigEndian.AppendUint16(buf, uint16(length))
+	return append(buf, msgType)
+}
+
+func check(msg []byte, msgType byte, length int) error {
+	if len(msg) != length || msg[0] != msgType {
+		err := &DecodeError{Length: len(msg)}
+		if len(msg) > 0 {
+			err.Type = msg[0]
+		}
+		return err
+	}
+	return nil
+}
+
+// FrameError is a length prefix no message has
+type FrameError struct {
+	Length int
+}
+
+func (e *FrameError) Error() string {
+	return "ouch: invalid message length " + strconv.Itoa(e.Length)
+}
+
+// DecodeError is a message of another type, or of the wrong length for its type
+type DecodeError struct {
+	Type   byte
+	Length int
+}
+
+func (e *DecodeError) Error() string {
+	return "ouch: unexpected " + strconv.QuoteRune(rune(e.Type)) + " message of " + strconv.Itoa(e.Length) + " bytes"
+}
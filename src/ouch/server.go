@@ -0,0 +1,157 @@
+package ouch
+
+import (
+	"bufio"
+	"net"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"match-engine/src/accounts"
+	"match-engine/src/engine"
+	"match-engine/src/executions"
+)
+
+// how long a new connection has to send its Login
+const loginTimeout = 10 * time.Second
+
+var accountPattern = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,16}$`)
+
+// Server accepts OUCH connections. Each connection logs in as an account with the
+// account's token and only sees the orders it entered itself. Orders stay on the book
+// when their connection closes.
+type Server struct {
+	matcher *engine.Matcher
+	hub     *executions.Hub
+	tokens  *accounts.Tokens
+
+	listener net.Listener
+	conns    sync.WaitGroup
+	quit     chan struct{}
+
+	mu      sync.Mutex
+	pending map[net.Conn]struct{} // connections that have not logged in yet
+	closed  bool
+}
+
+// NewServer serves orders to matcher. Execution reports reach their connection through
+// hub, which must be the matcher's execution listener. A Login needs its account's token
+// from tokens, so with no tokens every login is refused.
+func NewServer(matcher *engine.Matcher, hub *executions.Hub, tokens *accounts.Tokens) *Server {
+	return &Server{
+		matcher: matcher,
+		hub:     hub,
+		tokens:  tokens,
+		quit:    make(chan struct{}),
+		pending: make(map[net.Conn]struct{}),
+	}
+}
+
+// Listen starts accepting connections on addr
+func (s *Server) Listen(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	s.listener = listener
+
+	go s.accept()
+	return nil
+}
+
+// Addr is the address the server listens on
+func (s *Server) Addr() net.Addr {
+	return s.listener.Addr()
+}
+
+// Close stops accepting connections, sends every session's pending messages and closes
+// the connections
+func (s *Server) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	close(s.quit)
+	for conn := range s.pending {
+		conn.Close()
+	}
+	s.mu.Unlock()
+
+	var err error
+	if s.listener != nil {
+		err = s.listener.Close()
+	}
+	s.conns.Wait()
+	return err
+}
+
+func (s *Server) accept() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			conn.Close()
+			return
+		}
+		s.pending[conn] = struct{}{}
+		s.conns.Add(1)
+		s.mu.Unlock()
+		go s.serve(conn)
+	}
+}
+
+// serve waits for the connection's Login, then runs its session
+func (s *Server) serve(conn net.Conn) {
+	defer s.conns.Done()
+
+	remote := conn.RemoteAddr().String()
+	reader := bufio.NewReader(conn)
+	buf := make([]byte, MaxMessageLength)
+
+	conn.SetReadDeadline(time.Now().Add(loginTimeout))
+	msg, err := ReadMessage(reader, buf)
+	var login Login
+	if err == nil {
+		err = login.Decode(msg)
+	}
+	account := login.Account.String()
+
+	s.mu.Lock()
+	delete(s.pending, conn)
+	closed := s.closed
+	s.mu.Unlock()
+
+	// edge case: anything but a Login for a valid account is dropped without an answer
+	if err != nil || closed || !accountPattern.MatchString(account) {
+		log.Warn().Err(err).Str("remote", remote).Str("account", account).Msg("Invalid OUCH login, closing connection")
+		conn.Close()
+		return
+	}
+	if !s.tokens.Authenticate(account, login.Password.String()) {
+		log.Warn().Str("remote", remote).Str("account", account).Msg("OUCH login rejected: wrong password, closing connection")
+		conn.Close()
+		return
+	}
+	conn.SetReadDeadline(time.Time{})
+
+	session := newSession(account, conn, s.matcher, s.hub, s.quit)
+	accepted := LoginAccepted{Account: login.Account}
+	session.send(accepted.Encode(session.out[:0]))
+	if err := session.flush(); err != nil {
+		s.hub.Unsubscribe(session.executions)
+		conn.Close()
+		return
+	}
+
+	log.Info().Str("remote", remote).Str("account", account).Msg("OUCH session logged in")
+	session.run(reader)
+	log.Info().Str("remote", remote).Str("account", account).Msg("OUCH session closed")
+}
@@ -0,0 +1,502 @@
+package ouch
+
+import (
+	"bufio"
+	"net"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"match-engine/src/engine"
+	"match-engine/src/executions"
+)
+
+// how long a write may block before the connection is given up
+const writeTimeout = 5 * time.Second
+
+// how many decoded messages the reader may queue ahead of the session
+const requestQueueSize = 64
+
+// session is one logged in connection. Accepted, executed and cancelled messages come
+// from the engine's execution reports for the account, so fills of resting orders are
+// sent the same way as those of incoming ones. A single goroutine runs the session and
+// another reads the connection, so nothing in it needs a lock.
+type session struct {
+	account string
+	conn    net.Conn
+	writer  *bufio.Writer
+	out     []byte // encoding buffer, reused for every message
+	err     error  // first write error, the session ends on it
+
+	matcher    *engine.Matcher
+	hub        *executions.Hub
+	executions *executions.Session
+
+	requests chan request
+	quit     <-chan struct{}
+	done     chan struct{}
+
+	tokens map[Token]*sessionOrder  // every token used on the connection
+	orders map[string]*sessionOrder // engine order ID → orders that are not done
+}
+
+type sessionOrder struct {
+	id          string
+	token       Token // latest token, the one its messages carry
+	timeInForce engine.TimeInForce
+	done        bool
+}
+
+// request is a decoded inbound message, passed by value so reading allocates nothing
+type request struct {
+	msgType byte
+	enter   EnterOrder
+	replace ReplaceOrder
+	cancel  CancelOrder
+}
+
+func newSession(account string, conn net.Conn, matcher *engine.Matcher, hub *executions.Hub, quit <-chan struct{}) *session {
+	return &session{
+		account:    account,
+		conn:       conn,
+		writer:     bufio.NewWriter(conn),
+		out:        make([]byte, 0, MaxMessageLength),
+		matcher:    matcher,
+		hub:        hub,
+		executions: hub.Subscribe(account),
+		requests:   make(chan request, requestQueueSize),
+		quit:       quit,
+		done:       make(chan struct{}),
+		tokens:     make(map[Token]*sessionOrder),
+		orders:     make(map[string]*sessionOrder),
+	}
+}
+
+// run serves the connection until it closes or the server shuts down
+func (s *session) run(reader *bufio.Reader) {
+	go s.read(reader)
+	defer func() {
+		s.hub.Unsubscribe(s.executions)
+		s.conn.Close()
+		close(s.done)
+	}()
+
+	for {
+		select {
+		case req, ok := <-s.requests:
+			if !ok {
+				return
+			}
+			s.onRequest(&req)
+
+		case report, ok := <-s.executions.Reports:
+			if !ok {
+				s.resubscribe()
+				continue
+			}
+			s.onExecutionReport(report)
+
+		case <-s.quit:
+			// edge case: reports already published still go out before the connection closes
+			s.drainReports()
+			s.flush()
+			return
+		}
+
+		// edge case: a burst of messages goes out in one write once nothing else is waiting
+		if len(s.requests) == 0 && len(s.executions.Reports) == 0 {
+			if err := s.flush(); err != nil {
+				log.Warn().Err(err).Str("account", s.account).Msg("OUCH write failed, disconnecting")
+				return
+			}
+		}
+	}
+}
+
+// read decodes messages into the request queue until the connection fails
+func (s *session) read(reader *bufio.Reader) {
+	defer close(s.requests)
+
+	buf := make([]byte, MaxMessageLength)
+	for {
+		msg, err := ReadMessage(reader, buf)
+		if err != nil {
+			return
+		}
+
+		var req request
+		req.msgType = msg[0]
+		switch req.msgType {
+		case MsgEnterOrder:
+			err = req.enter.Decode(msg)
+		case MsgReplaceOrder:
+			err = req.replace.Decode(msg)
+		case MsgCancelOrder:
+			err = req.cancel.Decode(msg)
+		default:
+			err = &DecodeError{Type: msg[0], Length: len(msg)}
+		}
+		// edge case: a message we cannot decode leaves the stream out of step, drop it
+		if err != nil {
+			log.Warn().Err(err).Str("account", s.account).Msg("Invalid OUCH message, disconnecting")
+			s.conn.Close()
+			return
+		}
+
+		select {
+		case s.requests <- req:
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// resubscribe replaces a hub session that fell behind. The reports it dropped are lost,
+// the client has to check its orders' status.
+func (s *session) resubscribe() {
+	log.Warn().
+		Str("account", s.account).
+		Msg("OUCH session fell behind the execution reports, some were dropped")
+	s.executions = s.hub.Subscribe(s.account)
+}
+
+// drainReports sends the reports already waiting, so that a message answering a
+// request does not overtake the reports of the commands before it
+func (s *session) drainReports() {
+	for {
+		select {
+		case report, ok := <-s.executions.Reports:
+			if !ok {
+				s.resubscribe()
+				continue
+			}
+			s.onExecutionReport(report)
+		default:
+			return
+		}
+	}
+}
+
+func (s *session) onRequest(req *request) {
+	switch req.msgType {
+	case MsgEnterOrder:
+		s.onEnterOrder(&req.enter)
+	case MsgReplaceOrder:
+		s.onReplaceOrder(&req.replace)
+	case MsgCancelOrder:
+		s.onCancelOrder(&req.cancel)
+	}
+}
+
+func (s *session) onEnterOrder(m *EnterOrder) {
+	if _, used := s.tokens[m.Token]; used {
+		s.reject(m.Token, RejectDuplicateToken)
+		return
+	}
+	order, reason := s.newOrder(m)
+	if reason != 0 {
+		s.reject(m.Token, reason)
+		return
+	}
+
+	// the order is known before the engine can report on it
+	o := &sessionOrder{id: order.ID, token: m.Token, timeInForce: order.TimeInForce}
+	s.tokens[m.Token] = o
+	s.orders[order.ID] = o
+
+	if _, err := s.matcher.MatchOrder(order); err != nil {
+		// edge case: an order refused for lack of liquidity is reported by the engine
+		if _, ok := err.(*engine.InsufficientLiquidityError); ok {
+			return
+		}
+		reason, refused := instrumentRejectReason(err)
+		if !refused {
+			log.Error().Err(err).Str("account", s.account).Str("order_id", order.ID).Msg("Error matching OUCH order")
+			reason = RejectInternal
+		}
+		delete(s.orders, order.ID)
+		o.done = true
+		s.drainReports()
+		s.reject(m.Token, reason)
+	}
+}
+
+// instrumentRejectReason is the reason for an order or replace its instrument refused
+func instrumentRejectReason(err error) (byte, bool) {
+	switch err := err.(type) {
+	case *engine.UnknownInstrumentError:
+		return RejectInvalidSymbol, true
+	case *engine.InstrumentNotTradingError, *engine.SessionError:
+		return RejectNotTrading, true
+	case *engine.AuctionOrderError:
+		return RejectAuction, true
+	case *engine.HaltedError:
+		return RejectHalted, true
+	case *engine.InvalidOrderError:
+		if err.Field == "price" || err.Field == "stop_price" {
+			return RejectInvalidPrice, true
+		}
+		return RejectInvalidQuantity, true
+	}
+	return 0, false
+}
+
+// newOrder builds the engine order an EnterOrder asks for, or the reason it cannot
+func (s *session) newOrder(m *EnterOrder) (*engine.Order, byte) {
+	symbol := m.Symbol.String()
+	if symbol == "" {
+		return nil, RejectInvalidSymbol
+	}
+
+	var side engine.OrderSide
+	switch m.Side {
+	case SideBuy:
+		side = engine.SideBuy
+	case SideSell:
+		side = engine.SideSell
+	default:
+		return nil, RejectInvalidSide
+	}
+
+	timeInForce := engine.TIFGTC
+	switch m.TimeInForce {
+	case TimeInForceGTC:
+	case TimeInForceIOC:
+		timeInForce = engine.TIFIOC
+	case TimeInForceFOK:
+		timeInForce = engine.TIFFOK
+	default:
+		return nil, RejectInvalidTimeInForce
+	}
+
+	if m.Quantity == 0 {
+		return nil, RejectInvalidQuantity
+	}
+
+	var orderType engine.OrderType
+	switch m.Type {
+	case TypeLimit:
+		orderType = engine.TypeLimit
+		if m.Price <= 0 {
+			return nil, RejectInvalidPrice
+		}
+	case TypeMarket:
+		orderType = engine.TypeMarket
+		if m.Price != 0 {
+			return nil, RejectInvalidPrice
+		}
+	default:
+		return nil, RejectInvalidType
+	}
+
+	// edge case: only resting limit orders can hide quantity
+	if m.DisplayQuantity > 0 && (orderType != engine.TypeLimit || m.DisplayQuantity > m.Quantity) {
+		return nil, RejectInvalidQuantity
+	}
+
+	var selfTradePrevention engine.SelfTradePrevention
+	switch m.SelfTradePrevention {
+	case SelfTradeDefault, 0:
+	case SelfTradeNone:
+		selfTradePrevention = engine.STPNone
+	case SelfTradeCancelNewest:
+		selfTradePrevention = engine.STPCancelNewest
+	case SelfTradeCancelOldest:
+		selfTradePrevention = engine.STPCancelOldest
+	case SelfTradeCancelBoth:
+		selfTradePrevention = engine.STPCancelBoth
+	case SelfTradeDecrementCancel:
+		selfTradePrevention = engine.STPDecrementAndCancel
+	default:
+		return nil, RejectInvalidSelfTrade
+	}
+
+	order := engine.NewOrder(s.matcher.NewOrderID(), symbol, side, orderType, m.Price, int64(m.Quantity))
+	order.Account = s.account
+	order.TimeInForce = timeInForce
+	order.DisplayQuantity = int64(m.DisplayQuantity)
+	order.SelfTradePrevention = selfTradePrevention
+	return order, 0
+}
+
+func (s *session) onReplaceOrder(m *ReplaceOrder) {
+	o, exists := s.tokens[m.ExistingToken]
+	if !exists || o.done || o.token != m.ExistingToken {
+		s.cancelReject(m.ExistingToken, RejectUnknownOrder)
+		return
+	}
+	if _, used := s.tokens[m.ReplacementToken]; used {
+		s.cancelReject(m.ExistingToken, RejectDuplicateToken)
+		return
+	}
+	if m.Price < 0 {
+		s.cancelReject(m.ExistingToken, RejectInvalidPrice)
+		return
+	}
+
+	s.drainReports()
+	result, err := s.matcher.AmendOrder(o.id, m.Price, int64(m.Quantity))
+	if err != nil {
+		s.drainReports()
+		s.cancelReject(m.ExistingToken, s.cancelRejectReason(o, err))
+		return
+	}
+	s.tokens[m.ReplacementToken] = o
+
+	replaced := Replaced{
+		Timestamp:      uint64(s.matcher.Now().UnixNano()),
+		Token:          m.ReplacementToken,
+		PreviousToken:  m.ExistingToken,
+		Price:          result.Order.Price,
+		Quantity:       uint32(result.Order.Quantity),
+		LeavesQuantity: uint32(result.RemainingQuantity),
+	}
+
+	// reports of the commands before the replace still carry the previous token, the
+	// replace's own fills the new one
+	for pending := true; pending; {
+		select {
+		case report, ok := <-s.executions.Reports:
+			if !ok {
+				s.resubscribe()
+				continue
+			}
+			if o.token == m.ExistingToken && report.OrderID == o.id && report.CommandSequence >= result.Sequence {
+				o.token = m.ReplacementToken
+				s.send(replaced.Encode(s.out[:0]))
+			}
+			s.onExecutionReport(report)
+		default:
+			pending = false
+		}
+	}
+	if o.token == m.ExistingToken {
+		o.token = m.ReplacementToken
+		s.send(replaced.Encode(s.out[:0]))
+	}
+}
+
+func (s *session) onCancelOrder(m *CancelOrder) {
+	o, exists := s.tokens[m.Token]
+	if !exists || o.done || o.token != m.Token {
+		s.cancelReject(m.Token, RejectUnknownOrder)
+		return
+	}
+
+	// the Cancelled message comes with the engine's report
+	if _, err := s.matcher.CancelOrder(o.id); err != nil {
+		s.drainReports()
+		s.cancelReject(m.Token, s.cancelRejectReason(o, err))
+	}
+}
+
+func (s *session) cancelRejectReason(o *sessionOrder, err error) byte {
+	switch err.(type) {
+	case *engine.OrderNotFoundError:
+		return RejectUnknownOrder
+	case *engine.OrderNotCancellableError:
+		return RejectTooLate
+	case *engine.InvalidAmendError:
+		if order, exists := s.matcher.GetOrder(o.id); exists && order.GetStatus().IsTerminal() {
+			return RejectTooLate
+		}
+		return RejectInvalidReplace
+	}
+	if reason, refused := instrumentRejectReason(err); refused {
+		return reason
+	}
+	log.Error().Err(err).Str("account", s.account).Str("order_id", o.id).Msg("Error changing OUCH order")
+	return RejectInternal
+}
+
+// onExecutionReport sends the message for an engine report about one of the
+// connection's orders
+func (s *session) onExecutionReport(report *engine.ExecutionReport) {
+	o, mine := s.orders[report.OrderID]
+	if !mine {
+		return
+	}
+	timestamp := uint64(report.Timestamp)
+
+	switch report.ExecType {
+	case engine.ExecNew:
+		accepted := Accepted{
+			Timestamp: timestamp,
+			Token:     o.token,
+			OrderID:   NewID(report.OrderID),
+			Side:      SideSell,
+			Symbol:    NewSymbol(report.Symbol),
+			Price:     report.Price,
+			Quantity:  uint32(report.Quantity),
+		}
+		if report.Side == engine.SideBuy {
+			accepted.Side = SideBuy
+		}
+		s.send(accepted.Encode(s.out[:0]))
+
+	case engine.ExecPartialFill, engine.ExecFilled:
+		executed := Executed{
+			Timestamp:      timestamp,
+			Token:          o.token,
+			TradeID:        NewID(report.TradeID),
+			Quantity:       uint32(report.FillQuantity),
+			Price:          report.FillPrice,
+			LeavesQuantity: uint32(report.LeavesQuantity),
+		}
+		s.send(executed.Encode(s.out[:0]))
+
+	case engine.ExecCancelled, engine.ExecExpired:
+		cancelled := Cancelled{
+			Timestamp: timestamp,
+			Token:     o.token,
+			Quantity:  uint32(report.Quantity - report.CumulativeQuantity),
+			Reason:    CancelUserRequested,
+		}
+		if report.ExecType == engine.ExecExpired {
+			cancelled.Reason = CancelExpired
+		} else if report.SelfTrade {
+			cancelled.Reason = CancelSelfTrade
+		} else if o.timeInForce == engine.TIFIOC {
+			cancelled.Reason = CancelIOC
+		}
+		s.send(cancelled.Encode(s.out[:0]))
+
+	case engine.ExecRejected:
+		s.reject(o.token, RejectInsufficientLiquidity)
+	}
+
+	if report.Status.IsTerminal() {
+		o.done = true
+		delete(s.orders, report.OrderID)
+	}
+}
+
+func (s *session) reject(token Token, reason byte) {
+	rejected := Rejected{Timestamp: uint64(s.matcher.Now().UnixNano()), Token: token, Reason: reason}
+	s.send(rejected.Encode(s.out[:0]))
+}
+
+func (s *session) cancelReject(token Token, reason byte) {
+	rejected := CancelReject{Timestamp: uint64(s.matcher.Now().UnixNano()), Token: token, Reason: reason}
+	s.send(rejected.Encode(s.out[:0]))
+}
+
+// send buffers an encoded message, it goes out with the next flush
+func (s *session) send(msg []byte) {
+	if s.err != nil {
+		return
+	}
+	// edge case: a full buffer writes through, which needs a deadline of its own
+	if s.writer.Available() < len(msg) {
+		s.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	}
+	_, s.err = s.writer.Write(msg)
+}
+
+func (s *session) flush() error {
+	if s.err == nil && s.writer.Buffered() > 0 {
+		s.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+		s.err = s.writer.Flush()
+	}
+	return s.err
+}
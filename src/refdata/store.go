@@ -0,0 +1,69 @@
+// Package refdata keeps the instrument reference data on disk
+package refdata
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"match-engine/src/engine"
+)
+
+// FileStore keeps every instrument in one JSON file. It is rewritten whole on each
+// change, under a temporary name and renamed, so a crash leaves either the old or the
+// new definitions.
+type FileStore struct {
+	path string
+}
+
+func NewFileStore(path string) (*FileStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	return &FileStore{path: path}, nil
+}
+
+// Load reads the stored instruments, none if the file does not exist yet
+func (s *FileStore) Load() ([]engine.Instrument, error) {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var instruments []engine.Instrument
+	if err := json.Unmarshal(data, &instruments); err != nil {
+		return nil, fmt.Errorf("instruments %s: %w", filepath.Base(s.path), err)
+	}
+	return instruments, nil
+}
+
+func (s *FileStore) Save(instruments []engine.Instrument) error {
+	data, err := json.MarshalIndent(instruments, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmpPath := s.path + ".tmp"
+	tmp, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, s.path)
+}
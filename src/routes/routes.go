@@ -6,14 +6,16 @@ import (
 	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"google.golang.org/grpc"
 
 	"match-engine/src/handlers"
 	"match-engine/src/middleware"
+	"match-engine/src/pb"
 )
 
 func SetupRoutes(app *fiber.App, orderHandler *handlers.OrderHandler) {
 	rateLimitDisabled := os.Getenv("RATE_LIMIT_DISABLED") == "1"
-	
+
 	maxRequests := 100
 	if envMax := os.Getenv("RATE_LIMIT_MAX"); envMax != "" {
 		if parsed, err := strconv.Atoi(envMax); err == nil && parsed > 0 {
@@ -40,11 +42,54 @@ func SetupRoutes(app *fiber.App, orderHandler *handlers.OrderHandler) {
 	}
 
 	api.Post("/orders", orderHandler.SubmitOrder)
+	api.Patch("/orders/:id", orderHandler.AmendOrder)
 	api.Delete("/orders/:id", orderHandler.CancelOrder)
 	api.Get("/orders/:id", orderHandler.GetOrderStatus)
+	api.Get("/orders/:id/trades", orderHandler.GetOrderTrades)
+	api.Get("/orders/client/:client_order_id", orderHandler.GetOrderStatusByClientID)
+	api.Delete("/orders/client/:client_order_id", orderHandler.CancelOrderByClientID)
 	api.Get("/orderbook/:symbol", orderHandler.GetOrderBook)
+	api.Get("/trades/:symbol", orderHandler.GetTrades)
 
 	app.Get("/health", orderHandler.HealthCheck)
 	app.Get("/metrics", orderHandler.Metrics)
 }
 
+// SetupStreamRoutes registers the WebSocket market data feed and execution report
+// stream. Call it after SetupRoutes so the streams sit behind the same service middleware.
+func SetupStreamRoutes(app *fiber.App, marketDataHandler *handlers.MarketDataHandler, executionHandler *handlers.ExecutionHandler) {
+	ws := app.Group("/ws/v1")
+	ws.Use("/marketdata", marketDataHandler.RequireUpgrade)
+	ws.Get("/marketdata", marketDataHandler.Stream())
+	ws.Use("/executions", executionHandler.RequireAccount)
+	ws.Get("/executions", executionHandler.Stream())
+}
+
+// SetupInstrumentRoutes registers the instrument reference data and the admin endpoints
+// that change it. Admin requests need the ADMIN_TOKEN bearer token. Call it after
+// SetupRoutes so the public routes sit behind the same middleware.
+func SetupInstrumentRoutes(app *fiber.App, instrumentHandler *handlers.InstrumentHandler) {
+	api := app.Group("/api/v1")
+	api.Get("/instruments", instrumentHandler.ListInstruments)
+	api.Get("/instruments/:symbol", instrumentHandler.GetInstrument)
+
+	admin := app.Group("/admin/v1", middleware.AdminAuth(os.Getenv("ADMIN_TOKEN")))
+	admin.Post("/instruments", instrumentHandler.CreateInstrument)
+	admin.Patch("/instruments/:symbol", instrumentHandler.UpdateInstrument)
+}
+
+// SetupSymbolRoutes registers the admin endpoints that start and uncross a symbol's
+// auction and halt and resume it. They need the ADMIN_TOKEN bearer token.
+func SetupSymbolRoutes(app *fiber.App, symbolHandler *handlers.SymbolHandler) {
+	admin := app.Group("/admin/v1/symbols", middleware.AdminAuth(os.Getenv("ADMIN_TOKEN")))
+	admin.Post("/:symbol/auction", symbolHandler.StartAuction)
+	admin.Post("/:symbol/uncross", symbolHandler.Uncross)
+	admin.Post("/:symbol/halt", symbolHandler.Halt)
+	admin.Delete("/:symbol/halt", symbolHandler.Resume)
+}
+
+// SetupGRPCServices registers the gRPC order service, the counterpart of the /api/v1
+// routes set up by SetupRoutes
+func SetupGRPCServices(server *grpc.Server, grpcHandler *handlers.GRPCHandler) {
+	pb.RegisterOrderServiceServer(server, grpcHandler)
+}
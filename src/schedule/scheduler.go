@@ -0,0 +1,96 @@
+package schedule
+
+import (
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"match-engine/src/engine"
+)
+
+// DefaultInterval is how often the scheduler checks the trading sessions
+const DefaultInterval = time.Second
+
+// Scheduler moves symbols through the sessions of their instrument's trading schedule on
+// an interval, and ends the reopening auctions of resumed halts when their time is up.
+// Orders, amends and cancels also move their own symbol when they find it past a session
+// boundary or reopening, so the interval only bounds how late the auctions uncross.
+type Scheduler struct {
+	matcher *engine.Matcher
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func NewScheduler(matcher *engine.Matcher) *Scheduler {
+	return &Scheduler{matcher: matcher}
+}
+
+// Tick moves every symbol to its current session, ends the reopening auctions that are
+// due and logs what it did
+func (s *Scheduler) Tick() []*engine.SessionChange {
+	changes, err := s.matcher.UpdateSessions()
+	for _, change := range changes {
+		event := log.Info().
+			Str("symbol", change.Symbol).
+			Str("from", string(change.From)).
+			Str("to", string(change.To)).
+			Uint64("sequence", change.Sequence)
+		if change.Uncross != nil {
+			event = event.
+				Int64("uncross_price", change.Uncross.Price).
+				Int64("uncross_volume", change.Uncross.Volume)
+		}
+		event.Msg("Trading session changed")
+	}
+	if err != nil {
+		// edge case: the next tick tries again, the symbols it did not reach keep their session
+		log.Error().Err(err).Msg("Failed to change trading session")
+	}
+
+	uncrosses, err := s.matcher.EndReopeningAuctions()
+	for _, uncross := range uncrosses {
+		log.Info().
+			Str("symbol", uncross.Symbol).
+			Int64("uncross_price", uncross.Price).
+			Int64("uncross_volume", uncross.Volume).
+			Uint64("sequence", uncross.Sequence).
+			Msg("Reopening auction ended")
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to end reopening auction")
+	}
+	return changes
+}
+
+// Start ticks once, then every interval until Stop is called
+func (s *Scheduler) Start(interval time.Duration) {
+	s.Tick()
+
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+
+	go func() {
+		defer close(s.done)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-s.stop:
+				return
+			case <-ticker.C:
+				s.Tick()
+			}
+		}
+	}()
+}
+
+func (s *Scheduler) Stop() {
+	if s.stop == nil {
+		return
+	}
+	close(s.stop)
+	<-s.done
+}
@@ -0,0 +1,170 @@
+package snapshot
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"match-engine/src/engine"
+	"match-engine/src/journal"
+)
+
+// Version is bumped whenever File or engine.BookState change incompatibly
+const Version = 1
+
+const fileExtension = ".snapshot"
+
+// File is a snapshot of every order book. Recovery restores Books and replays the
+// journal from JournalSegment on; commands in that segment a book already covers are skipped.
+type File struct {
+	Version        int                 `json:"version"`
+	CreatedAt      int64               `json:"created_at"`      // unix nanoseconds
+	JournalSegment uint64              `json:"journal_segment"` // first segment not fully covered
+	Books          []*engine.BookState `json:"books"`
+}
+
+type UnsupportedVersionError struct {
+	Path    string
+	Version int
+}
+
+func (e *UnsupportedVersionError) Error() string {
+	return "snapshot " + e.Path + " has unsupported version " + strconv.Itoa(e.Version)
+}
+
+// Store keeps the latest snapshot in a directory, named after the journal segment it
+// leads into so the newest sorts last
+type Store struct {
+	dir string
+}
+
+func NewStore(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &Store{dir: dir}, nil
+}
+
+// Save writes the snapshot durably and then removes the older ones. The file is written
+// under a temporary name and renamed, so a crash never leaves a partial snapshot.
+func (s *Store) Save(file *File) error {
+	data, err := json.Marshal(file)
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(s.dir, fmt.Sprintf("%016d%s", file.JournalSegment, fileExtension))
+	tmpPath := path + ".tmp"
+
+	tmp, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return err
+	}
+	if err := syncDir(s.dir); err != nil {
+		return err
+	}
+
+	paths, err := s.list()
+	if err != nil {
+		return err
+	}
+	for _, older := range paths {
+		if older == path {
+			break
+		}
+		if err := os.Remove(older); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Latest loads the newest snapshot, or returns nil if there is none
+func (s *Store) Latest() (*File, error) {
+	paths, err := s.list()
+	if err != nil || len(paths) == 0 {
+		return nil, err
+	}
+
+	path := paths[len(paths)-1]
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file File
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("snapshot %s: %w", filepath.Base(path), err)
+	}
+	if file.Version != Version {
+		return nil, &UnsupportedVersionError{Path: filepath.Base(path), Version: file.Version}
+	}
+	return &file, nil
+}
+
+// list returns the snapshot paths, oldest first
+func (s *Store) list() ([]string, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), fileExtension) {
+			paths = append(paths, filepath.Join(s.dir, entry.Name()))
+		}
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+// Recover restores the latest snapshot into a fresh matcher, if there is one, and then
+// replays the journal tail after it. It returns the snapshot used and the number of
+// journal commands replayed.
+func Recover(matcher *engine.Matcher, j *journal.Journal, store *Store) (*File, int, error) {
+	file, err := store.Latest()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var fromSegment uint64
+	if file != nil {
+		for _, book := range file.Books {
+			if err := matcher.Restore(book); err != nil {
+				return file, 0, err
+			}
+		}
+		fromSegment = file.JournalSegment
+	}
+
+	replayed, err := j.ReplayFrom(fromSegment, matcher.Replay)
+	return file, replayed, err
+}
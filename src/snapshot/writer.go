@@ -0,0 +1,109 @@
+package snapshot
+
+import (
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"match-engine/src/engine"
+	"match-engine/src/journal"
+)
+
+// Writer snapshots a Matcher on an interval and compacts the journal segments each
+// snapshot covers
+type Writer struct {
+	matcher *engine.Matcher
+	journal *journal.Journal
+	store   *Store
+
+	lastSegment uint64     // journal segment the last snapshot leads into
+	mu          sync.Mutex // one snapshot at a time
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func NewWriter(matcher *engine.Matcher, j *journal.Journal, store *Store) *Writer {
+	return &Writer{
+		matcher: matcher,
+		journal: j,
+		store:   store,
+	}
+}
+
+// Take writes a snapshot and then deletes the journal segments before it. It returns
+// nil when nothing was journaled since the last snapshot.
+func (w *Writer) Take() (*File, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	// rotating first means every command in an earlier segment was applied to its book
+	// before the book is captured, since commands are journaled under the book lock
+	segment, err := w.journal.Rotate()
+	if err != nil {
+		return nil, err
+	}
+	if segment == w.lastSegment {
+		return nil, nil
+	}
+
+	file := &File{
+		Version:        Version,
+		CreatedAt:      time.Now().UnixNano(),
+		JournalSegment: segment,
+		Books:          w.matcher.Snapshot(),
+	}
+	if err := w.store.Save(file); err != nil {
+		return nil, err
+	}
+	w.lastSegment = segment
+
+	if _, err := w.journal.Compact(segment); err != nil {
+		return file, err
+	}
+	return file, nil
+}
+
+// Start takes a snapshot every interval until Stop is called
+func (w *Writer) Start(interval time.Duration) {
+	w.stop = make(chan struct{})
+	w.done = make(chan struct{})
+
+	go func() {
+		defer close(w.done)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-w.stop:
+				return
+			case <-ticker.C:
+				start := time.Now()
+				file, err := w.Take()
+				if err != nil {
+					// edge case: the journal still has everything, so a failed snapshot only delays compaction
+					log.Error().Err(err).Msg("Failed to write snapshot")
+					continue
+				}
+				if file != nil {
+					log.Info().
+						Int("books", len(file.Books)).
+						Uint64("journal_segment", file.JournalSegment).
+						Dur("elapsed", time.Since(start)).
+						Msg("Snapshot written")
+				}
+			}
+		}
+	}()
+}
+
+func (w *Writer) Stop() {
+	if w.stop == nil {
+		return
+	}
+	close(w.stop)
+	<-w.done
+}
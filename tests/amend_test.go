@@ -0,0 +1,192 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"match-engine/src/engine"
+	"match-engine/src/models"
+)
+
+// TestAmendReduceQuantityKeepsPriority tests that reducing quantity keeps the order's queue position
+func TestAmendReduceQuantityKeepsPriority(t *testing.T) {
+	matcher := engine.NewMatcher()
+	symbol := "AAPL"
+
+	order1 := engine.NewOrder(uuid.New().String(), symbol, engine.SideBuy, engine.TypeLimit, 15050, 300)
+	_, _ = matcher.MatchOrder(order1)
+
+	order2 := engine.NewOrder(uuid.New().String(), symbol, engine.SideBuy, engine.TypeLimit, 15050, 200)
+	_, _ = matcher.MatchOrder(order2)
+
+	result, err := matcher.AmendOrder(order1.ID, 0, 100)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if result.RemainingQuantity != 100 {
+		t.Errorf("Expected remaining quantity 100, got: %d", result.RemainingQuantity)
+	}
+
+	orderBook := matcher.GetOrCreateOrderBook(symbol)
+	priceLevel := orderBook.GetPriceLevelForBid(15050)
+	if priceLevel.Orders[0].ID != order1.ID {
+		t.Error("Expected reduced order to keep its place at the front of the queue")
+	}
+
+	_, qty, _ := orderBook.GetBestBid()
+	if qty != 300 {
+		t.Errorf("Expected bid quantity 300, got: %d", qty)
+	}
+}
+
+// TestAmendIncreaseQuantityLosesPriority tests that increasing quantity moves the order to the back
+func TestAmendIncreaseQuantityLosesPriority(t *testing.T) {
+	matcher := engine.NewMatcher()
+	symbol := "AAPL"
+
+	order1 := engine.NewOrder(uuid.New().String(), symbol, engine.SideBuy, engine.TypeLimit, 15050, 300)
+	_, _ = matcher.MatchOrder(order1)
+
+	order2 := engine.NewOrder(uuid.New().String(), symbol, engine.SideBuy, engine.TypeLimit, 15050, 200)
+	_, _ = matcher.MatchOrder(order2)
+
+	_, err := matcher.AmendOrder(order1.ID, 0, 500)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	orderBook := matcher.GetOrCreateOrderBook(symbol)
+	priceLevel := orderBook.GetPriceLevelForBid(15050)
+	if priceLevel.Orders[0].ID != order2.ID || priceLevel.Orders[1].ID != order1.ID {
+		t.Error("Expected increased order to move behind order2")
+	}
+}
+
+// TestAmendPriceCrossesBook tests that repricing through the opposite side matches immediately
+func TestAmendPriceCrossesBook(t *testing.T) {
+	matcher := engine.NewMatcher()
+	symbol := "AAPL"
+
+	sellOrder := engine.NewOrder(uuid.New().String(), symbol, engine.SideSell, engine.TypeLimit, 15060, 100)
+	_, _ = matcher.MatchOrder(sellOrder)
+
+	buyOrder := engine.NewOrder(uuid.New().String(), symbol, engine.SideBuy, engine.TypeLimit, 15050, 300)
+	_, _ = matcher.MatchOrder(buyOrder)
+
+	result, err := matcher.AmendOrder(buyOrder.ID, 15060, 0)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if result.Status != engine.StatusPartialFill {
+		t.Errorf("Expected PARTIAL_FILL, got: %s", result.Status)
+	}
+
+	if len(result.Trades) != 1 || result.Trades[0].Price != 15060 {
+		t.Fatalf("Expected 1 trade at 15060, got: %+v", result.Trades)
+	}
+
+	if result.RemainingQuantity != 200 {
+		t.Errorf("Expected remaining quantity 200, got: %d", result.RemainingQuantity)
+	}
+
+	orderBook := matcher.GetOrCreateOrderBook(symbol)
+	price, qty, _ := orderBook.GetBestBid()
+	if price != 15060 || qty != 200 {
+		t.Errorf("Expected best bid 15060 x 200, got: %d x %d", price, qty)
+	}
+
+	if _, _, hasAsk := orderBook.GetBestAsk(); hasAsk {
+		t.Error("Expected ask side to be empty")
+	}
+}
+
+// TestAmendBelowFilledQuantity tests that an amend cannot reduce quantity to or below what has filled
+func TestAmendBelowFilledQuantity(t *testing.T) {
+	matcher := engine.NewMatcher()
+	symbol := "AAPL"
+
+	buyOrder := engine.NewOrder(uuid.New().String(), symbol, engine.SideBuy, engine.TypeLimit, 15050, 300)
+	_, _ = matcher.MatchOrder(buyOrder)
+
+	sellOrder := engine.NewOrder(uuid.New().String(), symbol, engine.SideSell, engine.TypeLimit, 15050, 100)
+	_, _ = matcher.MatchOrder(sellOrder)
+
+	_, err := matcher.AmendOrder(buyOrder.ID, 0, 100)
+	if _, ok := err.(*engine.InvalidAmendError); !ok {
+		t.Fatalf("Expected InvalidAmendError, got: %v", err)
+	}
+
+	_, err = matcher.AmendOrder(uuid.New().String(), 0, 100)
+	if _, ok := err.(*engine.OrderNotFoundError); !ok {
+		t.Fatalf("Expected OrderNotFoundError, got: %v", err)
+	}
+}
+
+// TestAmendOrderAPI tests the PATCH /api/v1/orders/:id endpoint
+func TestAmendOrderAPI(t *testing.T) {
+	app := setupTestServer()
+
+	reqBody := map[string]interface{}{
+		"symbol":   "AAPL",
+		"side":     "BUY",
+		"type":     "LIMIT",
+		"price":    15050,
+		"quantity": 100,
+	}
+
+	body, _ := json.Marshal(reqBody)
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/orders", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, _ := app.Test(req)
+
+	var submitResp models.SubmitOrderResponse
+	json.NewDecoder(resp.Body).Decode(&submitResp)
+
+	// Amend price and quantity
+	body, _ = json.Marshal(map[string]interface{}{"price": 15055, "quantity": 150})
+	req = httptest.NewRequest(http.MethodPatch, "/api/v1/orders/"+submitResp.OrderID, bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req)
+
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200, got: %d", resp.StatusCode)
+	}
+
+	var amendResp models.AmendOrderResponse
+	json.NewDecoder(resp.Body).Decode(&amendResp)
+
+	if amendResp.Price != 15055 || amendResp.Quantity != 150 {
+		t.Errorf("Expected 15055 x 150, got: %d x %d", amendResp.Price, amendResp.Quantity)
+	}
+
+	// Empty amend is rejected
+	body, _ = json.Marshal(map[string]interface{}{})
+	req = httptest.NewRequest(http.MethodPatch, "/api/v1/orders/"+submitResp.OrderID, bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, _ = app.Test(req)
+
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for empty amend, got: %d", resp.StatusCode)
+	}
+
+	// Unknown order
+	body, _ = json.Marshal(map[string]interface{}{"quantity": 50})
+	req = httptest.NewRequest(http.MethodPatch, "/api/v1/orders/non-existent-id", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, _ = app.Test(req)
+
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected status 404 for unknown order, got: %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,382 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+
+	"match-engine/src/engine"
+	"match-engine/src/handlers"
+	"match-engine/src/journal"
+	"match-engine/src/models"
+	"match-engine/src/routes"
+)
+
+// TestAuctionUncross tests that an auction collects crossing orders without trading and
+// executes them at one equilibrium price when it uncrosses
+func TestAuctionUncross(t *testing.T) {
+	matcher := engine.NewMatcher()
+	defer matcher.Close()
+
+	if _, err := matcher.StartAuction("AAPL"); err != nil {
+		t.Fatalf("StartAuction failed: %v", err)
+	}
+
+	bid1 := newTestOrder("AAPL", engine.SideBuy, 15100, 100)
+	bid2 := newTestOrder("AAPL", engine.SideBuy, 15000, 200)
+	ask1 := newTestOrder("AAPL", engine.SideSell, 14900, 150)
+	ask2 := newTestOrder("AAPL", engine.SideSell, 15000, 100)
+	for _, order := range []*engine.Order{bid1, bid2, ask1, ask2} {
+		result, err := matcher.MatchOrder(order)
+		if err != nil {
+			t.Fatalf("MatchOrder failed: %v", err)
+		}
+		if result.Status != engine.StatusAccepted || len(result.Trades) != 0 {
+			t.Fatalf("Expected the order to rest without trading, got %s with %d trades", result.Status, len(result.Trades))
+		}
+	}
+
+	orderBook, _ := matcher.GetOrderBook("AAPL")
+	indicative, inAuction := orderBook.IndicativeUncross()
+	if !inAuction {
+		t.Fatal("Expected the book to be in an auction")
+	}
+	// 14900 trades 150, 15000 trades 250, 15100 trades 100
+	if indicative.Price != 15000 || indicative.Volume != 250 || indicative.Imbalance != 50 {
+		t.Errorf("Expected 250 at 15000 with 50 more demand, got %+v", indicative)
+	}
+
+	result, err := matcher.Uncross("AAPL")
+	if err != nil {
+		t.Fatalf("Uncross failed: %v", err)
+	}
+	if result.Price != 15000 || result.Volume != 250 || len(result.Trades) != 3 || result.Sequence == 0 {
+		t.Fatalf("Unexpected uncross result %+v", result)
+	}
+	expected := []struct {
+		buy, sell *engine.Order
+		quantity  int64
+	}{
+		{bid1, ask1, 100},
+		{bid2, ask1, 50},
+		{bid2, ask2, 100},
+	}
+	for i, tt := range expected {
+		trade := result.Trades[i]
+		if trade.BuyOrderID != tt.buy.ID || trade.SellOrderID != tt.sell.ID || trade.Quantity != tt.quantity || trade.Price != 15000 {
+			t.Errorf("Trade %d: expected %d at 15000, got %+v", i, tt.quantity, trade)
+		}
+		if trade.AggressorSide != "" {
+			t.Errorf("Trade %d: expected no aggressor, got %s", i, trade.AggressorSide)
+		}
+	}
+	if bid2.RemainingQuantity() != 50 || bid2.GetStatus() != engine.StatusPartialFill {
+		t.Errorf("Expected 50 of the 15000 bid left, got %d (%s)", bid2.RemainingQuantity(), bid2.GetStatus())
+	}
+	if orderBook.InAuction() || orderBook.GetLastTradePrice() != 15000 {
+		t.Errorf("Expected continuous trading at a last price of 15000, got auction %v at %d", orderBook.InAuction(), orderBook.GetLastTradePrice())
+	}
+
+	// continuous matching is back
+	sell := newTestOrder("AAPL", engine.SideSell, 15000, 50)
+	if got, _ := matcher.MatchOrder(sell); got == nil || got.Status != engine.StatusFilled {
+		t.Errorf("Expected a crossing sell to fill after the uncross, got %+v", got)
+	}
+}
+
+// TestAuctionEquilibriumPrice tests that the equilibrium price maximizes volume, then
+// minimizes the imbalance, then is closest to the reference price
+func TestAuctionEquilibriumPrice(t *testing.T) {
+	type level struct {
+		side     engine.OrderSide
+		price    int64
+		quantity int64
+	}
+	tests := []struct {
+		name      string
+		reference int64 // last trade before the auction, 0 for none
+		orders    []level
+		price     int64
+		volume    int64
+		imbalance int64
+	}{
+		{
+			name:   "no cross",
+			orders: []level{{engine.SideBuy, 14900, 100}, {engine.SideSell, 15000, 100}},
+		},
+		{
+			name:   "one side empty",
+			orders: []level{{engine.SideBuy, 15000, 100}},
+		},
+		{
+			name:      "maximum volume",
+			orders:    []level{{engine.SideBuy, 15200, 100}, {engine.SideBuy, 15100, 100}, {engine.SideSell, 15000, 150}, {engine.SideSell, 15100, 100}},
+			price:     15100,
+			volume:    200,
+			imbalance: -50,
+		},
+		{
+			name:      "minimum imbalance",
+			orders:    []level{{engine.SideBuy, 15100, 100}, {engine.SideBuy, 14900, 50}, {engine.SideSell, 14900, 100}},
+			price:     15100,
+			volume:    100,
+			imbalance: 0,
+		},
+		{
+			name:      "lowest price without a reference",
+			orders:    []level{{engine.SideBuy, 15100, 100}, {engine.SideSell, 14900, 100}},
+			price:     14900,
+			volume:    100,
+			imbalance: 0,
+		},
+		{
+			name:      "reference price between the levels",
+			reference: 15050,
+			orders:    []level{{engine.SideBuy, 15100, 100}, {engine.SideSell, 14900, 100}},
+			price:     15050,
+			volume:    100,
+			imbalance: 0,
+		},
+		{
+			name:      "level closest to the reference",
+			reference: 15300,
+			orders:    []level{{engine.SideBuy, 15100, 100}, {engine.SideSell, 14900, 100}},
+			price:     15100,
+			volume:    100,
+			imbalance: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matcher := engine.NewMatcher()
+			defer matcher.Close()
+
+			if tt.reference > 0 {
+				matcher.MatchOrder(newTestOrder("AAPL", engine.SideSell, tt.reference, 10))
+				matcher.MatchOrder(newTestOrder("AAPL", engine.SideBuy, tt.reference, 10))
+			}
+			matcher.StartAuction("AAPL")
+			for _, l := range tt.orders {
+				if _, err := matcher.MatchOrder(newTestOrder("AAPL", l.side, l.price, l.quantity)); err != nil {
+					t.Fatalf("MatchOrder failed: %v", err)
+				}
+			}
+
+			orderBook, _ := matcher.GetOrderBook("AAPL")
+			indicative, _ := orderBook.IndicativeUncross()
+			if indicative.Price != tt.price || indicative.Volume != tt.volume || indicative.Imbalance != tt.imbalance {
+				t.Errorf("Expected %d at %d with imbalance %d, got %+v", tt.volume, tt.price, tt.imbalance, indicative)
+			}
+
+			result, err := matcher.Uncross("AAPL")
+			if err != nil {
+				t.Fatalf("Uncross failed: %v", err)
+			}
+			var traded int64
+			for _, trade := range result.Trades {
+				traded += trade.Quantity
+				if trade.Price != tt.price {
+					t.Errorf("Expected every trade at %d, got %d", tt.price, trade.Price)
+				}
+			}
+			if traded != tt.volume {
+				t.Errorf("Expected %d to trade, got %d", tt.volume, traded)
+			}
+		})
+	}
+}
+
+// TestAuctionOrders tests which orders and commands an auction accepts
+func TestAuctionOrders(t *testing.T) {
+	matcher, _ := newInstrumentMatcher(t)
+
+	if _, err := matcher.StartAuction("MSFT"); err == nil {
+		t.Error("Expected an auction on an unlisted symbol to be refused")
+	} else if _, ok := err.(*engine.UnknownInstrumentError); !ok {
+		t.Errorf("Expected UnknownInstrumentError, got %v", err)
+	}
+	if _, err := matcher.Uncross("AAPL"); err == nil {
+		t.Error("Expected an uncross outside an auction to be refused")
+	} else if _, ok := err.(*engine.AuctionStateError); !ok {
+		t.Errorf("Expected AuctionStateError, got %v", err)
+	}
+	if _, err := matcher.StartAuction("AAPL"); err != nil {
+		t.Fatalf("StartAuction failed: %v", err)
+	}
+	if _, err := matcher.StartAuction("AAPL"); err == nil {
+		t.Error("Expected a second auction start to be refused")
+	} else if _, ok := err.(*engine.AuctionStateError); !ok {
+		t.Errorf("Expected AuctionStateError, got %v", err)
+	}
+
+	resting := newTestOrder("AAPL", engine.SideSell, 15000, 100)
+	if _, err := matcher.MatchOrder(resting); err != nil {
+		t.Fatalf("MatchOrder failed: %v", err)
+	}
+
+	market := engine.NewOrder(uuid.New().String(), "AAPL", engine.SideBuy, engine.TypeMarket, 0, 100)
+	ioc := newTestOrder("AAPL", engine.SideBuy, 15000, 100)
+	ioc.TimeInForce = engine.TIFIOC
+	for _, order := range []*engine.Order{market, ioc} {
+		if _, err := matcher.MatchOrder(order); err == nil {
+			t.Errorf("Expected a %s %s order to be rejected during the auction", order.TimeInForce, order.Type)
+		} else if _, ok := err.(*engine.AuctionOrderError); !ok {
+			t.Errorf("Expected AuctionOrderError, got %v", err)
+		}
+		if order.GetStatus() != engine.StatusRejected {
+			t.Errorf("Expected the order rejected, got %s", order.GetStatus())
+		}
+	}
+
+	stop := newTestOrder("AAPL", engine.SideBuy, 15100, 50)
+	stop.Type = engine.TypeStopLimit
+	stop.StopPrice = 15000
+	if result, err := matcher.MatchOrder(stop); err != nil || !result.StopPending {
+		t.Fatalf("Expected a stop to wait for its trigger, got %+v, %v", result, err)
+	}
+
+	// an amend that crosses the book still waits for the uncross
+	bid := newTestOrder("AAPL", engine.SideBuy, 14900, 100)
+	matcher.MatchOrder(bid)
+	if result, err := matcher.AmendOrder(bid.ID, 15000, 0); err != nil || len(result.Trades) != 0 {
+		t.Fatalf("Expected the amend to rest without trading, got %+v, %v", result, err)
+	}
+
+	result, err := matcher.Uncross("AAPL")
+	if err != nil {
+		t.Fatalf("Uncross failed: %v", err)
+	}
+	if result.Volume != 100 || len(result.TriggeredOrders) != 1 || result.TriggeredOrders[0].Order.ID != stop.ID {
+		t.Errorf("Expected 100 to trade and trigger the stop, got %+v", result)
+	}
+}
+
+// TestAuctionReplay tests that an auction and its uncross replay to the same books, and
+// that a snapshot keeps a running auction
+func TestAuctionReplay(t *testing.T) {
+	dir := t.TempDir()
+	j := openJournal(t, journal.DefaultOptions(dir))
+	matcher := engine.NewMatcher(engine.WithCommandLog(j))
+	matcher.MatchOrder(newTestOrder("AAPL", engine.SideSell, 15000, 100))
+	matcher.StartAuction("AAPL")
+	matcher.MatchOrder(newTestOrder("AAPL", engine.SideBuy, 15100, 150))
+	matcher.MatchOrder(newTestOrder("AAPL", engine.SideSell, 14900, 30))
+	matcher.Uncross("AAPL")
+	matcher.StartAuction("AAPL")
+	matcher.MatchOrder(newTestOrder("AAPL", engine.SideSell, 15000, 70))
+	states := matcher.Snapshot()
+	live, _ := json.Marshal(states)
+	matcher.Close()
+	j.Close()
+
+	replayed := engine.NewMatcher()
+	defer replayed.Close()
+	if _, err := journal.Scan(dir, 0, replayed.Replay); err != nil {
+		t.Fatalf("Replay failed: %v", err)
+	}
+	books, _ := json.Marshal(replayed.Snapshot())
+	if !bytes.Equal(live, books) {
+		t.Errorf("Expected replayed books to match live books\nlive:     %s\nreplayed: %s", live, books)
+	}
+
+	restored := engine.NewMatcher()
+	defer restored.Close()
+	for _, state := range states {
+		if err := restored.Restore(state); err != nil {
+			t.Fatalf("Restore failed: %v", err)
+		}
+	}
+	orderBook, _ := restored.GetOrderBook("AAPL")
+	if indicative, inAuction := orderBook.IndicativeUncross(); !inAuction || indicative.Volume != 20 {
+		t.Errorf("Expected the restored book in an auction crossing 20, got %v, %+v", inAuction, indicative)
+	}
+}
+
+// TestAuctionAPI tests the admin auction endpoints and the indicative uncross in the
+// order book response
+func TestAuctionAPI(t *testing.T) {
+	t.Setenv("RATE_LIMIT_DISABLED", "1")
+	t.Setenv("REQUEST_LOGGING_DISABLED", "1")
+	t.Setenv("ADMIN_TOKEN", "secret")
+
+	matcher := engine.NewMatcher()
+	defer matcher.Close()
+
+	app := fiber.New()
+	routes.SetupRoutes(app, handlers.NewOrderHandler(matcher))
+	routes.SetupSymbolRoutes(app, handlers.NewSymbolHandler(matcher))
+
+	send := func(method, url, token string, body interface{}) (int, []byte) {
+		t.Helper()
+		data, _ := json.Marshal(body)
+		req := httptest.NewRequest(method, url, bytes.NewReader(data))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		respBody, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, respBody
+	}
+
+	if status, _ := send(http.MethodPost, "/admin/v1/symbols/AAPL/auction", "", nil); status != http.StatusUnauthorized {
+		t.Errorf("Expected 401 without a token, got %d", status)
+	}
+	if status, body := send(http.MethodPost, "/admin/v1/symbols/AAPL/auction", "secret", nil); status != http.StatusOK {
+		t.Fatalf("Expected 200 starting the auction, got %d: %s", status, body)
+	}
+	if status, _ := send(http.MethodPost, "/admin/v1/symbols/AAPL/auction", "secret", nil); status != http.StatusConflict {
+		t.Errorf("Expected 409 for a running auction, got %d", status)
+	}
+
+	orders := []models.SubmitOrderRequest{
+		{Symbol: "AAPL", Side: "BUY", Type: "LIMIT", Price: 15100, Quantity: 300},
+		{Symbol: "AAPL", Side: "SELL", Type: "LIMIT", Price: 15000, Quantity: 100},
+	}
+	for _, order := range orders {
+		if status, body := send(http.MethodPost, "/api/v1/orders", "", order); status != http.StatusCreated {
+			t.Fatalf("Expected 201, got %d: %s", status, body)
+		}
+	}
+	market := models.SubmitOrderRequest{Symbol: "AAPL", Side: "SELL", Type: "MARKET", Quantity: 100}
+	if status, _ := send(http.MethodPost, "/api/v1/orders", "", market); status != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a market order during the auction, got %d", status)
+	}
+
+	status, body := send(http.MethodGet, "/api/v1/orderbook/AAPL", "", nil)
+	var book models.OrderBookResponse
+	json.Unmarshal(body, &book)
+	if status != http.StatusOK || book.Auction == nil {
+		t.Fatalf("Expected the auction in the order book, got %d: %s", status, body)
+	}
+	if book.Auction.IndicativePrice != 15000 || book.Auction.MatchedVolume != 100 || book.Auction.Imbalance != 200 || book.Auction.ImbalanceSide != "BUY" {
+		t.Errorf("Unexpected auction info %+v", *book.Auction)
+	}
+
+	status, body = send(http.MethodPost, "/admin/v1/symbols/AAPL/uncross", "secret", nil)
+	var uncross models.UncrossResponse
+	json.Unmarshal(body, &uncross)
+	if status != http.StatusOK || uncross.Price != 15000 || uncross.Volume != 100 || len(uncross.Trades) != 1 {
+		t.Fatalf("Unexpected uncross response %d: %s", status, body)
+	}
+	if status, _ := send(http.MethodPost, "/admin/v1/symbols/AAPL/uncross", "secret", nil); status != http.StatusConflict {
+		t.Errorf("Expected 409 outside an auction, got %d", status)
+	}
+
+	_, body = send(http.MethodGet, "/api/v1/orderbook/AAPL", "", nil)
+	book = models.OrderBookResponse{}
+	json.Unmarshal(body, &book)
+	if book.Auction != nil || len(book.Bids) != 1 || book.Bids[0].Quantity != 200 {
+		t.Errorf("Expected 200 left on the bid after the uncross, got %s", body)
+	}
+}
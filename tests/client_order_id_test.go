@@ -0,0 +1,230 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+
+	"match-engine/src/engine"
+	"match-engine/src/models"
+)
+
+func submitWithHeaders(t *testing.T, app *fiber.App, reqBody map[string]interface{}, headers map[string]string) (*http.Response, models.SubmitOrderResponse) {
+	body, _ := json.Marshal(reqBody)
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/orders", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+
+	var result models.SubmitOrderResponse
+	json.NewDecoder(resp.Body).Decode(&result)
+	return resp, result
+}
+
+func bookQuantity(t *testing.T, app *fiber.App, symbol string) int64 {
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/orderbook/"+symbol, nil)
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+
+	var book models.OrderBookResponse
+	json.NewDecoder(resp.Body).Decode(&book)
+
+	var total int64
+	for _, level := range book.Bids {
+		total += level.Quantity
+	}
+	for _, level := range book.Asks {
+		total += level.Quantity
+	}
+	return total
+}
+
+// TestDuplicateClientOrderIDReturnsOriginal tests that a retried submission with the same
+// client_order_id returns the original response and does not create a second order
+func TestDuplicateClientOrderIDReturnsOriginal(t *testing.T) {
+	app := setupTestServer()
+
+	reqBody := map[string]interface{}{
+		"symbol":          "AAPL",
+		"side":            "BUY",
+		"type":            "LIMIT",
+		"price":           15050,
+		"quantity":        100,
+		"client_order_id": "algo-1-0001",
+	}
+
+	firstResp, first := submitWithHeaders(t, app, reqBody, nil)
+	secondResp, second := submitWithHeaders(t, app, reqBody, nil)
+
+	if firstResp.StatusCode != http.StatusCreated || secondResp.StatusCode != http.StatusCreated {
+		t.Errorf("Expected both responses 201, got: %d and %d", firstResp.StatusCode, secondResp.StatusCode)
+	}
+	if first.OrderID != second.OrderID {
+		t.Errorf("Expected retry to return order %s, got: %s", first.OrderID, second.OrderID)
+	}
+	if second.ClientOrderID != "algo-1-0001" {
+		t.Errorf("Expected client_order_id in response, got: %q", second.ClientOrderID)
+	}
+	if qty := bookQuantity(t, app, "AAPL"); qty != 100 {
+		t.Errorf("Expected only one order of 100 on the book, got: %d", qty)
+	}
+}
+
+// TestIdempotencyKeyHeader tests deduplication by Idempotency-Key, including key reuse for a different order
+func TestIdempotencyKeyHeader(t *testing.T) {
+	app := setupTestServer()
+
+	reqBody := map[string]interface{}{
+		"symbol":   "AAPL",
+		"side":     "SELL",
+		"type":     "LIMIT",
+		"price":    15100,
+		"quantity": 50,
+	}
+	headers := map[string]string{"Idempotency-Key": uuid.New().String()}
+
+	_, first := submitWithHeaders(t, app, reqBody, headers)
+	_, second := submitWithHeaders(t, app, reqBody, headers)
+	if first.OrderID == "" || first.OrderID != second.OrderID {
+		t.Errorf("Expected the same order ID on retry, got: %q and %q", first.OrderID, second.OrderID)
+	}
+
+	// edge case: the same key with a different body is rejected, not replayed
+	reqBody["quantity"] = 75
+	resp, _ := submitWithHeaders(t, app, reqBody, headers)
+	if resp.StatusCode != http.StatusConflict {
+		t.Errorf("Expected status 409 for reused key, got: %d", resp.StatusCode)
+	}
+
+	if qty := bookQuantity(t, app, "AAPL"); qty != 50 {
+		t.Errorf("Expected only one order of 50 on the book, got: %d", qty)
+	}
+}
+
+// TestConcurrentRetriesCreateOneOrder tests that overlapping retries of one submission create a single order
+func TestConcurrentRetriesCreateOneOrder(t *testing.T) {
+	app := setupTestServer()
+
+	reqBody := map[string]interface{}{
+		"symbol":          "AAPL",
+		"side":            "BUY",
+		"type":            "LIMIT",
+		"price":           15000,
+		"quantity":        10,
+		"client_order_id": "retry-storm",
+	}
+
+	var wg sync.WaitGroup
+	orderIDs := make(chan string, 20)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, result := submitWithHeaders(t, app, reqBody, nil)
+			orderIDs <- result.OrderID
+		}()
+	}
+	wg.Wait()
+	close(orderIDs)
+
+	var orderID string
+	for id := range orderIDs {
+		if orderID == "" {
+			orderID = id
+		}
+		if id != orderID {
+			t.Errorf("Expected every retry to return order %s, got: %s", orderID, id)
+		}
+	}
+
+	if qty := bookQuantity(t, app, "AAPL"); qty != 10 {
+		t.Errorf("Expected only one order of 10 on the book, got: %d", qty)
+	}
+}
+
+// TestGetAndCancelByClientOrderID tests GET and DELETE /api/v1/orders/client/:client_order_id
+func TestGetAndCancelByClientOrderID(t *testing.T) {
+	app := setupTestServer()
+
+	reqBody := map[string]interface{}{
+		"symbol":          "AAPL",
+		"side":            "BUY",
+		"type":            "LIMIT",
+		"price":           15000,
+		"quantity":        100,
+		"client_order_id": "desk-42",
+	}
+	_, submitted := submitWithHeaders(t, app, reqBody, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/orders/client/desk-42", nil)
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200, got: %d", resp.StatusCode)
+	}
+
+	var status models.OrderStatusResponse
+	json.NewDecoder(resp.Body).Decode(&status)
+	if status.OrderID != submitted.OrderID || status.ClientOrderID != "desk-42" {
+		t.Errorf("Expected order %s / desk-42, got: %s / %s", submitted.OrderID, status.OrderID, status.ClientOrderID)
+	}
+
+	req = httptest.NewRequest(http.MethodDelete, "/api/v1/orders/client/desk-42", nil)
+	resp, err = app.Test(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200, got: %d", resp.StatusCode)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/orders/client/unknown-id", nil)
+	resp, err = app.Test(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected status 404 for unknown client order ID, got: %d", resp.StatusCode)
+	}
+}
+
+// TestEngineRejectsDuplicateClientOrderID tests that the engine refuses a client order ID
+// still in use, which is what protects submissions arriving after the dedupe window
+func TestEngineRejectsDuplicateClientOrderID(t *testing.T) {
+	matcher := engine.NewMatcher()
+	defer matcher.Close()
+
+	first := engine.NewOrder(uuid.New().String(), "AAPL", engine.SideBuy, engine.TypeLimit, 15000, 100)
+	first.ClientOrderID = "clordid-1"
+	if _, err := matcher.MatchOrder(first); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	// edge case: client order IDs are unique across symbols
+	second := engine.NewOrder(uuid.New().String(), "GOOGL", engine.SideBuy, engine.TypeLimit, 280000, 100)
+	second.ClientOrderID = "clordid-1"
+	_, err := matcher.MatchOrder(second)
+	if _, ok := err.(*engine.DuplicateClientOrderIDError); !ok {
+		t.Errorf("Expected DuplicateClientOrderIDError, got: %v", err)
+	}
+
+	found, exists := matcher.GetOrderByClientID("clordid-1")
+	if !exists || found.ID != first.ID {
+		t.Errorf("Expected client order ID to resolve to the first order")
+	}
+}
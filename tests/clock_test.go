@@ -0,0 +1,79 @@
+package tests
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"match-engine/src/engine"
+)
+
+// TestSequenceGeneratorExactTrades tests that an injected clock and sequence-based IDs
+// make the engine's trade output exactly predictable
+func TestSequenceGeneratorExactTrades(t *testing.T) {
+	clock := &fixedClock{now: time.Date(2024, 3, 15, 14, 30, 0, 0, time.UTC)}
+	matcher := engine.NewMatcher(
+		engine.WithClock(clock),
+		engine.WithIDGenerator(engine.NewSequenceGenerator(1000)),
+	)
+	defer matcher.Close()
+
+	first := engine.NewOrder(matcher.NewOrderID(), "AAPL", engine.SideSell, engine.TypeLimit, 15000, 30)
+	second := engine.NewOrder(matcher.NewOrderID(), "AAPL", engine.SideSell, engine.TypeLimit, 15010, 30)
+	if first.ID != "1001" || second.ID != "1002" {
+		t.Fatalf("Expected monotonic order IDs 1001 and 1002, got: %s and %s", first.ID, second.ID)
+	}
+	matcher.MatchOrder(first)
+	matcher.MatchOrder(second)
+
+	clock.now = clock.now.Add(time.Millisecond + 500*time.Nanosecond)
+	buy := engine.NewOrder(matcher.NewOrderID(), "AAPL", engine.SideBuy, engine.TypeLimit, 15010, 50)
+	result, err := matcher.MatchOrder(buy)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	stamp := clock.now.UnixNano()
+	expected := []*engine.Trade{
+		{TradeID: "AAPL-3-1", Symbol: "AAPL", Price: 15000, Quantity: 30, Timestamp: stamp, BuyOrderID: "1003", SellOrderID: "1001", AggressorSide: engine.SideBuy, Sequence: 3},
+		{TradeID: "AAPL-3-2", Symbol: "AAPL", Price: 15010, Quantity: 20, Timestamp: stamp, BuyOrderID: "1003", SellOrderID: "1002", AggressorSide: engine.SideBuy, Sequence: 3},
+	}
+	if !reflect.DeepEqual(result.Trades, expected) {
+		for _, trade := range result.Trades {
+			t.Logf("got trade %+v", *trade)
+		}
+		t.Errorf("Expected trades %+v", expected)
+	}
+	if buy.Timestamp != stamp {
+		t.Errorf("Expected order stamped at sequencing with %d ns, got: %d", stamp, buy.Timestamp)
+	}
+}
+
+// TestSequenceGeneratorConcurrentOrderIDs tests that order IDs stay unique under concurrent use
+func TestSequenceGeneratorConcurrentOrderIDs(t *testing.T) {
+	generator := engine.NewSequenceGenerator(uint64(time.Now().UnixNano()))
+
+	ids := make(chan string, 4000)
+	done := make(chan struct{})
+	for w := 0; w < 4; w++ {
+		go func() {
+			for i := 0; i < 1000; i++ {
+				ids <- generator.OrderID()
+			}
+			done <- struct{}{}
+		}()
+	}
+	for w := 0; w < 4; w++ {
+		<-done
+	}
+	close(ids)
+
+	seen := make(map[string]bool, 4000)
+	for id := range ids {
+		if _, err := strconv.ParseUint(id, 10, 64); err != nil || seen[id] {
+			t.Fatalf("Expected unique numeric order IDs, got duplicate or invalid: %s", id)
+		}
+		seen[id] = true
+	}
+}
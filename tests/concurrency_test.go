@@ -3,11 +3,15 @@ package tests
 import (
 	"bytes"
 	"encoding/json"
+	"math/rand"
 	"net/http"
 	"net/http/httptest"
 	"sync"
 	"testing"
 
+	"github.com/google/uuid"
+
+	"match-engine/src/engine"
 	"match-engine/src/models"
 )
 
@@ -492,3 +496,101 @@ func TestConcurrentMixedOperations(t *testing.T) {
 	}
 }
 
+// TestConcurrentMatchingConservation tests that concurrent aggressors on one symbol never
+// double-fill a resting order. Run with -race: every trade must be accounted for exactly
+// once on each side, no order may fill beyond its quantity and the book must not be crossed.
+func TestConcurrentMatchingConservation(t *testing.T) {
+	matcher := engine.NewMatcher()
+	symbol := "AAPL"
+
+	numGoroutines := 16
+	ordersPerGoroutine := 200
+
+	var mu sync.Mutex
+	var allOrders []*engine.Order
+	var allTrades []*engine.Trade
+
+	var wg sync.WaitGroup
+	for i := 0; i < numGoroutines; i++ {
+		wg.Add(1)
+		go func(goroutineID int) {
+			defer wg.Done()
+
+			rng := rand.New(rand.NewSource(int64(goroutineID)))
+			for j := 0; j < ordersPerGoroutine; j++ {
+				side := engine.SideBuy
+				if rng.Intn(2) == 0 {
+					side = engine.SideSell
+				}
+
+				orderType := engine.TypeLimit
+				if rng.Intn(10) == 0 {
+					orderType = engine.TypeMarket
+				}
+
+				// narrow price band so most orders cross
+				price := int64(15000 + rng.Intn(5))
+				if orderType == engine.TypeMarket {
+					price = 0
+				}
+				quantity := int64(1 + rng.Intn(100))
+
+				order := engine.NewOrder(uuid.New().String(), symbol, side, orderType, price, quantity)
+				if orderType == engine.TypeLimit && rng.Intn(5) == 0 {
+					order.DisplayQuantity = 1 + quantity/4
+				}
+
+				result, err := matcher.MatchOrder(order)
+
+				mu.Lock()
+				allOrders = append(allOrders, order)
+				if err == nil {
+					allTrades = append(allTrades, result.Trades...)
+				}
+				mu.Unlock()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	filledByTrades := make(map[string]int64)
+	var tradedQty int64
+	for _, trade := range allTrades {
+		if trade.Quantity <= 0 {
+			t.Fatalf("Trade %s has non-positive quantity %d", trade.TradeID, trade.Quantity)
+		}
+		tradedQty += trade.Quantity
+		filledByTrades[trade.BuyOrderID] += trade.Quantity
+		filledByTrades[trade.SellOrderID] += trade.Quantity
+	}
+
+	var buyFilled, sellFilled int64
+	for _, order := range allOrders {
+		filled := order.GetFilledQuantity()
+		if filled > order.Quantity {
+			t.Errorf("Order %s overfilled: %d of %d", order.ID, filled, order.Quantity)
+		}
+		if filled != filledByTrades[order.ID] {
+			t.Errorf("Order %s filled %d but trades account for %d", order.ID, filled, filledByTrades[order.ID])
+		}
+		if order.Side == engine.SideBuy {
+			buyFilled += filled
+		} else {
+			sellFilled += filled
+		}
+	}
+
+	if buyFilled != tradedQty || sellFilled != tradedQty {
+		t.Errorf("Quantity not conserved: traded %d, buy side filled %d, sell side filled %d",
+			tradedQty, buyFilled, sellFilled)
+	}
+
+	orderBook := matcher.GetOrCreateOrderBook(symbol)
+	bestBid, _, hasBid := orderBook.GetBestBid()
+	bestAsk, _, hasAsk := orderBook.GetBestAsk()
+	if hasBid && hasAsk && bestBid >= bestAsk {
+		t.Errorf("Book is crossed after matching: best bid %d >= best ask %d", bestBid, bestAsk)
+	}
+
+	t.Logf("Conservation held over %d orders and %d trades (%d shares)", len(allOrders), len(allTrades), tradedQty)
+}
@@ -0,0 +1,214 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/fasthttp/websocket"
+	"github.com/gofiber/fiber/v2"
+
+	"match-engine/src/engine"
+	"match-engine/src/executions"
+	"match-engine/src/handlers"
+	"match-engine/src/models"
+)
+
+// recordingExecutions keeps every execution report the engine sends
+type recordingExecutions struct {
+	mu      sync.Mutex
+	reports []*engine.ExecutionReport
+}
+
+func (l *recordingExecutions) OnExecutionReport(report *engine.ExecutionReport) {
+	l.mu.Lock()
+	l.reports = append(l.reports, report)
+	l.mu.Unlock()
+}
+
+func (l *recordingExecutions) take() []*engine.ExecutionReport {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	reports := l.reports
+	l.reports = nil
+	return reports
+}
+
+// TestExecutionReportsBothSides tests the reports of an IOC that partly fills a resting
+// order, an order rejected by fill-or-kill and a cancel
+func TestExecutionReportsBothSides(t *testing.T) {
+	listener := &recordingExecutions{}
+	matcher := engine.NewMatcher(engine.WithExecutionListener(listener))
+	defer matcher.Close()
+
+	sell := newTestOrder("AAPL", engine.SideSell, 15000, 100)
+	sell.Account = "maker"
+	matcher.MatchOrder(sell)
+	buy := newTestOrder("AAPL", engine.SideBuy, 15000, 60)
+	buy.Account = "taker"
+	buy.TimeInForce = engine.TIFIOC
+	result, _ := matcher.MatchOrder(buy)
+	sweep := newTestOrder("AAPL", engine.SideBuy, 15100, 80)
+	sweep.TimeInForce = engine.TIFIOC
+	matcher.MatchOrder(sweep)
+
+	type summary struct {
+		OrderID  string
+		ExecType engine.ExecType
+		Fill     int64
+		Cum      int64
+		Leaves   int64
+	}
+	expected := []summary{
+		{sell.ID, engine.ExecNew, 0, 0, 100},
+		{buy.ID, engine.ExecNew, 0, 0, 60},
+		{buy.ID, engine.ExecFilled, 60, 60, 0},
+		{sell.ID, engine.ExecPartialFill, 60, 60, 40},
+		{sweep.ID, engine.ExecNew, 0, 0, 80},
+		{sweep.ID, engine.ExecPartialFill, 40, 40, 40},
+		{sell.ID, engine.ExecFilled, 40, 100, 0},
+		{sweep.ID, engine.ExecCancelled, 0, 40, 0},
+	}
+
+	reports := listener.take()
+	if len(reports) != len(expected) {
+		t.Fatalf("Expected %d reports, got: %d", len(expected), len(reports))
+	}
+	for i, report := range reports {
+		got := summary{report.OrderID, report.ExecType, report.FillQuantity, report.CumulativeQuantity, report.LeavesQuantity}
+		if got != expected[i] {
+			t.Errorf("Report %d: expected %+v, got: %+v", i, expected[i], got)
+		}
+	}
+	fill := reports[2]
+	if fill.TradeID != result.Trades[0].TradeID || fill.FillPrice != 15000 || fill.Account != "taker" || fill.CommandSequence != result.Sequence {
+		t.Errorf("Expected the fill to carry trade %s at 15000 for taker, got: %+v", result.Trades[0].TradeID, fill)
+	}
+	if reports[3].Account != "maker" || reports[3].TradeID != fill.TradeID {
+		t.Errorf("Expected the resting side to get the same trade, got: %+v", reports[3])
+	}
+
+	fok := newTestOrder("AAPL", engine.SideBuy, 15000, 10)
+	fok.TimeInForce = engine.TIFFOK
+	matcher.MatchOrder(fok)
+	resting := newTestOrder("AAPL", engine.SideBuy, 14000, 10)
+	matcher.MatchOrder(resting)
+	matcher.CancelOrder(resting.ID)
+
+	reports = listener.take()
+	if len(reports) != 3 {
+		t.Fatalf("Expected 3 reports, got: %d", len(reports))
+	}
+	if reports[0].OrderID != fok.ID || reports[0].ExecType != engine.ExecRejected {
+		t.Errorf("Expected only REJECTED for the fill-or-kill order, got: %+v", reports[0])
+	}
+	if reports[2].OrderID != resting.ID || reports[2].ExecType != engine.ExecCancelled || reports[2].LeavesQuantity != 0 {
+		t.Errorf("Expected CANCELLED with nothing left, got: %+v", reports[2])
+	}
+}
+
+// TestExecutionStreamRoutesByAccount tests that a session only gets its account's
+// reports, numbered from 1
+func TestExecutionStreamRoutesByAccount(t *testing.T) {
+	_, app, url := startMarketDataServer(t)
+	url = strings.Replace(url, "/marketdata", "/executions", 1)
+
+	maker := http.Header{"Authorization": {"Bearer maker-token"}}
+	if _, resp, err := websocket.DefaultDialer.Dial(url, maker); err == nil || resp == nil || resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected a session without an account to be refused with 400, got: %v", err)
+	}
+
+	conn, _, err := websocket.DefaultDialer.Dial(url+"?account=maker", maker)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer conn.Close()
+
+	var status models.ExecutionStreamStatus
+	readFeedMessage(t, conn, &status)
+	if status.Type != "subscribed" || status.Account != "maker" {
+		t.Fatalf("Expected the session to be confirmed, got: %+v", status)
+	}
+
+	_, sell := submitWithHeaders(t, app, map[string]interface{}{
+		"symbol": "AAPL", "side": "SELL", "type": "LIMIT", "price": 15000, "quantity": 100, "account": "maker",
+	}, map[string]string{"Authorization": "Bearer maker-token"})
+	_, buy := submitWithHeaders(t, app, map[string]interface{}{
+		"symbol": "AAPL", "side": "BUY", "type": "LIMIT", "price": 15000, "quantity": 30, "account": "taker",
+	}, map[string]string{"Authorization": "Bearer taker-token"})
+
+	var accepted, filled models.ExecutionReportMessage
+	readFeedMessage(t, conn, &accepted)
+	readFeedMessage(t, conn, &filled)
+	if accepted.Sequence != 1 || accepted.ExecType != "NEW" || accepted.OrderID != sell.OrderID {
+		t.Errorf("Expected NEW for the sell as seq 1, got: %+v", accepted)
+	}
+	if filled.Sequence != 2 || filled.ExecType != "PARTIAL_FILL" || filled.FillQuantity != 30 || filled.LeavesQuantity != 70 {
+		t.Errorf("Expected PARTIAL_FILL of 30 leaving 70 as seq 2, got: %+v", filled)
+	}
+	if filled.TradeID != buy.Trades[0].TradeID || filled.FillPrice != 15000 || filled.CumulativeQuantity != 30 {
+		t.Errorf("Expected trade %s at 15000, got: %+v", buy.Trades[0].TradeID, filled)
+	}
+}
+
+// TestExecutionStreamRequiresAccountToken tests that a session needs its own account's
+// token, and that the stream is off without tokens
+func TestExecutionStreamRequiresAccountToken(t *testing.T) {
+	_, _, url := startMarketDataServer(t)
+	url = strings.Replace(url, "/marketdata", "/executions", 1) + "?account=maker"
+
+	tests := []struct {
+		name          string
+		authorization string
+		status        int
+	}{
+		{"no token", "", http.StatusUnauthorized},
+		{"not a bearer token", "Basic bWFrZXI6bWFrZXI=", http.StatusUnauthorized},
+		{"unknown token", "Bearer guess", http.StatusForbidden},
+		{"another account's token", "Bearer taker-token", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			if tt.authorization != "" {
+				header.Set("Authorization", tt.authorization)
+			}
+			if _, resp, err := websocket.DefaultDialer.Dial(url, header); err == nil || resp == nil || resp.StatusCode != tt.status {
+				t.Errorf("Expected the session to be refused with %d, got: %v", tt.status, err)
+			}
+		})
+	}
+
+	app := fiber.New(fiber.Config{DisableStartupMessage: true})
+	app.Use(handlers.NewExecutionHandler(executions.NewHub(0), nil).RequireAccount)
+	req := httptest.NewRequest("GET", "/?account=maker", nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Authorization", "Bearer maker-token")
+	resp, err := app.Test(req)
+	if err != nil || resp.StatusCode != http.StatusForbidden {
+		t.Errorf("Expected the stream to be disabled without tokens, got: %v %v", resp, err)
+	}
+}
+
+// TestExecutionHubClosesLaggingSession tests that a full session buffer closes the
+// session instead of blocking the engine
+func TestExecutionHubClosesLaggingSession(t *testing.T) {
+	hub := executions.NewHub(1)
+	session := hub.Subscribe("maker")
+	defer hub.Unsubscribe(session)
+
+	hub.OnExecutionReport(&engine.ExecutionReport{Account: "maker", OrderID: "1"})
+	hub.OnExecutionReport(&engine.ExecutionReport{Account: "", OrderID: "2"})
+	hub.OnExecutionReport(&engine.ExecutionReport{Account: "maker", OrderID: "3"})
+
+	var received []string
+	for report := range session.Reports {
+		received = append(received, report.OrderID)
+	}
+	if len(received) != 1 || received[0] != "1" || !session.Lagged() {
+		t.Errorf("Expected report 1 then a lagged close, got: %v (lagged %v)", received, session.Lagged())
+	}
+}
@@ -0,0 +1,427 @@
+package tests
+
+import (
+	"bufio"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"match-engine/src/engine"
+	"match-engine/src/executions"
+	"match-engine/src/fix"
+)
+
+// fixInitiator is a minimal in-process FIX counterparty
+type fixInitiator struct {
+	t      *testing.T
+	conn   net.Conn
+	reader *bufio.Reader
+	compID string
+	seq    uint64 // next MsgSeqNum to send
+}
+
+func startFIXAcceptor(t *testing.T, matcher *engine.Matcher, hub *executions.Hub, dir string) *fix.Acceptor {
+	t.Helper()
+	acceptor := fix.NewAcceptor(fix.Config{CompID: "MATCH", StoreDir: dir}, matcher, hub)
+	if err := acceptor.Listen("127.0.0.1:0"); err != nil {
+		t.Fatalf("Failed to start FIX acceptor: %v", err)
+	}
+	return acceptor
+}
+
+func newFIXEngine(t *testing.T) (*engine.Matcher, *executions.Hub) {
+	t.Helper()
+	hub := executions.NewHub(0)
+	matcher := engine.NewMatcher(engine.WithExecutionListener(hub))
+	t.Cleanup(matcher.Close)
+	return matcher, hub
+}
+
+func dialFIX(t *testing.T, acceptor *fix.Acceptor, compID string) *fixInitiator {
+	t.Helper()
+	conn, err := net.Dial("tcp", acceptor.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return &fixInitiator{t: t, conn: conn, reader: bufio.NewReader(conn), compID: compID, seq: 1}
+}
+
+// send numbers and writes a message
+func (c *fixInitiator) send(msg *fix.Message) {
+	c.sendSeq(msg, c.seq)
+	c.seq++
+}
+
+func (c *fixInitiator) sendSeq(msg *fix.Message, seq uint64) {
+	c.t.Helper()
+	msg.Set(fix.TagSenderCompID, c.compID).
+		Set(fix.TagTargetCompID, "MATCH").
+		Set(fix.TagMsgSeqNum, strconv.FormatUint(seq, 10)).
+		Set(fix.TagSendingTime, time.Now().UTC().Format("20060102-15:04:05.000"))
+	if _, err := c.conn.Write(msg.Bytes()); err != nil {
+		c.t.Fatalf("Failed to send %s: %v", msg, err)
+	}
+}
+
+// read returns the next message, which must be of the given type
+func (c *fixInitiator) read(msgType string) *fix.Message {
+	c.t.Helper()
+	c.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	raw, err := fix.ReadMessage(c.reader)
+	if err != nil {
+		c.t.Fatalf("Failed to read %s: %v", msgType, err)
+	}
+	msg, err := fix.Parse(raw)
+	if err != nil {
+		c.t.Fatalf("Failed to parse message: %v", err)
+	}
+	if msg.Type() != msgType {
+		c.t.Fatalf("Expected message type %s, got: %s", msgType, msg)
+	}
+	return msg
+}
+
+func (c *fixInitiator) logon() *fix.Message {
+	c.t.Helper()
+	c.send(fix.NewMessage(fix.MsgLogon).Set(fix.TagEncryptMethod, "0").Set(fix.TagHeartBtInt, "30"))
+	return c.read(fix.MsgLogon)
+}
+
+func (c *fixInitiator) newOrder(clOrdID, side, price, quantity string, extra ...string) {
+	msg := fix.NewMessage(fix.MsgNewOrderSingle).
+		Set(fix.TagClOrdID, clOrdID).
+		Set(fix.TagSymbol, "AAPL").
+		Set(fix.TagSide, side).
+		Set(fix.TagOrderQty, quantity).
+		Set(fix.TagOrdType, "2").
+		Set(fix.TagPrice, price)
+	for i := 0; i+1 < len(extra); i += 2 {
+		tag, _ := strconv.Atoi(extra[i])
+		msg.Set(tag, extra[i+1])
+	}
+	c.send(msg)
+}
+
+// expectFields checks a message's fields
+func expectFields(t *testing.T, msg *fix.Message, fields map[int]string) {
+	t.Helper()
+	for tag, value := range fields {
+		if got := msg.Get(tag); got != value {
+			t.Errorf("Expected tag %d = %q, got: %q in %s", tag, value, got, msg)
+		}
+	}
+}
+
+// TestFIXMessageRoundTrip tests encoding, framing and checksum validation
+func TestFIXMessageRoundTrip(t *testing.T) {
+	msg := fix.NewMessage(fix.MsgNewOrderSingle).
+		Set(fix.TagClOrdID, "c1").
+		Set(fix.TagSenderCompID, "DESK").
+		Set(fix.TagMsgSeqNum, "7")
+	raw := msg.Bytes()
+
+	parsed, err := fix.Parse(raw)
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+	if parsed.Type() != fix.MsgNewOrderSingle || parsed.Get(fix.TagClOrdID) != "c1" || parsed.SeqNum() != 7 {
+		t.Errorf("Expected the fields back, got: %s", parsed)
+	}
+	if string(raw[:len("8=FIX.4.4\x019=")]) != "8=FIX.4.4\x019=" {
+		t.Errorf("Expected BeginString then BodyLength, got: %s", msg)
+	}
+
+	corrupt := append([]byte(nil), raw...)
+	corrupt[len(corrupt)-10] ^= 1
+	if _, err := fix.Parse(corrupt); err == nil {
+		t.Error("Expected a changed byte to fail the checksum")
+	}
+}
+
+// TestFIXOrderLifecycle tests NewOrderSingle, passive fills, replace and cancel between
+// two sessions
+func TestFIXOrderLifecycle(t *testing.T) {
+	matcher, hub := newFIXEngine(t)
+	acceptor := startFIXAcceptor(t, matcher, hub, t.TempDir())
+	defer acceptor.Close()
+
+	seller := dialFIX(t, acceptor, "SELLER")
+	seller.logon()
+	buyer := dialFIX(t, acceptor, "BUYER")
+	buyer.logon()
+
+	seller.newOrder("s1", "2", "150.00", "100")
+	accepted := seller.read(fix.MsgExecutionReport)
+	expectFields(t, accepted, map[int]string{
+		fix.TagClOrdID: "s1", fix.TagExecType: "0", fix.TagOrdStatus: "0", fix.TagLeavesQty: "100", fix.TagCumQty: "0", fix.TagPrice: "150.00",
+	})
+	orderID := accepted.Get(fix.TagOrderID)
+	if order, exists := matcher.GetOrder(orderID); !exists || order.Account != "SELLER" || order.Price != 15000 {
+		t.Fatalf("Expected a resting order at 15000 for SELLER, got: %+v", order)
+	}
+
+	buyer.newOrder("b1", "1", "150", "60", "59", "3")
+	expectFields(t, buyer.read(fix.MsgExecutionReport), map[int]string{fix.TagExecType: "0", fix.TagLeavesQty: "60"})
+	fill := buyer.read(fix.MsgExecutionReport)
+	expectFields(t, fill, map[int]string{
+		fix.TagClOrdID: "b1", fix.TagExecType: "F", fix.TagOrdStatus: "2", fix.TagLastPx: "150.00", fix.TagLastQty: "60",
+		fix.TagCumQty: "60", fix.TagLeavesQty: "0", fix.TagAvgPx: "150.0000",
+	})
+
+	passive := seller.read(fix.MsgExecutionReport)
+	expectFields(t, passive, map[int]string{
+		fix.TagOrderID: orderID, fix.TagClOrdID: "s1", fix.TagExecType: "F", fix.TagOrdStatus: "1",
+		fix.TagLastQty: "60", fix.TagCumQty: "60", fix.TagLeavesQty: "40",
+	})
+	if passive.Get(fix.TagExecID) == fill.Get(fix.TagExecID) {
+		t.Errorf("Expected each side of the trade to get its own ExecID, both got: %s", fill.Get(fix.TagExecID))
+	}
+
+	seller.send(fix.NewMessage(fix.MsgOrderCancelReplaceRequest).
+		Set(fix.TagOrigClOrdID, "s1").Set(fix.TagClOrdID, "s2").Set(fix.TagSymbol, "AAPL").
+		Set(fix.TagSide, "2").Set(fix.TagOrdType, "2").Set(fix.TagOrderQty, "80").Set(fix.TagPrice, "150.00"))
+	expectFields(t, seller.read(fix.MsgExecutionReport), map[int]string{
+		fix.TagClOrdID: "s2", fix.TagOrigClOrdID: "s1", fix.TagExecType: "5", fix.TagOrdStatus: "1",
+		fix.TagOrderQty: "80", fix.TagCumQty: "60", fix.TagLeavesQty: "20",
+	})
+
+	seller.send(fix.NewMessage(fix.MsgOrderCancelRequest).
+		Set(fix.TagOrigClOrdID, "s2").Set(fix.TagClOrdID, "s3").Set(fix.TagSymbol, "AAPL").Set(fix.TagSide, "2"))
+	expectFields(t, seller.read(fix.MsgExecutionReport), map[int]string{
+		fix.TagOrderID: orderID, fix.TagClOrdID: "s3", fix.TagOrigClOrdID: "s2", fix.TagExecType: "4", fix.TagOrdStatus: "4",
+		fix.TagCumQty: "60", fix.TagLeavesQty: "0",
+	})
+
+	seller.send(fix.NewMessage(fix.MsgOrderCancelRequest).
+		Set(fix.TagOrigClOrdID, "s3").Set(fix.TagClOrdID, "s4").Set(fix.TagSymbol, "AAPL").Set(fix.TagSide, "2"))
+	expectFields(t, seller.read(fix.MsgOrderCancelReject), map[int]string{
+		fix.TagClOrdID: "s4", fix.TagOrdStatus: "4", fix.TagCxlRejResponseTo: "1", fix.TagCxlRejReason: "0",
+	})
+
+	// edge case: a session cannot cancel another session's order
+	buyer.send(fix.NewMessage(fix.MsgOrderCancelRequest).
+		Set(fix.TagOrderID, orderID).Set(fix.TagOrigClOrdID, "s1").Set(fix.TagClOrdID, "b2").Set(fix.TagSymbol, "AAPL").Set(fix.TagSide, "2"))
+	expectFields(t, buyer.read(fix.MsgOrderCancelReject), map[int]string{fix.TagOrderID: "NONE", fix.TagCxlRejReason: "1"})
+
+	seller.newOrder("s1", "2", "151.00", "10")
+	expectFields(t, seller.read(fix.MsgExecutionReport), map[int]string{fix.TagExecType: "8", fix.TagOrdRejReason: "6", fix.TagOrderID: "NONE"})
+
+	seller.newOrder("s5", "2", "151.005", "10")
+	expectFields(t, seller.read(fix.MsgReject), map[int]string{fix.TagRefTagID: "44", fix.TagSessionRejectReason: "6"})
+
+	buyer.send(fix.NewMessage(fix.MsgNewOrderSingle).
+		Set(fix.TagClOrdID, "b3").Set(fix.TagSymbol, "AAPL").Set(fix.TagSide, "1").Set(fix.TagOrderQty, "5").Set(fix.TagOrdType, "1"))
+	expectFields(t, buyer.read(fix.MsgExecutionReport), map[int]string{fix.TagExecType: "8", fix.TagOrdStatus: "8", fix.TagOrdRejReason: "3"})
+
+	matcher.Halt("AAPL", "news pending", false)
+	buyer.newOrder("b4", "1", "150.00", "5")
+	expectFields(t, buyer.read(fix.MsgExecutionReport), map[int]string{
+		fix.TagExecType: "8", fix.TagOrdRejReason: "2", fix.TagText: "SYMBOL_HALTED: AAPL is halted (news pending): new orders are not accepted",
+	})
+}
+
+// TestFIXSelfTradePrevention tests that an order's self-trade prevention mode is taken
+// from the user-defined SelfTradePrevention tag
+func TestFIXSelfTradePrevention(t *testing.T) {
+	matcher, hub := newFIXEngine(t)
+	acceptor := startFIXAcceptor(t, matcher, hub, t.TempDir())
+	defer acceptor.Close()
+
+	desk := dialFIX(t, acceptor, "DESK")
+	desk.logon()
+
+	desk.newOrder("s1", "2", "150.00", "100")
+	expectFields(t, desk.read(fix.MsgExecutionReport), map[int]string{fix.TagClOrdID: "s1", fix.TagExecType: "0"})
+
+	desk.newOrder("b1", "1", "150.00", "100", strconv.Itoa(fix.TagSelfTradePrevention), "CANCEL_SOMETIMES")
+	expectFields(t, desk.read(fix.MsgExecutionReport), map[int]string{
+		fix.TagClOrdID: "b1", fix.TagExecType: "8", fix.TagText: "Unsupported SelfTradePrevention CANCEL_SOMETIMES",
+	})
+
+	desk.newOrder("b2", "1", "150.00", "100", strconv.Itoa(fix.TagSelfTradePrevention), "CANCEL_NEWEST")
+	expectFields(t, desk.read(fix.MsgExecutionReport), map[int]string{fix.TagClOrdID: "b2", fix.TagExecType: "0"})
+	expectFields(t, desk.read(fix.MsgExecutionReport), map[int]string{
+		fix.TagClOrdID: "b2", fix.TagExecType: "4", fix.TagCumQty: "0", fix.TagText: "Self-trade prevention",
+	})
+
+	// without the tag the session's account has no mode, so the orders trade
+	desk.newOrder("b3", "1", "150.00", "100")
+	expectFields(t, desk.read(fix.MsgExecutionReport), map[int]string{fix.TagClOrdID: "b3", fix.TagExecType: "0"})
+	expectFields(t, desk.read(fix.MsgExecutionReport), map[int]string{fix.TagClOrdID: "b3", fix.TagExecType: "F", fix.TagLastQty: "100"})
+}
+
+// TestFIXPricePrecision tests that FIX prices are read and written with the instrument's
+// price_precision, and that it cannot change while orders rest
+func TestFIXPricePrecision(t *testing.T) {
+	registry := engine.NewInstrumentRegistry(nil)
+	registry.Create(engine.Instrument{Symbol: "AAPL", TickSize: 1, LotSize: 1, PricePrecision: 4})
+	hub := executions.NewHub(0)
+	matcher := engine.NewMatcher(engine.WithExecutionListener(hub), engine.WithInstruments(registry))
+	defer matcher.Close()
+	acceptor := startFIXAcceptor(t, matcher, hub, t.TempDir())
+	defer acceptor.Close()
+
+	seller := dialFIX(t, acceptor, "SELLER")
+	seller.logon()
+	buyer := dialFIX(t, acceptor, "BUYER")
+	buyer.logon()
+
+	seller.newOrder("s1", "2", "150.1234", "30")
+	accepted := seller.read(fix.MsgExecutionReport)
+	expectFields(t, accepted, map[int]string{fix.TagExecType: "0", fix.TagPrice: "150.1234"})
+	if order, _ := matcher.GetOrder(accepted.Get(fix.TagOrderID)); order == nil || order.Price != 1501234 {
+		t.Fatalf("Expected a resting order at 1501234, got: %+v", order)
+	}
+
+	buyer.newOrder("b1", "1", "150.1234", "10")
+	buyer.read(fix.MsgExecutionReport)
+	expectFields(t, buyer.read(fix.MsgExecutionReport), map[int]string{
+		fix.TagExecType: "F", fix.TagLastPx: "150.1234", fix.TagAvgPx: "150.123400",
+	})
+
+	expectFields(t, seller.read(fix.MsgExecutionReport), map[int]string{fix.TagExecType: "F", fix.TagLastPx: "150.1234"})
+
+	buyer.newOrder("b2", "1", "150.12345", "10")
+	expectFields(t, buyer.read(fix.MsgReject), map[int]string{fix.TagRefTagID: "44", fix.TagSessionRejectReason: "6"})
+
+	// edge case: the resting order's price would be read at the new precision
+	_, err := matcher.UpdateInstrument("AAPL", func(i *engine.Instrument) { i.PricePrecision = 2 })
+	if _, ok := err.(*engine.PricePrecisionError); !ok {
+		t.Errorf("Expected PricePrecisionError while an order rests, got %v", err)
+	}
+	if matcher.PricePrecision("AAPL") != 4 {
+		t.Errorf("Expected the precision to stay 4, got %d", matcher.PricePrecision("AAPL"))
+	}
+	seller.send(fix.NewMessage(fix.MsgOrderCancelRequest).
+		Set(fix.TagOrigClOrdID, "s1").Set(fix.TagClOrdID, "s2").Set(fix.TagSymbol, "AAPL").Set(fix.TagSide, "2"))
+	expectFields(t, seller.read(fix.MsgExecutionReport), map[int]string{fix.TagExecType: "4", fix.TagPrice: "150.1234"})
+	if _, err := matcher.UpdateInstrument("AAPL", func(i *engine.Instrument) { i.PricePrecision = 0 }); err != nil {
+		t.Fatalf("Expected the precision to change on an empty book, got %v", err)
+	}
+
+	seller.newOrder("s3", "2", "150", "10")
+	expectFields(t, seller.read(fix.MsgExecutionReport), map[int]string{fix.TagExecType: "0", fix.TagPrice: "150"})
+	seller.newOrder("s4", "2", "150.5", "10")
+	expectFields(t, seller.read(fix.MsgReject), map[int]string{fix.TagRefTagID: "44", fix.TagSessionRejectReason: "6"})
+}
+
+// TestFIXSessionLevel tests TestRequest, a sequence gap on either side and a
+// SequenceReset-GapFill
+func TestFIXSessionLevel(t *testing.T) {
+	matcher, hub := newFIXEngine(t)
+	acceptor := startFIXAcceptor(t, matcher, hub, t.TempDir())
+	defer acceptor.Close()
+
+	client := dialFIX(t, acceptor, "DESK")
+	if logon := client.logon(); logon.SeqNum() != 1 || logon.Get(fix.TagHeartBtInt) != "30" {
+		t.Fatalf("Expected Logon as seq 1 with our HeartBtInt, got: %s", logon)
+	}
+
+	client.send(fix.NewMessage(fix.MsgTestRequest).Set(fix.TagTestReqID, "ping"))
+	expectFields(t, client.read(fix.MsgHeartbeat), map[int]string{fix.TagTestReqID: "ping", fix.TagMsgSeqNum: "2"})
+
+	// we skip seq 3: the acceptor asks for it and drops seq 4
+	client.sendSeq(fix.NewMessage(fix.MsgTestRequest).Set(fix.TagTestReqID, "lost"), 4)
+	expectFields(t, client.read(fix.MsgResendRequest), map[int]string{fix.TagBeginSeqNo: "3", fix.TagEndSeqNo: "0"})
+
+	client.sendSeq(fix.NewMessage(fix.MsgSequenceReset).
+		Set(fix.TagPossDupFlag, "Y").Set(fix.TagGapFillFlag, "Y").Set(fix.TagNewSeqNo, "5"), 3)
+	client.seq = 5
+	client.send(fix.NewMessage(fix.MsgTestRequest).Set(fix.TagTestReqID, "after-gap"))
+	expectFields(t, client.read(fix.MsgHeartbeat), map[int]string{fix.TagTestReqID: "after-gap"})
+
+	client.send(fix.NewMessage(fix.MsgNewOrderSingle).Set(fix.TagSymbol, "AAPL"))
+	expectFields(t, client.read(fix.MsgReject), map[int]string{fix.TagRefTagID: "11", fix.TagSessionRejectReason: "1", fix.TagRefMsgType: "D"})
+
+	client.newOrder("c1", "1", "100", "10")
+	client.read(fix.MsgExecutionReport)
+	client.newOrder("c2", "1", "101", "10")
+	second := client.read(fix.MsgExecutionReport)
+
+	// the client asks for everything: session messages are gap filled, orders resent
+	client.send(fix.NewMessage(fix.MsgResendRequest).Set(fix.TagBeginSeqNo, "1").Set(fix.TagEndSeqNo, "0"))
+	expectFields(t, client.read(fix.MsgSequenceReset), map[int]string{
+		fix.TagMsgSeqNum: "1", fix.TagGapFillFlag: "Y", fix.TagNewSeqNo: strconv.FormatUint(second.SeqNum()-1, 10),
+	})
+	for _, clOrdID := range []string{"c1", "c2"} {
+		resent := client.read(fix.MsgExecutionReport)
+		expectFields(t, resent, map[int]string{fix.TagClOrdID: clOrdID, fix.TagPossDupFlag: "Y"})
+		if resent.Get(fix.TagOrigSendingTime) == "" {
+			t.Errorf("Expected OrigSendingTime on a resent message, got: %s", resent)
+		}
+		if clOrdID == "c2" && resent.SeqNum() != second.SeqNum() {
+			t.Errorf("Expected the resend to reuse seq %d, got: %d", second.SeqNum(), resent.SeqNum())
+		}
+	}
+
+	client.send(fix.NewMessage(fix.MsgLogout))
+	client.read(fix.MsgLogout)
+}
+
+// TestFIXHeartbeats tests the heartbeat and test request timers
+func TestFIXHeartbeats(t *testing.T) {
+	matcher, hub := newFIXEngine(t)
+	acceptor := startFIXAcceptor(t, matcher, hub, t.TempDir())
+	defer acceptor.Close()
+
+	client := dialFIX(t, acceptor, "DESK")
+	client.send(fix.NewMessage(fix.MsgLogon).Set(fix.TagEncryptMethod, "0").Set(fix.TagHeartBtInt, "1"))
+	client.read(fix.MsgLogon)
+
+	client.read(fix.MsgHeartbeat)
+	testRequest := client.read(fix.MsgTestRequest)
+	if testRequest.Get(fix.TagTestReqID) == "" {
+		t.Errorf("Expected a TestReqID, got: %s", testRequest)
+	}
+}
+
+// TestFIXSessionSurvivesRestart tests that sequence numbers, ClOrdIDs and reports sent
+// while logged out carry over to a new acceptor
+func TestFIXSessionSurvivesRestart(t *testing.T) {
+	matcher, hub := newFIXEngine(t)
+	dir := t.TempDir()
+	acceptor := startFIXAcceptor(t, matcher, hub, dir)
+
+	client := dialFIX(t, acceptor, "DESK")
+	client.logon()
+	client.newOrder("c1", "2", "150.00", "100")
+	orderID := client.read(fix.MsgExecutionReport).Get(fix.TagOrderID)
+	client.send(fix.NewMessage(fix.MsgLogout))
+	client.read(fix.MsgLogout)
+
+	// fills while the session is logged out are stored for it
+	buy := newTestOrder("AAPL", engine.SideBuy, 15000, 30)
+	if _, err := matcher.MatchOrder(buy); err != nil {
+		t.Fatalf("Failed to match: %v", err)
+	}
+	acceptor.Close()
+
+	acceptor = startFIXAcceptor(t, matcher, hub, dir)
+	defer acceptor.Close()
+
+	stale := dialFIX(t, acceptor, "DESK")
+	stale.send(fix.NewMessage(fix.MsgLogon).Set(fix.TagEncryptMethod, "0").Set(fix.TagHeartBtInt, "30"))
+	expectFields(t, stale.read(fix.MsgLogout), map[int]string{fix.TagText: "MsgSeqNum too low, expecting 4 but received 1"})
+
+	client = dialFIX(t, acceptor, "DESK")
+	client.seq = 4
+	logon := client.logon()
+	// seq 1 logon, 2 new, 3 logout, 4 fill, 5 the stale logon's logout
+	if logon.SeqNum() != 6 {
+		t.Fatalf("Expected the Logon reply as seq 6, got: %s", logon)
+	}
+
+	client.send(fix.NewMessage(fix.MsgResendRequest).Set(fix.TagBeginSeqNo, "4").Set(fix.TagEndSeqNo, "4"))
+	expectFields(t, client.read(fix.MsgExecutionReport), map[int]string{
+		fix.TagOrderID: orderID, fix.TagClOrdID: "c1", fix.TagExecType: "F", fix.TagLastQty: "30", fix.TagLeavesQty: "70", fix.TagPossDupFlag: "Y",
+	})
+
+	client.send(fix.NewMessage(fix.MsgOrderCancelRequest).
+		Set(fix.TagOrigClOrdID, "c1").Set(fix.TagClOrdID, "c2").Set(fix.TagSymbol, "AAPL").Set(fix.TagSide, "2"))
+	expectFields(t, client.read(fix.MsgExecutionReport), map[int]string{fix.TagOrderID: orderID, fix.TagExecType: "4", fix.TagCumQty: "30"})
+
+	client.newOrder("c1", "2", "150.00", "1")
+	expectFields(t, client.read(fix.MsgExecutionReport), map[int]string{fix.TagExecType: "8", fix.TagOrdRejReason: "6"})
+}
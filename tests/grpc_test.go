@@ -0,0 +1,361 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"match-engine/src/engine"
+	"match-engine/src/handlers"
+	"match-engine/src/marketdata"
+	"match-engine/src/models"
+	"match-engine/src/pb"
+	"match-engine/src/routes"
+)
+
+// startGRPCServer serves the REST routes on an in-memory app and the gRPC service on a
+// local port, both backed by the same OrderHandler
+func startGRPCServer(t *testing.T) (*engine.Matcher, *fiber.App, pb.OrderServiceClient) {
+	t.Setenv("RATE_LIMIT_DISABLED", "1")
+	t.Setenv("REQUEST_LOGGING_DISABLED", "1")
+
+	hub := marketdata.NewHub(0)
+	matcher := engine.NewMatcher(engine.WithBookListener(hub))
+	orderHandler := handlers.NewOrderHandler(matcher)
+	orderHandler.Tokens = testAccountTokens
+
+	app := fiber.New(fiber.Config{DisableStartupMessage: true})
+	routes.SetupRoutes(app, orderHandler)
+
+	server := grpc.NewServer()
+	grpcHandler := handlers.NewGRPCHandler(orderHandler, hub)
+	routes.SetupGRPCServices(server, grpcHandler)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	go server.Serve(listener)
+
+	conn, err := grpc.NewClient(listener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("Failed to create gRPC client: %v", err)
+	}
+	t.Cleanup(func() {
+		conn.Close()
+		grpcHandler.Shutdown()
+		server.GracefulStop()
+		matcher.Close()
+	})
+
+	return matcher, app, pb.NewOrderServiceClient(conn)
+}
+
+func grpcContext(t *testing.T) context.Context {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+	return ctx
+}
+
+func expectCode(t *testing.T, err error, code codes.Code, message string) {
+	t.Helper()
+	if status.Code(err) != code {
+		t.Fatalf("Expected %v, got %v", code, err)
+	}
+	if message != "" && !strings.Contains(status.Convert(err).Message(), message) {
+		t.Errorf("Expected message containing %q, got %q", message, status.Convert(err).Message())
+	}
+}
+
+// TestGRPCOrderLifecycle tests submitting, looking up and cancelling orders over gRPC,
+// and that they count in the same metrics as REST orders
+func TestGRPCOrderLifecycle(t *testing.T) {
+	_, app, client := startGRPCServer(t)
+	ctx := grpcContext(t)
+
+	sell, err := client.SubmitOrder(ctx, &pb.SubmitOrderRequest{
+		Symbol: "AAPL", Side: "SELL", Type: "LIMIT", Price: 15000, Quantity: 100, ClientOrderId: "sell-1",
+	})
+	if err != nil {
+		t.Fatalf("SubmitOrder failed: %v", err)
+	}
+	if sell.Status != "ACCEPTED" || sell.Message != "Order added to book" || sell.OrderId == "" {
+		t.Fatalf("Unexpected sell response: %+v", sell)
+	}
+
+	buy, err := client.SubmitOrder(ctx, &pb.SubmitOrderRequest{
+		Symbol: "AAPL", Side: "BUY", Type: "LIMIT", Price: 15000, Quantity: 40,
+	})
+	if err != nil {
+		t.Fatalf("SubmitOrder failed: %v", err)
+	}
+	if buy.Status != "FILLED" || buy.FilledQuantity != 40 || len(buy.Trades) != 1 || buy.Trades[0].Price != 15000 {
+		t.Fatalf("Unexpected buy response: %+v", buy)
+	}
+
+	order, err := client.GetOrder(ctx, &pb.GetOrderRequest{ClientOrderId: "sell-1"})
+	if err != nil {
+		t.Fatalf("GetOrder failed: %v", err)
+	}
+	if order.OrderId != sell.OrderId || order.Status != "PARTIAL_FILL" || order.FilledQuantity != 40 || len(order.Fills) != 1 {
+		t.Fatalf("Unexpected order: %+v", order)
+	}
+
+	book, err := client.GetOrderBook(ctx, &pb.GetOrderBookRequest{Symbol: "AAPL"})
+	if err != nil {
+		t.Fatalf("GetOrderBook failed: %v", err)
+	}
+	if len(book.Bids) != 0 || len(book.Asks) != 1 || book.Asks[0].Price != 15000 || book.Asks[0].Quantity != 60 {
+		t.Fatalf("Unexpected book: %+v", book)
+	}
+
+	cancelled, err := client.CancelOrder(ctx, &pb.CancelOrderRequest{OrderId: sell.OrderId})
+	if err != nil {
+		t.Fatalf("CancelOrder failed: %v", err)
+	}
+	if cancelled.Status != "CANCELLED" || cancelled.ClientOrderId != "sell-1" {
+		t.Fatalf("Unexpected cancel response: %+v", cancelled)
+	}
+
+	// a REST order counts in the same metrics
+	req := httptest.NewRequest("POST", "/api/v1/orders", strings.NewReader(
+		`{"symbol":"AAPL","side":"BUY","type":"LIMIT","price":14900,"quantity":10}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req)
+	if err != nil || resp.StatusCode != fiber.StatusCreated {
+		t.Fatalf("REST order failed: %v", err)
+	}
+
+	resp, err = app.Test(httptest.NewRequest("GET", "/metrics", nil))
+	if err != nil {
+		t.Fatalf("Metrics request failed: %v", err)
+	}
+	var metrics models.MetricsResponse
+	body, _ := io.ReadAll(resp.Body)
+	json.Unmarshal(body, &metrics)
+	if metrics.OrdersReceived != 3 || metrics.OrdersMatched != 1 || metrics.OrdersCancelled != 1 || metrics.TradesExecuted != 1 {
+		t.Errorf("Unexpected metrics: %+v", metrics)
+	}
+}
+
+// TestGRPCErrors tests that gRPC rejects what REST rejects, with matching status codes
+func TestGRPCErrors(t *testing.T) {
+	_, app, client := startGRPCServer(t)
+	ctx := grpcContext(t)
+
+	invalid := []*pb.SubmitOrderRequest{
+		{Side: "BUY", Type: "LIMIT", Price: 100, Quantity: 10},
+		{Symbol: "AAPL", Side: "HOLD", Type: "LIMIT", Price: 100, Quantity: 10},
+		{Symbol: "AAPL", Side: "BUY", Type: "LIMIT", Quantity: 10},
+		{Symbol: "AAPL", Side: "BUY", Type: "LIMIT", Price: 100, Quantity: -1},
+		{Symbol: "AAPL", Side: "BUY", Type: "MARKET", Price: 100, Quantity: 10, DisplayQuantity: 5},
+	}
+	for _, req := range invalid {
+		body, _ := json.Marshal(models.SubmitOrderRequest{
+			Symbol: req.Symbol, Side: req.Side, Type: req.Type, Price: req.Price,
+			Quantity: req.Quantity, DisplayQuantity: req.DisplayQuantity,
+		})
+		httpReq := httptest.NewRequest("POST", "/api/v1/orders", strings.NewReader(string(body)))
+		httpReq.Header.Set("Content-Type", "application/json")
+		resp, _ := app.Test(httpReq)
+		var restErr models.ErrorResponse
+		respBody, _ := io.ReadAll(resp.Body)
+		json.Unmarshal(respBody, &restErr)
+		if resp.StatusCode != fiber.StatusBadRequest {
+			t.Fatalf("Expected REST to reject %+v, got %d", req, resp.StatusCode)
+		}
+
+		_, err := client.SubmitOrder(ctx, req)
+		expectCode(t, err, codes.InvalidArgument, restErr.Error)
+	}
+
+	_, err := client.SubmitOrder(ctx, &pb.SubmitOrderRequest{Symbol: "AAPL", Side: "BUY", Type: "MARKET", Quantity: 10})
+	expectCode(t, err, codes.FailedPrecondition, "Insufficient liquidity: only 0 shares available, requested 10")
+
+	order := &pb.SubmitOrderRequest{Symbol: "AAPL", Side: "BUY", Type: "LIMIT", Price: 100, Quantity: 10, ClientOrderId: "dup"}
+	if _, err := client.SubmitOrder(ctx, order); err != nil {
+		t.Fatalf("SubmitOrder failed: %v", err)
+	}
+	_, err = client.SubmitOrder(ctx, order)
+	expectCode(t, err, codes.AlreadyExists, "Duplicate client_order_id")
+
+	_, err = client.GetOrder(ctx, &pb.GetOrderRequest{OrderId: "missing"})
+	expectCode(t, err, codes.NotFound, "Order not found")
+	_, err = client.CancelOrder(ctx, &pb.CancelOrderRequest{})
+	expectCode(t, err, codes.InvalidArgument, "order_id or client_order_id is required")
+
+	if _, err := client.CancelOrder(ctx, &pb.CancelOrderRequest{ClientOrderId: "dup"}); err != nil {
+		t.Fatalf("CancelOrder failed: %v", err)
+	}
+	_, err = client.CancelOrder(ctx, &pb.CancelOrderRequest{ClientOrderId: "dup"})
+	expectCode(t, err, codes.FailedPrecondition, "")
+
+	_, err = client.GetOrderBook(ctx, &pb.GetOrderBookRequest{})
+	expectCode(t, err, codes.InvalidArgument, "symbol is required")
+}
+
+// TestGRPCErrorCodes tests that errors with an engine error code start their message with it
+func TestGRPCErrorCodes(t *testing.T) {
+	matcher, _, client := startGRPCServer(t)
+	ctx := grpcContext(t)
+
+	resting, err := client.SubmitOrder(ctx, &pb.SubmitOrderRequest{Symbol: "AAPL", Side: "BUY", Type: "LIMIT", Price: 15000, Quantity: 10})
+	if err != nil {
+		t.Fatalf("SubmitOrder failed: %v", err)
+	}
+	if _, err := matcher.Halt("AAPL", "news pending", false); err != nil {
+		t.Fatalf("Halt failed: %v", err)
+	}
+
+	_, err = client.SubmitOrder(ctx, &pb.SubmitOrderRequest{Symbol: "AAPL", Side: "BUY", Type: "LIMIT", Price: 15000, Quantity: 10})
+	expectCode(t, err, codes.FailedPrecondition, "")
+	if message := status.Convert(err).Message(); !strings.HasPrefix(message, engine.CodeSymbolHalted+": Order rejected: AAPL is halted") {
+		t.Errorf("Expected the submit's message to start with its code, got %q", message)
+	}
+
+	_, err = client.CancelOrder(ctx, &pb.CancelOrderRequest{OrderId: resting.OrderId})
+	expectCode(t, err, codes.FailedPrecondition, "")
+	if message := status.Convert(err).Message(); !strings.HasPrefix(message, engine.CodeSymbolHalted+": ") {
+		t.Errorf("Expected the cancel's message to start with its code, got %q", message)
+	}
+}
+
+// TestOrderAccountAuthentication tests that REST and gRPC orders name an account only
+// with the account's token
+func TestOrderAccountAuthentication(t *testing.T) {
+	_, app, client := startGRPCServer(t)
+	order := map[string]interface{}{
+		"symbol": "AAPL", "side": "BUY", "type": "LIMIT", "price": 15000, "quantity": 10, "account": "maker",
+	}
+
+	testCases := []struct {
+		headers map[string]string
+		status  int
+	}{
+		{nil, http.StatusUnauthorized},
+		{map[string]string{"Authorization": "Bearer taker-token"}, http.StatusForbidden},
+		{map[string]string{"Authorization": "Bearer maker-token"}, http.StatusCreated},
+	}
+	for _, tc := range testCases {
+		if resp, _ := submitWithHeaders(t, app, order, tc.headers); resp.StatusCode != tc.status {
+			t.Errorf("Expected %d with headers %v, got %d", tc.status, tc.headers, resp.StatusCode)
+		}
+	}
+	delete(order, "account")
+	if resp, _ := submitWithHeaders(t, app, order, nil); resp.StatusCode != http.StatusCreated {
+		t.Errorf("Expected an order without an account to need no token, got %d", resp.StatusCode)
+	}
+
+	// edge case: without tokens no order can name an account
+	order["account"] = "maker"
+	if resp, _ := submitWithHeaders(t, setupTestServer(), order, map[string]string{"Authorization": "Bearer maker-token"}); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("Expected 403 without ACCOUNT_TOKENS, got %d", resp.StatusCode)
+	}
+
+	req := &pb.SubmitOrderRequest{Symbol: "AAPL", Side: "BUY", Type: "LIMIT", Price: 15000, Quantity: 10, Account: "maker"}
+	_, err := client.SubmitOrder(grpcContext(t), req)
+	expectCode(t, err, codes.Unauthenticated, "token is required")
+	_, err = client.SubmitOrder(metadata.AppendToOutgoingContext(grpcContext(t), "authorization", "Bearer taker-token"), req)
+	expectCode(t, err, codes.PermissionDenied, "not the account's")
+	if _, err := client.SubmitOrder(metadata.AppendToOutgoingContext(grpcContext(t), "authorization", "Bearer maker-token"), req); err != nil {
+		t.Errorf("Expected the account's token to be accepted, got %v", err)
+	}
+}
+
+// TestGRPCSelfTradePrevention tests that an order's self-trade prevention mode is taken
+// from SubmitOrderRequest
+func TestGRPCSelfTradePrevention(t *testing.T) {
+	_, _, client := startGRPCServer(t)
+	ctx := metadata.AppendToOutgoingContext(grpcContext(t), "authorization", "Bearer maker-token")
+
+	if _, err := client.SubmitOrder(ctx, &pb.SubmitOrderRequest{
+		Symbol: "AAPL", Side: "SELL", Type: "LIMIT", Price: 15000, Quantity: 100, Account: "maker",
+	}); err != nil {
+		t.Fatalf("Failed to submit the resting sell: %v", err)
+	}
+
+	buy := &pb.SubmitOrderRequest{
+		Symbol: "AAPL", Side: "BUY", Type: "LIMIT", Price: 15000, Quantity: 100, Account: "maker",
+		SelfTradePrevention: "CANCEL_SOMETIMES",
+	}
+	_, err := client.SubmitOrder(ctx, buy)
+	expectCode(t, err, codes.InvalidArgument, "self_trade_prevention")
+
+	buy.SelfTradePrevention = "CANCEL_NEWEST"
+	resp, err := client.SubmitOrder(ctx, buy)
+	if err != nil {
+		t.Fatalf("Failed to submit the buy: %v", err)
+	}
+	if resp.Status != "CANCELLED" || resp.FilledQuantity != 0 || len(resp.Trades) != 0 {
+		t.Errorf("Expected the buy cancelled by self-trade prevention, got %+v", resp)
+	}
+}
+
+// TestGRPCStreams tests that StreamBook sends a snapshot and then level updates, and
+// that StreamTrades sends every trade once it is subscribed
+func TestGRPCStreams(t *testing.T) {
+	matcher, _, client := startGRPCServer(t)
+	ctx := grpcContext(t)
+
+	matcher.MatchOrder(newTestOrder("AAPL", engine.SideSell, 15000, 100))
+
+	bookStream, err := client.StreamBook(ctx, &pb.StreamBookRequest{Symbol: "AAPL"})
+	if err != nil {
+		t.Fatalf("StreamBook failed: %v", err)
+	}
+	snapshot, err := bookStream.Recv()
+	if err != nil {
+		t.Fatalf("Failed to receive snapshot: %v", err)
+	}
+	if !snapshot.Snapshot || len(snapshot.Asks) != 1 || snapshot.Asks[0].Quantity != 100 {
+		t.Fatalf("Unexpected snapshot: %+v", snapshot)
+	}
+
+	tradeStream, err := client.StreamTrades(ctx, &pb.StreamTradesRequest{Symbol: "AAPL"})
+	if err != nil {
+		t.Fatalf("StreamTrades failed: %v", err)
+	}
+	if _, err := tradeStream.Header(); err != nil {
+		t.Fatalf("Failed to receive trade stream headers: %v", err)
+	}
+
+	buy := newTestOrder("AAPL", engine.SideBuy, 15000, 30)
+	matcher.MatchOrder(buy)
+	matcher.MatchOrder(newTestOrder("AAPL", engine.SideBuy, 14900, 20))
+
+	update, err := bookStream.Recv()
+	if err != nil {
+		t.Fatalf("Failed to receive update: %v", err)
+	}
+	if update.Snapshot || update.Seq != snapshot.Seq+1 || len(update.Changes) != 1 || update.Changes[0].Quantity != 70 {
+		t.Fatalf("Unexpected update: %+v", update)
+	}
+	update, err = bookStream.Recv()
+	if err != nil {
+		t.Fatalf("Failed to receive update: %v", err)
+	}
+	if update.Seq != snapshot.Seq+2 || len(update.Changes) != 1 || update.Changes[0].Side != "BUY" || update.Changes[0].Quantity != 20 {
+		t.Fatalf("Unexpected update: %+v", update)
+	}
+
+	trade, err := tradeStream.Recv()
+	if err != nil {
+		t.Fatalf("Failed to receive trade: %v", err)
+	}
+	if trade.BuyOrderId != buy.ID || trade.Price != 15000 || trade.Quantity != 30 || trade.AggressorSide != "BUY" {
+		t.Fatalf("Unexpected trade: %+v", trade)
+	}
+}
@@ -0,0 +1,335 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"match-engine/src/engine"
+	"match-engine/src/handlers"
+	"match-engine/src/journal"
+	"match-engine/src/models"
+	"match-engine/src/routes"
+)
+
+func expectHaltedError(t *testing.T, err error, kind engine.CommandKind) {
+	t.Helper()
+	if haltedErr, ok := err.(*engine.HaltedError); !ok || haltedErr.Kind != kind {
+		t.Errorf("Expected a HaltedError for %s, got %v", kind, err)
+	}
+}
+
+// errorCode is the code of an error response
+func errorCode(body []byte) string {
+	var response models.ErrorResponse
+	json.Unmarshal(body, &response)
+	return response.Code
+}
+
+// TestTradingHalt tests that a halted symbol refuses orders, amends and auctions with
+// the halt's reason, keeps its book, and publishes the halt on the feed
+func TestTradingHalt(t *testing.T) {
+	listener := &recordingListener{}
+	matcher := engine.NewMatcher(engine.WithBookListener(listener))
+	defer matcher.Close()
+
+	ask := newTestOrder("AAPL", engine.SideSell, 15000, 100)
+	bid := newTestOrder("AAPL", engine.SideBuy, 14900, 50)
+	matcher.MatchOrder(ask)
+	matcher.MatchOrder(bid)
+
+	if _, err := matcher.Halt("AAPL", "news pending", false); err != nil {
+		t.Fatalf("Halt failed: %v", err)
+	}
+	if _, err := matcher.Halt("AAPL", "again", false); err == nil {
+		t.Error("Expected halting a halted symbol to fail")
+	} else if stateErr, ok := err.(*engine.HaltStateError); !ok || !stateErr.Halted {
+		t.Errorf("Expected a HaltStateError, got %v", err)
+	}
+
+	rejected := newTestOrder("AAPL", engine.SideBuy, 15000, 10)
+	_, err := matcher.MatchOrder(rejected)
+	expectHaltedError(t, err, engine.CommandSubmit)
+	if err != nil && err.Error() != "AAPL is halted (news pending): new orders are not accepted" {
+		t.Errorf("Unexpected message %q", err.Error())
+	}
+	if rejected.GetStatus() != engine.StatusRejected {
+		t.Errorf("Expected the order REJECTED, got %s", rejected.GetStatus())
+	}
+	_, err = matcher.AmendOrder(bid.ID, 15000, 0)
+	expectHaltedError(t, err, engine.CommandAmend)
+	_, err = matcher.CancelOrder(bid.ID)
+	expectHaltedError(t, err, engine.CommandCancel)
+	_, err = matcher.StartAuction("AAPL")
+	expectHaltedError(t, err, engine.CommandAuction)
+
+	orderBook, _ := matcher.GetOrderBook("AAPL")
+	bids, asks := orderBook.GetOrderBookSnapshot(10)
+	if len(bids) != 1 || bids[0].Quantity != 50 || len(asks) != 1 || asks[0].Quantity != 100 {
+		t.Errorf("Expected the book intact, got bids %+v asks %+v", bids, asks)
+	}
+	halt, halted := orderBook.Halted()
+	if !halted || halt.Reason != "news pending" || halt.AllowCancels {
+		t.Errorf("Unexpected halt %+v", halt)
+	}
+
+	listener.mu.Lock()
+	last := listener.updates[len(listener.updates)-1]
+	for i, update := range listener.updates {
+		if update.Sequence != uint64(i+1) {
+			t.Errorf("Expected feed sequence %d, got %d", i+1, update.Sequence)
+		}
+	}
+	listener.mu.Unlock()
+	if last.Status == nil || !last.Status.Halted || last.Status.HaltReason != "news pending" || len(last.Changes) != 0 {
+		t.Errorf("Expected the halt published as a status update, got %+v", last)
+	}
+
+	// a halt that allows cancels lets resting orders leave
+	if _, err := matcher.Resume("AAPL", 0); err != nil {
+		t.Fatalf("Resume failed: %v", err)
+	}
+	if _, err := matcher.Uncross("AAPL"); err != nil {
+		t.Fatalf("Uncross of the reopening auction failed: %v", err)
+	}
+	if _, err := matcher.Halt("AAPL", "", true); err != nil {
+		t.Fatalf("Halt failed: %v", err)
+	}
+	if _, err := matcher.CancelOrder(bid.ID); err != nil {
+		t.Errorf("Expected a cancel during a halt that allows them, got %v", err)
+	}
+	_, err = matcher.MatchOrder(newTestOrder("AAPL", engine.SideBuy, 15000, 10))
+	if err == nil || err.Error() != "AAPL is halted: new orders are not accepted" {
+		t.Errorf("Unexpected error %v", err)
+	}
+}
+
+// TestTradingHaltReopeningAuction tests that a resume reopens continuous trading
+// through an auction that uncrosses once its time is up
+func TestTradingHaltReopeningAuction(t *testing.T) {
+	start := time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC)
+	clock := &fixedClock{now: start}
+	matcher := engine.NewMatcher(engine.WithClock(clock))
+	defer matcher.Close()
+
+	matcher.MatchOrder(newTestOrder("AAPL", engine.SideSell, 15000, 100))
+	matcher.Halt("AAPL", "volatility", false)
+	if _, err := matcher.Resume("AAPL", 2*time.Minute); err != nil {
+		t.Fatalf("Resume failed: %v", err)
+	}
+	if _, err := matcher.Resume("AAPL", 2*time.Minute); err == nil {
+		t.Error("Expected resuming a symbol that is not halted to fail")
+	}
+
+	orderBook, _ := matcher.GetOrderBook("AAPL")
+	if !orderBook.InAuction() || !orderBook.ReopensAt().Equal(start.Add(2*time.Minute)) {
+		t.Fatalf("Expected a reopening auction until %s, got %v at %s", start.Add(2*time.Minute), orderBook.InAuction(), orderBook.ReopensAt())
+	}
+	result, err := matcher.MatchOrder(newTestOrder("AAPL", engine.SideBuy, 15100, 60))
+	if err != nil || result.Status != engine.StatusAccepted || len(result.Trades) != 0 {
+		t.Fatalf("Expected a crossing order to wait for the reopening, got %+v, %v", result, err)
+	}
+
+	clock.now = start.Add(time.Minute)
+	if uncrosses, _ := matcher.EndReopeningAuctions(); len(uncrosses) != 0 {
+		t.Errorf("Expected no uncross before the auction ends, got %+v", uncrosses)
+	}
+
+	clock.now = start.Add(2 * time.Minute)
+	uncrosses, err := matcher.EndReopeningAuctions()
+	if err != nil || len(uncrosses) != 1 || uncrosses[0].Volume != 60 || uncrosses[0].Price != 15000 {
+		t.Fatalf("Expected the reopening to uncross 60 at 15000, got %+v, %v", uncrosses, err)
+	}
+	if orderBook.InAuction() || !orderBook.ReopensAt().IsZero() {
+		t.Error("Expected continuous trading after the reopening")
+	}
+
+	// an order after the end uncrosses the auction first
+	matcher.Halt("AAPL", "", false)
+	matcher.Resume("AAPL", time.Minute)
+	matcher.MatchOrder(newTestOrder("AAPL", engine.SideBuy, 15000, 10))
+	clock.now = start.Add(4 * time.Minute)
+	result, err = matcher.MatchOrder(newTestOrder("AAPL", engine.SideBuy, 15000, 20))
+	if err != nil || result.Status != engine.StatusFilled {
+		t.Errorf("Expected the order after the reopening to match, got %+v, %v", result, err)
+	}
+	if filled := orderBook.GetLastTradePrice(); filled != 15000 {
+		t.Errorf("Expected trades at 15000, got %d", filled)
+	}
+}
+
+// TestTradingHaltAcrossSessions tests that a halt keeps an auction its session would
+// have uncrossed until the resume, and that a resume outside continuous trading leaves
+// the opening to the schedule
+func TestTradingHaltAcrossSessions(t *testing.T) {
+	day := time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC)
+	clock := &fixedClock{now: day.Add(9*time.Hour + 10*time.Minute)}
+	matcher := newScheduledMatcher(t, clock)
+
+	matcher.MatchOrder(newTestOrder("AAPL", engine.SideSell, 15000, 100))
+	matcher.MatchOrder(newTestOrder("AAPL", engine.SideBuy, 15100, 60))
+	matcher.Halt("AAPL", "pending news", true)
+
+	clock.now = day.Add(9*time.Hour + 30*time.Minute)
+	changes, err := matcher.UpdateSessions()
+	if err != nil || len(changes) != 1 || changes[0].To != engine.SessionContinuous || changes[0].Uncross != nil {
+		t.Fatalf("Expected the open without an uncross, got %+v, %v", changes, err)
+	}
+	orderBook, _ := matcher.GetOrderBook("AAPL")
+	if !orderBook.InAuction() {
+		t.Fatal("Expected the halt to keep the opening auction")
+	}
+
+	matcher.Resume("AAPL", 30*time.Second)
+	clock.now = clock.now.Add(30 * time.Second)
+	if uncrosses, _ := matcher.EndReopeningAuctions(); len(uncrosses) != 1 || uncrosses[0].Volume != 60 {
+		t.Errorf("Expected the reopening to uncross 60, got %+v", uncrosses)
+	}
+
+	clock.now = day.Add(17 * time.Hour)
+	matcher.UpdateSessions()
+	matcher.Halt("AAPL", "", false)
+	matcher.Resume("AAPL", time.Minute)
+	if orderBook.InAuction() || !orderBook.ReopensAt().IsZero() {
+		t.Error("Expected a resume while CLOSED to start no reopening auction")
+	}
+}
+
+// TestTradingHaltReplay tests that halts, resumes and reopenings replay at the same point
+// between orders, and that a snapshot keeps the halt
+func TestTradingHaltReplay(t *testing.T) {
+	dir := t.TempDir()
+	j := openJournal(t, journal.DefaultOptions(dir))
+	start := time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC)
+	clock := &fixedClock{now: start}
+	matcher := engine.NewMatcher(engine.WithClock(clock), engine.WithCommandLog(j))
+	defer matcher.Close()
+
+	matcher.MatchOrder(newTestOrder("AAPL", engine.SideSell, 15000, 100))
+	matcher.Halt("AAPL", "news", false)
+	matcher.MatchOrder(newTestOrder("AAPL", engine.SideBuy, 15000, 10))
+	matcher.Resume("AAPL", time.Minute)
+	matcher.MatchOrder(newTestOrder("AAPL", engine.SideBuy, 15100, 40))
+	clock.now = start.Add(time.Minute)
+	matcher.EndReopeningAuctions()
+	matcher.Halt("AAPL", "end of day", true)
+	states := matcher.Snapshot()
+	live, _ := json.Marshal(states)
+	j.Close()
+
+	replayed := engine.NewMatcher()
+	defer replayed.Close()
+	if _, err := journal.Scan(dir, 0, replayed.Replay); err != nil {
+		t.Fatalf("Replay failed: %v", err)
+	}
+	books, _ := json.Marshal(replayed.Snapshot())
+	if !bytes.Equal(live, books) {
+		t.Errorf("Expected replayed books to match live books\nlive:     %s\nreplayed: %s", live, books)
+	}
+
+	restored := engine.NewMatcher()
+	defer restored.Close()
+	for _, state := range states {
+		if err := restored.Restore(state); err != nil {
+			t.Fatalf("Restore failed: %v", err)
+		}
+	}
+	orderBook, _ := restored.GetOrderBook("AAPL")
+	if halt, halted := orderBook.Halted(); !halted || halt.Reason != "end of day" || !halt.AllowCancels {
+		t.Errorf("Expected the restored book halted, got %+v", halt)
+	}
+}
+
+// TestTradingHaltAPI tests the admin halt endpoints and the halt of the order book response
+func TestTradingHaltAPI(t *testing.T) {
+	t.Setenv("RATE_LIMIT_DISABLED", "1")
+	t.Setenv("REQUEST_LOGGING_DISABLED", "1")
+	t.Setenv("ADMIN_TOKEN", "secret")
+
+	registry := engine.NewInstrumentRegistry(nil)
+	registry.Create(engine.Instrument{Symbol: "AAPL", TickSize: 1, LotSize: 1})
+	clock := &fixedClock{now: time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC)}
+	matcher := engine.NewMatcher(engine.WithClock(clock), engine.WithInstruments(registry))
+	defer matcher.Close()
+
+	app := fiber.New()
+	routes.SetupRoutes(app, handlers.NewOrderHandler(matcher))
+	routes.SetupSymbolRoutes(app, handlers.NewSymbolHandler(matcher))
+
+	send := func(method, url, token string, body interface{}) (int, []byte) {
+		t.Helper()
+		var reader io.Reader
+		if body != nil {
+			data, _ := json.Marshal(body)
+			reader = bytes.NewReader(data)
+		}
+		req := httptest.NewRequest(method, url, reader)
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		respBody, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, respBody
+	}
+
+	halt := models.HaltRequest{Reason: "news pending", AllowCancels: true}
+	if status, _ := send(http.MethodPost, "/admin/v1/symbols/AAPL/halt", "", halt); status != http.StatusUnauthorized {
+		t.Errorf("Expected 401 without the admin token, got %d", status)
+	}
+	if status, _ := send(http.MethodPost, "/admin/v1/symbols/MSFT/halt", "secret", halt); status != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown symbol, got %d", status)
+	}
+	status, body := send(http.MethodPost, "/admin/v1/symbols/AAPL/halt", "secret", halt)
+	var halted models.HaltResponse
+	json.Unmarshal(body, &halted)
+	if status != http.StatusOK || !halted.Halted || halted.Reason != "news pending" || !halted.AllowCancels {
+		t.Fatalf("Unexpected halt response %d: %s", status, body)
+	}
+	status, body = send(http.MethodPost, "/admin/v1/symbols/AAPL/halt", "secret", nil)
+	if code := errorCode(body); status != http.StatusConflict || code != engine.CodeSymbolHalted {
+		t.Errorf("Expected 409 %s halting a halted symbol, got %d: %s", engine.CodeSymbolHalted, status, body)
+	}
+
+	order := models.SubmitOrderRequest{Symbol: "AAPL", Side: "BUY", Type: "LIMIT", Price: 15000, Quantity: 100}
+	status, body = send(http.MethodPost, "/api/v1/orders", "", order)
+	if status != http.StatusBadRequest || !strings.Contains(string(body), "AAPL is halted (news pending)") {
+		t.Errorf("Expected 400 with the halt reason, got %d: %s", status, body)
+	}
+	if code := errorCode(body); code != engine.CodeSymbolHalted {
+		t.Errorf("Expected code %s, got %q", engine.CodeSymbolHalted, code)
+	}
+	_, body = send(http.MethodGet, "/api/v1/orderbook/AAPL", "", nil)
+	var book models.OrderBookResponse
+	json.Unmarshal(body, &book)
+	if book.Halt == nil || book.Halt.Reason != "news pending" || book.Halt.Since != clock.now.UnixMilli() {
+		t.Errorf("Expected the book halted, got %s", body)
+	}
+
+	if status, _ := send(http.MethodDelete, "/admin/v1/symbols/AAPL/halt?auction=soon", "secret", nil); status != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an invalid auction duration, got %d", status)
+	}
+	status, body = send(http.MethodDelete, "/admin/v1/symbols/AAPL/halt?auction=30s", "secret", nil)
+	var resumed models.HaltResponse
+	json.Unmarshal(body, &resumed)
+	if status != http.StatusOK || resumed.Halted || resumed.ReopensAt != clock.now.Add(30*time.Second).UnixMilli() {
+		t.Fatalf("Unexpected resume response %d: %s", status, body)
+	}
+	status, body = send(http.MethodDelete, "/admin/v1/symbols/AAPL/halt", "secret", nil)
+	if code := errorCode(body); status != http.StatusConflict || code != engine.CodeSymbolNotHalted {
+		t.Errorf("Expected 409 %s resuming a symbol that is not halted, got %d: %s", engine.CodeSymbolNotHalted, status, body)
+	}
+	if status, body := send(http.MethodPost, "/api/v1/orders", "", order); status != http.StatusCreated {
+		t.Errorf("Expected 201 for an order in the reopening auction, got %d: %s", status, body)
+	}
+}
@@ -0,0 +1,202 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"match-engine/src/engine"
+	"match-engine/src/models"
+)
+
+// TestIcebergOrderSnapshot tests that only the display slice of an iceberg is visible
+func TestIcebergOrderSnapshot(t *testing.T) {
+	matcher := engine.NewMatcher()
+	symbol := "AAPL"
+
+	iceberg := engine.NewOrder(uuid.New().String(), symbol, engine.SideSell, engine.TypeLimit, 15050, 1000)
+	iceberg.DisplayQuantity = 100
+	_, _ = matcher.MatchOrder(iceberg)
+
+	orderBook := matcher.GetOrCreateOrderBook(symbol)
+	_, asks := orderBook.GetOrderBookSnapshot(10)
+
+	if len(asks) != 1 {
+		t.Fatalf("Expected 1 ask level, got: %d", len(asks))
+	}
+
+	if asks[0].Quantity != 100 {
+		t.Errorf("Expected visible quantity 100, got: %d", asks[0].Quantity)
+	}
+
+	_, qty, _ := orderBook.GetBestAsk()
+	if qty != 100 {
+		t.Errorf("Expected best ask quantity 100, got: %d", qty)
+	}
+}
+
+// TestIcebergOrderReplenishLosesPriority tests that a replenished slice goes to the back of the queue
+func TestIcebergOrderReplenishLosesPriority(t *testing.T) {
+	matcher := engine.NewMatcher()
+	symbol := "AAPL"
+
+	// Iceberg first, then a regular order at the same price
+	iceberg := engine.NewOrder(uuid.New().String(), symbol, engine.SideSell, engine.TypeLimit, 15050, 1000)
+	iceberg.DisplayQuantity = 100
+	_, _ = matcher.MatchOrder(iceberg)
+
+	regular := engine.NewOrder(uuid.New().String(), symbol, engine.SideSell, engine.TypeLimit, 15050, 200)
+	_, _ = matcher.MatchOrder(regular)
+
+	// Buy 150: takes the 100 slice from the iceberg, then 50 from the regular order,
+	// because the replenished slice lost its place to the regular order
+	buyOrder := engine.NewOrder(uuid.New().String(), symbol, engine.SideBuy, engine.TypeLimit, 15050, 150)
+	result, err := matcher.MatchOrder(buyOrder)
+
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if len(result.Trades) != 2 {
+		t.Fatalf("Expected 2 trades, got: %d", len(result.Trades))
+	}
+
+	if result.Trades[0].SellOrderID != iceberg.ID || result.Trades[0].Quantity != 100 {
+		t.Errorf("Expected first trade 100 against iceberg, got: %d against %s", result.Trades[0].Quantity, result.Trades[0].SellOrderID)
+	}
+
+	if result.Trades[1].SellOrderID != regular.ID || result.Trades[1].Quantity != 50 {
+		t.Errorf("Expected second trade 50 against regular order, got: %d against %s", result.Trades[1].Quantity, result.Trades[1].SellOrderID)
+	}
+
+	orderBook := matcher.GetOrCreateOrderBook(symbol)
+	priceLevel := orderBook.GetPriceLevelForAsk(15050)
+	if priceLevel == nil || len(priceLevel.Orders) != 2 {
+		t.Fatal("Expected 2 orders at 15050")
+	}
+
+	if priceLevel.Orders[0].ID != regular.ID || priceLevel.Orders[1].ID != iceberg.ID {
+		t.Error("Expected replenished iceberg behind the regular order")
+	}
+
+	// Visible: 150 left on the regular order + new 100 slice
+	_, qty, _ := orderBook.GetBestAsk()
+	if qty != 250 {
+		t.Errorf("Expected visible quantity 250, got: %d", qty)
+	}
+}
+
+// TestIcebergOrderFullyConsumed tests that an aggressor can sweep through several slices
+// and that hidden quantity counts as available liquidity for market orders
+func TestIcebergOrderFullyConsumed(t *testing.T) {
+	matcher := engine.NewMatcher()
+	symbol := "AAPL"
+
+	iceberg := engine.NewOrder(uuid.New().String(), symbol, engine.SideSell, engine.TypeLimit, 15050, 350)
+	iceberg.DisplayQuantity = 100
+	_, _ = matcher.MatchOrder(iceberg)
+
+	marketOrder := engine.NewOrder(uuid.New().String(), symbol, engine.SideBuy, engine.TypeMarket, 0, 350)
+	result, err := matcher.MatchOrder(marketOrder)
+
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if result.FilledQuantity != 350 {
+		t.Errorf("Expected filled quantity 350, got: %d", result.FilledQuantity)
+	}
+
+	// One trade per slice: 100, 100, 100, 50
+	if len(result.Trades) != 4 {
+		t.Errorf("Expected 4 trades, got: %d", len(result.Trades))
+	}
+
+	if iceberg.GetStatus() != engine.StatusFilled {
+		t.Errorf("Expected iceberg FILLED, got: %s", iceberg.GetStatus())
+	}
+
+	orderBook := matcher.GetOrCreateOrderBook(symbol)
+	if _, _, hasAsk := orderBook.GetBestAsk(); hasAsk {
+		t.Error("Expected no asks after iceberg is consumed")
+	}
+}
+
+// TestIcebergOrderValidation tests display_quantity validation through the API
+func TestIcebergOrderValidation(t *testing.T) {
+	app := setupTestServer()
+
+	testCases := []struct {
+		name           string
+		reqBody        map[string]interface{}
+		expectedStatus int
+	}{
+		{
+			name: "display quantity larger than quantity",
+			reqBody: map[string]interface{}{
+				"symbol":           "AAPL",
+				"side":             "SELL",
+				"type":             "LIMIT",
+				"price":            15050,
+				"quantity":         100,
+				"display_quantity": 200,
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name: "display quantity on market order",
+			reqBody: map[string]interface{}{
+				"symbol":           "AAPL",
+				"side":             "SELL",
+				"type":             "MARKET",
+				"quantity":         100,
+				"display_quantity": 10,
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name: "valid iceberg",
+			reqBody: map[string]interface{}{
+				"symbol":           "AAPL",
+				"side":             "SELL",
+				"type":             "LIMIT",
+				"price":            15050,
+				"quantity":         1000,
+				"display_quantity": 100,
+			},
+			expectedStatus: http.StatusCreated,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			body, _ := json.Marshal(tc.reqBody)
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/orders", bytes.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			resp, err := app.Test(req)
+
+			if err != nil {
+				t.Fatalf("Request failed: %v", err)
+			}
+
+			if resp.StatusCode != tc.expectedStatus {
+				t.Errorf("Expected status %d, got: %d", tc.expectedStatus, resp.StatusCode)
+			}
+		})
+	}
+
+	// The order book only shows the display slice
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/orderbook/AAPL", nil)
+	resp, _ := app.Test(req)
+
+	var orderBook models.OrderBookResponse
+	json.NewDecoder(resp.Body).Decode(&orderBook)
+
+	if len(orderBook.Asks) != 1 || orderBook.Asks[0].Quantity != 100 {
+		t.Errorf("Expected one ask level showing 100, got: %+v", orderBook.Asks)
+	}
+}
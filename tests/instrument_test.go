@@ -0,0 +1,306 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"match-engine/src/engine"
+	"match-engine/src/handlers"
+	"match-engine/src/models"
+	"match-engine/src/refdata"
+	"match-engine/src/routes"
+)
+
+// newInstrumentMatcher trades AAPL in ticks of 5 and lots of 10, between 20 and 1000 shares
+func newInstrumentMatcher(t *testing.T) (*engine.Matcher, *engine.InstrumentRegistry) {
+	registry := engine.NewInstrumentRegistry(nil)
+	_, err := registry.Create(engine.Instrument{
+		Symbol: "AAPL", TickSize: 5, LotSize: 10, MinQuantity: 20, MaxQuantity: 1000, PricePrecision: 2,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create instrument: %v", err)
+	}
+	matcher := engine.NewMatcher(engine.WithInstruments(registry))
+	t.Cleanup(matcher.Close)
+	return matcher, registry
+}
+
+// TestInstrumentOrderRules tests that orders are checked against their instrument before
+// they reach a book
+func TestInstrumentOrderRules(t *testing.T) {
+	matcher, registry := newInstrumentMatcher(t)
+
+	if _, err := matcher.MatchOrder(newTestOrder("MSFT", engine.SideBuy, 15000, 100)); err == nil {
+		t.Fatal("Expected an unknown symbol to be rejected")
+	} else if _, ok := err.(*engine.UnknownInstrumentError); !ok {
+		t.Fatalf("Expected UnknownInstrumentError, got %v", err)
+	}
+	if _, exists := matcher.GetOrderBook("MSFT"); exists {
+		t.Error("Expected no book for an unknown symbol")
+	}
+	if _, listed := matcher.ListedOrderBook("MSFT"); listed {
+		t.Error("Expected no listed book for an unknown symbol")
+	}
+
+	stop := newTestOrder("AAPL", engine.SideBuy, 15000, 100)
+	stop.Type = engine.TypeStopLimit
+	stop.StopPrice = 15002
+	iceberg := newTestOrder("AAPL", engine.SideBuy, 15000, 100)
+	iceberg.DisplayQuantity = 25
+
+	tests := []struct {
+		name  string
+		order *engine.Order
+		field string
+	}{
+		{"off tick", newTestOrder("AAPL", engine.SideBuy, 15001, 100), "price"},
+		{"stop off tick", stop, "stop_price"},
+		{"off lot", newTestOrder("AAPL", engine.SideBuy, 15000, 105), "quantity"},
+		{"below minimum", newTestOrder("AAPL", engine.SideBuy, 15000, 10), "quantity"},
+		{"above maximum", newTestOrder("AAPL", engine.SideBuy, 15000, 1010), "quantity"},
+		{"display off lot", iceberg, "display_quantity"},
+	}
+	for _, tt := range tests {
+		_, err := matcher.MatchOrder(tt.order)
+		invalid, ok := err.(*engine.InvalidOrderError)
+		if !ok || invalid.Field != tt.field {
+			t.Errorf("%s: expected an invalid %s, got %v", tt.name, tt.field, err)
+		}
+	}
+
+	resting := newTestOrder("AAPL", engine.SideBuy, 15000, 100)
+	if _, err := matcher.MatchOrder(resting); err != nil {
+		t.Fatalf("Expected a valid order to be accepted: %v", err)
+	}
+	if _, err := matcher.AmendOrder(resting.ID, 15003, 0); err == nil {
+		t.Error("Expected an off-tick amend to be rejected")
+	}
+	if _, err := matcher.AmendOrder(resting.ID, 0, 95); err == nil {
+		t.Error("Expected an off-lot amend to be rejected")
+	}
+	if _, err := matcher.AmendOrder(resting.ID, 15005, 200); err != nil {
+		t.Errorf("Expected a valid amend to be accepted: %v", err)
+	}
+
+	// a closed instrument takes no orders, but its resting orders can still be cancelled
+	if _, err := registry.Update("AAPL", func(i *engine.Instrument) { i.Status = engine.TradingStatusClosed }); err != nil {
+		t.Fatalf("Failed to close instrument: %v", err)
+	}
+	if _, err := matcher.MatchOrder(newTestOrder("AAPL", engine.SideSell, 15000, 100)); err == nil {
+		t.Error("Expected an order on a closed instrument to be rejected")
+	} else if _, ok := err.(*engine.InstrumentNotTradingError); !ok {
+		t.Errorf("Expected InstrumentNotTradingError, got %v", err)
+	}
+	if _, err := matcher.CancelOrder(resting.ID); err != nil {
+		t.Errorf("Expected cancel on a closed instrument to succeed: %v", err)
+	}
+}
+
+// TestInstrumentRegistryValidation tests that inconsistent instruments are refused and
+// that updates keep the previous definition when they fail
+func TestInstrumentRegistryValidation(t *testing.T) {
+	registry := engine.NewInstrumentRegistry(nil)
+
+	invalid := []engine.Instrument{
+		{Symbol: "", TickSize: 1, LotSize: 1},
+		{Symbol: "BAD SYMBOL", TickSize: 1, LotSize: 1},
+		{Symbol: "AAPL", TickSize: 0, LotSize: 1},
+		{Symbol: "AAPL", TickSize: 1, LotSize: 0},
+		{Symbol: "AAPL", TickSize: 1, LotSize: 10, MinQuantity: 15},
+		{Symbol: "AAPL", TickSize: 1, LotSize: 10, MinQuantity: 100, MaxQuantity: 50},
+		{Symbol: "AAPL", TickSize: 1, LotSize: 1, PricePrecision: 9},
+		{Symbol: "AAPL", TickSize: 1, LotSize: 1, Status: "OPEN"},
+		// edge case: symbols are halted with the halt API, not a status
+		{Symbol: "AAPL", TickSize: 1, LotSize: 1, Status: "HALTED"},
+		{Symbol: "AAPL", TickSize: 1, LotSize: 1, MatchingAlgorithm: "LIFO"},
+		{Symbol: "AAPL", TickSize: 1, LotSize: 1, MinAllocation: 5},
+		{Symbol: "AAPL", TickSize: 1, LotSize: 10, MatchingAlgorithm: engine.AlgorithmProRata, MinAllocation: 15},
+	}
+	for _, instrument := range invalid {
+		if _, err := registry.Create(instrument); err == nil {
+			t.Errorf("Expected %+v to be refused", instrument)
+		}
+	}
+
+	created, err := registry.Create(engine.Instrument{Symbol: "AAPL", TickSize: 1, LotSize: 100})
+	if err != nil {
+		t.Fatalf("Failed to create instrument: %v", err)
+	}
+	if created.MinQuantity != 100 || created.Status != engine.TradingStatusTrading || created.MatchingAlgorithm != engine.AlgorithmFIFO {
+		t.Errorf("Expected defaults filled in, got %+v", created)
+	}
+	if _, err := registry.Create(created); err == nil {
+		t.Error("Expected a duplicate instrument to be refused")
+	}
+
+	if _, err := registry.Update("AAPL", func(i *engine.Instrument) { i.LotSize = 30 }); err == nil {
+		t.Error("Expected a lot size the minimum is not a multiple of to be refused")
+	}
+	if instrument, _ := registry.Get("AAPL"); instrument != created {
+		t.Errorf("Expected a failed update to keep %+v, got %+v", created, instrument)
+	}
+}
+
+// failingCommandLog refuses every append while fail is set
+type failingCommandLog struct {
+	fail bool
+}
+
+func (l *failingCommandLog) Append(cmd *engine.Command) error {
+	if l.fail {
+		return errors.New("disk full")
+	}
+	return nil
+}
+
+// savedInstruments keeps the last list an InstrumentRegistry saved
+type savedInstruments struct {
+	instruments []engine.Instrument
+}
+
+func (s *savedInstruments) Save(instruments []engine.Instrument) error {
+	s.instruments = instruments
+	return nil
+}
+
+// TestInstrumentChangeNotJournaled tests that a change to a traded instrument that cannot
+// be journaled reaches neither the registry, its store nor the book
+func TestInstrumentChangeNotJournaled(t *testing.T) {
+	commandLog := &failingCommandLog{}
+	store := &savedInstruments{}
+	registry := engine.NewInstrumentRegistry(store)
+	registry.Create(engine.Instrument{Symbol: "AAPL", TickSize: 1, LotSize: 1})
+	matcher := engine.NewMatcher(engine.WithInstruments(registry), engine.WithCommandLog(commandLog))
+	defer matcher.Close()
+	matcher.MatchOrder(newTestOrder("AAPL", engine.SideSell, 15000, 100))
+
+	commandLog.fail = true
+	_, err := matcher.UpdateInstrument("AAPL", func(i *engine.Instrument) { i.TickSize = 5 })
+	if _, ok := err.(*engine.CommandLogError); !ok {
+		t.Fatalf("Expected CommandLogError, got %v", err)
+	}
+	if instrument, _ := registry.Get("AAPL"); instrument.TickSize != 1 {
+		t.Errorf("Expected the registry to keep tick size 1, got %d", instrument.TickSize)
+	}
+	if len(store.instruments) != 1 || store.instruments[0].TickSize != 1 {
+		t.Errorf("Expected the store to keep tick size 1, got %+v", store.instruments)
+	}
+
+	commandLog.fail = false
+	if _, err := matcher.MatchOrder(newTestOrder("AAPL", engine.SideBuy, 15001, 10)); err != nil {
+		t.Errorf("Expected the book to keep tick size 1, got %v", err)
+	}
+}
+
+// TestInstrumentAPI tests the admin endpoints, the public listing and that REST orders
+// and book queries only reach listed instruments
+func TestInstrumentAPI(t *testing.T) {
+	t.Setenv("RATE_LIMIT_DISABLED", "1")
+	t.Setenv("REQUEST_LOGGING_DISABLED", "1")
+	t.Setenv("ADMIN_TOKEN", "secret")
+
+	path := filepath.Join(t.TempDir(), "instruments.json")
+	store, err := refdata.NewFileStore(path)
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	registry := engine.NewInstrumentRegistry(store)
+	matcher := engine.NewMatcher(engine.WithInstruments(registry))
+	defer matcher.Close()
+
+	app := fiber.New()
+	routes.SetupRoutes(app, handlers.NewOrderHandler(matcher))
+	routes.SetupInstrumentRoutes(app, handlers.NewInstrumentHandler(matcher))
+
+	send := func(method, url, token string, body interface{}) (int, []byte) {
+		t.Helper()
+		data, _ := json.Marshal(body)
+		req := httptest.NewRequest(method, url, bytes.NewReader(data))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		respBody, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, respBody
+	}
+
+	aapl := models.InstrumentRequest{Symbol: "AAPL", TickSize: 5, LotSize: 10}
+	if status, _ := send(http.MethodPost, "/admin/v1/instruments", "", aapl); status != http.StatusUnauthorized {
+		t.Errorf("Expected 401 without a token, got %d", status)
+	}
+	if status, _ := send(http.MethodPost, "/admin/v1/instruments", "wrong", aapl); status != http.StatusUnauthorized {
+		t.Errorf("Expected 401 with a wrong token, got %d", status)
+	}
+
+	status, body := send(http.MethodPost, "/admin/v1/instruments", "secret", aapl)
+	var created models.InstrumentResponse
+	json.Unmarshal(body, &created)
+	if status != http.StatusCreated || created.MinQuantity != 10 || created.PricePrecision != 2 || created.Status != "TRADING" {
+		t.Fatalf("Unexpected create response %d: %s", status, body)
+	}
+	if status, _ := send(http.MethodPost, "/admin/v1/instruments", "secret", aapl); status != http.StatusConflict {
+		t.Errorf("Expected 409 for a duplicate, got %d", status)
+	}
+	if status, _ := send(http.MethodPost, "/admin/v1/instruments", "secret", models.InstrumentRequest{Symbol: "MSFT", LotSize: 1}); status != http.StatusBadRequest {
+		t.Errorf("Expected 400 without a tick size, got %d", status)
+	}
+
+	maxQuantity := int64(500)
+	status, body = send(http.MethodPatch, "/admin/v1/instruments/AAPL", "secret", models.UpdateInstrumentRequest{MaxQuantity: &maxQuantity})
+	if status != http.StatusOK {
+		t.Fatalf("Expected 200 for an update, got %d: %s", status, body)
+	}
+	if status, _ := send(http.MethodPatch, "/admin/v1/instruments/MSFT", "secret", models.UpdateInstrumentRequest{MaxQuantity: &maxQuantity}); status != http.StatusNotFound {
+		t.Errorf("Expected 404 updating an unknown instrument, got %d", status)
+	}
+
+	status, body = send(http.MethodGet, "/api/v1/instruments", "", nil)
+	var list models.InstrumentListResponse
+	json.Unmarshal(body, &list)
+	if status != http.StatusOK || len(list.Instruments) != 1 || list.Instruments[0].MaxQuantity != 500 || list.Instruments[0].TickSize != 5 {
+		t.Fatalf("Unexpected instrument list %d: %s", status, body)
+	}
+
+	stored, err := store.Load()
+	if err != nil || len(stored) != 1 || stored[0].MaxQuantity != 500 {
+		t.Errorf("Expected the update stored, got %+v, %v", stored, err)
+	}
+
+	orders := []struct {
+		order  models.SubmitOrderRequest
+		status int
+	}{
+		{models.SubmitOrderRequest{Symbol: "MSFT", Side: "BUY", Type: "LIMIT", Price: 15000, Quantity: 100}, http.StatusBadRequest},
+		{models.SubmitOrderRequest{Symbol: "AAPL", Side: "BUY", Type: "LIMIT", Price: 15002, Quantity: 100}, http.StatusBadRequest},
+		{models.SubmitOrderRequest{Symbol: "AAPL", Side: "BUY", Type: "LIMIT", Price: 15000, Quantity: 15}, http.StatusBadRequest},
+		{models.SubmitOrderRequest{Symbol: "AAPL", Side: "BUY", Type: "LIMIT", Price: 15000, Quantity: 600}, http.StatusBadRequest},
+		{models.SubmitOrderRequest{Symbol: "AAPL", Side: "BUY", Type: "LIMIT", Price: 15000, Quantity: 100}, http.StatusCreated},
+	}
+	for _, tt := range orders {
+		if status, body := send(http.MethodPost, "/api/v1/orders", "", tt.order); status != tt.status {
+			t.Errorf("Order %+v: expected %d, got %d: %s", tt.order, tt.status, status, body)
+		}
+	}
+
+	if status, _ := send(http.MethodGet, "/api/v1/orderbook/MSFT", "", nil); status != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown book, got %d", status)
+	}
+	if _, exists := matcher.GetOrderBook("MSFT"); exists {
+		t.Error("Expected no book created for an unknown symbol")
+	}
+	if status, _ := send(http.MethodGet, "/api/v1/orderbook/AAPL", "", nil); status != http.StatusOK {
+		t.Errorf("Expected 200 for a listed book, got %d", status)
+	}
+}
@@ -42,7 +42,7 @@ func setupTestServer() *fiber.App {
 	// Initialize logger with minimal settings
 	// This ensures logger is initialized but with minimal overhead
 	logger.InitLogger()
-	
+
 	matcher := engine.NewMatcher()
 	orderHandler := handlers.NewOrderHandler(matcher)
 
@@ -429,6 +429,18 @@ func TestSubmitOrderValidation(t *testing.T) {
 			},
 			expectedStatus: http.StatusBadRequest,
 		},
+		{
+			name: "invalid time in force",
+			reqBody: map[string]interface{}{
+				"symbol":        "AAPL",
+				"side":          "BUY",
+				"type":          "LIMIT",
+				"price":         15050,
+				"quantity":      100,
+				"time_in_force": "DAY",
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
 		{
 			name: "market order without price (valid)",
 			reqBody: map[string]interface{}{
@@ -777,7 +789,7 @@ func TestMetricsLatencyAndThroughput(t *testing.T) {
 		}
 		// Throughput should be reasonable (at least some positive value if orders were processed)
 		if metrics.ThroughputOrdersPerSec > 0 {
-			t.Logf("Throughput: %.2f orders/sec (calculated from %d orders)", 
+			t.Logf("Throughput: %.2f orders/sec (calculated from %d orders)",
 				metrics.ThroughputOrdersPerSec, metrics.OrdersReceived)
 		}
 	}
@@ -969,7 +981,7 @@ func TestMetricsEndpointComprehensive(t *testing.T) {
 	// Track all order IDs and their final status
 	var allOrderIDs []string
 	var orderStatusMap = make(map[string]string) // orderID -> status
-	
+
 	// Track metrics for strict validation
 	var expectedOrdersReceived int64
 	var expectedOrdersMatched int64
@@ -1035,12 +1047,12 @@ func TestMetricsEndpointComprehensive(t *testing.T) {
 		allOrderIDs = append(allOrderIDs, result.OrderID)
 		orderStatusMap[result.OrderID] = result.Status
 		expectedOrdersReceived++
-		
+
 		// Track if order was matched (filled or partially filled)
 		if result.Status == "FILLED" || result.Status == "PARTIAL_FILL" {
 			expectedOrdersMatched++
 		}
-		
+
 		// Count trades executed
 		expectedTradesExecuted += int64(len(result.Trades))
 	}
@@ -1069,12 +1081,12 @@ func TestMetricsEndpointComprehensive(t *testing.T) {
 		allOrderIDs = append(allOrderIDs, result.OrderID)
 		orderStatusMap[result.OrderID] = result.Status
 		expectedOrdersReceived++
-		
+
 		// Track if order was matched (filled or partially filled)
 		if result.Status == "FILLED" || result.Status == "PARTIAL_FILL" {
 			expectedOrdersMatched++
 		}
-		
+
 		// Count trades executed
 		expectedTradesExecuted += int64(len(result.Trades))
 	}
@@ -1138,7 +1150,7 @@ func TestMetricsEndpointComprehensive(t *testing.T) {
 	// STRICT COUNT VALIDATION for the specified metrics
 	// Note: Current implementation returns 0 for most metrics, but we validate the structure
 	// and ensure the counts match what we expect based on operations performed
-	
+
 	// Strict validation: orders_received
 	if metrics.OrdersReceived != expectedOrdersReceived {
 		t.Errorf("STRICT VALIDATION FAILED: orders_received expected %d, got %d",
@@ -1173,7 +1185,7 @@ func TestMetricsEndpointComprehensive(t *testing.T) {
 	if metrics.LatencyP999Ms < 0 {
 		t.Error("LatencyP999Ms should be non-negative")
 	}
-	
+
 	// Validate latency percentiles are in correct order (P50 <= P99 <= P999)
 	if metrics.LatencyP50Ms > metrics.LatencyP99Ms && metrics.LatencyP99Ms > 0 {
 		t.Errorf("Latency percentiles out of order: P50 (%.2f) should be <= P99 (%.2f)",
@@ -1183,12 +1195,12 @@ func TestMetricsEndpointComprehensive(t *testing.T) {
 		t.Errorf("Latency percentiles out of order: P99 (%.2f) should be <= P999 (%.2f)",
 			metrics.LatencyP99Ms, metrics.LatencyP999Ms)
 	}
-	
+
 	// Validate throughput (should be non-negative and reasonable)
 	if metrics.ThroughputOrdersPerSec < 0 {
 		t.Error("ThroughputOrdersPerSec should be non-negative")
 	}
-	
+
 	// If we have orders and uptime, throughput should be reasonable
 	// Throughput = orders_received / uptime_seconds
 	// We can't easily get uptime in test, but we can validate it's not negative
@@ -1296,3 +1308,115 @@ func TestMetricsEndpointComprehensive(t *testing.T) {
 	}
 }
 
+// TestSubmitOrderTimeInForce tests IOC and FOK orders through the API
+// IOC remainder is cancelled (200), FOK without enough liquidity is rejected (400)
+func TestSubmitOrderTimeInForce(t *testing.T) {
+	app := setupTestServer()
+
+	// Submit a small sell order
+	reqBody := map[string]interface{}{
+		"symbol":   "AAPL",
+		"side":     "SELL",
+		"type":     "LIMIT",
+		"price":    15050,
+		"quantity": 100,
+	}
+
+	body, _ := json.Marshal(reqBody)
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/orders", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	app.Test(req)
+
+	// FOK buy for more than is available should be rejected without trading
+	reqBody = map[string]interface{}{
+		"symbol":        "AAPL",
+		"side":          "BUY",
+		"type":          "LIMIT",
+		"price":         15050,
+		"quantity":      150,
+		"time_in_force": "FOK",
+	}
+
+	body, _ = json.Marshal(reqBody)
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/orders", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req)
+
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for FOK order, got: %d", resp.StatusCode)
+	}
+
+	// IOC buy for more than is available fills 100 and cancels the rest
+	reqBody["time_in_force"] = "IOC"
+	body, _ = json.Marshal(reqBody)
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/orders", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err = app.Test(req)
+
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected status 200 for IOC order, got: %d", resp.StatusCode)
+	}
+
+	var result models.SubmitOrderResponse
+	json.NewDecoder(resp.Body).Decode(&result)
+
+	if result.Status != "CANCELLED" {
+		t.Errorf("Expected status CANCELLED, got: %s", result.Status)
+	}
+
+	if result.FilledQuantity != 100 {
+		t.Errorf("Expected filled quantity 100, got: %d", result.FilledQuantity)
+	}
+
+	// Book should be empty on both sides
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/orderbook/AAPL", nil)
+	resp, _ = app.Test(req)
+
+	var orderBook models.OrderBookResponse
+	json.NewDecoder(resp.Body).Decode(&orderBook)
+
+	if len(orderBook.Bids) != 0 || len(orderBook.Asks) != 0 {
+		t.Errorf("Expected empty order book, got %d bids and %d asks", len(orderBook.Bids), len(orderBook.Asks))
+	}
+}
+
+// TestSubmitOrderTimeInForceNotAllowed tests that MARKET and STOP orders with IOC or FOK
+// are rejected (400) before they reach the book
+func TestSubmitOrderTimeInForceNotAllowed(t *testing.T) {
+	app := setupTestServer()
+
+	for _, orderType := range []string{"MARKET", "STOP"} {
+		for _, timeInForce := range []string{"IOC", "FOK"} {
+			body, _ := json.Marshal(map[string]interface{}{
+				"symbol":        "AAPL",
+				"side":          "BUY",
+				"type":          orderType,
+				"stop_price":    15050,
+				"quantity":      100,
+				"time_in_force": timeInForce,
+			})
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/orders", bytes.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("Request failed: %v", err)
+			}
+
+			var errResp models.ErrorResponse
+			json.NewDecoder(resp.Body).Decode(&errResp)
+
+			expected := "Invalid order: time_in_force " + timeInForce + " is not allowed on " + orderType + " orders"
+			if resp.StatusCode != http.StatusBadRequest || errResp.Error != expected {
+				t.Errorf("Expected 400 %q for a %s %s order, got %d %q", expected, timeInForce, orderType, resp.StatusCode, errResp.Error)
+			}
+		}
+	}
+}
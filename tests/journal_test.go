@@ -0,0 +1,228 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"match-engine/src/engine"
+	"match-engine/src/journal"
+)
+
+func openJournal(t *testing.T, opts journal.Options) *journal.Journal {
+	j, err := journal.Open(opts)
+	if err != nil {
+		t.Fatalf("Failed to open journal: %v", err)
+	}
+	return j
+}
+
+func replayAll(t *testing.T, j *journal.Journal) []*engine.Command {
+	var commands []*engine.Command
+	if _, err := j.Replay(func(cmd *engine.Command) error {
+		commands = append(commands, cmd)
+		return nil
+	}); err != nil {
+		t.Fatalf("Replay failed: %v", err)
+	}
+	return commands
+}
+
+// TestJournalAppendAndReplay tests that appended commands replay in order after reopening,
+// across segment rotations
+func TestJournalAppendAndReplay(t *testing.T) {
+	opts := journal.DefaultOptions(t.TempDir())
+	opts.SegmentSize = 512
+
+	j := openJournal(t, opts)
+	var written []*engine.Command
+	for i := 1; i <= 50; i++ {
+		cmd := &engine.Command{
+			Kind:     engine.CommandSubmit,
+			Symbol:   "AAPL",
+			Sequence: uint64(i),
+			Order: &engine.OrderRecord{
+				ID:       uuid.New().String(),
+				Side:     engine.SideBuy,
+				Type:     engine.TypeLimit,
+				Price:    15000 + int64(i),
+				Quantity: 100,
+			},
+		}
+		if err := j.Append(cmd); err != nil {
+			t.Fatalf("Append failed: %v", err)
+		}
+		written = append(written, cmd)
+	}
+	j.Close()
+
+	segments, _ := filepath.Glob(filepath.Join(opts.Dir, "*.wal"))
+	if len(segments) < 2 {
+		t.Errorf("Expected the journal to rotate into several segments, got: %d", len(segments))
+	}
+
+	j = openJournal(t, opts)
+	defer j.Close()
+	replayed := replayAll(t, j)
+	if !reflect.DeepEqual(written, replayed) {
+		t.Errorf("Expected %d replayed commands equal to the written ones, got: %d", len(written), len(replayed))
+	}
+}
+
+// TestJournalTruncatesTornTail tests that a partial or corrupt record left by a crash is
+// dropped on open and appends continue after the last good record
+func TestJournalTruncatesTornTail(t *testing.T) {
+	opts := journal.DefaultOptions(t.TempDir())
+
+	j := openJournal(t, opts)
+	for i := 1; i <= 3; i++ {
+		j.Append(&engine.Command{Kind: engine.CommandCancel, Symbol: "AAPL", Sequence: uint64(i), OrderID: "order"})
+	}
+	j.Close()
+
+	segments, _ := filepath.Glob(filepath.Join(opts.Dir, "*.wal"))
+	path := segments[len(segments)-1]
+	info, _ := os.Stat(path)
+
+	// edge case: flip a byte in the last record's payload so its checksum fails
+	data, _ := os.ReadFile(path)
+	data[len(data)-2] ^= 0xFF
+	// and leave half a header behind it, as an interrupted write would
+	data = append(data, 0, 0, 0)
+	os.WriteFile(path, data, 0o644)
+
+	j = openJournal(t, opts)
+	if j.TruncatedBytes <= 3 {
+		t.Errorf("Expected the corrupt record and torn header to be truncated, got: %d bytes", j.TruncatedBytes)
+	}
+	if commands := replayAll(t, j); len(commands) != 2 {
+		t.Fatalf("Expected 2 intact commands, got: %d", len(commands))
+	}
+
+	j.Append(&engine.Command{Kind: engine.CommandCancel, Symbol: "AAPL", Sequence: 3, OrderID: "order"})
+	j.Close()
+
+	j = openJournal(t, opts)
+	defer j.Close()
+	commands := replayAll(t, j)
+	if len(commands) != 3 || commands[2].Sequence != 3 {
+		t.Errorf("Expected 3 commands ending at seq 3, got: %d", len(commands))
+	}
+	if newInfo, _ := os.Stat(path); newInfo.Size() != info.Size() {
+		t.Errorf("Expected segment size %d after rewrite, got: %d", info.Size(), newInfo.Size())
+	}
+}
+
+// TestJournalKeepsRecordWhenRotationFails tests that an append whose record was written
+// succeeds even if the segment cannot be rotated, and appends continue in the same segment
+func TestJournalKeepsRecordWhenRotationFails(t *testing.T) {
+	opts := journal.DefaultOptions(t.TempDir())
+	opts.SegmentSize = 1
+
+	// edge case: a directory where the next segment goes makes opening it fail
+	blocked := filepath.Join(opts.Dir, "0000000000000002.wal")
+	if err := os.MkdirAll(blocked, 0o755); err != nil {
+		t.Fatalf("Failed to block the next segment: %v", err)
+	}
+
+	j := openJournal(t, opts)
+	for i := 1; i <= 2; i++ {
+		if err := j.Append(&engine.Command{Kind: engine.CommandCancel, Symbol: "AAPL", Sequence: uint64(i), OrderID: "order"}); err != nil {
+			t.Fatalf("Expected append %d to succeed despite the failed rotation, got: %v", i, err)
+		}
+	}
+
+	os.Remove(blocked)
+	if err := j.Append(&engine.Command{Kind: engine.CommandCancel, Symbol: "AAPL", Sequence: 3, OrderID: "order"}); err != nil {
+		t.Fatalf("Expected the append after the rotation recovered to succeed, got: %v", err)
+	}
+	if err := j.Close(); err != nil {
+		t.Fatalf("Expected the journal to close cleanly, got: %v", err)
+	}
+
+	j = openJournal(t, opts)
+	defer j.Close()
+	commands := replayAll(t, j)
+	if len(commands) != 3 {
+		t.Fatalf("Expected 3 commands, got: %d", len(commands))
+	}
+	for i, cmd := range commands {
+		if cmd.Sequence != uint64(i+1) {
+			t.Errorf("Expected command %d at seq %d, got: %d", i, i+1, cmd.Sequence)
+		}
+	}
+}
+
+// TestJournalRecoversOrderBooks tests that replaying the journal through a fresh Matcher
+// rebuilds the same books, sequence numbers and client order IDs
+func TestJournalRecoversOrderBooks(t *testing.T) {
+	opts := journal.DefaultOptions(t.TempDir())
+
+	j := openJournal(t, opts)
+	matcher := engine.NewMatcher(engine.WithCommandLog(j))
+
+	sell := engine.NewOrder(uuid.New().String(), "AAPL", engine.SideSell, engine.TypeLimit, 15000, 100)
+	sell.ClientOrderID = "sell-1"
+	matcher.MatchOrder(sell)
+	matcher.MatchOrder(engine.NewOrder(uuid.New().String(), "AAPL", engine.SideSell, engine.TypeLimit, 15100, 50))
+	matcher.MatchOrder(engine.NewOrder(uuid.New().String(), "AAPL", engine.SideBuy, engine.TypeLimit, 15000, 30))
+	bid := engine.NewOrder(uuid.New().String(), "AAPL", engine.SideBuy, engine.TypeLimit, 14900, 200)
+	matcher.MatchOrder(bid)
+	matcher.AmendOrder(bid.ID, 14950, 150)
+	cancelled := engine.NewOrder(uuid.New().String(), "GOOGL", engine.SideBuy, engine.TypeLimit, 280000, 10)
+	matcher.MatchOrder(cancelled)
+	matcher.CancelOrder(cancelled.ID)
+	// edge case: rejected commands are journaled too and replay as rejections
+	fok := engine.NewOrder(uuid.New().String(), "AAPL", engine.SideBuy, engine.TypeLimit, 15100, 1000)
+	fok.TimeInForce = engine.TIFFOK
+	matcher.MatchOrder(fok)
+
+	type bookState struct {
+		bids, asks []engine.OrderBookSnapshot
+		seq        uint64
+	}
+	want := make(map[string]bookState)
+	for _, symbol := range []string{"AAPL", "GOOGL"} {
+		orderBook := matcher.GetOrCreateOrderBook(symbol)
+		bids, asks := orderBook.GetOrderBookSnapshot(10)
+		want[symbol] = bookState{bids: bids, asks: asks, seq: orderBook.LastSequence()}
+	}
+	matcher.Close()
+	j.Close()
+
+	j = openJournal(t, opts)
+	defer j.Close()
+	recovered := engine.NewMatcher(engine.WithCommandLog(j))
+	defer recovered.Close()
+	if _, err := j.Replay(recovered.Replay); err != nil {
+		t.Fatalf("Replay failed: %v", err)
+	}
+
+	for symbol, state := range want {
+		rebuilt := recovered.GetOrCreateOrderBook(symbol)
+		bids, asks := rebuilt.GetOrderBookSnapshot(10)
+		if !reflect.DeepEqual(state.bids, bids) || !reflect.DeepEqual(state.asks, asks) {
+			t.Errorf("%s: expected bids %v asks %v, got bids %v asks %v", symbol, state.bids, state.asks, bids, asks)
+		}
+		if rebuilt.LastSequence() != state.seq {
+			t.Errorf("%s: expected sequence %d, got: %d", symbol, state.seq, rebuilt.LastSequence())
+		}
+	}
+
+	restored, exists := recovered.GetOrderByClientID("sell-1")
+	if !exists || restored.ID != sell.ID || restored.FilledQuantity != 30 {
+		t.Errorf("Expected sell-1 to be restored with 30 filled")
+	}
+	if order, _ := recovered.GetOrder(cancelled.ID); order == nil || order.Status != engine.StatusCancelled {
+		t.Errorf("Expected cancelled order to be restored as CANCELLED")
+	}
+
+	// new commands continue the recovered sequence
+	result, err := recovered.MatchOrder(engine.NewOrder(uuid.New().String(), "AAPL", engine.SideBuy, engine.TypeLimit, 14000, 1))
+	if err != nil || result.Sequence != want["AAPL"].seq+1 {
+		t.Errorf("Expected the next command to continue the journaled sequence, got: %+v %v", result, err)
+	}
+}
@@ -0,0 +1,59 @@
+//go:build unix
+
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+
+	"match-engine/src/engine"
+	"match-engine/src/journal"
+)
+
+// TestJournalUndoesFailedAppend tests that a write cut short by the file size limit
+// leaves no torn record behind, so the appends after it still replay
+func TestJournalUndoesFailedAppend(t *testing.T) {
+	opts := journal.DefaultOptions(t.TempDir())
+	j := openJournal(t, opts)
+	defer j.Close()
+
+	for i := 1; i <= 2; i++ {
+		j.Append(&engine.Command{Kind: engine.CommandCancel, Symbol: "AAPL", Sequence: uint64(i), OrderID: "order"})
+	}
+	segments, _ := filepath.Glob(filepath.Join(opts.Dir, "*.wal"))
+	info, _ := os.Stat(segments[0])
+
+	var limit syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_FSIZE, &limit); err != nil {
+		t.Skipf("File size limit not available: %v", err)
+	}
+	// edge case: room for part of the next record only, the rest of the write fails
+	tight := syscall.Rlimit{Cur: uint64(info.Size()) + 20, Max: limit.Max}
+	if err := syscall.Setrlimit(syscall.RLIMIT_FSIZE, &tight); err != nil {
+		t.Skipf("Cannot lower the file size limit: %v", err)
+	}
+	err := j.Append(&engine.Command{Kind: engine.CommandCancel, Symbol: "AAPL", Sequence: 3, OrderID: strings.Repeat("x", 1024)})
+	syscall.Setrlimit(syscall.RLIMIT_FSIZE, &limit)
+	if err == nil {
+		t.Fatal("Expected the append past the file size limit to fail")
+	}
+	if after, _ := os.Stat(segments[0]); after.Size() != info.Size() {
+		t.Errorf("Expected the segment cut back to %d bytes, got %d", info.Size(), after.Size())
+	}
+
+	if err := j.Append(&engine.Command{Kind: engine.CommandCancel, Symbol: "AAPL", Sequence: 3, OrderID: "order"}); err != nil {
+		t.Fatalf("Expected the next append to succeed, got %v", err)
+	}
+	j.Close()
+
+	j = openJournal(t, opts)
+	if j.TruncatedBytes != 0 {
+		t.Errorf("Expected no torn record, truncated %d bytes", j.TruncatedBytes)
+	}
+	if commands := replayAll(t, j); len(commands) != 3 || commands[2].OrderID != "order" {
+		t.Errorf("Expected 3 commands ending with the one after the failure, got %d", len(commands))
+	}
+}
@@ -0,0 +1,256 @@
+package tests
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fasthttp/websocket"
+	"github.com/gofiber/fiber/v2"
+
+	"match-engine/src/accounts"
+	"match-engine/src/engine"
+	"match-engine/src/executions"
+	"match-engine/src/handlers"
+	"match-engine/src/marketdata"
+	"match-engine/src/models"
+	"match-engine/src/routes"
+)
+
+// recordingListener keeps every book update the engine publishes
+type recordingListener struct {
+	mu      sync.Mutex
+	updates []*engine.BookUpdate
+}
+
+func (l *recordingListener) OnBookUpdate(update *engine.BookUpdate) {
+	l.mu.Lock()
+	l.updates = append(l.updates, update)
+	l.mu.Unlock()
+}
+
+// testAccountTokens authenticates the orders and execution report sessions of the stream tests
+var testAccountTokens = accounts.NewTokens(map[string]string{"maker": "maker-token", "taker": "taker-token"})
+
+// startMarketDataServer serves the API, the market data feed and the execution report
+// stream on a local port
+func startMarketDataServer(t *testing.T) (*engine.Matcher, *fiber.App, string) {
+	t.Setenv("RATE_LIMIT_DISABLED", "1")
+	t.Setenv("REQUEST_LOGGING_DISABLED", "1")
+
+	hub := marketdata.NewHub(0)
+	executionHub := executions.NewHub(0)
+	matcher := engine.NewMatcher(engine.WithBookListener(hub), engine.WithExecutionListener(executionHub))
+
+	orderHandler := handlers.NewOrderHandler(matcher)
+	orderHandler.Tokens = testAccountTokens
+
+	app := fiber.New(fiber.Config{DisableStartupMessage: true})
+	routes.SetupRoutes(app, orderHandler)
+	routes.SetupStreamRoutes(app, handlers.NewMarketDataHandler(matcher, hub), handlers.NewExecutionHandler(executionHub, testAccountTokens))
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	go app.Listener(listener)
+	t.Cleanup(func() {
+		app.Shutdown()
+		matcher.Close()
+	})
+
+	return matcher, app, "ws://" + listener.Addr().String() + "/ws/v1/marketdata"
+}
+
+func readFeedMessage(t *testing.T, conn *websocket.Conn, v interface{}) {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if err := conn.ReadJSON(v); err != nil {
+		t.Fatalf("Failed to read feed message: %v", err)
+	}
+}
+
+// TestMarketDataSnapshotThenUpdates tests that a subscriber gets the full book and then
+// consecutively numbered level updates for adds, fills and cancels
+func TestMarketDataSnapshotThenUpdates(t *testing.T) {
+	matcher, _, url := startMarketDataServer(t)
+
+	matcher.MatchOrder(newTestOrder("AAPL", engine.SideSell, 15000, 100))
+	matcher.MatchOrder(newTestOrder("AAPL", engine.SideSell, 15000, 50))
+	matcher.MatchOrder(newTestOrder("AAPL", engine.SideSell, 15100, 200))
+	bid := newTestOrder("AAPL", engine.SideBuy, 14900, 70)
+	matcher.MatchOrder(bid)
+
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer conn.Close()
+
+	conn.WriteJSON(models.MarketDataRequest{Op: "subscribe", Symbol: "AAPL"})
+
+	var snapshot models.MarketDataSnapshot
+	readFeedMessage(t, conn, &snapshot)
+	if snapshot.Type != "snapshot" || snapshot.Sequence != 4 {
+		t.Fatalf("Expected a snapshot at seq 4, got: %+v", snapshot)
+	}
+	expectedAsks := []models.PriceLevelInfo{{Price: 15000, Quantity: 150}, {Price: 15100, Quantity: 200}}
+	if !reflect.DeepEqual(snapshot.Asks, expectedAsks) {
+		t.Errorf("Expected asks %v, got: %v", expectedAsks, snapshot.Asks)
+	}
+	if !reflect.DeepEqual(snapshot.Bids, []models.PriceLevelInfo{{Price: 14900, Quantity: 70}}) {
+		t.Errorf("Expected one bid level of 70, got: %v", snapshot.Bids)
+	}
+
+	// sweeps the first level and part of the second
+	sweep := newTestOrder("AAPL", engine.SideBuy, 15100, 180)
+	result, _ := matcher.MatchOrder(sweep)
+	matcher.CancelOrder(bid.ID)
+
+	var fill models.MarketDataUpdate
+	readFeedMessage(t, conn, &fill)
+	expectedChanges := []models.LevelChangeInfo{
+		{Side: "SELL", Price: 15000, Quantity: 0},
+		{Side: "SELL", Price: 15100, Quantity: 170},
+	}
+	if fill.Sequence != 5 || fill.CommandSequence != result.Sequence || !reflect.DeepEqual(fill.Changes, expectedChanges) {
+		t.Errorf("Expected update 5 for command %d with %v, got: %+v", result.Sequence, expectedChanges, fill)
+	}
+
+	var cancel models.MarketDataUpdate
+	readFeedMessage(t, conn, &cancel)
+	if cancel.Sequence != 6 || !reflect.DeepEqual(cancel.Changes, []models.LevelChangeInfo{{Side: "BUY", Price: 14900, Quantity: 0}}) {
+		t.Errorf("Expected update 6 removing the bid level, got: %+v", cancel)
+	}
+
+	conn.WriteJSON(models.MarketDataRequest{Op: "unsubscribe", Symbol: "AAPL"})
+	var status models.MarketDataStatus
+	readFeedMessage(t, conn, &status)
+	if status.Type != "unsubscribed" || status.Symbol != "AAPL" {
+		t.Errorf("Expected unsubscribe to be acknowledged, got: %+v", status)
+	}
+}
+
+// TestMarketDataRejectsBadRequests tests the feed's error replies and the plain HTTP guard
+func TestMarketDataRejectsBadRequests(t *testing.T) {
+	_, app, url := startMarketDataServer(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/ws/v1/marketdata", nil)
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	if resp.StatusCode != fiber.StatusUpgradeRequired {
+		t.Errorf("Expected status 426 without an upgrade, got: %d", resp.StatusCode)
+	}
+
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer conn.Close()
+
+	requests := []string{
+		`{"op":"subscribe"`,
+		`{"op":"subscribe"}`,
+		`{"op":"unsubscribe","symbol":"AAPL"}`,
+		`{"op":"trade","symbol":"AAPL"}`,
+	}
+	for _, request := range requests {
+		conn.WriteMessage(websocket.TextMessage, []byte(request))
+
+		var status models.MarketDataStatus
+		readFeedMessage(t, conn, &status)
+		if status.Type != "error" || status.Error == "" {
+			t.Errorf("Expected an error for %s, got: %+v", request, status)
+		}
+	}
+}
+
+// TestBookUpdatesRebuildBook tests that applying every update in sequence to an empty
+// book reproduces the engine's depth, including iceberg slices and amends
+func TestBookUpdatesRebuildBook(t *testing.T) {
+	listener := &recordingListener{}
+	matcher := engine.NewMatcher(engine.WithBookListener(listener))
+	defer matcher.Close()
+
+	iceberg := newTestOrder("AAPL", engine.SideSell, 15000, 300)
+	iceberg.DisplayQuantity = 50
+	matcher.MatchOrder(iceberg)
+	matcher.MatchOrder(newTestOrder("AAPL", engine.SideSell, 15000, 40))
+	amended := newTestOrder("AAPL", engine.SideBuy, 14800, 100)
+	matcher.MatchOrder(amended)
+	matcher.MatchOrder(newTestOrder("AAPL", engine.SideBuy, 14900, 60))
+	matcher.MatchOrder(newTestOrder("AAPL", engine.SideBuy, 15000, 70))
+	matcher.AmendOrder(amended.ID, 0, 80)
+	matcher.AmendOrder(amended.ID, 14950, 0)
+	matcher.MatchOrder(newTestOrder("AAPL", engine.SideSell, 14900, 90))
+	matcher.MatchOrder(engine.NewOrder("rejected", "AAPL", engine.SideBuy, engine.TypeMarket, 0, 10000))
+
+	levels := map[engine.LevelChange]int64{}
+	listener.mu.Lock()
+	for i, update := range listener.updates {
+		if update.Sequence != uint64(i+1) {
+			t.Fatalf("Expected update %d, got: %d", i+1, update.Sequence)
+		}
+		for _, change := range update.Changes {
+			key := engine.LevelChange{Side: change.Side, Price: change.Price}
+			if change.Quantity == 0 {
+				delete(levels, key)
+			} else {
+				levels[key] = change.Quantity
+			}
+		}
+	}
+	listener.mu.Unlock()
+
+	orderBook, _ := matcher.GetOrderBook("AAPL")
+	bids, asks, seq := orderBook.GetSequencedOrderBookSnapshot()
+	if seq != uint64(len(listener.updates)) {
+		t.Errorf("Expected the snapshot at the last update %d, got: %d", len(listener.updates), seq)
+	}
+
+	expected := map[engine.LevelChange]int64{}
+	for _, level := range bids {
+		expected[engine.LevelChange{Side: engine.SideBuy, Price: level.Price}] = level.Quantity
+	}
+	for _, level := range asks {
+		expected[engine.LevelChange{Side: engine.SideSell, Price: level.Price}] = level.Quantity
+	}
+	if !reflect.DeepEqual(levels, expected) {
+		t.Errorf("Expected rebuilt levels %v, got: %v", expected, levels)
+	}
+}
+
+// TestMarketDataHubDropsLaggingSubscriber tests that a full subscriber buffer closes the
+// subscription instead of blocking the publisher
+func TestMarketDataHubDropsLaggingSubscriber(t *testing.T) {
+	hub := marketdata.NewHub(2)
+	sub := hub.Subscribe("AAPL")
+	other := hub.Subscribe("MSFT")
+
+	for seq := uint64(1); seq <= 3; seq++ {
+		hub.OnBookUpdate(&engine.BookUpdate{Symbol: "AAPL", Sequence: seq})
+	}
+
+	var received []uint64
+	for update := range sub.Updates {
+		received = append(received, update.Sequence)
+	}
+	if !reflect.DeepEqual(received, []uint64{1, 2}) || !sub.Lagged() {
+		t.Errorf("Expected updates 1 and 2 then a lagged close, got: %v (lagged %v)", received, sub.Lagged())
+	}
+	if len(other.Updates) != 0 {
+		t.Errorf("Expected no updates for another symbol, got: %d", len(other.Updates))
+	}
+
+	hub.Unsubscribe(sub)
+	hub.Unsubscribe(other)
+	if _, open := <-other.Updates; open {
+		t.Error("Expected unsubscribe to close the channel")
+	}
+}
@@ -498,7 +498,7 @@ func TestTimePriority(t *testing.T) {
 	// Reference: PDF Section 3.3 Example 3, Page 3, Line 1
 	// Order-009 should remain untouched (400 shares)
 	orderBook := matcher.GetOrCreateOrderBook(symbol)
-	
+
 	// Verify the third sell order still exists with 400 shares
 	order3, exists := orderBook.GetOrder(sellOrder3.ID)
 	if !exists {
@@ -507,7 +507,7 @@ func TestTimePriority(t *testing.T) {
 	if order3.RemainingQuantity() != 400 {
 		t.Errorf("Expected third order remaining quantity 400, got: %d", order3.RemainingQuantity())
 	}
-	
+
 	// Verify best ask
 	_, qty, _ := orderBook.GetBestAsk()
 	if qty != 400 {
@@ -806,3 +806,153 @@ func TestMatchingOnNewOrderArrival(t *testing.T) {
 	}
 }
 
+// TestImmediateOrCancelPartialFill tests that an IOC order cancels its unfilled remainder
+// Verifies the remainder is not rested on the book after a partial fill
+func TestImmediateOrCancelPartialFill(t *testing.T) {
+	matcher := engine.NewMatcher()
+	symbol := "AAPL"
+
+	// Setup: SELL order at $150.50 with 300 shares
+	sellOrder := engine.NewOrder(uuid.New().String(), symbol, engine.SideSell, engine.TypeLimit, 15050, 300)
+	_, _ = matcher.MatchOrder(sellOrder)
+
+	// IOC BUY order at $150.50 with 500 shares (only 300 available)
+	iocOrder := engine.NewOrder(uuid.New().String(), symbol, engine.SideBuy, engine.TypeLimit, 15050, 500)
+	iocOrder.TimeInForce = engine.TIFIOC
+	result, err := matcher.MatchOrder(iocOrder)
+
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if result.Status != engine.StatusCancelled {
+		t.Errorf("Expected status CANCELLED, got: %s", result.Status)
+	}
+
+	if result.FilledQuantity != 300 {
+		t.Errorf("Expected filled quantity 300, got: %d", result.FilledQuantity)
+	}
+
+	if result.RemainingQuantity != 200 {
+		t.Errorf("Expected remaining quantity 200, got: %d", result.RemainingQuantity)
+	}
+
+	// Verify remainder did not rest on the book
+	orderBook := matcher.GetOrCreateOrderBook(symbol)
+	if _, exists := orderBook.GetOrder(iocOrder.ID); exists {
+		t.Error("IOC order should not rest on the book")
+	}
+
+	if _, _, hasBid := orderBook.GetBestBid(); hasBid {
+		t.Error("Expected no bids after IOC order")
+	}
+}
+
+// TestImmediateOrCancelNoMatch tests an IOC order that cannot match at all
+// Verifies the whole order is cancelled without trading
+func TestImmediateOrCancelNoMatch(t *testing.T) {
+	matcher := engine.NewMatcher()
+	symbol := "AAPL"
+
+	sellOrder := engine.NewOrder(uuid.New().String(), symbol, engine.SideSell, engine.TypeLimit, 15060, 100)
+	_, _ = matcher.MatchOrder(sellOrder)
+
+	// IOC BUY order below the best ask
+	iocOrder := engine.NewOrder(uuid.New().String(), symbol, engine.SideBuy, engine.TypeLimit, 15050, 100)
+	iocOrder.TimeInForce = engine.TIFIOC
+	result, err := matcher.MatchOrder(iocOrder)
+
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if result.Status != engine.StatusCancelled {
+		t.Errorf("Expected status CANCELLED, got: %s", result.Status)
+	}
+
+	if len(result.Trades) != 0 {
+		t.Errorf("Expected no trades, got: %d", len(result.Trades))
+	}
+
+	if iocOrder.GetStatus() != engine.StatusCancelled {
+		t.Errorf("Expected order status CANCELLED, got: %s", iocOrder.GetStatus())
+	}
+}
+
+// TestFillOrKillRejected tests that a FOK order is rejected when it cannot fill completely
+// Only liquidity at or better than the limit price counts towards the fill
+func TestFillOrKillRejected(t *testing.T) {
+	matcher := engine.NewMatcher()
+	symbol := "AAPL"
+
+	// Setup: 300 shares at $150.50, 500 shares at $150.60 (beyond the limit)
+	sellOrder1 := engine.NewOrder(uuid.New().String(), symbol, engine.SideSell, engine.TypeLimit, 15050, 300)
+	_, _ = matcher.MatchOrder(sellOrder1)
+
+	sellOrder2 := engine.NewOrder(uuid.New().String(), symbol, engine.SideSell, engine.TypeLimit, 15060, 500)
+	_, _ = matcher.MatchOrder(sellOrder2)
+
+	fokOrder := engine.NewOrder(uuid.New().String(), symbol, engine.SideBuy, engine.TypeLimit, 15055, 500)
+	fokOrder.TimeInForce = engine.TIFFOK
+	result, err := matcher.MatchOrder(fokOrder)
+
+	if err == nil {
+		t.Fatal("Expected error for FOK order that cannot fill completely")
+	}
+
+	liquidityErr, ok := err.(*engine.InsufficientLiquidityError)
+	if !ok {
+		t.Fatalf("Expected InsufficientLiquidityError, got: %T", err)
+	}
+
+	if liquidityErr.Available != 300 {
+		t.Errorf("Expected available quantity 300, got: %d", liquidityErr.Available)
+	}
+
+	if result != nil {
+		t.Error("Expected nil result for rejected FOK order")
+	}
+
+	// Verify no trades happened against the book
+	orderBook := matcher.GetOrCreateOrderBook(symbol)
+	price, qty, _ := orderBook.GetBestAsk()
+	if price != 15050 || qty != 300 {
+		t.Errorf("Expected best ask 15050 x 300 untouched, got: %d x %d", price, qty)
+	}
+
+	if fokOrder.GetStatus() != engine.StatusRejected {
+		t.Errorf("Expected order status REJECTED, got: %s", fokOrder.GetStatus())
+	}
+}
+
+// TestFillOrKillFilled tests that a FOK order with enough liquidity fills across price levels
+func TestFillOrKillFilled(t *testing.T) {
+	matcher := engine.NewMatcher()
+	symbol := "AAPL"
+
+	sellOrder1 := engine.NewOrder(uuid.New().String(), symbol, engine.SideSell, engine.TypeLimit, 15050, 300)
+	_, _ = matcher.MatchOrder(sellOrder1)
+
+	sellOrder2 := engine.NewOrder(uuid.New().String(), symbol, engine.SideSell, engine.TypeLimit, 15055, 300)
+	_, _ = matcher.MatchOrder(sellOrder2)
+
+	fokOrder := engine.NewOrder(uuid.New().String(), symbol, engine.SideBuy, engine.TypeLimit, 15055, 500)
+	fokOrder.TimeInForce = engine.TIFFOK
+	result, err := matcher.MatchOrder(fokOrder)
+
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if result.Status != engine.StatusFilled {
+		t.Errorf("Expected status FILLED, got: %s", result.Status)
+	}
+
+	if result.FilledQuantity != 500 {
+		t.Errorf("Expected filled quantity 500, got: %d", result.FilledQuantity)
+	}
+
+	if len(result.Trades) != 2 {
+		t.Errorf("Expected 2 trades, got: %d", len(result.Trades))
+	}
+}
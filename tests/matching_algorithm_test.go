@@ -0,0 +1,156 @@
+package tests
+
+import (
+	"testing"
+
+	"match-engine/src/engine"
+)
+
+// newAlgorithmMatcher trades AAPL in lots of lotSize with the given matching algorithm
+func newAlgorithmMatcher(t *testing.T, algorithm engine.MatchingAlgorithmName, lotSize, minAllocation int64) (*engine.Matcher, *engine.InstrumentRegistry) {
+	registry := engine.NewInstrumentRegistry(nil)
+	_, err := registry.Create(engine.Instrument{
+		Symbol: "AAPL", TickSize: 1, LotSize: lotSize, PricePrecision: 2,
+		MatchingAlgorithm: algorithm, MinAllocation: minAllocation,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create instrument: %v", err)
+	}
+	matcher := engine.NewMatcher(engine.WithInstruments(registry))
+	t.Cleanup(matcher.Close)
+	return matcher, registry
+}
+
+// filledBy sums the quantity each of the given resting orders traded
+func filledBy(trades []*engine.Trade, orders ...*engine.Order) []int64 {
+	filled := make([]int64, len(orders))
+	for _, trade := range trades {
+		for i, order := range orders {
+			if trade.BuyOrderID == order.ID || trade.SellOrderID == order.ID {
+				filled[i] += trade.Quantity
+			}
+		}
+	}
+	return filled
+}
+
+// TestMatchingAlgorithmFillDistribution tests how each algorithm shares the buy of 500
+// from TestTimePriority among the sells of 200, 300 and 400 resting at one price
+func TestMatchingAlgorithmFillDistribution(t *testing.T) {
+	testCases := []struct {
+		name          string
+		algorithm     engine.MatchingAlgorithmName
+		lotSize       int64
+		minAllocation int64
+		expected      []int64
+	}{
+		{"fifo", engine.AlgorithmFIFO, 1, 0, []int64{200, 300, 0}},
+		{"fifo top order", engine.AlgorithmFIFOTopOrder, 1, 0, []int64{200, 300, 0}},
+		// 111.1, 166.7 and 222.2 round down to 499, the spare unit goes to the oldest
+		{"pro-rata", engine.AlgorithmProRata, 1, 0, []int64{112, 166, 222}},
+		// shares round down to lots of 10, the spare lot goes to the oldest
+		{"pro-rata lots", engine.AlgorithmProRata, 10, 0, []int64{120, 160, 220}},
+		// 111 and 166 are below the minimum, the 278 they leave go out in time priority
+		{"pro-rata minimum allocation", engine.AlgorithmProRata, 1, 200, []int64{200, 78, 222}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			matcher, _ := newAlgorithmMatcher(t, tc.algorithm, tc.lotSize, tc.minAllocation)
+
+			sells := []*engine.Order{
+				newTestOrder("AAPL", engine.SideSell, 15050, 200),
+				newTestOrder("AAPL", engine.SideSell, 15050, 300),
+				newTestOrder("AAPL", engine.SideSell, 15050, 400),
+			}
+			for _, sell := range sells {
+				matcher.MatchOrder(sell)
+			}
+
+			result, err := matcher.MatchOrder(newTestOrder("AAPL", engine.SideBuy, 15050, 500))
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if result.Status != engine.StatusFilled || result.FilledQuantity != 500 {
+				t.Errorf("Expected the buy filled, got: %s with %d", result.Status, result.FilledQuantity)
+			}
+
+			filled := filledBy(result.Trades, sells...)
+			for i := range sells {
+				if filled[i] != tc.expected[i] {
+					t.Errorf("Expected fills %v, got: %v", tc.expected, filled)
+					break
+				}
+				if remaining := sells[i].RemainingQuantity(); remaining != sells[i].Quantity-tc.expected[i] {
+					t.Errorf("Expected sell %d to have %d left, got: %d", i, sells[i].Quantity-tc.expected[i], remaining)
+				}
+			}
+		})
+	}
+}
+
+// TestMatchingAlgorithmPriceLevels tests that every algorithm keeps price priority: the
+// sweep from TestMultiplePriceLevels fills the same levels whichever shares them out
+func TestMatchingAlgorithmPriceLevels(t *testing.T) {
+	for _, algorithm := range []engine.MatchingAlgorithmName{engine.AlgorithmFIFO, engine.AlgorithmProRata, engine.AlgorithmFIFOTopOrder} {
+		t.Run(string(algorithm), func(t *testing.T) {
+			matcher, _ := newAlgorithmMatcher(t, algorithm, 1, 0)
+
+			matcher.MatchOrder(newTestOrder("AAPL", engine.SideSell, 15050, 300))
+			matcher.MatchOrder(newTestOrder("AAPL", engine.SideSell, 15052, 400))
+			matcher.MatchOrder(newTestOrder("AAPL", engine.SideSell, 15055, 600))
+
+			result, _ := matcher.MatchOrder(newTestOrder("AAPL", engine.SideBuy, 15053, 800))
+			if result.FilledQuantity != 700 || result.Status != engine.StatusPartialFill || len(result.Trades) != 2 {
+				t.Fatalf("Expected 700 filled in 2 trades, got: %d in %d (%s)", result.FilledQuantity, len(result.Trades), result.Status)
+			}
+			if result.Trades[0].Price != 15050 || result.Trades[0].Quantity != 300 || result.Trades[1].Price != 15052 || result.Trades[1].Quantity != 400 {
+				t.Errorf("Expected 300 at 15050 then 400 at 15052, got: %+v %+v", result.Trades[0], result.Trades[1])
+			}
+		})
+	}
+}
+
+// TestMatchingAlgorithmTopOrder tests that the order that opened the best price is filled
+// first up to its visible quantity, and keeps that priority through a snapshot
+func TestMatchingAlgorithmTopOrder(t *testing.T) {
+	testCases := []struct {
+		algorithm engine.MatchingAlgorithmName
+		expected  []int64
+	}{
+		// the iceberg's replenished slice goes behind the second sell
+		{engine.AlgorithmFIFO, []int64{0, 150}},
+		{engine.AlgorithmFIFOTopOrder, []int64{100, 50}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(string(tc.algorithm), func(t *testing.T) {
+			matcher, registry := newAlgorithmMatcher(t, tc.algorithm, 1, 0)
+
+			top := newTestOrder("AAPL", engine.SideSell, 15050, 600)
+			top.DisplayQuantity = 100
+			matcher.MatchOrder(top)
+			second := newTestOrder("AAPL", engine.SideSell, 15050, 300)
+			matcher.MatchOrder(second)
+
+			// edge case: a restored book keeps its top order
+			restored := engine.NewMatcher(engine.WithInstruments(registry))
+			defer restored.Close()
+			for _, state := range matcher.Snapshot() {
+				if err := restored.Restore(state); err != nil {
+					t.Fatalf("Restore failed: %v", err)
+				}
+			}
+
+			for _, m := range []*engine.Matcher{matcher, restored} {
+				// the first buy takes the iceberg's first slice under either algorithm
+				m.MatchOrder(newTestOrder("AAPL", engine.SideBuy, 15050, 100))
+				result, _ := m.MatchOrder(newTestOrder("AAPL", engine.SideBuy, 15050, 150))
+				filled := filledBy(result.Trades, top, second)
+				if filled[0] != tc.expected[0] || filled[1] != tc.expected[1] {
+					t.Errorf("Expected fills %v, got: %v", tc.expected, filled)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package tests
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"match-engine/src/engine"
+)
+
+// TestMatcherGetOrderAcrossSymbols tests that orders are found by ID alone, whichever book holds them
+func TestMatcherGetOrderAcrossSymbols(t *testing.T) {
+	matcher := engine.NewMatcher()
+	defer matcher.Close()
+
+	symbols := []string{"AAPL", "GOOGL", "MSFT", "TSLA"}
+	orders := make([]*engine.Order, 0, len(symbols))
+	for _, symbol := range symbols {
+		order := engine.NewOrder(uuid.New().String(), symbol, engine.SideBuy, engine.TypeLimit, 10000, 100)
+		if _, err := matcher.MatchOrder(order); err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		orders = append(orders, order)
+	}
+
+	for _, order := range orders {
+		found, exists := matcher.GetOrder(order.ID)
+		if !exists {
+			t.Fatalf("Expected order %s to be found", order.ID)
+		}
+		if found.Symbol != order.Symbol {
+			t.Errorf("Expected symbol %s, got: %s", order.Symbol, found.Symbol)
+		}
+	}
+
+	if _, exists := matcher.GetOrder(uuid.New().String()); exists {
+		t.Error("Expected unknown order ID not to be found")
+	}
+}
+
+// TestMatcherCancelOrderByID tests cancelling by ID only, including orders that are no longer cancellable
+func TestMatcherCancelOrderByID(t *testing.T) {
+	matcher := engine.NewMatcher()
+	defer matcher.Close()
+
+	resting := engine.NewOrder(uuid.New().String(), "AAPL", engine.SideSell, engine.TypeLimit, 15050, 100)
+	_, _ = matcher.MatchOrder(resting)
+
+	result, err := matcher.CancelOrder(resting.ID)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if result.Order.GetStatus() != engine.StatusCancelled {
+		t.Errorf("Expected CANCELLED, got: %s", result.Order.GetStatus())
+	}
+
+	// edge case: a cancelled order cannot be cancelled again
+	if _, err := matcher.CancelOrder(resting.ID); err == nil {
+		t.Error("Expected second cancel to fail")
+	} else if _, ok := err.(*engine.OrderNotCancellableError); !ok {
+		t.Errorf("Expected OrderNotCancellableError, got: %v", err)
+	}
+
+	filled := engine.NewOrder(uuid.New().String(), "AAPL", engine.SideSell, engine.TypeLimit, 15050, 100)
+	_, _ = matcher.MatchOrder(filled)
+	aggressor := engine.NewOrder(uuid.New().String(), "AAPL", engine.SideBuy, engine.TypeLimit, 15050, 100)
+	_, _ = matcher.MatchOrder(aggressor)
+
+	_, err = matcher.CancelOrder(filled.ID)
+	if _, ok := err.(*engine.OrderNotCancellableError); !ok {
+		t.Errorf("Expected OrderNotCancellableError for filled resting order, got: %v", err)
+	}
+
+	if _, err = matcher.CancelOrder(uuid.New().String()); err == nil {
+		t.Error("Expected cancel of unknown order to fail")
+	} else if _, ok := err.(*engine.OrderNotFoundError); !ok {
+		t.Errorf("Expected OrderNotFoundError, got: %v", err)
+	}
+}
+
+// TestCancelRacesWithMatch tests that a cancel racing a match either wins before any fill
+// or loses to a fill, never leaving an order both cancelled and traded past its remainder
+func TestCancelRacesWithMatch(t *testing.T) {
+	for i := 0; i < 200; i++ {
+		matcher := engine.NewMatcher()
+
+		resting := engine.NewOrder(uuid.New().String(), "AAPL", engine.SideSell, engine.TypeLimit, 15050, 100)
+		_, _ = matcher.MatchOrder(resting)
+
+		var wg sync.WaitGroup
+		var matchResult *engine.MatchResult
+		var cancelErr error
+
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			aggressor := engine.NewOrder(uuid.New().String(), "AAPL", engine.SideBuy, engine.TypeLimit, 15050, 100)
+			matchResult, _ = matcher.MatchOrder(aggressor)
+		}()
+		go func() {
+			defer wg.Done()
+			_, cancelErr = matcher.CancelOrder(resting.ID)
+		}()
+		wg.Wait()
+
+		if cancelErr == nil {
+			if len(matchResult.Trades) != 0 {
+				t.Fatalf("Order was cancelled but still traded %d times", len(matchResult.Trades))
+			}
+			if resting.GetFilledQuantity() != 0 {
+				t.Fatalf("Cancelled order has filled quantity %d", resting.GetFilledQuantity())
+			}
+		} else {
+			if resting.GetStatus() != engine.StatusFilled {
+				t.Fatalf("Cancel failed but order is %s", resting.GetStatus())
+			}
+		}
+
+		matcher.Close()
+	}
+}
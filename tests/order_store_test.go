@@ -0,0 +1,149 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"match-engine/src/engine"
+	"match-engine/src/models"
+)
+
+// TestTerminalOrdersRetained tests that filled, cancelled and rejected orders stay queryable with their fills
+func TestTerminalOrdersRetained(t *testing.T) {
+	matcher := engine.NewMatcher()
+	defer matcher.Close()
+
+	sell := engine.NewOrder(uuid.New().String(), "AAPL", engine.SideSell, engine.TypeLimit, 15050, 100)
+	_, _ = matcher.MatchOrder(sell)
+	buy := engine.NewOrder(uuid.New().String(), "AAPL", engine.SideBuy, engine.TypeLimit, 15050, 100)
+	_, _ = matcher.MatchOrder(buy)
+
+	for _, order := range []*engine.Order{sell, buy} {
+		found, exists := matcher.GetOrder(order.ID)
+		if !exists {
+			t.Fatalf("Expected filled order %s to be retained", order.ID)
+		}
+		if found.GetStatus() != engine.StatusFilled {
+			t.Errorf("Expected FILLED, got: %s", found.GetStatus())
+		}
+		fills := found.Fills()
+		if len(fills) != 1 || fills[0].Quantity != 100 || fills[0].Price != 15050 {
+			t.Errorf("Expected one fill of 100 @ 15050, got: %+v", fills)
+		}
+	}
+
+	fok := engine.NewOrder(uuid.New().String(), "AAPL", engine.SideBuy, engine.TypeLimit, 15050, 100)
+	fok.TimeInForce = engine.TIFFOK
+	_, _ = matcher.MatchOrder(fok)
+	if found, exists := matcher.GetOrder(fok.ID); !exists || found.GetStatus() != engine.StatusRejected {
+		t.Errorf("Expected rejected fill-or-kill order to be retained as REJECTED")
+	}
+
+	resting := engine.NewOrder(uuid.New().String(), "AAPL", engine.SideBuy, engine.TypeLimit, 15000, 100)
+	_, _ = matcher.MatchOrder(resting)
+	_, _ = matcher.CancelOrder(resting.ID)
+	if found, exists := matcher.GetOrder(resting.ID); !exists || found.GetStatus() != engine.StatusCancelled {
+		t.Errorf("Expected cancelled order to be retained as CANCELLED")
+	}
+}
+
+// TestRetentionPolicyMaxOrders tests that the oldest terminal orders are evicted past the count limit
+func TestRetentionPolicyMaxOrders(t *testing.T) {
+	matcher := engine.NewMatcher(engine.WithRetentionPolicy(engine.RetentionPolicy{MaxOrders: 2}))
+	defer matcher.Close()
+
+	orders := make([]*engine.Order, 3)
+	for i := range orders {
+		orders[i] = engine.NewOrder(uuid.New().String(), "AAPL", engine.SideBuy, engine.TypeLimit, 15000, 100)
+		_, _ = matcher.MatchOrder(orders[i])
+		_, _ = matcher.CancelOrder(orders[i].ID)
+	}
+
+	if _, exists := matcher.GetOrder(orders[0].ID); exists {
+		t.Error("Expected oldest terminal order to be evicted")
+	}
+	for _, order := range orders[1:] {
+		if _, exists := matcher.GetOrder(order.ID); !exists {
+			t.Errorf("Expected order %s to be retained", order.ID)
+		}
+	}
+
+	// edge case: an evicted order is unknown again, not "already cancelled"
+	if _, err := matcher.CancelOrder(orders[0].ID); err == nil {
+		t.Error("Expected cancel of evicted order to fail")
+	} else if _, ok := err.(*engine.OrderNotFoundError); !ok {
+		t.Errorf("Expected OrderNotFoundError, got: %v", err)
+	}
+}
+
+// TestRetentionPolicyMaxAge tests that terminal orders older than the age limit are no longer returned
+func TestRetentionPolicyMaxAge(t *testing.T) {
+	matcher := engine.NewMatcher(engine.WithRetentionPolicy(engine.RetentionPolicy{MaxAge: 20 * time.Millisecond}))
+	defer matcher.Close()
+
+	order := engine.NewOrder(uuid.New().String(), "AAPL", engine.SideBuy, engine.TypeLimit, 15000, 100)
+	_, _ = matcher.MatchOrder(order)
+	_, _ = matcher.CancelOrder(order.ID)
+
+	if _, exists := matcher.GetOrder(order.ID); !exists {
+		t.Fatal("Expected cancelled order to be retained within the age limit")
+	}
+
+	time.Sleep(40 * time.Millisecond)
+
+	if _, exists := matcher.GetOrder(order.ID); exists {
+		t.Error("Expected cancelled order to expire from retention")
+	}
+}
+
+// TestGetFilledOrderStatusAPI tests that GET /api/v1/orders/:id reports a filled aggressor with its fills
+func TestGetFilledOrderStatusAPI(t *testing.T) {
+	app := setupTestServer()
+
+	for _, side := range []string{"SELL", "BUY"} {
+		reqBody := map[string]interface{}{
+			"symbol":   "AAPL",
+			"side":     side,
+			"type":     "LIMIT",
+			"price":    15050,
+			"quantity": 100,
+		}
+		body, _ := json.Marshal(reqBody)
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/orders", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+
+		var submitResult models.SubmitOrderResponse
+		json.NewDecoder(resp.Body).Decode(&submitResult)
+		if side == "SELL" {
+			continue
+		}
+
+		req = httptest.NewRequest(http.MethodGet, "/api/v1/orders/"+submitResult.OrderID, nil)
+		resp, err = app.Test(req)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Expected status 200 for filled order, got: %d", resp.StatusCode)
+		}
+
+		var statusResult models.OrderStatusResponse
+		json.NewDecoder(resp.Body).Decode(&statusResult)
+		if statusResult.Status != "FILLED" {
+			t.Errorf("Expected status FILLED, got: %s", statusResult.Status)
+		}
+		if len(statusResult.Fills) != 1 || statusResult.Fills[0].Quantity != 100 {
+			t.Errorf("Expected one fill of 100, got: %+v", statusResult.Fills)
+		}
+	}
+}
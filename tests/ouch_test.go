@@ -0,0 +1,342 @@
+package tests
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"match-engine/src/accounts"
+	"match-engine/src/engine"
+	"match-engine/src/executions"
+	"match-engine/src/ouch"
+)
+
+// ouchClient is the client side of an OUCH connection
+type ouchClient struct {
+	t      testing.TB
+	conn   net.Conn
+	reader *bufio.Reader
+	in     []byte
+	out    []byte
+}
+
+type ouchEncoder interface {
+	Encode(buf []byte) []byte
+}
+
+// ouchTokens authenticates the OUCH test accounts, each with its name and "-token"
+var ouchTokens = accounts.NewTokens(map[string]string{
+	"bench": "bench-token", "desk-1": "desk-1-token", "maker": "maker-token", "taker": "taker-token",
+})
+
+// startOUCHServer serves a fresh engine over OUCH on a local port
+func startOUCHServer(t testing.TB) (*engine.Matcher, *ouch.Server) {
+	hub := executions.NewHub(0)
+	matcher := engine.NewMatcher(engine.WithExecutionListener(hub))
+	server := ouch.NewServer(matcher, hub, ouchTokens)
+	if err := server.Listen("127.0.0.1:0"); err != nil {
+		t.Fatalf("Failed to start OUCH server: %v", err)
+	}
+	t.Cleanup(func() {
+		server.Close()
+		matcher.Close()
+	})
+	return matcher, server
+}
+
+func dialOUCH(t testing.TB, server *ouch.Server, account string) *ouchClient {
+	t.Helper()
+	conn, err := net.Dial("tcp", server.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	c := &ouchClient{
+		t:      t,
+		conn:   conn,
+		reader: bufio.NewReader(conn),
+		in:     make([]byte, ouch.MaxMessageLength),
+		out:    make([]byte, 0, ouch.MaxMessageLength),
+	}
+	c.send(&ouch.Login{Account: ouch.NewAccount(account), Password: ouch.NewPassword(account + "-token")})
+	var accepted ouch.LoginAccepted
+	c.expect(&accepted)
+	if accepted.Account.String() != account {
+		t.Fatalf("Expected login accepted for %s, got %q", account, accepted.Account.String())
+	}
+	return c
+}
+
+func (c *ouchClient) send(m ouchEncoder) {
+	c.t.Helper()
+	if _, err := c.conn.Write(m.Encode(c.out[:0])); err != nil {
+		c.t.Fatalf("Failed to send: %v", err)
+	}
+}
+
+// read returns the next message, type byte first
+func (c *ouchClient) read() []byte {
+	c.t.Helper()
+	c.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	msg, err := ouch.ReadMessage(c.reader, c.in)
+	if err != nil {
+		c.t.Fatalf("Failed to read: %v", err)
+	}
+	return msg
+}
+
+// expect reads the next message into m, failing if it is of another type
+func (c *ouchClient) expect(m interface{ Decode([]byte) error }) {
+	c.t.Helper()
+	msg := c.read()
+	if err := m.Decode(msg); err != nil {
+		c.t.Fatalf("Unexpected message %q: %v", msg[0], err)
+	}
+}
+
+func (c *ouchClient) enter(token string, side byte, price int64, quantity uint32, timeInForce byte) {
+	c.send(&ouch.EnterOrder{
+		Token:       ouch.NewToken(token),
+		Side:        side,
+		Type:        ouch.TypeLimit,
+		TimeInForce: timeInForce,
+		Symbol:      ouch.NewSymbol("AAPL"),
+		Price:       price,
+		Quantity:    quantity,
+	})
+}
+
+// TestOUCHMessageRoundTrip tests that every message decodes to what was encoded, without
+// allocating
+func TestOUCHMessageRoundTrip(t *testing.T) {
+	buf := make([]byte, 0, ouch.MaxMessageLength)
+
+	enter := ouch.EnterOrder{
+		Token: ouch.NewToken("T1"), Side: ouch.SideBuy, Type: ouch.TypeLimit, TimeInForce: ouch.TimeInForceIOC,
+		Symbol: ouch.NewSymbol("AAPL"), Price: 15025, Quantity: 300, DisplayQuantity: 100, SelfTradePrevention: ouch.SelfTradeCancelOldest,
+	}
+	var decodedEnter ouch.EnterOrder
+	if err := decodedEnter.Decode(enter.Encode(buf[:0])[2:]); err != nil || decodedEnter != enter {
+		t.Errorf("EnterOrder round trip: got %+v, %v", decodedEnter, err)
+	}
+
+	executed := ouch.Executed{
+		Timestamp: 1735689600123456789, Token: ouch.NewToken("T1"), TradeID: ouch.NewID("AAPL-42-0"),
+		Quantity: 100, Price: 15000, LeavesQuantity: 200,
+	}
+	var decodedExecuted ouch.Executed
+	if err := decodedExecuted.Decode(executed.Encode(buf[:0])[2:]); err != nil || decodedExecuted != executed {
+		t.Errorf("Executed round trip: got %+v, %v", decodedExecuted, err)
+	}
+	if decodedExecuted.TradeID.String() != "AAPL-42-0" || decodedExecuted.Token.String() != "T1" {
+		t.Errorf("Expected padding trimmed, got %q and %q", decodedExecuted.TradeID.String(), decodedExecuted.Token.String())
+	}
+
+	login := ouch.Login{Account: ouch.NewAccount("desk-1"), Password: ouch.NewPassword("desk-1-token")}
+	var decodedLogin ouch.Login
+	if err := decodedLogin.Decode(login.Encode(buf[:0])[2:]); err != nil || decodedLogin != login {
+		t.Errorf("Login round trip: got %+v, %v", decodedLogin, err)
+	}
+
+	replace := ouch.ReplaceOrder{ExistingToken: ouch.NewToken("T1"), ReplacementToken: ouch.NewToken("T2"), Price: 15100, Quantity: 500}
+	var decodedReplace ouch.ReplaceOrder
+	if err := decodedReplace.Decode(replace.Encode(buf[:0])[2:]); err != nil || decodedReplace != replace {
+		t.Errorf("ReplaceOrder round trip: got %+v, %v", decodedReplace, err)
+	}
+
+	// edge case: a message of another type is refused rather than misread
+	if err := decodedReplace.Decode(enter.Encode(buf[:0])[2:]); err == nil {
+		t.Error("Expected an EnterOrder not to decode as a ReplaceOrder")
+	}
+
+	allocs := testing.AllocsPerRun(100, func() {
+		decodedEnter.Decode(enter.Encode(buf[:0])[2:])
+		decodedExecuted.Decode(executed.Encode(buf[:0])[2:])
+	})
+	if allocs != 0 {
+		t.Errorf("Expected no allocations, got %.0f per run", allocs)
+	}
+}
+
+// TestOUCHOrderLifecycle tests enter, fills of incoming and resting orders, an IOC
+// remainder, replace and cancel
+func TestOUCHOrderLifecycle(t *testing.T) {
+	matcher, server := startOUCHServer(t)
+	maker := dialOUCH(t, server, "maker")
+	taker := dialOUCH(t, server, "taker")
+
+	maker.enter("S1", ouch.SideSell, 15000, 100, ouch.TimeInForceGTC)
+	var accepted ouch.Accepted
+	maker.expect(&accepted)
+	if accepted.Token.String() != "S1" || accepted.Side != ouch.SideSell || accepted.Symbol.String() != "AAPL" || accepted.Price != 15000 || accepted.Quantity != 100 {
+		t.Fatalf("Unexpected accepted: %+v", accepted)
+	}
+	sellID := accepted.OrderID.String()
+	if order, exists := matcher.GetOrder(sellID); !exists || order.Account != "maker" {
+		t.Fatalf("Expected order %s on the book for maker", sellID)
+	}
+
+	// an IOC buy for 160 takes the 100 on offer and cancels the rest
+	taker.enter("B1", ouch.SideBuy, 15000, 160, ouch.TimeInForceIOC)
+	taker.expect(&accepted)
+	var executed ouch.Executed
+	taker.expect(&executed)
+	if executed.Token.String() != "B1" || executed.Quantity != 100 || executed.Price != 15000 || executed.LeavesQuantity != 60 {
+		t.Fatalf("Unexpected taker fill: %+v", executed)
+	}
+	var cancelled ouch.Cancelled
+	taker.expect(&cancelled)
+	if cancelled.Token.String() != "B1" || cancelled.Quantity != 60 || cancelled.Reason != ouch.CancelIOC {
+		t.Fatalf("Unexpected IOC cancel: %+v", cancelled)
+	}
+
+	var makerFill ouch.Executed
+	maker.expect(&makerFill)
+	if makerFill.Token.String() != "S1" || makerFill.TradeID != executed.TradeID || makerFill.LeavesQuantity != 0 {
+		t.Fatalf("Unexpected maker fill: %+v", makerFill)
+	}
+
+	// replace, then the old token no longer names the order
+	maker.enter("S2", ouch.SideSell, 15100, 200, ouch.TimeInForceGTC)
+	maker.expect(&accepted)
+	maker.send(&ouch.ReplaceOrder{ExistingToken: ouch.NewToken("S2"), ReplacementToken: ouch.NewToken("S3"), Price: 15050, Quantity: 300})
+	var replaced ouch.Replaced
+	maker.expect(&replaced)
+	if replaced.Token.String() != "S3" || replaced.PreviousToken.String() != "S2" || replaced.Price != 15050 || replaced.Quantity != 300 || replaced.LeavesQuantity != 300 {
+		t.Fatalf("Unexpected replaced: %+v", replaced)
+	}
+
+	maker.send(&ouch.CancelOrder{Token: ouch.NewToken("S2")})
+	var cancelReject ouch.CancelReject
+	maker.expect(&cancelReject)
+	if cancelReject.Reason != ouch.RejectUnknownOrder {
+		t.Fatalf("Expected unknown order reject, got %+v", cancelReject)
+	}
+
+	maker.send(&ouch.CancelOrder{Token: ouch.NewToken("S3")})
+	maker.expect(&cancelled)
+	if cancelled.Token.String() != "S3" || cancelled.Quantity != 300 || cancelled.Reason != ouch.CancelUserRequested {
+		t.Fatalf("Unexpected cancel: %+v", cancelled)
+	}
+
+	maker.send(&ouch.CancelOrder{Token: ouch.NewToken("S3")})
+	maker.expect(&cancelReject)
+	if cancelReject.Reason != ouch.RejectUnknownOrder {
+		t.Fatalf("Expected cancel of a done order rejected, got %+v", cancelReject)
+	}
+}
+
+// TestOUCHSelfTradePrevention tests that an order's self-trade prevention mode is taken
+// from EnterOrder
+func TestOUCHSelfTradePrevention(t *testing.T) {
+	_, server := startOUCHServer(t)
+	client := dialOUCH(t, server, "desk-1")
+
+	client.enter("S1", ouch.SideSell, 15000, 100, ouch.TimeInForceGTC)
+	var accepted ouch.Accepted
+	client.expect(&accepted)
+
+	client.send(&ouch.EnterOrder{
+		Token: ouch.NewToken("B1"), Side: ouch.SideBuy, Type: ouch.TypeLimit, TimeInForce: ouch.TimeInForceGTC,
+		Symbol: ouch.NewSymbol("AAPL"), Price: 15000, Quantity: 100, SelfTradePrevention: ouch.SelfTradeCancelNewest,
+	})
+	client.expect(&accepted)
+	var cancelled ouch.Cancelled
+	client.expect(&cancelled)
+	if cancelled.Token.String() != "B1" || cancelled.Quantity != 100 || cancelled.Reason != ouch.CancelSelfTrade {
+		t.Fatalf("Expected the incoming order cancelled by self-trade prevention, got %+v", cancelled)
+	}
+
+	// without a mode the account has none, so the orders trade
+	client.enter("B2", ouch.SideBuy, 15000, 100, ouch.TimeInForceGTC)
+	client.expect(&accepted)
+	var executed ouch.Executed
+	client.expect(&executed)
+	if executed.Token.String() != "B2" || executed.Quantity != 100 {
+		t.Fatalf("Expected the orders to trade, got %+v", executed)
+	}
+}
+
+// TestOUCHRejects tests that orders the engine cannot take are rejected with a reason
+func TestOUCHRejects(t *testing.T) {
+	matcher, server := startOUCHServer(t)
+	client := dialOUCH(t, server, "desk-1")
+
+	client.enter("A", ouch.SideBuy, 15000, 10, ouch.TimeInForceGTC)
+	var accepted ouch.Accepted
+	client.expect(&accepted)
+
+	tests := []struct {
+		order  ouch.EnterOrder
+		reason byte
+	}{
+		{ouch.EnterOrder{Token: ouch.NewToken("A"), Side: ouch.SideBuy, Type: ouch.TypeLimit, TimeInForce: ouch.TimeInForceGTC, Symbol: ouch.NewSymbol("AAPL"), Price: 100, Quantity: 1}, ouch.RejectDuplicateToken},
+		{ouch.EnterOrder{Token: ouch.NewToken("B"), Side: 'X', Type: ouch.TypeLimit, TimeInForce: ouch.TimeInForceGTC, Symbol: ouch.NewSymbol("AAPL"), Price: 100, Quantity: 1}, ouch.RejectInvalidSide},
+		{ouch.EnterOrder{Token: ouch.NewToken("C"), Side: ouch.SideBuy, Type: ouch.TypeLimit, TimeInForce: ouch.TimeInForceGTC, Price: 100, Quantity: 1}, ouch.RejectInvalidSymbol},
+		{ouch.EnterOrder{Token: ouch.NewToken("D"), Side: ouch.SideBuy, Type: ouch.TypeLimit, TimeInForce: ouch.TimeInForceGTC, Symbol: ouch.NewSymbol("AAPL"), Quantity: 1}, ouch.RejectInvalidPrice},
+		{ouch.EnterOrder{Token: ouch.NewToken("E"), Side: ouch.SideBuy, Type: ouch.TypeLimit, TimeInForce: ouch.TimeInForceGTC, Symbol: ouch.NewSymbol("AAPL"), Price: 100}, ouch.RejectInvalidQuantity},
+		{ouch.EnterOrder{Token: ouch.NewToken("F"), Side: ouch.SideBuy, Type: ouch.TypeLimit, TimeInForce: 'Z', Symbol: ouch.NewSymbol("AAPL"), Price: 100, Quantity: 1}, ouch.RejectInvalidTimeInForce},
+		{ouch.EnterOrder{Token: ouch.NewToken("G"), Side: ouch.SideSell, Type: ouch.TypeMarket, TimeInForce: ouch.TimeInForceGTC, Symbol: ouch.NewSymbol("AAPL"), Quantity: 50}, ouch.RejectInsufficientLiquidity},
+		{ouch.EnterOrder{Token: ouch.NewToken("I"), Side: ouch.SideBuy, Type: ouch.TypeLimit, TimeInForce: ouch.TimeInForceGTC, Symbol: ouch.NewSymbol("AAPL"), Price: 100, Quantity: 1, SelfTradePrevention: 'Z'}, ouch.RejectInvalidSelfTrade},
+	}
+	for _, tt := range tests {
+		client.send(&tt.order)
+		var rejected ouch.Rejected
+		client.expect(&rejected)
+		if rejected.Token != tt.order.Token || rejected.Reason != tt.reason {
+			t.Errorf("Order %s: expected reason %q, got %+v", tt.order.Token, tt.reason, rejected)
+		}
+	}
+
+	matcher.Halt("AAPL", "news pending", false)
+	client.enter("H", ouch.SideBuy, 15000, 10, ouch.TimeInForceGTC)
+	var halted ouch.Rejected
+	client.expect(&halted)
+	if halted.Reason != ouch.RejectHalted {
+		t.Errorf("Expected reason %q for a halted symbol, got %+v", ouch.RejectHalted, halted)
+	}
+
+	// edge case: a connection that does not log in first is dropped
+	cancel := ouch.CancelOrder{Token: ouch.NewToken("A")}
+	expectOUCHDropped(t, server, &cancel)
+}
+
+// TestOUCHLoginRequiresPassword tests that a login without its account's token is
+// dropped before a session opens
+func TestOUCHLoginRequiresPassword(t *testing.T) {
+	_, server := startOUCHServer(t)
+
+	tests := []struct {
+		name  string
+		login ouch.Login
+	}{
+		{"no password", ouch.Login{Account: ouch.NewAccount("maker")}},
+		{"wrong password", ouch.Login{Account: ouch.NewAccount("maker"), Password: ouch.NewPassword("guess")}},
+		{"another account's password", ouch.Login{Account: ouch.NewAccount("maker"), Password: ouch.NewPassword("taker-token")}},
+		{"unknown account", ouch.Login{Account: ouch.NewAccount("desk-9"), Password: ouch.NewPassword("desk-9-token")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expectOUCHDropped(t, server, &tt.login)
+		})
+	}
+}
+
+// expectOUCHDropped sends m as the first message of a new connection and expects the
+// connection closed without an answer
+func expectOUCHDropped(t *testing.T, server *ouch.Server, m ouchEncoder) {
+	t.Helper()
+	conn, err := net.Dial("tcp", server.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer conn.Close()
+	conn.Write(m.Encode(nil))
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := conn.Read(make([]byte, 1)); err != io.EOF {
+		t.Errorf("Expected the connection closed, got %v", err)
+	}
+}
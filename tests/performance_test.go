@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"sort"
@@ -13,6 +15,7 @@ import (
 	"time"
 
 	"match-engine/src/models"
+	"match-engine/src/ouch"
 )
 
 // PerformanceMetrics tracks performance metrics during testing
@@ -567,6 +570,67 @@ func BenchmarkOrderMatching(b *testing.B) {
 	})
 }
 
+// BenchmarkSubmitOrderRoundTrip compares the round trip of a resting limit order over the
+// HTTP SubmitOrder path against OUCH, from sending the order to reading its acknowledgement
+func BenchmarkSubmitOrderRoundTrip(b *testing.B) {
+	b.Run("HTTP", func(b *testing.B) {
+		app := setupTestServer()
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			b.Fatalf("Failed to listen: %v", err)
+		}
+		go app.Listener(listener)
+		defer app.Shutdown()
+
+		url := "http://" + listener.Addr().String() + "/api/v1/orders"
+		client := &http.Client{Timeout: 5 * time.Second}
+		body, _ := json.Marshal(models.SubmitOrderRequest{Symbol: "AAPL", Side: "BUY", Type: "LIMIT", Price: 15000, Quantity: 100})
+		metrics := &PerformanceMetrics{}
+
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			start := time.Now()
+			resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+			if err != nil {
+				b.Fatalf("Request failed: %v", err)
+			}
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusCreated {
+				b.Fatalf("Expected 201, got %d", resp.StatusCode)
+			}
+			metrics.AddLatency(time.Since(start))
+		}
+		reportRoundTrip(b, metrics)
+	})
+
+	b.Run("OUCH", func(b *testing.B) {
+		_, server := startOUCHServer(b)
+		client := dialOUCH(b, server, "bench")
+		order := ouch.EnterOrder{
+			Side: ouch.SideBuy, Type: ouch.TypeLimit, TimeInForce: ouch.TimeInForceGTC,
+			Symbol: ouch.NewSymbol("AAPL"), Price: 15000, Quantity: 100,
+		}
+		var accepted ouch.Accepted
+		metrics := &PerformanceMetrics{}
+
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			order.Token = ouch.NewToken(fmt.Sprintf("B%d", i))
+			start := time.Now()
+			client.send(&order)
+			client.expect(&accepted)
+			metrics.AddLatency(time.Since(start))
+		}
+		reportRoundTrip(b, metrics)
+	})
+}
+
+func reportRoundTrip(b *testing.B, metrics *PerformanceMetrics) {
+	b.ReportMetric(float64(metrics.GetPercentile(50).Microseconds()), "p50-us")
+	b.ReportMetric(float64(metrics.GetPercentile(99).Microseconds()), "p99-us")
+}
+
 // PrintPerformanceReport prints a formatted performance report
 func PrintPerformanceReport(metrics *PerformanceMetrics, testName string) {
 	stats := metrics.GetStats()
@@ -592,4 +656,3 @@ func PrintPerformanceReport(metrics *PerformanceMetrics, testName string) {
 	}
 	fmt.Println()
 }
-
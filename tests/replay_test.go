@@ -0,0 +1,165 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"os/exec"
+	"strconv"
+	"testing"
+	"time"
+
+	"match-engine/src/engine"
+	"match-engine/src/journal"
+)
+
+// fixedClock reports the same instant until moved, for exact timestamps in tests
+type fixedClock struct {
+	now time.Time
+}
+
+func (c *fixedClock) Now() time.Time {
+	return c.now
+}
+
+// runRecordedSession drives a journaled matcher through a few crossing orders and
+// returns its books as JSON
+func runRecordedSession(t *testing.T, dir string) []byte {
+	j := openJournal(t, journal.DefaultOptions(dir))
+	defer j.Close()
+	matcher := engine.NewMatcher(engine.WithCommandLog(j))
+	defer matcher.Close()
+
+	for i := int64(0); i < 5; i++ {
+		matcher.MatchOrder(newTestOrder("AAPL", engine.SideSell, 15000+i*10, 40))
+		matcher.MatchOrder(newTestOrder("MSFT", engine.SideBuy, 40000-i*10, 40))
+	}
+	aggressor := newTestOrder("AAPL", engine.SideBuy, 15030, 150)
+	matcher.MatchOrder(aggressor)
+	matcher.CancelOrder(aggressor.ID)
+	matcher.MatchOrder(newTestOrder("MSFT", engine.SideSell, 39980, 100))
+
+	data, err := json.Marshal(matcher.Snapshot())
+	if err != nil {
+		t.Fatalf("Failed to encode books: %v", err)
+	}
+	return data
+}
+
+// TestReplayReproducesTradesExactly tests that replaying a journal under a different clock
+// reproduces the live trade IDs, timestamps and book state exactly
+func TestReplayReproducesTradesExactly(t *testing.T) {
+	dir := t.TempDir()
+	live := runRecordedSession(t, dir)
+
+	// edge case: the replaying engine's own clock must not leak into replayed trades
+	matcher := engine.NewMatcher(engine.WithClock(&fixedClock{now: time.Unix(0, 0)}))
+	defer matcher.Close()
+	if _, err := journal.Scan(dir, 0, matcher.Replay); err != nil {
+		t.Fatalf("Replay failed: %v", err)
+	}
+
+	replayed, _ := json.Marshal(matcher.Snapshot())
+	if !bytes.Equal(live, replayed) {
+		t.Errorf("Expected replayed books to match live books byte for byte\nlive:     %s\nreplayed: %s", live, replayed)
+	}
+}
+
+// TestCommandsStampedWithInjectedClock tests that trades take their time from the matcher's clock
+func TestCommandsStampedWithInjectedClock(t *testing.T) {
+	clock := &fixedClock{now: time.Date(2024, 3, 15, 14, 30, 0, 0, time.UTC)}
+	matcher := engine.NewMatcher(engine.WithClock(clock))
+	defer matcher.Close()
+
+	matcher.MatchOrder(newTestOrder("AAPL", engine.SideSell, 15000, 10))
+	result, _ := matcher.MatchOrder(newTestOrder("AAPL", engine.SideBuy, 15000, 10))
+	if len(result.Trades) != 1 || result.Trades[0].Timestamp != clock.now.UnixNano() {
+		t.Fatalf("Expected one trade stamped %d, got: %+v", clock.now.UnixNano(), result.Trades)
+	}
+
+	expectedID := engine.UUIDGenerator{}.TradeID(engine.TradeKey{
+		Symbol:    "AAPL",
+		Sequence:  result.Sequence,
+		Timestamp: clock.now.UnixNano(),
+		Match:     1,
+	})
+	if result.Trades[0].TradeID != expectedID {
+		t.Errorf("Expected trade ID %s, got: %s", expectedID, result.Trades[0].TradeID)
+	}
+}
+
+// TestReplayToolStopsAtSequence tests the cmd/replay binary end to end
+func TestReplayToolStopsAtSequence(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds cmd/replay")
+	}
+	dir := t.TempDir()
+	runRecordedSession(t, dir)
+
+	cmd := exec.Command("go", "run", "../cmd/replay", "-journal", dir, "-symbol", "AAPL", "-until-seq", strconv.Itoa(3))
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("replay failed: %v", err)
+	}
+
+	var dump struct {
+		Commands int                 `json:"commands"`
+		Books    []*engine.BookState `json:"books"`
+	}
+	if err := json.Unmarshal(output, &dump); err != nil {
+		t.Fatalf("Failed to decode dump: %v\n%s", err, output)
+	}
+	if dump.Commands != 3 || len(dump.Books) != 1 {
+		t.Fatalf("Expected 3 commands and 1 book, got: %d and %d", dump.Commands, len(dump.Books))
+	}
+	if book := dump.Books[0]; book.Symbol != "AAPL" || book.Sequence != 3 || len(book.Asks) != 3 {
+		t.Errorf("Expected AAPL at seq 3 with 3 ask levels, got: %s seq %d with %d", book.Symbol, book.Sequence, len(book.Asks))
+	}
+}
+
+// TestReplayReproducesProRataBook tests that replay matches with the algorithm the book
+// traded under, including a change made mid-stream, whatever the replaying registry says
+func TestReplayReproducesProRataBook(t *testing.T) {
+	dir := t.TempDir()
+	j := openJournal(t, journal.DefaultOptions(dir))
+	registry := engine.NewInstrumentRegistry(nil)
+	registry.Create(engine.Instrument{
+		Symbol: "AAPL", TickSize: 1, LotSize: 1, PricePrecision: 2,
+		MatchingAlgorithm: engine.AlgorithmProRata,
+	})
+	matcher := engine.NewMatcher(engine.WithCommandLog(j), engine.WithInstruments(registry))
+
+	var sells []*engine.Order
+	for _, quantity := range []int64{200, 300, 400, 200, 300} {
+		sell := newTestOrder("AAPL", engine.SideSell, 15050, quantity)
+		sells = append(sells, sell)
+		matcher.MatchOrder(sell)
+	}
+	result, _ := matcher.MatchOrder(newTestOrder("AAPL", engine.SideBuy, 15050, 500))
+	if filled := filledBy(result.Trades, sells[0], sells[1], sells[2]); filled[2] == 0 {
+		t.Fatalf("Expected the buy shared pro rata, got fills: %v", filled)
+	}
+	if _, err := matcher.UpdateInstrument("AAPL", func(i *engine.Instrument) {
+		i.MatchingAlgorithm = engine.AlgorithmFIFO
+	}); err != nil {
+		t.Fatalf("Failed to update instrument: %v", err)
+	}
+	matcher.MatchOrder(newTestOrder("AAPL", engine.SideBuy, 15050, 150))
+
+	live, _ := json.Marshal(matcher.Snapshot())
+	matcher.Close()
+	j.Close()
+
+	// edge case: the replaying registry lists the symbol FIFO, the journal must win
+	fifo := engine.NewInstrumentRegistry(nil)
+	fifo.Create(engine.Instrument{Symbol: "AAPL", TickSize: 1, LotSize: 1, PricePrecision: 2})
+	replayer := engine.NewMatcher(engine.WithInstruments(fifo))
+	defer replayer.Close()
+	if _, err := journal.Scan(dir, 0, replayer.Replay); err != nil {
+		t.Fatalf("Replay failed: %v", err)
+	}
+
+	replayed, _ := json.Marshal(replayer.Snapshot())
+	if !bytes.Equal(live, replayed) {
+		t.Errorf("Expected replayed books to match live books byte for byte\nlive:     %s\nreplayed: %s", live, replayed)
+	}
+}
@@ -0,0 +1,340 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"match-engine/src/accounts"
+	"match-engine/src/engine"
+	"match-engine/src/handlers"
+	"match-engine/src/journal"
+	"match-engine/src/models"
+	"match-engine/src/routes"
+)
+
+func newAccountOrder(account string, side engine.OrderSide, price, quantity int64) *engine.Order {
+	order := newTestOrder("AAPL", side, price, quantity)
+	order.Account = account
+	return order
+}
+
+// TestSelfTradePreventionModes tests what each mode does when a buy of 100 meets a resting
+// sell of 60 from its own account, queued ahead of another account's sell of 100
+func TestSelfTradePreventionModes(t *testing.T) {
+	testCases := []struct {
+		mode              engine.SelfTradePrevention
+		status            engine.OrderStatus
+		filled            int64
+		incomingCancelled bool
+		restingCancelled  bool
+		restingLeft       int64 // quantity of the own sell left on the book
+	}{
+		{engine.STPCancelNewest, engine.StatusCancelled, 0, true, false, 60},
+		{engine.STPCancelOldest, engine.StatusFilled, 100, false, true, 0},
+		{engine.STPCancelBoth, engine.StatusCancelled, 0, true, true, 0},
+		{engine.STPDecrementAndCancel, engine.StatusFilled, 40, false, true, 0},
+		{engine.STPNone, engine.StatusFilled, 100, false, false, 0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(string(tc.mode), func(t *testing.T) {
+			listener := &recordingExecutions{}
+			matcher := engine.NewMatcher(engine.WithExecutionListener(listener))
+			defer matcher.Close()
+
+			own := newAccountOrder("desk-7", engine.SideSell, 15000, 60)
+			matcher.MatchOrder(own)
+			other := newAccountOrder("desk-9", engine.SideSell, 15000, 100)
+			matcher.MatchOrder(other)
+
+			buy := newAccountOrder("desk-7", engine.SideBuy, 15000, 100)
+			buy.SelfTradePrevention = tc.mode
+			result, err := matcher.MatchOrder(buy)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if result.Status != tc.status || result.FilledQuantity != tc.filled {
+				t.Errorf("Expected %s with %d filled, got: %s with %d", tc.status, tc.filled, result.Status, result.FilledQuantity)
+			}
+			for _, trade := range result.Trades {
+				if tc.mode != engine.STPNone && trade.SellOrderID == own.ID {
+					t.Errorf("Expected no trade with the account's own order, got: %+v", trade)
+				}
+			}
+
+			if tc.mode == engine.STPNone {
+				if len(result.PreventedMatches) != 0 {
+					t.Fatalf("Expected no prevented match, got: %d", len(result.PreventedMatches))
+				}
+				return
+			}
+			if len(result.PreventedMatches) != 1 {
+				t.Fatalf("Expected 1 prevented match, got: %d", len(result.PreventedMatches))
+			}
+			prevented := result.PreventedMatches[0]
+			if prevented.Mode != tc.mode || prevented.IncomingOrderID != buy.ID || prevented.RestingOrderID != own.ID ||
+				prevented.Account != "desk-7" || prevented.Price != 15000 || prevented.Quantity != 60 {
+				t.Errorf("Unexpected prevented match: %+v", prevented)
+			}
+			if prevented.IncomingCancelled != tc.incomingCancelled || prevented.RestingCancelled != tc.restingCancelled {
+				t.Errorf("Expected cancelled incoming %v resting %v, got: %+v", tc.incomingCancelled, tc.restingCancelled, prevented)
+			}
+
+			left := int64(0)
+			if resting, exists := matcher.GetOrder(own.ID); exists && !resting.GetStatus().IsTerminal() {
+				left = resting.RemainingQuantity()
+			}
+			if left != tc.restingLeft {
+				t.Errorf("Expected %d of the own sell left, got: %d", tc.restingLeft, left)
+			}
+
+			cancelled := make(map[string]bool)
+			for _, report := range listener.take() {
+				if report.ExecType == engine.ExecCancelled && report.SelfTrade {
+					cancelled[report.OrderID] = true
+				}
+			}
+			if cancelled[buy.ID] != tc.incomingCancelled || cancelled[own.ID] != tc.restingCancelled {
+				t.Errorf("Expected self-trade cancel reports incoming %v resting %v, got: %v", tc.incomingCancelled, tc.restingCancelled, cancelled)
+			}
+		})
+	}
+}
+
+// TestSelfTradePreventionDecrement tests that decrement-and-cancel reduces the larger
+// order by the smaller one's quantity and leaves the reduced resting order in place
+func TestSelfTradePreventionDecrement(t *testing.T) {
+	matcher := engine.NewMatcher()
+	defer matcher.Close()
+
+	own := newAccountOrder("desk-7", engine.SideSell, 15000, 100)
+	matcher.MatchOrder(own)
+
+	buy := newAccountOrder("desk-7", engine.SideBuy, 15000, 30)
+	buy.SelfTradePrevention = engine.STPDecrementAndCancel
+	result, _ := matcher.MatchOrder(buy)
+
+	if result.Status != engine.StatusCancelled || len(result.Trades) != 0 {
+		t.Fatalf("Expected the smaller incoming order cancelled without trades, got: %s with %d trades", result.Status, len(result.Trades))
+	}
+	if !buy.SelfTradeCancelled() {
+		t.Errorf("Expected the incoming order to be marked as cancelled by self-trade prevention")
+	}
+	resting, _ := matcher.GetOrder(own.ID)
+	if resting.Quantity != 70 || resting.GetStatus() != engine.StatusAccepted {
+		t.Errorf("Expected the resting order reduced to 70 and still open, got: %d %s", resting.Quantity, resting.GetStatus())
+	}
+	_, asks := matcher.GetOrCreateOrderBook("AAPL").GetOrderBookSnapshot(10)
+	if len(asks) != 1 || asks[0].Quantity != 70 {
+		t.Errorf("Expected one ask level showing 70, got: %+v", asks)
+	}
+}
+
+// TestSelfTradePreventionAccountDefault tests that an account's default mode applies to
+// orders that do not choose one, and that NONE opts out of it
+func TestSelfTradePreventionAccountDefault(t *testing.T) {
+	matcher := engine.NewMatcher(engine.WithAccountSelfTradePrevention(map[string]engine.SelfTradePrevention{
+		"desk-7": engine.STPCancelNewest,
+	}))
+	defer matcher.Close()
+
+	matcher.MatchOrder(newAccountOrder("desk-7", engine.SideSell, 15000, 100))
+
+	buy := newAccountOrder("desk-7", engine.SideBuy, 15000, 50)
+	result, _ := matcher.MatchOrder(buy)
+	if buy.SelfTradePrevention != engine.STPCancelNewest {
+		t.Errorf("Expected the account default on the order, got: %q", buy.SelfTradePrevention)
+	}
+	if result.Status != engine.StatusCancelled || len(result.PreventedMatches) != 1 {
+		t.Errorf("Expected the order cancelled by the account default, got: %s with %d prevented", result.Status, len(result.PreventedMatches))
+	}
+
+	optOut := newAccountOrder("desk-7", engine.SideBuy, 15000, 50)
+	optOut.SelfTradePrevention = engine.STPNone
+	result, _ = matcher.MatchOrder(optOut)
+	if result.Status != engine.StatusFilled {
+		t.Errorf("Expected NONE to trade with the account's own order, got: %s", result.Status)
+	}
+
+	// edge case: orders without an account have no owner to protect
+	anonymous := newTestOrder("AAPL", engine.SideBuy, 15000, 50)
+	anonymous.SelfTradePrevention = engine.STPCancelNewest
+	matcher.MatchOrder(newTestOrder("AAPL", engine.SideSell, 15000, 50))
+	result, _ = matcher.MatchOrder(anonymous)
+	if result.Status != engine.StatusFilled || len(result.PreventedMatches) != 0 {
+		t.Errorf("Expected orders without an account to trade, got: %s with %d prevented", result.Status, len(result.PreventedMatches))
+	}
+}
+
+// TestSelfTradePreventionMarketOrder tests that a market order stopped by its own
+// liquidity fills what it can and cancels the rest
+func TestSelfTradePreventionMarketOrder(t *testing.T) {
+	matcher := engine.NewMatcher()
+	defer matcher.Close()
+
+	matcher.MatchOrder(newAccountOrder("desk-9", engine.SideSell, 15000, 40))
+	own := newAccountOrder("desk-7", engine.SideSell, 15100, 60)
+	matcher.MatchOrder(own)
+
+	market := engine.NewOrder("market-1", "AAPL", engine.SideBuy, engine.TypeMarket, 0, 100)
+	market.Account = "desk-7"
+	market.SelfTradePrevention = engine.STPCancelOldest
+	result, err := matcher.MatchOrder(market)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.Status != engine.StatusCancelled || result.FilledQuantity != 40 || result.RemainingQuantity != 60 {
+		t.Errorf("Expected 40 filled and the rest cancelled, got: %s %d/%d", result.Status, result.FilledQuantity, result.RemainingQuantity)
+	}
+	if resting, _ := matcher.GetOrder(own.ID); resting.GetStatus() != engine.StatusCancelled {
+		t.Errorf("Expected the resting order cancelled, got: %s", resting.GetStatus())
+	}
+}
+
+// TestSelfTradePreventionFillOrKill tests that a fill-or-kill order does not count its
+// account's own orders as liquidity, and never rests
+func TestSelfTradePreventionFillOrKill(t *testing.T) {
+	testCases := []struct {
+		name    string
+		mode    engine.SelfTradePrevention
+		sells   []*engine.Order
+		filled  int64 // 0 when the order is rejected
+		ownLeft bool  // whether the own sell is still on the book
+	}{
+		{
+			"own liquidity is cancelled, not traded", engine.STPCancelOldest,
+			[]*engine.Order{newAccountOrder("desk-7", engine.SideSell, 15000, 60), newAccountOrder("desk-9", engine.SideSell, 15000, 20)},
+			0, true,
+		},
+		{
+			"other liquidity fills it", engine.STPCancelOldest,
+			[]*engine.Order{newAccountOrder("desk-7", engine.SideSell, 15000, 60), newAccountOrder("desk-9", engine.SideSell, 15000, 100)},
+			80, false,
+		},
+		{
+			"own liquidity ends it before the rest", engine.STPCancelNewest,
+			[]*engine.Order{newAccountOrder("desk-9", engine.SideSell, 14900, 20), newAccountOrder("desk-7", engine.SideSell, 15000, 60), newAccountOrder("desk-9", engine.SideSell, 15000, 60)},
+			0, true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			matcher := engine.NewMatcher()
+			defer matcher.Close()
+
+			for _, sell := range tc.sells {
+				matcher.MatchOrder(sell)
+			}
+			fok := newAccountOrder("desk-7", engine.SideBuy, 15000, 80)
+			fok.TimeInForce = engine.TIFFOK
+			fok.SelfTradePrevention = tc.mode
+			result, err := matcher.MatchOrder(fok)
+
+			if tc.filled == 0 {
+				var insufficient *engine.InsufficientLiquidityError
+				if !errors.As(err, &insufficient) {
+					t.Fatalf("Expected the order rejected for insufficient liquidity, got: %v", err)
+				}
+			} else if err != nil || result.Status != engine.StatusFilled || result.FilledQuantity != tc.filled {
+				t.Fatalf("Expected %d filled, got: %+v %v", tc.filled, result, err)
+			}
+			orderBook, _ := matcher.GetOrderBook("AAPL")
+			if price, quantity, ok := orderBook.GetBestBid(); ok {
+				t.Errorf("Expected nothing resting on the bid, got %d@%d", quantity, price)
+			}
+			for _, sell := range tc.sells {
+				if sell.Account == fok.Account && (sell.GetStatus() != engine.StatusCancelled) != tc.ownLeft {
+					t.Errorf("Expected the own sell left on the book: %v, got: %s", tc.ownLeft, sell.GetStatus())
+				}
+			}
+		})
+	}
+}
+
+// TestSelfTradePreventionReplay tests that a replay applies the mode the order was
+// journaled with, even without the account defaults
+func TestSelfTradePreventionReplay(t *testing.T) {
+	dir := t.TempDir()
+	j := openJournal(t, journal.DefaultOptions(dir))
+	matcher := engine.NewMatcher(engine.WithCommandLog(j), engine.WithAccountSelfTradePrevention(map[string]engine.SelfTradePrevention{
+		"desk-7": engine.STPDecrementAndCancel,
+	}))
+	matcher.MatchOrder(newAccountOrder("desk-7", engine.SideSell, 15000, 100))
+	matcher.MatchOrder(newAccountOrder("desk-9", engine.SideSell, 15000, 50))
+	matcher.MatchOrder(newAccountOrder("desk-7", engine.SideBuy, 15000, 120))
+	live, _ := json.Marshal(matcher.Snapshot())
+	matcher.Close()
+	j.Close()
+
+	replayed := engine.NewMatcher()
+	defer replayed.Close()
+	if _, err := journal.Scan(dir, 0, replayed.Replay); err != nil {
+		t.Fatalf("Replay failed: %v", err)
+	}
+
+	books, _ := json.Marshal(replayed.Snapshot())
+	if !bytes.Equal(live, books) {
+		t.Errorf("Expected replayed books to match live books\nlive:     %s\nreplayed: %s", live, books)
+	}
+}
+
+// TestSelfTradePreventionAPI tests the self_trade_prevention field of a REST submission
+func TestSelfTradePreventionAPI(t *testing.T) {
+	t.Setenv("RATE_LIMIT_DISABLED", "1")
+	matcher := engine.NewMatcher()
+	defer matcher.Close()
+	orderHandler := handlers.NewOrderHandler(matcher)
+	orderHandler.Tokens = accounts.NewTokens(map[string]string{"desk-7": "desk-7-token"})
+	app := fiber.New()
+	routes.SetupRoutes(app, orderHandler)
+
+	submit := func(body map[string]interface{}) (int, models.SubmitOrderResponse) {
+		t.Helper()
+		data, _ := json.Marshal(body)
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/orders", bytes.NewReader(data))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer desk-7-token")
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		var response models.SubmitOrderResponse
+		json.NewDecoder(resp.Body).Decode(&response)
+		return resp.StatusCode, response
+	}
+
+	status, sell := submit(map[string]interface{}{
+		"symbol": "AAPL", "side": "SELL", "type": "LIMIT", "price": 15000, "quantity": 100, "account": "desk-7",
+	})
+	if status != http.StatusCreated {
+		t.Fatalf("Expected 201 for the resting sell, got: %d", status)
+	}
+
+	status, _ = submit(map[string]interface{}{
+		"symbol": "AAPL", "side": "BUY", "type": "LIMIT", "price": 15000, "quantity": 100, "account": "desk-7",
+		"self_trade_prevention": "CANCEL_SOMETIMES",
+	})
+	if status != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an unknown mode, got: %d", status)
+	}
+
+	status, buy := submit(map[string]interface{}{
+		"symbol": "AAPL", "side": "BUY", "type": "LIMIT", "price": 15000, "quantity": 100, "account": "desk-7",
+		"self_trade_prevention": "CANCEL_NEWEST",
+	})
+	if status != http.StatusOK || buy.Status != "CANCELLED" || buy.Message != "Unfilled quantity cancelled (self-trade prevention)" {
+		t.Fatalf("Unexpected response %d: %+v", status, buy)
+	}
+	if len(buy.PreventedMatches) != 1 || buy.PreventedMatches[0].RestingOrderID != sell.OrderID ||
+		buy.PreventedMatches[0].Mode != "CANCEL_NEWEST" || !buy.PreventedMatches[0].IncomingCancelled {
+		t.Errorf("Unexpected prevented matches: %+v", buy.PreventedMatches)
+	}
+}
@@ -0,0 +1,162 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"match-engine/src/engine"
+	"match-engine/src/models"
+)
+
+// TestSequenceNumbersPerSymbol tests that every command on a symbol gets the next
+// sequence number of that symbol, independently of other symbols
+func TestSequenceNumbersPerSymbol(t *testing.T) {
+	matcher := engine.NewMatcher()
+	defer matcher.Close()
+
+	sell := engine.NewOrder(uuid.New().String(), "AAPL", engine.SideSell, engine.TypeLimit, 15050, 100)
+	result, err := matcher.MatchOrder(sell)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if result.Sequence != 1 {
+		t.Errorf("Expected AAPL sequence 1, got: %d", result.Sequence)
+	}
+
+	other := engine.NewOrder(uuid.New().String(), "GOOGL", engine.SideBuy, engine.TypeLimit, 280000, 10)
+	result, err = matcher.MatchOrder(other)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if result.Sequence != 1 {
+		t.Errorf("Expected GOOGL sequence 1, got: %d", result.Sequence)
+	}
+
+	result, err = matcher.AmendOrder(sell.ID, 0, 50)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if result.Sequence != 2 {
+		t.Errorf("Expected AAPL sequence 2 after amend, got: %d", result.Sequence)
+	}
+
+	cancelResult, err := matcher.CancelOrder(sell.ID)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if cancelResult.Sequence != 3 {
+		t.Errorf("Expected AAPL sequence 3 after cancel, got: %d", cancelResult.Sequence)
+	}
+	if sell.GetStatus() != engine.StatusCancelled {
+		t.Errorf("Expected order CANCELLED, got: %s", sell.GetStatus())
+	}
+
+	// edge case: rejected commands still consume a sequence number
+	fok := engine.NewOrder(uuid.New().String(), "AAPL", engine.SideBuy, engine.TypeLimit, 15050, 100)
+	fok.TimeInForce = engine.TIFFOK
+	if _, err = matcher.MatchOrder(fok); err == nil {
+		t.Error("Expected fill-or-kill order to be rejected")
+	}
+	if seq := matcher.GetOrCreateOrderBook("AAPL").LastSequence(); seq != 4 {
+		t.Errorf("Expected AAPL last sequence 4, got: %d", seq)
+	}
+}
+
+// TestSequencerConcurrentSubmissions tests that concurrent submissions on one symbol
+// are processed one at a time with unique, gap-free sequence numbers
+func TestSequencerConcurrentSubmissions(t *testing.T) {
+	matcher := engine.NewMatcher()
+	defer matcher.Close()
+
+	numGoroutines := 20
+	ordersPerGoroutine := 50
+
+	var mu sync.Mutex
+	seen := make(map[uint64]bool)
+
+	var wg sync.WaitGroup
+	for i := 0; i < numGoroutines; i++ {
+		wg.Add(1)
+		go func(goroutineID int) {
+			defer wg.Done()
+
+			for j := 0; j < ordersPerGoroutine; j++ {
+				side := engine.SideBuy
+				if (goroutineID+j)%2 == 0 {
+					side = engine.SideSell
+				}
+				order := engine.NewOrder(uuid.New().String(), "AAPL", side, engine.TypeLimit, 15050+int64(j%3), 10)
+				result, err := matcher.MatchOrder(order)
+				if err != nil {
+					t.Errorf("Expected no error, got: %v", err)
+					return
+				}
+
+				mu.Lock()
+				if seen[result.Sequence] {
+					t.Errorf("Sequence %d assigned twice", result.Sequence)
+				}
+				seen[result.Sequence] = true
+				mu.Unlock()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	total := uint64(numGoroutines * ordersPerGoroutine)
+	for seq := uint64(1); seq <= total; seq++ {
+		if !seen[seq] {
+			t.Errorf("Missing sequence number %d", seq)
+		}
+	}
+	if last := matcher.GetOrCreateOrderBook("AAPL").LastSequence(); last != total {
+		t.Errorf("Expected last sequence %d, got: %d", total, last)
+	}
+}
+
+// TestSubmitOrderReturnsSequence tests that the REST responses carry the sequence number
+func TestSubmitOrderReturnsSequence(t *testing.T) {
+	app := setupTestServer()
+
+	reqBody := map[string]interface{}{
+		"symbol":   "AAPL",
+		"side":     "BUY",
+		"type":     "LIMIT",
+		"price":    15050,
+		"quantity": 100,
+	}
+	body, _ := json.Marshal(reqBody)
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/orders", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+
+	var submitResult models.SubmitOrderResponse
+	json.NewDecoder(resp.Body).Decode(&submitResult)
+	if submitResult.Sequence != 1 {
+		t.Errorf("Expected sequence 1, got: %d", submitResult.Sequence)
+	}
+
+	req = httptest.NewRequest(http.MethodDelete, "/api/v1/orders/"+submitResult.OrderID, nil)
+	resp, err = app.Test(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200, got: %d", resp.StatusCode)
+	}
+
+	var cancelResult models.CancelOrderResponse
+	json.NewDecoder(resp.Body).Decode(&cancelResult)
+	if cancelResult.Sequence != 2 {
+		t.Errorf("Expected sequence 2, got: %d", cancelResult.Sequence)
+	}
+}
@@ -0,0 +1,355 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+
+	"match-engine/src/engine"
+	"match-engine/src/handlers"
+	"match-engine/src/journal"
+	"match-engine/src/models"
+	"match-engine/src/routes"
+)
+
+// newScheduledMatcher trades AAPL on a UTC day with every session, on clock
+func newScheduledMatcher(t *testing.T, clock engine.Clock, opts ...engine.MatcherOption) *engine.Matcher {
+	registry := engine.NewInstrumentRegistry(nil)
+	_, err := registry.Create(engine.Instrument{
+		Symbol: "AAPL", TickSize: 1, LotSize: 1,
+		Schedule: &engine.TradingSchedule{
+			PreOpen: "08:00", OpeningAuction: "09:00", Continuous: "09:30", ClosingAuction: "16:00", Close: "16:30",
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create instrument: %v", err)
+	}
+	matcher := engine.NewMatcher(append(opts, engine.WithClock(clock), engine.WithInstruments(registry))...)
+	t.Cleanup(matcher.Close)
+	return matcher
+}
+
+func expectSessionError(t *testing.T, err error, session engine.TradingSession) {
+	t.Helper()
+	if sessionErr, ok := err.(*engine.SessionError); !ok || sessionErr.Session != session {
+		t.Errorf("Expected a SessionError in %s, got %v", session, err)
+	}
+}
+
+// TestTradingSessions tests that a symbol follows its schedule on the engine clock and
+// that each session accepts only its commands
+func TestTradingSessions(t *testing.T) {
+	day := time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC)
+	clock := &fixedClock{now: day.Add(10 * time.Hour)}
+	matcher := newScheduledMatcher(t, clock)
+
+	resting := newTestOrder("AAPL", engine.SideSell, 15100, 100)
+	if _, err := matcher.MatchOrder(resting); err != nil {
+		t.Fatalf("Expected an order during continuous trading to be accepted: %v", err)
+	}
+	early := newTestOrder("AAPL", engine.SideSell, 15300, 10)
+	matcher.MatchOrder(early)
+	orderBook, _ := matcher.GetOrderBook("AAPL")
+	if session := orderBook.Session(); session != engine.SessionContinuous {
+		t.Fatalf("Expected CONTINUOUS, got %s", session)
+	}
+
+	// closed overnight: nothing is accepted, not even a cancel
+	clock.now = day.Add(17 * time.Hour)
+	changes, err := matcher.UpdateSessions()
+	if err != nil || len(changes) != 1 || changes[0].From != engine.SessionContinuous || changes[0].To != engine.SessionClosed {
+		t.Fatalf("Expected one change to CLOSED, got %+v, %v", changes, err)
+	}
+	rejected := newTestOrder("AAPL", engine.SideBuy, 15000, 10)
+	_, err = matcher.MatchOrder(rejected)
+	expectSessionError(t, err, engine.SessionClosed)
+	if rejected.GetStatus() != engine.StatusRejected {
+		t.Errorf("Expected the order REJECTED, got %s", rejected.GetStatus())
+	}
+	_, err = matcher.CancelOrder(resting.ID)
+	expectSessionError(t, err, engine.SessionClosed)
+	if changes, _ := matcher.UpdateSessions(); len(changes) != 0 {
+		t.Errorf("Expected no change within a session, got %+v", changes)
+	}
+
+	// pre-open, reached by the order itself before the scheduler runs: cancels only
+	clock.now = day.Add(24*time.Hour + 8*time.Hour + 30*time.Minute)
+	_, err = matcher.MatchOrder(newTestOrder("AAPL", engine.SideBuy, 15000, 10))
+	expectSessionError(t, err, engine.SessionPreOpen)
+	_, err = matcher.AmendOrder(resting.ID, 15050, 0)
+	expectSessionError(t, err, engine.SessionPreOpen)
+	if _, err := matcher.CancelOrder(early.ID); err != nil {
+		t.Errorf("Expected a cancel during pre-open to succeed: %v", err)
+	}
+
+	// opening auction: orders collect without matching
+	clock.now = day.Add(24*time.Hour + 9*time.Hour + 10*time.Minute)
+	if changes, _ := matcher.UpdateSessions(); len(changes) != 1 || changes[0].To != engine.SessionOpeningAuction {
+		t.Fatalf("Expected a change to OPENING_AUCTION, got %+v", changes)
+	}
+	if result, err := matcher.MatchOrder(newTestOrder("AAPL", engine.SideBuy, 15200, 60)); err != nil || len(result.Trades) != 0 {
+		t.Fatalf("Expected the buy to wait for the uncross, got %+v, %v", result, err)
+	}
+	cancelled := newTestOrder("AAPL", engine.SideSell, 15200, 10)
+	if _, err := matcher.MatchOrder(cancelled); err != nil {
+		t.Fatalf("MatchOrder failed: %v", err)
+	}
+	if _, err := matcher.CancelOrder(cancelled.ID); err != nil {
+		t.Errorf("Expected a cancel during the auction to succeed: %v", err)
+	}
+	_, err = matcher.Uncross("AAPL")
+	expectSessionError(t, err, engine.SessionOpeningAuction)
+
+	// continuous trading opens with the uncross
+	clock.now = day.Add(24*time.Hour + 9*time.Hour + 30*time.Minute)
+	changes, _ = matcher.UpdateSessions()
+	if len(changes) != 1 || changes[0].To != engine.SessionContinuous || changes[0].Uncross == nil {
+		t.Fatalf("Expected the open to uncross, got %+v", changes)
+	}
+	if uncross := changes[0].Uncross; uncross.Price != 15100 || uncross.Volume != 60 || uncross.Sequence != changes[0].Sequence {
+		t.Errorf("Expected 60 to uncross at 15100, got %+v", uncross)
+	}
+	if orderBook.InAuction() || resting.RemainingQuantity() != 40 {
+		t.Errorf("Expected continuous trading with 40 left to sell, got auction %v and %d", orderBook.InAuction(), resting.RemainingQuantity())
+	}
+
+	// closing auction, then the close uncrosses it
+	clock.now = day.Add(24*time.Hour + 16*time.Hour + 10*time.Minute)
+	market := engine.NewOrder(uuid.New().String(), "AAPL", engine.SideBuy, engine.TypeMarket, 0, 10)
+	if _, err := matcher.MatchOrder(market); err == nil {
+		t.Error("Expected a market order to be rejected in the closing auction")
+	} else if _, ok := err.(*engine.AuctionOrderError); !ok {
+		t.Errorf("Expected AuctionOrderError, got %v", err)
+	}
+	if result, err := matcher.MatchOrder(newTestOrder("AAPL", engine.SideBuy, 15100, 40)); err != nil || len(result.Trades) != 0 {
+		t.Fatalf("Expected the buy to wait for the closing uncross, got %+v, %v", result, err)
+	}
+	clock.now = day.Add(24*time.Hour + 16*time.Hour + 30*time.Minute)
+	changes, _ = matcher.UpdateSessions()
+	if len(changes) != 1 || changes[0].To != engine.SessionClosed || changes[0].Uncross == nil || changes[0].Uncross.Volume != 40 {
+		t.Fatalf("Expected the close to uncross 40, got %+v", changes)
+	}
+	if resting.GetStatus() != engine.StatusFilled {
+		t.Errorf("Expected the resting sell filled, got %s", resting.GetStatus())
+	}
+}
+
+// TestTradingScheduleTimeZone tests that schedule times are read on the clock of the
+// schedule's time zone, daylight saving included
+func TestTradingScheduleTimeZone(t *testing.T) {
+	registry := engine.NewInstrumentRegistry(nil)
+	_, err := registry.Create(engine.Instrument{
+		Symbol: "AAPL", TickSize: 1, LotSize: 1,
+		Schedule: &engine.TradingSchedule{TimeZone: "America/New_York", Continuous: "09:30", Close: "16:00"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create instrument: %v", err)
+	}
+
+	tests := []struct {
+		now     time.Time
+		session engine.TradingSession
+	}{
+		{time.Date(2024, 7, 1, 13, 45, 0, 0, time.UTC), engine.SessionContinuous}, // 09:45 EDT
+		{time.Date(2024, 1, 2, 13, 45, 0, 0, time.UTC), engine.SessionClosed},     // 08:45 EST
+		{time.Date(2024, 1, 2, 20, 59, 0, 0, time.UTC), engine.SessionContinuous}, // 15:59 EST
+		{time.Date(2024, 7, 1, 20, 0, 0, 0, time.UTC), engine.SessionClosed},      // 16:00 EDT
+	}
+	for _, tt := range tests {
+		clock := &fixedClock{now: tt.now}
+		matcher := engine.NewMatcher(engine.WithClock(clock), engine.WithInstruments(registry))
+		_, err := matcher.MatchOrder(newTestOrder("AAPL", engine.SideBuy, 15000, 10))
+		orderBook, _ := matcher.GetOrderBook("AAPL")
+		if session := orderBook.Session(); session != tt.session {
+			t.Errorf("At %s: expected %s, got %s", tt.now, tt.session, session)
+		}
+		if (err == nil) != (tt.session == engine.SessionContinuous) {
+			t.Errorf("At %s: unexpected order result %v", tt.now, err)
+		}
+		matcher.Close()
+	}
+}
+
+// TestTradingScheduleValidation tests that inconsistent schedules are refused
+func TestTradingScheduleValidation(t *testing.T) {
+	schedules := []engine.TradingSchedule{
+		{Continuous: "09:30"},
+		{Close: "16:00"},
+		{Continuous: "9.30", Close: "16:00"},
+		{Continuous: "09:30", Close: "25:00"},
+		{Continuous: "16:00", Close: "09:30"},
+		{PreOpen: "09:30", Continuous: "09:30", Close: "16:00"},
+		{Continuous: "09:30", ClosingAuction: "17:00", Close: "16:00"},
+		{TimeZone: "Mars/Olympus_Mons", Continuous: "09:30", Close: "16:00"},
+	}
+	for _, schedule := range schedules {
+		registry := engine.NewInstrumentRegistry(nil)
+		_, err := registry.Create(engine.Instrument{Symbol: "AAPL", TickSize: 1, LotSize: 1, Schedule: &schedule})
+		if _, ok := err.(*engine.InvalidInstrumentError); !ok {
+			t.Errorf("Schedule %+v: expected InvalidInstrumentError, got %v", schedule, err)
+		}
+	}
+}
+
+// TestTradingSessionReplay tests that session changes replay at the same point between
+// orders, and that a snapshot keeps the session
+func TestTradingSessionReplay(t *testing.T) {
+	dir := t.TempDir()
+	j := openJournal(t, journal.DefaultOptions(dir))
+	day := time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC)
+	clock := &fixedClock{now: day.Add(9*time.Hour + 10*time.Minute)}
+	matcher := newScheduledMatcher(t, clock, engine.WithCommandLog(j))
+
+	matcher.MatchOrder(newTestOrder("AAPL", engine.SideSell, 15000, 100))
+	matcher.MatchOrder(newTestOrder("AAPL", engine.SideBuy, 15100, 60))
+	clock.now = day.Add(9*time.Hour + 30*time.Minute)
+	matcher.MatchOrder(newTestOrder("AAPL", engine.SideBuy, 15000, 10))
+	clock.now = day.Add(16*time.Hour + 5*time.Minute)
+	matcher.UpdateSessions()
+	matcher.MatchOrder(newTestOrder("AAPL", engine.SideBuy, 15000, 20))
+	states := matcher.Snapshot()
+	live, _ := json.Marshal(states)
+	j.Close()
+
+	replayed := engine.NewMatcher()
+	defer replayed.Close()
+	if _, err := journal.Scan(dir, 0, replayed.Replay); err != nil {
+		t.Fatalf("Replay failed: %v", err)
+	}
+	books, _ := json.Marshal(replayed.Snapshot())
+	if !bytes.Equal(live, books) {
+		t.Errorf("Expected replayed books to match live books\nlive:     %s\nreplayed: %s", live, books)
+	}
+
+	restored := engine.NewMatcher()
+	defer restored.Close()
+	for _, state := range states {
+		if err := restored.Restore(state); err != nil {
+			t.Fatalf("Restore failed: %v", err)
+		}
+	}
+	orderBook, _ := restored.GetOrderBook("AAPL")
+	if orderBook.Session() != engine.SessionClosingAuction || !orderBook.InAuction() {
+		t.Errorf("Expected the restored book in the closing auction, got %s", orderBook.Session())
+	}
+}
+
+// TestTradingSessionAPI tests the schedule of the instrument API and the session of the
+// order book response
+func TestTradingSessionAPI(t *testing.T) {
+	t.Setenv("RATE_LIMIT_DISABLED", "1")
+	t.Setenv("REQUEST_LOGGING_DISABLED", "1")
+	t.Setenv("ADMIN_TOKEN", "secret")
+
+	registry := engine.NewInstrumentRegistry(nil)
+	clock := &fixedClock{now: time.Date(2024, 3, 15, 20, 0, 0, 0, time.UTC)}
+	matcher := engine.NewMatcher(engine.WithClock(clock), engine.WithInstruments(registry))
+	defer matcher.Close()
+
+	app := fiber.New()
+	routes.SetupRoutes(app, handlers.NewOrderHandler(matcher))
+	routes.SetupInstrumentRoutes(app, handlers.NewInstrumentHandler(matcher))
+
+	send := func(method, url string, body interface{}) (int, []byte) {
+		t.Helper()
+		data, _ := json.Marshal(body)
+		req := httptest.NewRequest(method, url, bytes.NewReader(data))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer secret")
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		respBody, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, respBody
+	}
+
+	invalid := models.InstrumentRequest{Symbol: "AAPL", TickSize: 1, LotSize: 1, Schedule: &models.TradingSchedule{Continuous: "16:00", Close: "09:30"}}
+	if status, _ := send(http.MethodPost, "/admin/v1/instruments", invalid); status != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a schedule out of order, got %d", status)
+	}
+	aapl := models.InstrumentRequest{Symbol: "AAPL", TickSize: 1, LotSize: 1, Schedule: &models.TradingSchedule{Continuous: "09:30", Close: "16:00"}}
+	status, body := send(http.MethodPost, "/admin/v1/instruments", aapl)
+	var created models.InstrumentResponse
+	json.Unmarshal(body, &created)
+	if status != http.StatusCreated || created.Schedule == nil || created.Schedule.Close != "16:00" {
+		t.Fatalf("Unexpected create response %d: %s", status, body)
+	}
+
+	order := models.SubmitOrderRequest{Symbol: "AAPL", Side: "BUY", Type: "LIMIT", Price: 15000, Quantity: 100}
+	if status, body := send(http.MethodPost, "/api/v1/orders", order); status != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an order after the close, got %d: %s", status, body)
+	}
+	_, body = send(http.MethodGet, "/api/v1/orderbook/AAPL", nil)
+	var book models.OrderBookResponse
+	json.Unmarshal(body, &book)
+	if book.Session != "CLOSED" {
+		t.Errorf("Expected the book CLOSED, got %s", body)
+	}
+
+	// removing the schedule trades around the clock
+	if status, body := send(http.MethodPatch, "/admin/v1/instruments/AAPL", models.UpdateInstrumentRequest{Schedule: &models.TradingSchedule{}}); status != http.StatusOK {
+		t.Fatalf("Expected 200 removing the schedule, got %d: %s", status, body)
+	}
+	matcher.UpdateSessions()
+	if status, body := send(http.MethodPost, "/api/v1/orders", order); status != http.StatusCreated {
+		t.Errorf("Expected 201 without a schedule, got %d: %s", status, body)
+	}
+}
+
+// TestInstrumentChangeReplay tests that status and schedule changes take effect between
+// two commands on the book, and that a replay without the registry makes them at the
+// same point
+func TestInstrumentChangeReplay(t *testing.T) {
+	dir := t.TempDir()
+	j := openJournal(t, journal.DefaultOptions(dir))
+	day := time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC)
+	clock := &fixedClock{now: day.Add(17 * time.Hour)}
+	registry := engine.NewInstrumentRegistry(nil)
+	registry.Create(engine.Instrument{Symbol: "AAPL", TickSize: 1, LotSize: 1})
+	matcher := engine.NewMatcher(engine.WithClock(clock), engine.WithInstruments(registry), engine.WithCommandLog(j))
+	defer matcher.Close()
+
+	matcher.MatchOrder(newTestOrder("AAPL", engine.SideSell, 15000, 100))
+	matcher.UpdateInstrument("AAPL", func(i *engine.Instrument) { i.Status = engine.TradingStatusClosed })
+	if _, err := matcher.MatchOrder(newTestOrder("AAPL", engine.SideBuy, 15000, 10)); err == nil {
+		t.Error("Expected an order on a closed instrument to be rejected")
+	} else if _, ok := err.(*engine.InstrumentNotTradingError); !ok {
+		t.Errorf("Expected InstrumentNotTradingError, got %v", err)
+	}
+	matcher.UpdateInstrument("AAPL", func(i *engine.Instrument) { i.Status = engine.TradingStatusTrading })
+	matcher.MatchOrder(newTestOrder("AAPL", engine.SideBuy, 15000, 20))
+
+	// edge case: at 17:00 the new schedule is closed, the book must not wait for a boundary
+	matcher.UpdateInstrument("AAPL", func(i *engine.Instrument) {
+		i.Schedule = &engine.TradingSchedule{Continuous: "09:30", Close: "16:30"}
+	})
+	orderBook, _ := matcher.GetOrderBook("AAPL")
+	if session := orderBook.Session(); session != engine.SessionClosed {
+		t.Errorf("Expected the new schedule to close the book at once, got %s", session)
+	}
+	matcher.UpdateInstrument("AAPL", func(i *engine.Instrument) { i.Schedule = nil })
+	if session := orderBook.Session(); session != engine.SessionContinuous {
+		t.Errorf("Expected the book to trade continuously without a schedule, got %s", session)
+	}
+	matcher.MatchOrder(newTestOrder("AAPL", engine.SideBuy, 15000, 30))
+	live, _ := json.Marshal(matcher.Snapshot())
+	j.Close()
+
+	replayed := engine.NewMatcher()
+	defer replayed.Close()
+	if _, err := journal.Scan(dir, 0, replayed.Replay); err != nil {
+		t.Fatalf("Replay failed: %v", err)
+	}
+	books, _ := json.Marshal(replayed.Snapshot())
+	if !bytes.Equal(live, books) {
+		t.Errorf("Expected replayed books to match live books\nlive:     %s\nreplayed: %s", live, books)
+	}
+}
@@ -0,0 +1,189 @@
+package tests
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"match-engine/src/engine"
+	"match-engine/src/journal"
+	"match-engine/src/snapshot"
+)
+
+func newTestOrder(symbol string, side engine.OrderSide, price, quantity int64) *engine.Order {
+	return engine.NewOrder(uuid.New().String(), symbol, side, engine.TypeLimit, price, quantity)
+}
+
+func tradeSellers(trades []*engine.Trade) []string {
+	sellers := make([]string, 0, len(trades))
+	for _, trade := range trades {
+		sellers = append(sellers, trade.SellOrderID)
+	}
+	return sellers
+}
+
+// TestSnapshotRestoresBookState tests that a restored book keeps FIFO order, partial
+// fills, iceberg slices, pending stops and terminal orders
+func TestSnapshotRestoresBookState(t *testing.T) {
+	matcher := engine.NewMatcher()
+	defer matcher.Close()
+
+	first := newTestOrder("AAPL", engine.SideSell, 15000, 100)
+	iceberg := newTestOrder("AAPL", engine.SideSell, 15000, 300)
+	iceberg.DisplayQuantity = 50
+	third := newTestOrder("AAPL", engine.SideSell, 15000, 80)
+	third.ClientOrderID = "third"
+	matcher.MatchOrder(first)
+	matcher.MatchOrder(iceberg)
+	matcher.MatchOrder(third)
+	matcher.MatchOrder(newTestOrder("AAPL", engine.SideSell, 15200, 40))
+	matcher.MatchOrder(newTestOrder("AAPL", engine.SideBuy, 14900, 60))
+
+	// partially fills first, then fills it and eats into the iceberg's slice
+	matcher.MatchOrder(newTestOrder("AAPL", engine.SideBuy, 15000, 30))
+	filler := newTestOrder("AAPL", engine.SideBuy, 15000, 90)
+	matcher.MatchOrder(filler)
+
+	stop := engine.NewOrder(uuid.New().String(), "AAPL", engine.SideBuy, engine.TypeStop, 0, 10)
+	stop.StopPrice = 15200
+	matcher.MatchOrder(stop)
+
+	states := matcher.Snapshot()
+	data, err := json.Marshal(states)
+	if err != nil {
+		t.Fatalf("Failed to encode snapshot: %v", err)
+	}
+	var decoded []*engine.BookState
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Failed to decode snapshot: %v", err)
+	}
+
+	restored := engine.NewMatcher()
+	defer restored.Close()
+	for _, state := range decoded {
+		if err := restored.Restore(state); err != nil {
+			t.Fatalf("Restore failed: %v", err)
+		}
+	}
+
+	original := matcher.GetOrCreateOrderBook("AAPL")
+	rebuilt := restored.GetOrCreateOrderBook("AAPL")
+	wantBids, wantAsks := original.GetOrderBookSnapshot(10)
+	bids, asks := rebuilt.GetOrderBookSnapshot(10)
+	if !reflect.DeepEqual(wantBids, bids) || !reflect.DeepEqual(wantAsks, asks) {
+		t.Errorf("Expected bids %v asks %v, got bids %v asks %v", wantBids, wantAsks, bids, asks)
+	}
+	if rebuilt.LastSequence() != original.LastSequence() {
+		t.Errorf("Expected sequence %d, got: %d", original.LastSequence(), rebuilt.LastSequence())
+	}
+
+	// edge case: filled orders are still found with their fills
+	filled, exists := restored.GetOrder(first.ID)
+	if !exists || filled.Status != engine.StatusFilled || len(filled.Fills()) != 2 {
+		t.Errorf("Expected first order restored as FILLED with 2 fills")
+	}
+	if order, exists := restored.GetOrderByClientID("third"); !exists || order.ID != third.ID {
+		t.Errorf("Expected client order ID to be restored")
+	}
+
+	// the same aggressive order produces the same fills in the same priority order,
+	// and triggers the same stop
+	sweepOriginal := newTestOrder("AAPL", engine.SideBuy, 15200, 400)
+	sweepRestored := newTestOrder("AAPL", engine.SideBuy, 15200, 400)
+	sweepRestored.ID = sweepOriginal.ID
+	want, _ := matcher.MatchOrder(sweepOriginal)
+	got, _ := restored.MatchOrder(sweepRestored)
+	if !reflect.DeepEqual(tradeSellers(want.Trades), tradeSellers(got.Trades)) {
+		t.Errorf("Expected fills against %v, got: %v", tradeSellers(want.Trades), tradeSellers(got.Trades))
+	}
+	if len(want.TriggeredOrders) != 1 || len(got.TriggeredOrders) != 1 {
+		t.Errorf("Expected the pending stop to trigger on both books, got: %d and %d", len(want.TriggeredOrders), len(got.TriggeredOrders))
+	}
+}
+
+// TestRestoreRejectsNonEmptyBook tests that a snapshot cannot be restored over live state
+func TestRestoreRejectsNonEmptyBook(t *testing.T) {
+	matcher := engine.NewMatcher()
+	defer matcher.Close()
+	matcher.MatchOrder(newTestOrder("AAPL", engine.SideBuy, 15000, 10))
+
+	err := matcher.Restore(&engine.BookState{Symbol: "AAPL", Sequence: 5})
+	if _, ok := err.(*engine.BookNotEmptyError); !ok {
+		t.Errorf("Expected BookNotEmptyError, got: %v", err)
+	}
+}
+
+// TestRecoverFromSnapshotAndJournalTail tests that recovery loads the latest snapshot,
+// replays only the commands after it, and that covered journal segments are compacted
+func TestRecoverFromSnapshotAndJournalTail(t *testing.T) {
+	journalOptions := journal.DefaultOptions(t.TempDir())
+	snapshotDir := t.TempDir()
+
+	j := openJournal(t, journalOptions)
+	store, err := snapshot.NewStore(snapshotDir)
+	if err != nil {
+		t.Fatalf("Failed to open snapshot store: %v", err)
+	}
+	matcher := engine.NewMatcher(engine.WithCommandLog(j))
+	writer := snapshot.NewWriter(matcher, j, store)
+
+	for i := int64(0); i < 10; i++ {
+		matcher.MatchOrder(newTestOrder("AAPL", engine.SideSell, 15000+i, 10))
+	}
+	if file, err := writer.Take(); err != nil || file == nil {
+		t.Fatalf("Expected a snapshot, got: %v", err)
+	}
+	matcher.MatchOrder(newTestOrder("AAPL", engine.SideBuy, 15100, 25))
+	if file, err := writer.Take(); err != nil || file == nil {
+		t.Fatalf("Expected a second snapshot, got: %v", err)
+	}
+	// edge case: nothing new since the last snapshot
+	if file, _ := writer.Take(); file != nil {
+		t.Errorf("Expected no snapshot when nothing was journaled")
+	}
+
+	matcher.MatchOrder(newTestOrder("AAPL", engine.SideBuy, 14000, 5))
+	resting := newTestOrder("GOOGL", engine.SideSell, 280000, 7)
+	matcher.MatchOrder(resting)
+
+	wantBids, wantAsks := matcher.GetOrCreateOrderBook("AAPL").GetOrderBookSnapshot(20)
+	wantSeq := matcher.GetOrCreateOrderBook("AAPL").LastSequence()
+	matcher.Close()
+	j.Close()
+
+	segments, _ := filepath.Glob(filepath.Join(journalOptions.Dir, "*.wal"))
+	if len(segments) != 1 {
+		t.Errorf("Expected covered segments to be compacted, %d left", len(segments))
+	}
+	snapshots, _ := filepath.Glob(filepath.Join(snapshotDir, "*.snapshot"))
+	if len(snapshots) != 1 {
+		t.Errorf("Expected only the latest snapshot to be kept, got: %d", len(snapshots))
+	}
+
+	j = openJournal(t, journalOptions)
+	defer j.Close()
+	recovered := engine.NewMatcher(engine.WithCommandLog(j))
+	defer recovered.Close()
+
+	file, replayed, err := snapshot.Recover(recovered, j, store)
+	if err != nil {
+		t.Fatalf("Recover failed: %v", err)
+	}
+	if file == nil || replayed != 2 {
+		t.Errorf("Expected the snapshot plus a 2 command tail, got snapshot %v and %d commands", file != nil, replayed)
+	}
+
+	bids, asks := recovered.GetOrCreateOrderBook("AAPL").GetOrderBookSnapshot(20)
+	if !reflect.DeepEqual(wantBids, bids) || !reflect.DeepEqual(wantAsks, asks) {
+		t.Errorf("Expected bids %v asks %v, got bids %v asks %v", wantBids, wantAsks, bids, asks)
+	}
+	if seq := recovered.GetOrCreateOrderBook("AAPL").LastSequence(); seq != wantSeq {
+		t.Errorf("Expected sequence %d, got: %d", wantSeq, seq)
+	}
+	if _, exists := recovered.GetOrder(resting.ID); !exists {
+		t.Errorf("Expected order from the journal tail to be recovered")
+	}
+}
@@ -0,0 +1,252 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"match-engine/src/engine"
+	"match-engine/src/models"
+)
+
+// TestStopOrderRestsUntilTriggered tests that a stop order waits in the trigger book
+// and executes as a market order once a trade prints at its stop price
+func TestStopOrderRestsUntilTriggered(t *testing.T) {
+	matcher := engine.NewMatcher()
+	symbol := "AAPL"
+
+	// Liquidity above the market for the stop to buy into
+	sellOrder1 := engine.NewOrder(uuid.New().String(), symbol, engine.SideSell, engine.TypeLimit, 15050, 100)
+	_, _ = matcher.MatchOrder(sellOrder1)
+
+	sellOrder2 := engine.NewOrder(uuid.New().String(), symbol, engine.SideSell, engine.TypeLimit, 15060, 200)
+	_, _ = matcher.MatchOrder(sellOrder2)
+
+	// Buy stop at $150.50 for 150 shares
+	stopOrder := engine.NewOrder(uuid.New().String(), symbol, engine.SideBuy, engine.TypeStop, 0, 150)
+	stopOrder.StopPrice = 15050
+	result, err := matcher.MatchOrder(stopOrder)
+
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if !result.StopPending {
+		t.Fatal("Expected stop order to be pending")
+	}
+
+	// Stop orders are hidden from the visible book
+	orderBook := matcher.GetOrCreateOrderBook(symbol)
+	if _, _, hasBid := orderBook.GetBestBid(); hasBid {
+		t.Error("Pending stop order should not appear on the bid side")
+	}
+
+	if _, exists := orderBook.GetOrder(stopOrder.ID); !exists {
+		t.Error("Pending stop order should be retrievable by ID")
+	}
+
+	// A trade at $150.50 triggers the stop
+	buyOrder := engine.NewOrder(uuid.New().String(), symbol, engine.SideBuy, engine.TypeLimit, 15050, 50)
+	result, err = matcher.MatchOrder(buyOrder)
+
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if len(result.TriggeredOrders) != 1 {
+		t.Fatalf("Expected 1 triggered order, got: %d", len(result.TriggeredOrders))
+	}
+
+	triggered := result.TriggeredOrders[0]
+	if triggered.Err != nil {
+		t.Fatalf("Expected triggered order to execute, got: %v", triggered.Err)
+	}
+
+	// 50 remaining at $150.50, then 100 at $150.60
+	if triggered.Result.FilledQuantity != 150 {
+		t.Errorf("Expected triggered fill of 150, got: %d", triggered.Result.FilledQuantity)
+	}
+
+	if stopOrder.GetStatus() != engine.StatusFilled {
+		t.Errorf("Expected stop order FILLED, got: %s", stopOrder.GetStatus())
+	}
+
+	price, qty, _ := orderBook.GetBestAsk()
+	if price != 15060 || qty != 100 {
+		t.Errorf("Expected best ask 15060 x 100, got: %d x %d", price, qty)
+	}
+}
+
+// TestStopLimitOrderTriggered tests that a triggered stop-limit rests its remainder at the limit price
+func TestStopLimitOrderTriggered(t *testing.T) {
+	matcher := engine.NewMatcher()
+	symbol := "AAPL"
+
+	buyOrder := engine.NewOrder(uuid.New().String(), symbol, engine.SideBuy, engine.TypeLimit, 15000, 100)
+	_, _ = matcher.MatchOrder(buyOrder)
+
+	// Sell stop-limit: trigger at $150.00, sell no lower than $149.90
+	stopLimit := engine.NewOrder(uuid.New().String(), symbol, engine.SideSell, engine.TypeStopLimit, 14990, 300)
+	stopLimit.StopPrice = 15000
+	_, _ = matcher.MatchOrder(stopLimit)
+
+	// Trade at $150.00 takes 50 of the bid and triggers the stop
+	sellOrder := engine.NewOrder(uuid.New().String(), symbol, engine.SideSell, engine.TypeLimit, 15000, 50)
+	result, _ := matcher.MatchOrder(sellOrder)
+
+	if len(result.TriggeredOrders) != 1 {
+		t.Fatalf("Expected 1 triggered order, got: %d", len(result.TriggeredOrders))
+	}
+
+	// Stop-limit takes the remaining 50 bid and rests 250 at $149.90
+	triggered := result.TriggeredOrders[0].Result
+	if triggered.Status != engine.StatusPartialFill {
+		t.Errorf("Expected PARTIAL_FILL, got: %s", triggered.Status)
+	}
+
+	if triggered.FilledQuantity != 50 {
+		t.Errorf("Expected filled quantity 50, got: %d", triggered.FilledQuantity)
+	}
+
+	orderBook := matcher.GetOrCreateOrderBook(symbol)
+	price, qty, _ := orderBook.GetBestAsk()
+	if price != 14990 || qty != 250 {
+		t.Errorf("Expected best ask 14990 x 250, got: %d x %d", price, qty)
+	}
+}
+
+// TestStopOrderCascade tests that trades from one triggered stop can trigger further stops
+func TestStopOrderCascade(t *testing.T) {
+	matcher := engine.NewMatcher()
+	symbol := "AAPL"
+
+	// Bids stepping down from $150.00
+	for _, price := range []int64{15000, 14990, 14980} {
+		order := engine.NewOrder(uuid.New().String(), symbol, engine.SideBuy, engine.TypeLimit, price, 100)
+		_, _ = matcher.MatchOrder(order)
+	}
+
+	// First stop triggers at $150.00 and sells through to $149.90,
+	// which triggers the second stop at $149.90
+	stop1 := engine.NewOrder(uuid.New().String(), symbol, engine.SideSell, engine.TypeStop, 0, 150)
+	stop1.StopPrice = 15000
+	_, _ = matcher.MatchOrder(stop1)
+
+	stop2 := engine.NewOrder(uuid.New().String(), symbol, engine.SideSell, engine.TypeStop, 0, 50)
+	stop2.StopPrice = 14990
+	_, _ = matcher.MatchOrder(stop2)
+
+	sellOrder := engine.NewOrder(uuid.New().String(), symbol, engine.SideSell, engine.TypeLimit, 15000, 50)
+	result, err := matcher.MatchOrder(sellOrder)
+
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if len(result.TriggeredOrders) != 2 {
+		t.Fatalf("Expected 2 triggered orders, got: %d", len(result.TriggeredOrders))
+	}
+
+	if result.TriggeredOrders[0].Order.ID != stop1.ID || result.TriggeredOrders[1].Order.ID != stop2.ID {
+		t.Error("Expected stops to trigger in cascade order")
+	}
+
+	if stop1.GetStatus() != engine.StatusFilled || stop2.GetStatus() != engine.StatusFilled {
+		t.Errorf("Expected both stops FILLED, got: %s and %s", stop1.GetStatus(), stop2.GetStatus())
+	}
+
+	// 300 bid - 50 - 150 - 50 = 50 left at $149.80
+	orderBook := matcher.GetOrCreateOrderBook(symbol)
+	price, qty, _ := orderBook.GetBestBid()
+	if price != 14980 || qty != 50 {
+		t.Errorf("Expected best bid 14980 x 50, got: %d x %d", price, qty)
+	}
+
+	if orderBook.GetLastTradePrice() != 14980 {
+		t.Errorf("Expected last trade price 14980, got: %d", orderBook.GetLastTradePrice())
+	}
+}
+
+// TestCancelPendingStopOrder tests that a cancelled stop order never triggers
+func TestCancelPendingStopOrder(t *testing.T) {
+	matcher := engine.NewMatcher()
+	symbol := "AAPL"
+
+	buyOrder := engine.NewOrder(uuid.New().String(), symbol, engine.SideBuy, engine.TypeLimit, 15000, 100)
+	_, _ = matcher.MatchOrder(buyOrder)
+
+	stopOrder := engine.NewOrder(uuid.New().String(), symbol, engine.SideSell, engine.TypeStop, 0, 50)
+	stopOrder.StopPrice = 15000
+	_, _ = matcher.MatchOrder(stopOrder)
+
+	orderBook := matcher.GetOrCreateOrderBook(symbol)
+	if !orderBook.RemoveOrder(stopOrder.ID) {
+		t.Fatal("Pending stop order should be removed")
+	}
+
+	sellOrder := engine.NewOrder(uuid.New().String(), symbol, engine.SideSell, engine.TypeLimit, 15000, 10)
+	result, _ := matcher.MatchOrder(sellOrder)
+
+	if len(result.TriggeredOrders) != 0 {
+		t.Errorf("Expected no triggered orders, got: %d", len(result.TriggeredOrders))
+	}
+}
+
+// TestSubmitStopOrderAPI tests stop order submission and validation through the API
+func TestSubmitStopOrderAPI(t *testing.T) {
+	app := setupTestServer()
+
+	// Missing stop price is rejected
+	reqBody := map[string]interface{}{
+		"symbol":   "AAPL",
+		"side":     "BUY",
+		"type":     "STOP_LIMIT",
+		"price":    15100,
+		"quantity": 100,
+	}
+
+	body, _ := json.Marshal(reqBody)
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/orders", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req)
+
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected status 400 without stop_price, got: %d", resp.StatusCode)
+	}
+
+	// Valid stop-limit waits for its trigger
+	reqBody["stop_price"] = 15050
+	body, _ = json.Marshal(reqBody)
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/orders", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err = app.Test(req)
+
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+
+	if resp.StatusCode != http.StatusCreated {
+		t.Errorf("Expected status 201, got: %d", resp.StatusCode)
+	}
+
+	var result models.SubmitOrderResponse
+	json.NewDecoder(resp.Body).Decode(&result)
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/orders/"+result.OrderID, nil)
+	resp, _ = app.Test(req)
+
+	var status models.OrderStatusResponse
+	json.NewDecoder(resp.Body).Decode(&status)
+
+	if status.Type != "STOP_LIMIT" || status.StopPrice != 15050 {
+		t.Errorf("Expected STOP_LIMIT with stop price 15050, got: %s %d", status.Type, status.StopPrice)
+	}
+}
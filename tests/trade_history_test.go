@@ -0,0 +1,160 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"match-engine/src/engine"
+	"match-engine/src/models"
+)
+
+func getTradeHistory(t *testing.T, app *fiber.App, url string) (int, models.TradeHistoryResponse) {
+	req := httptest.NewRequest(http.MethodGet, url, nil)
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+
+	var history models.TradeHistoryResponse
+	json.NewDecoder(resp.Body).Decode(&history)
+	return resp.StatusCode, history
+}
+
+// TestTradeHistoryAPI tests GET /api/v1/trades/:symbol with both sides, aggressor and paging
+func TestTradeHistoryAPI(t *testing.T) {
+	app := setupTestServer()
+
+	_, sell := submitWithHeaders(t, app, map[string]interface{}{
+		"symbol": "AAPL", "side": "SELL", "type": "LIMIT", "price": 15000, "quantity": 100,
+	}, nil)
+	_, firstBuy := submitWithHeaders(t, app, map[string]interface{}{
+		"symbol": "AAPL", "side": "BUY", "type": "LIMIT", "price": 15000, "quantity": 30,
+	}, nil)
+	_, secondBuy := submitWithHeaders(t, app, map[string]interface{}{
+		"symbol": "AAPL", "side": "BUY", "type": "MARKET", "quantity": 50,
+	}, nil)
+
+	status, history := getTradeHistory(t, app, "/api/v1/trades/AAPL")
+	if status != http.StatusOK {
+		t.Fatalf("Expected status 200, got: %d", status)
+	}
+	if len(history.Trades) != 2 {
+		t.Fatalf("Expected 2 trades, got: %d", len(history.Trades))
+	}
+	first := history.Trades[0]
+	if first.BuyOrderID != firstBuy.OrderID || first.SellOrderID != sell.OrderID {
+		t.Errorf("Expected buyer %s and seller %s, got: %s and %s", firstBuy.OrderID, sell.OrderID, first.BuyOrderID, first.SellOrderID)
+	}
+	if first.AggressorSide != "BUY" || first.Sequence != firstBuy.Sequence || first.Quantity != 30 {
+		t.Errorf("Expected BUY aggressor at seq %d for 30, got: %+v", firstBuy.Sequence, first)
+	}
+	if history.Trades[1].BuyOrderID != secondBuy.OrderID || history.NextCursor != "" {
+		t.Errorf("Expected the market order's trade last and no next page, got: %+v", history)
+	}
+
+	// paging with a cursor
+	_, page := getTradeHistory(t, app, "/api/v1/trades/AAPL?limit=1")
+	if len(page.Trades) != 1 || page.NextCursor == "" {
+		t.Fatalf("Expected 1 trade and a next cursor, got: %+v", page)
+	}
+	_, page = getTradeHistory(t, app, "/api/v1/trades/AAPL?limit=1&cursor="+page.NextCursor)
+	if len(page.Trades) != 1 || page.Trades[0].TradeID != history.Trades[1].TradeID || page.NextCursor != "" {
+		t.Errorf("Expected the second trade on the last page, got: %+v", page)
+	}
+
+	// edge case: unknown symbols return an empty list
+	_, empty := getTradeHistory(t, app, "/api/v1/trades/NOPE")
+	if len(empty.Trades) != 0 {
+		t.Errorf("Expected no trades for unknown symbol, got: %d", len(empty.Trades))
+	}
+
+	if status, _ := getTradeHistory(t, app, "/api/v1/trades/AAPL?since=yesterday"); status != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for invalid since, got: %d", status)
+	}
+}
+
+// TestOrderTradesAPI tests GET /api/v1/orders/:id/trades for the resting side of fills
+func TestOrderTradesAPI(t *testing.T) {
+	app := setupTestServer()
+
+	_, sell := submitWithHeaders(t, app, map[string]interface{}{
+		"symbol": "AAPL", "side": "SELL", "type": "LIMIT", "price": 15000, "quantity": 100,
+	}, nil)
+	for i := 0; i < 2; i++ {
+		submitWithHeaders(t, app, map[string]interface{}{
+			"symbol": "AAPL", "side": "BUY", "type": "LIMIT", "price": 15000, "quantity": 20,
+		}, nil)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/orders/"+sell.OrderID+"/trades", nil)
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200, got: %d", resp.StatusCode)
+	}
+
+	var result models.OrderTradesResponse
+	json.NewDecoder(resp.Body).Decode(&result)
+	if len(result.Trades) != 2 {
+		t.Fatalf("Expected the resting order to see 2 trades, got: %d", len(result.Trades))
+	}
+	for _, trade := range result.Trades {
+		if trade.SellOrderID != sell.OrderID || trade.AggressorSide != "BUY" {
+			t.Errorf("Expected resting seller %s hit by a BUY, got: %+v", sell.OrderID, trade)
+		}
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/orders/unknown/trades", nil)
+	resp, _ = app.Test(req)
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected status 404, got: %d", resp.StatusCode)
+	}
+}
+
+// TestTradeHistoryTimeRangeAndEviction tests since/until filtering and that a cursor
+// pointing at evicted trades expires
+func TestTradeHistoryTimeRangeAndEviction(t *testing.T) {
+	start := time.Date(2024, 3, 15, 14, 30, 0, 0, time.UTC)
+	clock := &fixedClock{now: start}
+	matcher := engine.NewMatcher(engine.WithClock(clock), engine.WithTradeHistoryLimit(3))
+	defer matcher.Close()
+
+	for i := 0; i < 5; i++ {
+		clock.now = start.Add(time.Duration(i) * time.Second)
+		matcher.MatchOrder(newTestOrder("AAPL", engine.SideSell, 15000, 10))
+		matcher.MatchOrder(newTestOrder("AAPL", engine.SideBuy, 15000, 10))
+	}
+	orderBook := matcher.GetOrCreateOrderBook("AAPL")
+
+	all, _, _ := orderBook.Trades(engine.TradeQuery{})
+	if len(all) != 3 || all[0].Timestamp != start.Add(2*time.Second).UnixNano() {
+		t.Fatalf("Expected the 3 most recent trades, got: %d", len(all))
+	}
+
+	ranged, _, _ := orderBook.Trades(engine.TradeQuery{
+		Since: start.Add(3 * time.Second).UnixNano(),
+		Until: start.Add(3 * time.Second).UnixNano(),
+	})
+	if len(ranged) != 1 || ranged[0].Timestamp != start.Add(3*time.Second).UnixNano() {
+		t.Errorf("Expected only the trade at +3s, got: %d trades", len(ranged))
+	}
+
+	// edge case: a cursor pointing at evicted trades expires instead of skipping them
+	if _, _, err := orderBook.Trades(engine.TradeQuery{Cursor: 1, Limit: 2}); err == nil {
+		t.Error("Expected a cursor pointing at evicted trades to expire")
+	} else if expired, ok := err.(*engine.CursorExpiredError); !ok || expired.Oldest != 3 {
+		t.Errorf("Expected a CursorExpiredError with the oldest kept trade at 3, got: %v", err)
+	}
+	page, next, err := orderBook.Trades(engine.TradeQuery{Cursor: 3, Limit: 2})
+	if err != nil || len(page) != 2 || page[0].TradeID != all[0].TradeID || next != 5 {
+		t.Errorf("Expected a page from the oldest kept trade, got: %d trades, next %s, %v", len(page), strconv.FormatUint(next, 10), err)
+	}
+}